@@ -57,10 +57,10 @@ type Config struct {
 
 	// Timeouts and retries for system operations
 	Timeout    time.Duration // Operation timeout
-	MaxRetries int          // Maximum retry attempts
+	MaxRetries int          // Retries of a failed embedding request (see raggo.SetConfig)
 
 	// Additional settings for extended functionality
-	ExtraHeaders map[string]string // Additional HTTP headers
+	ExtraHeaders map[string]string // Additional HTTP headers for embedding requests (see raggo.SetConfig)
 }
 
 // LoadConfig loads configuration from multiple sources, combining them according
@@ -5,7 +5,9 @@ package raggo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/teilomillet/raggo/rag"
 	"github.com/teilomillet/raggo/rag/providers"
@@ -49,6 +51,9 @@ type EmbedderOption = rag.EmbedderOption
 // Supported providers include:
 //   - "openai": OpenAI's text-embedding-ada-002 and other models
 //   - "cohere": Cohere's embedding models
+//   - "ollama": Models served by a local Ollama server, no API key needed
+//   - "openai-compatible": Azure OpenAI, vLLM, LiteLLM, LocalAI, TEI and other
+//     services speaking the OpenAI embeddings protocol
 //   - "local": Local embedding models (if configured)
 //
 // Example:
@@ -93,6 +98,35 @@ func SetEmbedderAPIKey(apiKey string) EmbedderOption {
 	return rag.SetAPIKey(apiKey)
 }
 
+// SetEmbedderHeaders sets extra HTTP headers sent with every embedding
+// request, for gateways that need them. NewRAG and Register set them from
+// the ExtraHeaders of a config.Config passed with SetConfig or WithConfig.
+//
+// Example:
+//
+//	embedder, err := NewEmbedder(
+//	    SetEmbedderProvider("openai-compatible"),
+//	    SetOption("api_url", "https://gateway.internal/v1/embeddings"),
+//	    SetEmbedderHeaders(cfg.ExtraHeaders),
+//	)
+func SetEmbedderHeaders(headers map[string]string) EmbedderOption {
+	return rag.SetHeaders(headers)
+}
+
+// SetEmbedderMaxRetries sets how many times a failed embedding request is
+// retried, with exponential backoff that honours Retry-After. Only retryable
+// failures (timeouts, 429 and 5xx answers) are retried; 0 disables retries.
+//
+// Example:
+//
+//	embedder, err := NewEmbedder(
+//	    SetEmbedderProvider("openai"),
+//	    SetEmbedderMaxRetries(cfg.MaxRetries),
+//	)
+func SetEmbedderMaxRetries(n int) EmbedderOption {
+	return rag.SetMaxRetries(n)
+}
+
 // SetOption sets a custom option for the Embedder.
 // This allows for provider-specific configuration that isn't covered
 // by the standard options.
@@ -102,7 +136,6 @@ func SetEmbedderAPIKey(apiKey string) EmbedderOption {
 //	embedder, err := NewEmbedder(
 //	    SetEmbedderProvider("openai"),
 //	    SetOption("timeout", 30*time.Second),
-//	    SetOption("max_retries", 3),
 //	)
 func SetOption(key string, value interface{}) EmbedderOption {
 	return rag.SetOption(key, value)
@@ -112,6 +145,33 @@ func SetOption(key string, value interface{}) EmbedderOption {
 // This allows for different embedding providers to be used interchangeably.
 type Embedder = providers.Embedder
 
+// RateLimiter bounds the requests and tokens per minute sent to an embedding
+// API. Pass the same limiter to several embedders with
+// SetOption("rate_limiter", limiter) to keep them within a shared quota.
+type RateLimiter = providers.RateLimiter
+
+// APIError is the error embedders return when the embedding API answers
+// with a non-success status, after any retries.
+type APIError = providers.APIError
+
+// InputTooLongError is the error embedders return, without sending a
+// request, when a text exceeds the tokens the model accepts per input.
+type InputTooLongError = providers.InputTooLongError
+
+// NewRateLimiter creates a limiter allowing requestsPerMinute requests and
+// tokensPerMinute tokens per minute. A limit of 0 disables it.
+//
+// Example:
+//
+//	limiter := NewRateLimiter(3000, 1000000)
+//	embedder, err := NewEmbedder(
+//	    SetEmbedderProvider("openai"),
+//	    SetOption("rate_limiter", limiter),
+//	)
+func NewRateLimiter(requestsPerMinute, tokensPerMinute int) *RateLimiter {
+	return providers.NewRateLimiter(requestsPerMinute, tokensPerMinute)
+}
+
 // NewEmbedder creates a new Embedder instance based on the provided options.
 // It handles provider selection and configuration, returning a ready-to-use
 // embedding interface.
@@ -136,23 +196,69 @@ func NewEmbedder(opts ...EmbedderOption) (Embedder, error) {
 	return rag.NewEmbedder(opts...)
 }
 
+// FailureMode selects how EmbedChunks handles chunks that fail to embed:
+// FailFast stops at the first failure, CollectErrors embeds every chunk it
+// can and returns the failures as ChunkErrors.
+type FailureMode = rag.FailureMode
+
+const (
+	// FailFast stops embedding at the first failed batch
+	FailFast = rag.FailFast
+	// CollectErrors embeds every batch and reports failed chunks in ChunkErrors
+	CollectErrors = rag.CollectErrors
+)
+
+// ChunkError records why one chunk could not be embedded.
+type ChunkError = rag.ChunkError
+
+// ChunkErrors lists the chunks that failed to embed in CollectErrors mode.
+type ChunkErrors = rag.ChunkErrors
+
+// EmbeddingServiceOption configures the batching, concurrency and failure
+// handling of an EmbeddingService.
+type EmbeddingServiceOption = rag.EmbeddingServiceOption
+
+// SetEmbeddingConcurrency sets the maximum number of batches embedded at
+// once. The default is 4.
+func SetEmbeddingConcurrency(concurrency int) EmbeddingServiceOption {
+	return rag.SetEmbeddingConcurrency(concurrency)
+}
+
+// SetEmbeddingBatchSize sets the number of chunks passed to each EmbedBatch
+// call. The default is 100.
+func SetEmbeddingBatchSize(batchSize int) EmbeddingServiceOption {
+	return rag.SetEmbeddingBatchSize(batchSize)
+}
+
+// SetEmbeddingFailureMode sets how EmbedChunks handles failed batches.
+// The default is FailFast.
+func SetEmbeddingFailureMode(mode FailureMode) EmbeddingServiceOption {
+	return rag.SetEmbeddingFailureMode(mode)
+}
+
 // EmbeddingService handles the embedding process for text content.
 // It supports multiple embedders for different fields or purposes,
 // allowing for flexible embedding strategies.
 type EmbeddingService struct {
 	embedders map[string]Embedder
+	options   []EmbeddingServiceOption
 }
 
 // NewEmbeddingService creates a new embedding service with the specified embedder
-// as the default embedding provider.
+// as the default embedding provider. Options tune how chunks are batched and
+// how many batches are embedded concurrently.
 //
 // Example:
 //
 //	embedder, _ := NewEmbedder(SetEmbedderProvider("openai"))
-//	service := NewEmbeddingService(embedder)
-func NewEmbeddingService(embedder Embedder) *EmbeddingService {
+//	service := NewEmbeddingService(embedder,
+//	    SetEmbeddingConcurrency(8),
+//	    SetEmbeddingFailureMode(CollectErrors),
+//	)
+func NewEmbeddingService(embedder Embedder, opts ...EmbeddingServiceOption) *EmbeddingService {
 	return &EmbeddingService{
 		embedders: map[string]Embedder{"default": embedder},
+		options:   opts,
 	}
 }
 
@@ -161,10 +267,13 @@ func NewEmbeddingService(embedder Embedder) *EmbeddingService {
 // for each field if configured.
 //
 // The function:
-//   1. Processes each chunk through configured embedders
-//   2. Combines embeddings from all fields
+//   1. Embeds the chunks in concurrent batches through each configured embedder
+//   2. Combines embeddings from all fields, in chunk order
 //   3. Preserves chunk metadata
-//   4. Handles errors for individual chunks
+//   4. Handles errors according to the failure mode
+//
+// In CollectErrors mode, chunks that failed for a field lack that field's
+// embedding and the returned ChunkErrors lists them.
 //
 // Example:
 //
@@ -174,26 +283,45 @@ func NewEmbeddingService(embedder Embedder) *EmbeddingService {
 //	}
 //	embedded, err := service.EmbedChunks(ctx, chunks)
 func (s *EmbeddingService) EmbedChunks(ctx context.Context, chunks []rag.Chunk) ([]rag.EmbeddedChunk, error) {
-	embeddedChunks := make([]rag.EmbeddedChunk, 0, len(chunks))
-	for _, chunk := range chunks {
-		embeddings := make(map[string][]float64)
-		for field, embedder := range s.embedders {
-			embedding, err := embedder.Embed(ctx, chunk.Text)
-			if err != nil {
-				return nil, fmt.Errorf("error embedding chunk for field %s: %w", field, err)
-			}
-			embeddings[field] = embedding
-		}
-		embeddedChunk := rag.EmbeddedChunk{
+	embeddedChunks := make([]rag.EmbeddedChunk, len(chunks))
+	for i, chunk := range chunks {
+		embeddedChunks[i] = rag.EmbeddedChunk{
 			Text:       chunk.Text,
-			Embeddings: embeddings,
+			Embeddings: make(map[string][]float64, len(s.embedders)),
 			Metadata: map[string]interface{}{
 				"token_size":     chunk.TokenSize,
 				"start_sentence": chunk.StartSentence,
 				"end_sentence":   chunk.EndSentence,
 			},
 		}
-		embeddedChunks = append(embeddedChunks, embeddedChunk)
+	}
+
+	var failures ChunkErrors
+	for field, embedder := range s.embedders {
+		embedded, err := rag.NewEmbeddingService(embedder, s.options...).EmbedChunks(ctx, chunks)
+		var chunkErrs ChunkErrors
+		if errors.As(err, &chunkErrs) {
+			for _, chunkErr := range chunkErrs {
+				failures = append(failures, &ChunkError{
+					Index: chunkErr.Index,
+					Err:   fmt.Errorf("field %s: %w", field, chunkErr.Err),
+				})
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("error embedding chunks for field %s: %w", field, err)
+		}
+		for i, chunk := range embedded {
+			if embedding, ok := chunk.Embeddings["default"]; ok {
+				embeddedChunks[i].Embeddings[field] = embedding
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		sort.SliceStable(failures, func(i, j int) bool {
+			return failures[i].Index < failures[j].Index
+		})
+		return embeddedChunks, failures
 	}
 	return embeddedChunks, nil
 }
@@ -223,3 +351,31 @@ func (s *EmbeddingService) Embed(ctx context.Context, text string) ([]float64, e
 
 	return embedding, nil
 }
+
+// EmbedBatch generates embeddings for several texts using the default
+// embedder, in as few provider requests as its batch API allows. The
+// embeddings are returned in the order of texts.
+//
+// Example:
+//
+//	texts := []string{"First text", "Second text"}
+//	embeddings, err := service.EmbedBatch(ctx, texts)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+func (s *EmbeddingService) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	embedder, ok := s.embedders["default"]
+	if !ok {
+		return nil, fmt.Errorf("no default embedder found")
+	}
+
+	embeddings, err := embedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("error embedding texts: %w", err)
+	}
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("embedder returned %d embeddings for %d texts", len(embeddings), len(texts))
+	}
+
+	return embeddings, nil
+}
@@ -227,7 +227,7 @@ func NewMemoryContext(apiKey string, opts ...func(*MemoryContextOptions)) (*Memo
 				{Name: "ID", DataType: "int64", PrimaryKey: true, AutoID: true},
 				{Name: "Embedding", DataType: "float_vector", Dimension: 1536}, // text-embedding-3-small dimension
 				{Name: "Text", DataType: "varchar", MaxLength: 65535},
-				{Name: "Metadata", DataType: "varchar", MaxLength: 65535},
+				{Name: "Metadata", DataType: "json"},
 			},
 		}
 
@@ -49,6 +49,7 @@ import (
 	"time"
 
 	"github.com/teilomillet/gollm"
+	"github.com/teilomillet/raggo/config"
 	"github.com/teilomillet/raggo/rag"
 )
 
@@ -63,7 +64,7 @@ type RAGConfig struct {
 	Collection  string // Name of the vector collection
 	AutoCreate  bool   // Automatically create collection if it doesn't exist
 	IndexType   string // Type of vector index (e.g., "HNSW", "IVF")
-	IndexMetric string // Distance metric for similarity (e.g., "L2", "IP")
+	IndexMetric string // Distance metric for similarity ("L2", "IP" or "COSINE")
 
 	// Processing settings determine how documents are handled
 	ChunkSize    int // Size of text chunks in tokens
@@ -71,14 +72,16 @@ type RAGConfig struct {
 	BatchSize    int // Number of documents to process in parallel
 
 	// Embedding settings configure vector generation
-	Provider string // Embedding provider (e.g., "openai", "cohere")
-	Model    string // Embedding model name
-	LLMModel string // Language model for text generation
-	APIKey   string // API key for the provider
+	Provider   string            // Embedding provider (e.g., "openai", "cohere")
+	Model      string            // Embedding model name
+	LLMModel   string            // Language model for text generation
+	APIKey     string            // API key for the provider
+	MaxRetries int               // Retries of a failed embedding request (0 disables them)
+	Headers    map[string]string // Extra HTTP headers sent with every embedding request
 
 	// Search settings control retrieval behavior
 	TopK      int     // Number of results to retrieve
-	MinScore  float64 // Minimum similarity score threshold
+	MinScore  float64 // Minimum similarity score threshold (see rag.SearchResult for the scale)
 	UseHybrid bool    // Whether to use hybrid search
 
 	// System settings affect operational behavior
@@ -129,6 +132,7 @@ func DefaultRAGConfig() *RAGConfig {
 		Model:        "text-embedding-3-small",
 		LLMModel:     "gpt-4o-mini", // Update to latest model
 		APIKey:       os.Getenv("OPENAI_API_KEY"),
+		MaxRetries:   3,
 		TopK:         5,
 		MinScore:     0.7,
 		UseHybrid:    true,
@@ -271,7 +275,8 @@ func SetTopK(k int) RAGOption {
 }
 
 // SetMinScore sets the minimum similarity score threshold for retrieval.
-// Documents with scores below this threshold are filtered out.
+// Documents with scores below this threshold are filtered out. Scores are
+// "higher is more similar" on every backend; see rag.SearchResult for the scale.
 //
 // Example:
 //
@@ -298,6 +303,67 @@ func SetTimeout(timeout time.Duration) RAGOption {
 	}
 }
 
+// SetMaxRetries sets how many times a failed embedding request is retried
+// with backoff before the document fails (3 by default, 0 disables retries).
+//
+// Example:
+//
+//	rag, err := raggo.NewRAG(
+//	    raggo.SetMaxRetries(5),
+//	)
+func SetMaxRetries(n int) RAGOption {
+	return func(c *RAGConfig) {
+		c.MaxRetries = n
+	}
+}
+
+// SetHeaders sets extra HTTP headers sent with every embedding request,
+// for gateways that need them, e.g. with the "openai-compatible" provider.
+//
+// Example:
+//
+//	rag, err := raggo.NewRAG(
+//	    raggo.SetHeaders(map[string]string{"X-Team": "search"}),
+//	)
+func SetHeaders(headers map[string]string) RAGOption {
+	return func(c *RAGConfig) {
+		c.Headers = headers
+	}
+}
+
+// SetConfig applies the embedding settings of a config.Config, such as one
+// returned by config.LoadConfig: its Model, the key APIKeys holds for the
+// embedding provider, MaxRetries and ExtraHeaders. Empty and zero values
+// keep the current setting, and the provider must be chosen before
+// SetConfig for its key to be found.
+//
+// Example:
+//
+//	cfg, err := config.LoadConfig()
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	rag, err := raggo.NewRAG(
+//	    raggo.SetProvider("openai"),
+//	    raggo.SetConfig(cfg),
+//	)
+func SetConfig(conf *config.Config) RAGOption {
+	return func(c *RAGConfig) {
+		if conf.Model != "" {
+			c.Model = conf.Model
+		}
+		if key := conf.APIKeys[c.Provider]; key != "" {
+			c.APIKey = key
+		}
+		if conf.MaxRetries > 0 {
+			c.MaxRetries = conf.MaxRetries
+		}
+		if len(conf.ExtraHeaders) > 0 {
+			c.Headers = conf.ExtraHeaders
+		}
+	}
+}
+
 // SetDebug enables or disables debug logging.
 // When enabled, the system will output detailed operation information.
 //
@@ -378,11 +444,13 @@ func (r *RAG) initialize() error {
 		SetEmbedderProvider(r.config.Provider),
 		SetEmbedderModel(r.config.Model),
 		SetEmbedderAPIKey(r.config.APIKey),
+		SetEmbedderMaxRetries(r.config.MaxRetries),
+		SetEmbedderHeaders(r.config.Headers),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create embedder: %w", err)
 	}
-	r.embedder = NewEmbeddingService(embedder)
+	r.embedder = NewEmbeddingService(embedder, SetEmbeddingBatchSize(r.config.BatchSize))
 
 	return r.db.Connect(context.Background())
 }
@@ -408,21 +476,9 @@ func (r *RAG) LoadDocuments(ctx context.Context, source string) error {
 	}
 
 	// Process source
-	var paths []string
-	if info, err := os.Stat(source); err == nil {
-		if info.IsDir() {
-			paths, err = loader.LoadDir(ctx, source)
-		} else {
-			path, err := loader.LoadFile(ctx, source)
-			if err == nil {
-				paths = []string{path}
-			}
-		}
-		if err != nil {
-			return fmt.Errorf("failed to load source: %w", err)
-		}
-	} else {
-		return fmt.Errorf("invalid source: %s", source)
+	sources, err := loadSources(ctx, loader, source)
+	if err != nil {
+		return err
 	}
 
 	// Ensure collection exists
@@ -433,8 +489,8 @@ func (r *RAG) LoadDocuments(ctx context.Context, source string) error {
 	}
 
 	// Process documents
-	for _, path := range paths {
-		if err := r.processDocument(ctx, path, chunker); err != nil {
+	for _, src := range sources {
+		if err := r.processDocument(ctx, src, chunker); err != nil {
 			return err
 		}
 	}
@@ -465,9 +521,10 @@ func (r *RAG) storeEnrichedChunks(ctx context.Context, enrichedChunks []string,
 	for i, chunk := range embeddedChunks {
 		records[i] = Record{
 			Fields: map[string]interface{}{
-				"Embedding": chunk.Embeddings["default"],
+				"Embedding": Vector(chunk.Embeddings["default"]),
 				"Text":      chunk.Text,
 				"Metadata": map[string]interface{}{
+					DocIDKey:     ChunkDocID(source, i),
 					"source":     source,
 					"chunk":      i,
 					"total":      len(enrichedChunks),
@@ -477,8 +534,8 @@ func (r *RAG) storeEnrichedChunks(ctx context.Context, enrichedChunks []string,
 		}
 	}
 
-	// Store in database
-	return r.db.Insert(ctx, r.config.Collection, records)
+	// Store in database, then remove the chunks of any earlier processing
+	return r.db.ReplaceSource(ctx, r.config.Collection, source, records)
 }
 
 // ProcessWithContext processes and stores documents with additional contextual information.
@@ -500,7 +557,7 @@ func (r *RAG) ProcessWithContext(ctx context.Context, source string, llmModel st
 				{Name: "ID", DataType: "int64", PrimaryKey: true, AutoID: true},
 				{Name: "Embedding", DataType: "float_vector", Dimension: 1536},
 				{Name: "Text", DataType: "varchar", MaxLength: 65535},
-				{Name: "Metadata", DataType: "varchar", MaxLength: 65535},
+				{Name: "Metadata", DataType: "json"},
 			},
 		}
 
@@ -575,6 +632,11 @@ func (r *RAG) ProcessWithContext(ctx context.Context, source string, llmModel st
 		return fmt.Errorf("failed to initialize LLM: %w", err)
 	}
 
+	// Chunks are stored batch by batch under a new revision, and the chunks
+	// of any earlier processing of the source are only removed once every
+	// batch is stored, so that a failure leaves the earlier version in place
+	revision := newRevision()
+
 	// Process chunks with context in batches
 	for i := 0; i < len(chunks); i += r.config.BatchSize {
 		end := min(i+r.config.BatchSize, len(chunks))
@@ -599,20 +661,21 @@ func (r *RAG) ProcessWithContext(ctx context.Context, source string, llmModel st
 			Debug("Enriched content:", truncateString(enrichedChunks[j], 200))
 		}
 
+		// Create embeddings for the enriched texts of the batch
+		embeddings, err := r.embedder.EmbedBatch(ctx, enrichedChunks)
+		if err != nil {
+			return fmt.Errorf("failed to embed text: %w", err)
+		}
+
 		// Create records with enriched text
 		records := make([]Record, len(batch))
 		for j := range batch {
-			// Create embeddings for enriched text
-			embedding, err := r.embedder.Embed(ctx, enrichedChunks[j])
-			if err != nil {
-				return fmt.Errorf("failed to embed text: %w", err)
-			}
-
 			records[j] = Record{
 				Fields: map[string]interface{}{
 					"Text":      enrichedChunks[j],
-					"Embedding": embedding,
+					"Embedding": Vector(embeddings[j]),
 					"Metadata": map[string]interface{}{
+						DocIDKey:     ChunkDocID(source, i+j),
 						"source":     source,
 						"chunk":      i + j,
 						"total":      len(chunks),
@@ -623,12 +686,15 @@ func (r *RAG) ProcessWithContext(ctx context.Context, source string, llmModel st
 		}
 
 		// Store in database
-		if err := r.db.Insert(ctx, r.config.Collection, records); err != nil {
+		if err := r.db.Upsert(ctx, r.config.Collection, withRevision(records, revision)); err != nil {
 			return fmt.Errorf("failed to store chunks: %w", err)
 		}
 	}
 
-	return nil
+	if len(chunks) == 0 {
+		return nil
+	}
+	return r.db.PruneSource(ctx, r.config.Collection, source, revision)
 }
 
 // Add this helper function if it doesn't exist
@@ -669,7 +735,8 @@ Provide only the context, without any introductory phrases or explanations.`, ch
 //
 //	results, err := rag.Query(ctx, "How does feature X work?")
 func (r *RAG) Query(ctx context.Context, query string) ([]RetrieverResult, error) {
-	if !r.config.UseHybrid {
+	// Backends without hybrid search fall back to dense search
+	if !r.config.UseHybrid || !r.db.Capabilities().Hybrid {
 		return r.simpleSearch(ctx, query)
 	}
 	return r.hybridSearch(ctx, query)
@@ -704,7 +771,7 @@ func (r *RAG) ensureCollection(ctx context.Context) error {
 				{Name: "ID", DataType: "int64", PrimaryKey: true, AutoID: true},
 				{Name: "Embedding", DataType: "float_vector", Dimension: 1536},
 				{Name: "Text", DataType: "varchar", MaxLength: 65535},
-				{Name: "Metadata", DataType: "varchar", MaxLength: 65535},
+				{Name: "Metadata", DataType: "json"},
 			},
 		}
 
@@ -731,9 +798,9 @@ func (r *RAG) ensureCollection(ctx context.Context) error {
 	return nil
 }
 
-func (r *RAG) processDocument(ctx context.Context, path string, chunker Chunker) error { // Changed: use interface
+func (r *RAG) processDocument(ctx context.Context, src loadedSource, chunker Chunker) error { // Changed: use interface
 	parser := NewParser()
-	doc, err := parser.Parse(path)
+	doc, err := parser.Parse(src.path)
 	if err != nil {
 		return fmt.Errorf("failed to parse document: %w", err)
 	}
@@ -748,10 +815,11 @@ func (r *RAG) processDocument(ctx context.Context, path string, chunker Chunker)
 	for i, chunk := range embeddedChunks {
 		records[i] = Record{
 			Fields: map[string]interface{}{
-				"Embedding": chunk.Embeddings["default"],
+				"Embedding": Vector(chunk.Embeddings["default"]),
 				"Text":      chunk.Text,
 				"Metadata": map[string]interface{}{
-					"source":     path,
+					DocIDKey:     ChunkDocID(src.source, i),
+					"source":     src.source,
 					"chunk":      i,
 					"total":      len(chunks),
 					"token_size": chunk.Metadata["token_size"],
@@ -760,7 +828,8 @@ func (r *RAG) processDocument(ctx context.Context, path string, chunker Chunker)
 		}
 	}
 
-	return r.db.Insert(ctx, r.config.Collection, records)
+	// Store the chunks, then remove those of any earlier processing
+	return r.db.ReplaceSource(ctx, r.config.Collection, src.source, records)
 }
 
 func (r *RAG) simpleSearch(ctx context.Context, query string) ([]RetrieverResult, error) {
@@ -802,7 +871,7 @@ func (r *RAG) hybridSearch(ctx context.Context, query string) ([]RetrieverResult
 		vectors,
 		r.config.TopK,
 		r.config.IndexMetric,
-		r.config.SearchParams, // Use the config's search params
+		r.hybridSearchParams(query),
 		nil,
 	)
 	if err != nil {
@@ -812,6 +881,18 @@ func (r *RAG) hybridSearch(ctx context.Context, query string) ([]RetrieverResult
 	return r.processResults(results), nil
 }
 
+// hybridSearchParams returns the configured search parameters with the query
+// text added, for backends that run the keyword search themselves. The
+// configured map is copied so it is never mutated.
+func (r *RAG) hybridSearchParams(query string) map[string]interface{} {
+	params := make(map[string]interface{}, len(r.config.SearchParams)+1)
+	for k, v := range r.config.SearchParams {
+		params[k] = v
+	}
+	params[rag.QueryTextParam] = query
+	return params
+}
+
 func (r *RAG) processResults(results []SearchResult) []RetrieverResult {
 	processed := make([]RetrieverResult, 0, len(results))
 
@@ -825,7 +906,9 @@ func (r *RAG) processResults(results []SearchResult) []RetrieverResult {
 
 		retResult := RetrieverResult{
 			Content:  content,
+			DocID:    result.DocID,
 			Score:    result.Score,
+			Distance: result.Distance,
 			Metadata: metadata,
 		}
 
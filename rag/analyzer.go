@@ -0,0 +1,346 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Tokenizer splits raw text into tokens.
+type Tokenizer func(text string) []string
+
+// TokenFilter transforms a token stream, e.g. by lowercasing, removing stop
+// words or stemming. Filters may drop tokens or emit additional ones.
+type TokenFilter func(tokens []string) []string
+
+// Stemmer reduces a lowercase word to its stem.
+type Stemmer func(word string) string
+
+// Analyzer is a composable text analysis chain: a Tokenizer followed by any
+// number of TokenFilters applied in order. Its Analyze method can be used as
+// a BM25Index preprocessor. The same analyzer must be used for indexing and
+// for searching, otherwise query terms will not match indexed terms.
+//
+// Example:
+//
+//	analyzer := rag.NewAnalyzer(
+//	    rag.UnicodeTokenizer,
+//	    rag.LowercaseFilter,
+//	    rag.StopWordFilter(rag.EnglishStopWords...),
+//	    rag.StemFilter(rag.PorterStem),
+//	    rag.NGramFilter(3, 4),
+//	)
+//	idx.SetAnalyzer(analyzer)
+type Analyzer struct {
+	tokenizer Tokenizer
+	filters   []TokenFilter
+}
+
+// NewAnalyzer creates an analyzer from a tokenizer and a chain of filters.
+// A nil tokenizer defaults to UnicodeTokenizer.
+func NewAnalyzer(tokenizer Tokenizer, filters ...TokenFilter) *Analyzer {
+	if tokenizer == nil {
+		tokenizer = UnicodeTokenizer
+	}
+	return &Analyzer{tokenizer: tokenizer, filters: filters}
+}
+
+// NewStandardAnalyzer returns a language-neutral analyzer that segments text
+// into Unicode words, strips punctuation and lowercases the result.
+func NewStandardAnalyzer() *Analyzer {
+	return NewAnalyzer(UnicodeTokenizer, LowercaseFilter)
+}
+
+// NewEnglishAnalyzer returns an analyzer for English text: Unicode word
+// segmentation, lowercasing, possessive removal, English stop words and the
+// Porter stemmer.
+func NewEnglishAnalyzer() *Analyzer {
+	return NewAnalyzer(UnicodeTokenizer,
+		LowercaseFilter,
+		EnglishPossessiveFilter,
+		StopWordFilter(EnglishStopWords...),
+		StemFilter(PorterStem),
+	)
+}
+
+// NewFrenchAnalyzer returns an analyzer for French text: Unicode word
+// segmentation, lowercasing, elision removal (l', d', qu'...), French stop
+// words and the Snowball French stemmer.
+func NewFrenchAnalyzer() *Analyzer {
+	return NewAnalyzer(UnicodeTokenizer,
+		LowercaseFilter,
+		ElisionFilter(FrenchElisions...),
+		StopWordFilter(FrenchStopWords...),
+		StemFilter(FrenchStem),
+	)
+}
+
+// Analyze runs text through the tokenizer and every filter of the chain.
+func (a *Analyzer) Analyze(text string) []string {
+	tokens := a.tokenizer(text)
+	for _, filter := range a.filters {
+		tokens = filter(tokens)
+	}
+	return tokens
+}
+
+// UnicodeTokenizer segments text into words following a simplified form of
+// the Unicode word boundary rules (UAX #29): a word is a run of letters,
+// combining marks and digits, optionally joined by apostrophes (so "l'index"
+// and "don't" stay whole for the elision and possessive filters). Han
+// ideographs are emitted one per token. Punctuation and symbols are dropped,
+// so "database," and "database" yield the same token. Text is normalised to
+// NFC first so that precomposed and decomposed accents match.
+func UnicodeTokenizer(text string) []string {
+	text = norm.NFC.String(text)
+	var tokens []string
+	start := -1
+	for i, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			if start >= 0 {
+				tokens = append(tokens, text[start:i])
+				start = -1
+			}
+			tokens = append(tokens, string(r))
+		case isWordRune(r):
+			if start < 0 {
+				start = i
+			}
+		case isApostrophe(r) && start >= 0 && nextIsWordRune(text, i+utf8.RuneLen(r)):
+			// Keep apostrophes between word characters
+		default:
+			if start >= 0 {
+				tokens = append(tokens, text[start:i])
+				start = -1
+			}
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, text[start:])
+	}
+
+	// Normalise typographic apostrophes so filters only deal with '
+	for i, token := range tokens {
+		if strings.ContainsRune(token, '’') {
+			tokens[i] = strings.ReplaceAll(token, "’", "'")
+		}
+	}
+	return tokens
+}
+
+// WhitespaceTokenizer splits text on Unicode whitespace only, keeping
+// punctuation attached to words.
+func WhitespaceTokenizer(text string) []string {
+	return strings.Fields(text)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’'
+}
+
+func nextIsWordRune(text string, i int) bool {
+	if i >= len(text) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return isWordRune(r) && !unicode.Is(unicode.Han, r)
+}
+
+// LowercaseFilter lowercases every token.
+func LowercaseFilter(tokens []string) []string {
+	for i, token := range tokens {
+		tokens[i] = strings.ToLower(token)
+	}
+	return tokens
+}
+
+// ASCIIFoldingFilter removes diacritics and expands common ligatures, so that
+// "données" and "donnees" produce the same term. Apply it after stemming, as
+// stemmers rely on accents.
+func ASCIIFoldingFilter(tokens []string) []string {
+	for i, token := range tokens {
+		tokens[i] = foldToASCII(token)
+	}
+	return tokens
+}
+
+func foldToASCII(token string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(token) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r == 'œ':
+			b.WriteString("oe")
+		case r == 'æ':
+			b.WriteString("ae")
+		case r == 'ß':
+			b.WriteString("ss")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// StopWordFilter returns a filter that drops the given words. Tokens are
+// compared as-is, so place it after LowercaseFilter.
+func StopWordFilter(words ...string) TokenFilter {
+	stopWords := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		stopWords[word] = struct{}{}
+	}
+	return func(tokens []string) []string {
+		kept := tokens[:0]
+		for _, token := range tokens {
+			if _, stop := stopWords[token]; !stop {
+				kept = append(kept, token)
+			}
+		}
+		return kept
+	}
+}
+
+// StemFilter returns a filter that replaces every token with its stem.
+func StemFilter(stemmer Stemmer) TokenFilter {
+	return func(tokens []string) []string {
+		for i, token := range tokens {
+			tokens[i] = stemmer(token)
+		}
+		return tokens
+	}
+}
+
+// EnglishPossessiveFilter removes a trailing "'s" from tokens.
+func EnglishPossessiveFilter(tokens []string) []string {
+	for i, token := range tokens {
+		tokens[i] = strings.TrimSuffix(token, "'s")
+	}
+	return tokens
+}
+
+// ElisionFilter returns a filter that removes elided articles and pronouns
+// such as "l'" or "qu'" from the start of tokens ("l'index" becomes "index").
+// The elisions are given without their apostrophe.
+func ElisionFilter(elisions ...string) TokenFilter {
+	set := make(map[string]struct{}, len(elisions))
+	for _, elision := range elisions {
+		set[elision] = struct{}{}
+	}
+	return func(tokens []string) []string {
+		for i, token := range tokens {
+			if prefix, rest, found := strings.Cut(token, "'"); found && rest != "" {
+				if _, ok := set[prefix]; ok {
+					tokens[i] = rest
+				}
+			}
+		}
+		return tokens
+	}
+}
+
+// NGramFilter returns a filter that replaces every token with its character
+// n-grams of length minN to maxN, which improves recall on partial words and
+// compound terms at the cost of a larger index. Tokens shorter than minN are
+// kept whole.
+func NGramFilter(minN, maxN int) TokenFilter {
+	if minN < 1 {
+		minN = 1
+	}
+	if maxN < minN {
+		maxN = minN
+	}
+	return func(tokens []string) []string {
+		grams := make([]string, 0, len(tokens))
+		for _, token := range tokens {
+			runes := []rune(token)
+			if len(runes) < minN {
+				grams = append(grams, token)
+				continue
+			}
+			for n := minN; n <= maxN && n <= len(runes); n++ {
+				for i := 0; i+n <= len(runes); i++ {
+					grams = append(grams, string(runes[i:i+n]))
+				}
+			}
+		}
+		return grams
+	}
+}
+
+// ShingleFilter returns a filter that keeps every token and adds word n-grams
+// (shingles) of 2 to maxN consecutive tokens joined by a space, so that
+// phrase matches score higher than scattered terms.
+func ShingleFilter(maxN int) TokenFilter {
+	return func(tokens []string) []string {
+		shingles := append([]string(nil), tokens...)
+		for n := 2; n <= maxN; n++ {
+			for i := 0; i+n <= len(tokens); i++ {
+				shingles = append(shingles, strings.Join(tokens[i:i+n], " "))
+			}
+		}
+		return shingles
+	}
+}
+
+// FrenchElisions are the elided forms removed by NewFrenchAnalyzer.
+var FrenchElisions = []string{
+	"l", "m", "t", "qu", "n", "s", "j", "d", "c",
+	"jusqu", "quoiqu", "lorsqu", "puisqu",
+}
+
+// EnglishStopWords is the Snowball English stop word list.
+var EnglishStopWords = []string{
+	"i", "me", "my", "myself", "we", "our", "ours", "ourselves", "you", "your",
+	"yours", "yourself", "yourselves", "he", "him", "his", "himself", "she",
+	"her", "hers", "herself", "it", "its", "itself", "they", "them", "their",
+	"theirs", "themselves", "what", "which", "who", "whom", "this", "that",
+	"these", "those", "am", "is", "are", "was", "were", "be", "been", "being",
+	"have", "has", "had", "having", "do", "does", "did", "doing", "would",
+	"should", "could", "ought", "i'm", "you're", "he's", "she's", "it's",
+	"we're", "they're", "i've", "you've", "we've", "they've", "i'd", "you'd",
+	"he'd", "she'd", "we'd", "they'd", "i'll", "you'll", "he'll", "she'll",
+	"we'll", "they'll", "isn't", "aren't", "wasn't", "weren't", "hasn't",
+	"haven't", "hadn't", "doesn't", "don't", "didn't", "won't", "wouldn't",
+	"shan't", "shouldn't", "can't", "cannot", "couldn't", "mustn't", "let's",
+	"that's", "who's", "what's", "here's", "there's", "when's", "where's",
+	"why's", "how's", "a", "an", "the", "and", "but", "if", "or", "because",
+	"as", "until", "while", "of", "at", "by", "for", "with", "about",
+	"against", "between", "into", "through", "during", "before", "after",
+	"above", "below", "to", "from", "up", "down", "in", "out", "on", "off",
+	"over", "under", "again", "further", "then", "once", "here", "there",
+	"when", "where", "why", "how", "all", "any", "both", "each", "few", "more",
+	"most", "other", "some", "such", "no", "nor", "not", "only", "own", "same",
+	"so", "than", "too", "very",
+}
+
+// FrenchStopWords is the Snowball French stop word list.
+var FrenchStopWords = []string{
+	"au", "aux", "avec", "ce", "ces", "dans", "de", "des", "du", "elle", "en",
+	"et", "eux", "il", "je", "la", "le", "leur", "lui", "ma", "mais", "me",
+	"même", "mes", "moi", "mon", "ne", "nos", "notre", "nous", "on", "ou",
+	"par", "pas", "pour", "qu", "que", "qui", "sa", "se", "ses", "son", "sur",
+	"ta", "te", "tes", "toi", "ton", "tu", "un", "une", "vos", "votre", "vous",
+	"c", "d", "j", "l", "à", "m", "n", "s", "t", "y", "été", "étée", "étées",
+	"étés", "étant", "suis", "es", "est", "sommes", "êtes", "sont", "serai",
+	"seras", "sera", "serons", "serez", "seront", "serais", "serait",
+	"serions", "seriez", "seraient", "étais", "était", "étions", "étiez",
+	"étaient", "fus", "fut", "fûmes", "fûtes", "furent", "sois", "soit",
+	"soyons", "soyez", "soient", "fusse", "fusses", "fût", "fussions",
+	"fussiez", "fussent", "ayant", "eu", "eue", "eues", "eus", "ai", "as",
+	"avons", "avez", "ont", "aurai", "auras", "aura", "aurons", "aurez",
+	"auront", "aurais", "aurait", "aurions", "auriez", "auraient", "avais",
+	"avait", "avions", "aviez", "avaient", "eut", "eûmes", "eûtes", "eurent",
+	"aie", "aies", "ait", "ayons", "ayez", "aient", "eusse", "eusses", "eût",
+	"eussions", "eussiez", "eussent", "ceci", "cela", "celà", "cet", "cette",
+	"ici", "ils", "les", "leurs", "quel", "quels", "quelle", "quelles", "sans",
+	"soi",
+}
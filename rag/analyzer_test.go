@@ -0,0 +1,123 @@
+package rag
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestUnicodeTokenizer(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "Hello, world! The database.", want: []string{"Hello", "world", "The", "database"}},
+		{text: "  \t\n", want: nil},
+		// Apostrophes inside words are kept, typographic ones normalised
+		{text: "l'index don’t", want: []string{"l'index", "don't"}},
+		{text: "rock 'n' roll x'", want: []string{"rock", "n", "roll", "x"}},
+		// Han ideographs are one token each
+		{text: "数据库abc", want: []string{"数", "据", "库", "abc"}},
+		// Decomposed accents are composed
+		{text: "cafe\u0301 café", want: []string{"café", "café"}},
+		{text: "v2.0 e-mail", want: []string{"v2", "0", "e", "mail"}},
+	}
+	for _, tt := range tests {
+		if got := UnicodeTokenizer(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("UnicodeTokenizer(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	if got, want := WhitespaceTokenizer("Hello, world!  x"), []string{"Hello,", "world!", "x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WhitespaceTokenizer = %q, want %q", got, want)
+	}
+}
+
+func TestTokenFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter TokenFilter
+		tokens []string
+		want   []string
+	}{
+		{name: "lowercase", filter: LowercaseFilter, tokens: []string{"Hello", "ÉTÉ"}, want: []string{"hello", "été"}},
+		{name: "ASCII folding", filter: ASCIIFoldingFilter, tokens: []string{"données", "cœur", "straße", "naïve"}, want: []string{"donnees", "coeur", "strasse", "naive"}},
+		{name: "stop words", filter: StopWordFilter("the", "a"), tokens: []string{"the", "cat", "The", "a"}, want: []string{"cat", "The"}},
+		{name: "stem", filter: StemFilter(PorterStem), tokens: []string{"running", "cats"}, want: []string{"run", "cat"}},
+		{name: "possessive", filter: EnglishPossessiveFilter, tokens: []string{"john's", "its", "'s"}, want: []string{"john", "its", ""}},
+		{name: "elision", filter: ElisionFilter(FrenchElisions...), tokens: []string{"l'index", "qu'il", "aujourd'hui", "l'"}, want: []string{"index", "il", "aujourd'hui", "l'"}},
+		{name: "n-grams", filter: NGramFilter(2, 3), tokens: []string{"abcd", "a", "été"}, want: []string{"ab", "bc", "cd", "abc", "bcd", "a", "ét", "té", "été"}},
+		{name: "shingles", filter: ShingleFilter(3), tokens: []string{"new", "york", "city"}, want: []string{"new", "york", "city", "new york", "york city", "new york city"}},
+	}
+	for _, tt := range tests {
+		if got := tt.filter(append([]string(nil), tt.tokens...)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %q = %q, want %q", tt.name, tt.tokens, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzers(t *testing.T) {
+	tests := []struct {
+		name     string
+		analyzer *Analyzer
+		text     string
+		want     []string
+	}{
+		{
+			name:     "standard",
+			analyzer: NewStandardAnalyzer(),
+			text:     "Hello, World! Don't stop.",
+			want:     []string{"hello", "world", "don't", "stop"},
+		},
+		{
+			name:     "english",
+			analyzer: NewEnglishAnalyzer(),
+			text:     "The cat's owners were RUNNING between connected houses, quickly!",
+			want:     []string{"cat", "owner", "run", "connect", "hous", "quickli"},
+		},
+		{
+			name:     "french",
+			analyzer: NewFrenchAnalyzer(),
+			text:     "L’index des données qu'il continuellement mises à jour",
+			want:     []string{"index", "don", "continuel", "mis", "jour"},
+		},
+		{
+			name:     "default tokenizer",
+			analyzer: NewAnalyzer(nil, NGramFilter(3, 3)),
+			text:     "abcd, e",
+			want:     []string{"abc", "bcd", "e"},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.analyzer.Analyze(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Analyze(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestBM25Analyzer(t *testing.T) {
+	ctx := context.Background()
+	idx := NewBM25Index()
+	idx.SetAnalyzer(NewEnglishAnalyzer())
+	for id, text := range map[int64]string{
+		1: "Connecting the databases",
+		2: "A connection was made",
+		3: "The weather is nice",
+	} {
+		if err := idx.Add(ctx, id, text, nil); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	// Stemming matches other forms of the query words; stop words match nothing
+	results, err := idx.Search(ctx, "connected", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if got := resultIDs(results); len(got) != 2 || got[0] == 3 || got[1] == 3 {
+		t.Errorf("Search(connected) = %v, want documents 1 and 2", got)
+	}
+	if results, _ := idx.Search(ctx, "the was", 10); len(results) != 0 {
+		t.Errorf("Search of stop words = %v, want no results", resultIDs(results))
+	}
+}
@@ -0,0 +1,84 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultSearchBatchConcurrency is the number of searches a SearchBatch runs
+// at once on remote backends that have no multi-query request.
+const defaultSearchBatchConcurrency = 8
+
+// searchEach calls search for every query on up to workers goroutines and
+// returns the results in query order. The first error cancels the searches
+// that have not finished and is returned.
+func searchEach(ctx context.Context, queries []Vector, workers int, search func(ctx context.Context, query Vector) ([]SearchResult, error)) ([][]SearchResult, error) {
+	results := make([][]SearchResult, len(queries))
+	err := runEach(ctx, len(queries), workers, func(ctx context.Context, i int) error {
+		found, err := search(ctx, queries[i])
+		if err != nil {
+			return err
+		}
+		results[i] = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// runEach calls fn for every index in [0, n) on up to workers goroutines.
+// The first error cancels the calls that have not finished, skips those not
+// yet started and is returned; otherwise the error of ctx is returned, if any.
+func runEach(ctx context.Context, n, workers int, fn func(ctx context.Context, i int) error) error {
+	if n == 0 {
+		return nil
+	}
+	workers = max(1, min(workers, n))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	next := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				// The feed may hand out an index as ctx is being cancelled
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,93 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSearchEach(t *testing.T) {
+	ctx := context.Background()
+	queries := []Vector{{0}, {1}, {2}}
+
+	// The first search only finishes once the last one has
+	lastDone := make(chan struct{})
+	batch, err := searchEach(ctx, queries, len(queries), func(ctx context.Context, query Vector) ([]SearchResult, error) {
+		switch query[0] {
+		case 0:
+			<-lastDone
+		case 2:
+			defer close(lastDone)
+		}
+		return []SearchResult{{ID: int64(query[0])}}, nil
+	})
+	if err != nil {
+		t.Fatalf("searchEach: %v", err)
+	}
+	for i, results := range batch {
+		if got := resultIDs(results); !reflect.DeepEqual(got, []int64{int64(i)}) {
+			t.Errorf("result list %d = %v, want the results of query %d", i, got, i)
+		}
+	}
+
+	// A failed search fails the batch and cancels the searches in flight
+	errSearch := errors.New("search failed")
+	_, err = searchEach(ctx, queries, len(queries), func(ctx context.Context, query Vector) ([]SearchResult, error) {
+		if query[0] == 1 {
+			return nil, errSearch
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, errSearch) {
+		t.Errorf("searchEach with a failing search: error = %v, want %v", err, errSearch)
+	}
+}
+
+func TestRunEach(t *testing.T) {
+	ctx := context.Background()
+
+	// Every index is called once, with at most workers calls at a time
+	const n, workers = 50, 4
+	var running, peak atomic.Int32
+	calls := make([]atomic.Int32, n)
+	err := runEach(ctx, n, workers, func(ctx context.Context, i int) error {
+		now := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		calls[i].Add(1)
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("runEach: %v", err)
+	}
+	for i := range calls {
+		if got := calls[i].Load(); got != 1 {
+			t.Errorf("index %d was called %d times, want once", i, got)
+		}
+	}
+	if got := peak.Load(); got > workers {
+		t.Errorf("%d calls ran at once, want at most %d", got, workers)
+	}
+
+	// A cancelled context makes no calls and returns its error
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	var called atomic.Bool
+	err = runEach(cancelled, n, workers, func(context.Context, int) error {
+		called.Store(true)
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || called.Load() {
+		t.Errorf("runEach with a cancelled context = %v, called: %v, want context.Canceled without calls", err, called.Load())
+	}
+}
@@ -3,15 +3,23 @@ package rag
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"sync"
 
 	"github.com/philippgille/chromem-go"
 )
 
+// chromemTestCollection is the collection newChromemDB creates to check that
+// the database works. It is hidden from ListCollections.
+const chromemTestCollection = "test_collection"
+
 // ChromemDB implements a vector database interface using ChromeM.
 // ChromeM is a lightweight, embedded vector database that supports:
 // - In-memory and persistent storage modes
@@ -32,6 +40,10 @@ type ChromemDB struct {
 	dimension   int                   // Vector dimension for embeddings
 }
 
+func init() {
+	Register("chromem", func(cfg *Config) (VectorDB, error) { return newChromemDB(cfg) }, Capabilities{Hybrid: true, Filters: true, Delete: true, Persistence: true})
+}
+
 // newChromemDB creates a new ChromemDB instance with the given configuration.
 // It supports both in-memory and persistent storage modes:
 // - If cfg.Address is empty: Creates an in-memory database
@@ -86,7 +98,7 @@ func newChromemDB(cfg *Config) (*ChromemDB, error) {
 	}
 
 	// Test database by creating and removing a test collection
-	testCol := "test_collection"
+	testCol := chromemTestCollection
 	log.Printf("Testing database by creating test collection %s", testCol)
 	
 	// Create test collection
@@ -225,7 +237,7 @@ func (c *ChromemDB) CreateCollection(ctx context.Context, name string, schema Sc
 	// Check if collection already exists in our map
 	if _, exists := c.collections[name]; exists {
 		log.Printf("Collection %s already exists in our map", name)
-		return nil
+		return fmt.Errorf("%w: %s", ErrCollectionExists, name)
 	}
 
 	// Get OpenAI API key from environment
@@ -260,12 +272,15 @@ func (c *ChromemDB) CreateCollection(ctx context.Context, name string, schema Sc
 
 // Insert adds new records to a collection.
 // The function:
-// 1. Retrieves or creates the target collection
-// 2. Processes records in parallel for efficiency
-// 3. Creates ChromeM documents with:
-//    - Unique IDs
+// 1. Retrieves the target collection
+// 2. Validates the records against the standard document schema with the
+//    configured dimension (see ValidateRecords)
+// 3. Converts records to ChromeM documents with:
+//    - The record ID, or the one derived from its document ID or content
+//      (see ContentID) when no ID is set
 //    - Metadata from record fields
 //    - Content from specified text field
+// 4. Adds the documents in batches
 //
 // Thread-safe: Uses ChromeM's internal synchronization.
 func (c *ChromemDB) Insert(ctx context.Context, collectionName string, data []Record) error {
@@ -277,94 +292,158 @@ func (c *ChromemDB) Insert(ctx context.Context, collectionName string, data []Re
 	// Get collection from our map
 	col, exists := c.collections[collectionName]
 	if !exists {
-		return fmt.Errorf("collection %s does not exist in collections map", collectionName)
+		return collectionNotFound(collectionName)
+	}
+	if err := ValidateRecords(documentSchema(collectionName, c.dimension), data); err != nil {
+		return err
 	}
 
-	// Convert records to chromem documents
-	docs := make([]chromem.Document, len(data))
-	validCount := 0
+	docs, err := recordsToDocuments(data)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		log.Printf("Warning: No valid documents to insert into collection %s", collectionName)
+		return nil
+	}
 
-	for i, record := range data {
-		// Extract content and metadata
-		content, ok := record.Fields["Text"].(string)
-		if !ok {
-			log.Printf("Warning: Record %d has no 'Text' field or it's not a string, skipping", i)
-			continue
-		}
+	log.Printf("Converted %d/%d records to valid documents for collection %s", len(docs), len(data), collectionName)
 
-		metadata := make(map[string]string)
-		if metaField, ok := record.Fields["Metadata"]; ok {
-			if meta, ok := metaField.(map[string]interface{}); ok {
-				for k, v := range meta {
-					if str, ok := v.(string); ok {
-						metadata[k] = str
-					}
-				}
-			}
-		}
+	if err := addDocuments(ctx, col, docs, collectionName); err != nil {
+		return err
+	}
 
-		// Get embedding and convert to []float32 if needed
-		var embedding []float32
-		if embField, ok := record.Fields["Embedding"]; ok {
-			switch e := embField.(type) {
-			case []float32:
-				embedding = e
-			case Vector:
-				embedding = toFloat32Slice(e)
-			case []float64:
-				embedding = make([]float32, len(e))
-				for j, v := range e {
-					embedding[j] = float32(v)
-				}
-			default:
-				log.Printf("Warning: Record %d has invalid embedding type %T, skipping", i, embField)
-				continue
-			}
-		} else {
-			log.Printf("Warning: Record %d has no 'Embedding' field, skipping", i)
-			continue
-		}
+	log.Printf("Successfully inserted %d documents into collection %s", len(docs), collectionName)
 
-		// Create document
-		docs[validCount] = chromem.Document{
-			ID:        fmt.Sprintf("%d", i),
-			Content:   content,
-			Metadata:  metadata,
-			Embedding: embedding,
-		}
-		validCount++
-	}
+	return nil
+}
 
-	// Trim docs to valid count
-	docs = docs[:validCount]
+// Upsert adds records to a collection, replacing documents with the same ID.
+// ChromeM overwrites documents on ID collision, so this shares Insert's path.
+//
+// Thread-safe: Protected by write lock.
+func (c *ChromemDB) Upsert(ctx context.Context, collectionName string, data []Record) error {
+	return c.Insert(ctx, collectionName, data)
+}
 
-	if validCount == 0 {
-		log.Printf("Warning: No valid documents to insert into collection %s", collectionName)
+// Delete removes the documents with the given IDs from a collection.
+//
+// Thread-safe: Uses ChromeM's internal synchronization.
+func (c *ChromemDB) Delete(ctx context.Context, collectionName string, ids []int64) error {
+	if len(ids) == 0 {
 		return nil
 	}
 
-	log.Printf("Converted %d/%d records to valid documents for collection %s", validCount, len(data), collectionName)
+	col, err := c.getCollection(ctx, collectionName)
+	if err != nil {
+		return err
+	}
 
-	// Insert documents in batches to avoid memory issues
-	batchSize := 100
-	for i := 0; i < len(docs); i += batchSize {
-		end := i + batchSize
-		if end > len(docs) {
-			end = len(docs)
-		}
-		batch := docs[i:end]
+	docIDs := make([]string, len(ids))
+	for i, id := range ids {
+		docIDs[i] = strconv.FormatInt(id, 10)
+	}
 
-		log.Printf("Inserting batch of %d documents (batch %d/%d) into collection %s", len(batch), (i/batchSize)+1, (len(docs)+batchSize-1)/batchSize, collectionName)
-		for _, doc := range batch {
-			err := col.AddDocument(ctx, doc)
-			if err != nil {
-				return fmt.Errorf("failed to insert document: %w", err)
-			}
+	if err := col.Delete(ctx, nil, nil, docIDs...); err != nil {
+		return fmt.Errorf("failed to delete documents: %w", err)
+	}
+	return nil
+}
+
+// DeleteByFilter removes every document matching filter. Only filters that
+// chromem can evaluate natively are supported: equality on metadata keys
+// combined with AND, plus CONTAINS / NOT CONTAINS on the document text.
+//
+// Thread-safe: Uses ChromeM's internal synchronization.
+func (c *ChromemDB) DeleteByFilter(ctx context.Context, collectionName string, filter *Filter) error {
+	if filter == nil {
+		return fmt.Errorf("delete filter must not be empty")
+	}
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	where, whereDocument, exact := chromemWhere(filter)
+	if !exact {
+		return fmt.Errorf("filter %q cannot be expressed as a chromem delete", filter.String())
+	}
+
+	col, err := c.getCollection(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+
+	if err := col.Delete(ctx, where, whereDocument); err != nil {
+		return fmt.Errorf("failed to delete documents: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves the documents with the given IDs from a collection.
+// IDs that are not present in the collection are skipped.
+//
+// Thread-safe: Uses ChromeM's internal synchronization.
+func (c *ChromemDB) Get(ctx context.Context, collectionName string, ids []int64) ([]Record, error) {
+	col, err := c.getCollection(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, 0, len(ids))
+	for _, id := range ids {
+		doc, err := col.GetByID(ctx, strconv.FormatInt(id, 10))
+		if err != nil {
+			continue
 		}
+		records = append(records, documentToRecord(doc))
 	}
+	return records, nil
+}
 
-	log.Printf("Successfully inserted %d documents into collection %s", validCount, collectionName)
+// Scan calls fn with every document of a collection, ordered by ID.
+// ChromeM cannot list documents, so they are all fetched at once with an
+// exhaustive query whose result size is the collection size.
+//
+// Thread-safe: Uses ChromeM's internal synchronization.
+func (c *ChromemDB) Scan(ctx context.Context, collectionName string, batchSize int, fn func([]Record) error) error {
+	col, err := c.getCollection(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+	count := col.Count()
+	if count == 0 {
+		return nil
+	}
+
+	// Any unit vector of the right dimension matches every document.
+	query := make([]float32, c.dimension)
+	query[0] = 1
+	results, err := col.QueryEmbedding(ctx, query, count, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to scan documents: %w", err)
+	}
 
+	records := make([]Record, len(results))
+	for i, result := range results {
+		records[i] = documentToRecord(chromem.Document{
+			ID:        result.ID,
+			Metadata:  result.Metadata,
+			Embedding: result.Embedding,
+			Content:   result.Content,
+		})
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		a, _ := recordID(records[i])
+		b, _ := recordID(records[j])
+		return a < b
+	})
+
+	batchSize = scanBatchSize(batchSize)
+	for start := 0; start < len(records); start += batchSize {
+		if err := fn(records[start:min(start+batchSize, len(records))]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -378,10 +457,11 @@ func (c *ChromemDB) Flush(ctx context.Context, collectionName string) error {
 
 // CreateIndex creates an index for the specified field.
 // This is a no-op for ChromeM as it manages its own indexing,
-// but implemented to satisfy the database interface.
+// but implemented to satisfy the database interface. The index metric must
+// be COSINE, the only metric chromem searches by.
 func (c *ChromemDB) CreateIndex(ctx context.Context, collectionName, field string, index Index) error {
 	// No explicit index creation in chromem
-	return nil
+	return checkChromemMetric(index.Metric)
 }
 
 // LoadCollection prepares a collection for searching.
@@ -410,7 +490,7 @@ func (c *ChromemDB) LoadCollection(ctx context.Context, name string) error {
 	col := c.db.GetCollection(name, embeddingFunc)
 	if col == nil {
 		log.Printf("Collection %s not found", name)
-		return fmt.Errorf("collection %s not found", name)
+		return collectionNotFound(name)
 	}
 
 	// Store collection in our map
@@ -420,6 +500,52 @@ func (c *ChromemDB) LoadCollection(ctx context.Context, name string) error {
 	return nil
 }
 
+// ListCollections returns the names of all collections, sorted.
+func (c *ChromemDB) ListCollections(ctx context.Context) ([]string, error) {
+	var names []string
+	for name := range c.db.ListCollections() {
+		if name != chromemTestCollection {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DescribeCollection returns the schema of the records raggo writes, as
+// ChromeM does not store one. ChromeM searches exhaustively, so no indexes
+// are reported.
+func (c *ChromemDB) DescribeCollection(ctx context.Context, name string) (*CollectionInfo, error) {
+	if _, err := c.getCollection(ctx, name); err != nil {
+		return nil, err
+	}
+	return &CollectionInfo{Name: name, Schema: documentSchema(name, c.dimension), Indexes: map[string]Index{}}, nil
+}
+
+// Count returns the number of documents in a collection.
+func (c *ChromemDB) Count(ctx context.Context, collectionName string) (int64, error) {
+	col, err := c.getCollection(ctx, collectionName)
+	if err != nil {
+		return 0, err
+	}
+	return int64(col.Count()), nil
+}
+
+// Stats reports the document count of a collection. ChromeM does not expose
+// storage sizes, and searches without an index.
+func (c *ChromemDB) Stats(ctx context.Context, collectionName string) (*CollectionStats, error) {
+	count, err := c.Count(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	return &CollectionStats{
+		Count:       count,
+		Dimension:   c.dimension,
+		StorageSize: -1,
+		IndexStatus: IndexStatusNone,
+	}, nil
+}
+
 // Search performs vector similarity search on a collection.
 // The function:
 // 1. Retrieves the target collection
@@ -427,10 +553,19 @@ func (c *ChromemDB) LoadCollection(ctx context.Context, name string) error {
 // 3. Executes search with specified parameters
 // 4. Formats results to match interface requirements
 //
-// Note: ChromeM only supports cosine similarity for distance metric.
+// ChromeM only ranks by cosine similarity, so metricType must be COSINE;
+// other metrics are rejected rather than silently scored by cosine.
+//
+// A filter passed through FilterParam is translated into chromem's where and
+// whereDocument maps. Parts of the filter chromem cannot express (ranges, IN,
+// OR, ...) are evaluated in-process, see queryFiltered.
 //
 // Thread-safe: Uses ChromeM's internal synchronization.
 func (c *ChromemDB) Search(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}) ([]SearchResult, error) {
+	if err := checkChromemMetric(metricType); err != nil {
+		return nil, err
+	}
+
 	c.mu.RLock()
 
 	// First check if collection exists in our map
@@ -454,6 +589,9 @@ func (c *ChromemDB) Search(ctx context.Context, collectionName string, vectors m
 	if len(vectors) != 1 {
 		return nil, fmt.Errorf("chromem only supports single vector search")
 	}
+	if err := validateQueryVectors(documentSchema(collectionName, c.dimension), vectors); err != nil {
+		return nil, err
+	}
 
 	// Get the first vector
 	var queryVector Vector
@@ -465,15 +603,19 @@ func (c *ChromemDB) Search(ctx context.Context, collectionName string, vectors m
 	// Convert query vector to float32
 	query := toFloat32Slice(queryVector)
 
-	log.Printf("Searching collection %s with query vector of length %d", collectionName, len(query))
+	filter, err := filterFromParams(searchParams)
+	if err != nil {
+		return nil, err
+	}
+
+	GlobalLogger.Debug("Searching chromem collection", "collection", collectionName, "dimension", len(query))
 
-	// Search documents using empty filters for where and whereDocument
-	results, err := col.QueryEmbedding(ctx, query, topK, make(map[string]string), make(map[string]string))
+	results, err := queryFiltered(ctx, col, query, topK, filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search documents: %w", err)
+		return nil, err
 	}
 
-	log.Printf("Found %d results (requested topK=%d)", len(results), topK)
+	GlobalLogger.Debug("Chromem search finished", "results", len(results), "topK", topK)
 
 	if len(results) == 0 {
 		log.Printf("Warning: No results found in collection %s. This could indicate that either: (1) the collection is empty, (2) no similar documents were found, or (3) the collection was not properly loaded.", collectionName)
@@ -489,22 +631,66 @@ func (c *ChromemDB) Search(ctx context.Context, collectionName string, vectors m
 			fields["Metadata"] = result.Metadata
 		}
 
+		// Documents with non-numeric IDs, which raggo never creates, get ID 0
+		id, _ := strconv.ParseInt(result.ID, 10, 64)
+
 		searchResults[i] = SearchResult{
-			ID:     int64(i),
-			Score:  float64(result.Similarity),
-			Fields: fields,
+			ID:       id,
+			DocID:    docIDFromFields(fields),
+			Score:    float64(result.Similarity),
+			Distance: 1 - float64(result.Similarity),
+			Fields:   fields,
 		}
-		log.Printf("Result %d: score=%f, content=%s", i, result.Similarity, result.Content)
+		GlobalLogger.Debug("Chromem search result", "rank", i, "id", result.ID, "score", result.Similarity)
 	}
 
 	return searchResults, nil
 }
 
-// HybridSearch performs combined vector and keyword search.
-// Currently not implemented for ChromeM - returns an error.
+// SearchBatch runs the searches of the query vectors on up to GOMAXPROCS
+// goroutines. Each search is performed as by Search; the field is ignored as
+// chromem collections hold a single embedding per document.
+func (c *ChromemDB) SearchBatch(ctx context.Context, collectionName, field string, queries []Vector, topK int, metricType string, searchParams map[string]interface{}) ([][]SearchResult, error) {
+	return searchEach(ctx, queries, runtime.GOMAXPROCS(0), func(ctx context.Context, query Vector) ([]SearchResult, error) {
+		return c.Search(ctx, collectionName, map[string]Vector{field: query}, topK, metricType, searchParams)
+	})
+}
+
+// HybridSearch searches with each query vector separately and fuses the
+// rankings with Reciprocal Rank Fusion. The reranker may be nil (RRF with
+// k = 60) or a *RRFReranker. As chromem collections hold a single embedding
+// per document, every vector is searched against it. Result scores are the
+// fused scores; their Distance is zero.
 func (c *ChromemDB) HybridSearch(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}, reranker interface{}) ([]SearchResult, error) {
-	// Not implemented for chromem
-	return nil, fmt.Errorf("hybrid search not implemented for chromem")
+	rrf := NewRRFReranker(0)
+	if reranker != nil {
+		var ok bool
+		if rrf, ok = reranker.(*RRFReranker); !ok {
+			return nil, fmt.Errorf("invalid reranker type %T for chromem", reranker)
+		}
+	}
+
+	// Search fields in a stable order so that ties fuse deterministically
+	fields := make([]string, 0, len(vectors))
+	for field := range vectors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	rankings := make([][]SearchResult, 0, len(fields))
+	for _, field := range fields {
+		ranking, err := c.Search(ctx, collectionName, map[string]Vector{field: vectors[field]}, topK, metricType, searchParams)
+		if err != nil {
+			return nil, err
+		}
+		rankings = append(rankings, ranking)
+	}
+
+	results := rrf.fuse(rankings...)
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
 }
 
 // SetColumnNames sets the list of columns to retrieve in search results.
@@ -523,3 +709,237 @@ func toFloat32Slice(v Vector) []float32 {
 	}
 	return result
 }
+
+// getCollection returns the cached collection, loading it from the database
+// when it is not cached yet.
+func (c *ChromemDB) getCollection(ctx context.Context, name string) (*chromem.Collection, error) {
+	c.mu.RLock()
+	col, exists := c.collections[name]
+	c.mu.RUnlock()
+	if exists {
+		return col, nil
+	}
+
+	if err := c.LoadCollection(ctx, name); err != nil {
+		return nil, fmt.Errorf("failed to load collection: %w", err)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.collections[name], nil
+}
+
+// recordsToDocuments converts records into ChromeM documents.
+// Records without a string 'Text' field or a usable 'Embedding' field are
+// skipped with a warning. The document ID is taken from the record's ID
+// field, falling back to the ID derived from its document ID (see DocIDKey)
+// and then to the one derived from its content (see ContentID).
+func recordsToDocuments(data []Record) ([]chromem.Document, error) {
+	docs := make([]chromem.Document, 0, len(data))
+
+	for i, record := range data {
+		// Extract content and metadata
+		content, ok := record.Fields["Text"].(string)
+		if !ok {
+			log.Printf("Warning: Record %d has no 'Text' field or it's not a string, skipping", i)
+			continue
+		}
+
+		metadata := make(map[string]string)
+		if metaField, ok := record.Fields["Metadata"]; ok {
+			if meta, ok := metaField.(map[string]interface{}); ok {
+				for k, v := range meta {
+					metadata[k] = chromemMetadataValue(v)
+				}
+			}
+		}
+
+		// Get embedding and convert to []float32 if needed
+		var embedding []float32
+		if embField, ok := record.Fields["Embedding"]; ok {
+			switch e := embField.(type) {
+			case []float32:
+				embedding = e
+			case Vector:
+				embedding = toFloat32Slice(e)
+			case []float64:
+				embedding = make([]float32, len(e))
+				for j, v := range e {
+					embedding[j] = float32(v)
+				}
+			default:
+				log.Printf("Warning: Record %d has invalid embedding type %T, skipping", i, embField)
+				continue
+			}
+		} else {
+			log.Printf("Warning: Record %d has no 'Embedding' field, skipping", i)
+			continue
+		}
+
+		id, err := storedID(record)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+
+		docs = append(docs, chromem.Document{
+			ID:        strconv.FormatInt(id, 10),
+			Content:   content,
+			Metadata:  metadata,
+			Embedding: embedding,
+		})
+	}
+
+	return docs, nil
+}
+
+// addDocuments adds documents to a collection in batches to avoid memory issues.
+func addDocuments(ctx context.Context, col *chromem.Collection, docs []chromem.Document, collectionName string) error {
+	batchSize := 100
+	for i := 0; i < len(docs); i += batchSize {
+		end := i + batchSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+		batch := docs[i:end]
+
+		log.Printf("Inserting batch of %d documents (batch %d/%d) into collection %s", len(batch), (i/batchSize)+1, (len(docs)+batchSize-1)/batchSize, collectionName)
+		for _, doc := range batch {
+			err := col.AddDocument(ctx, doc)
+			if err != nil {
+				return fmt.Errorf("failed to insert document: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// documentToRecord converts a ChromeM document back into a Record with the
+// same field layout used on insert: ID, Text, Metadata and Embedding.
+func documentToRecord(doc chromem.Document) Record {
+	fields := map[string]interface{}{
+		"Text": doc.Content,
+	}
+	if id, err := strconv.ParseInt(doc.ID, 10, 64); err == nil {
+		fields["ID"] = id
+	}
+	if len(doc.Metadata) > 0 {
+		metadata := make(map[string]interface{}, len(doc.Metadata))
+		for k, v := range doc.Metadata {
+			metadata[k] = v
+		}
+		fields["Metadata"] = metadata
+	}
+	if len(doc.Embedding) > 0 {
+		embedding := make(Vector, len(doc.Embedding))
+		for i, v := range doc.Embedding {
+			embedding[i] = float64(v)
+		}
+		fields["Embedding"] = embedding
+	}
+	return Record{Fields: fields}
+}
+
+// chromemMetadataValue converts a metadata value to the string form chromem
+// stores. Scalars use their natural text form so they stay filterable;
+// composite values are stored as JSON.
+func chromemMetadataValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(val)
+	}
+	if _, ok := toFloat(v); ok {
+		return fmt.Sprint(v)
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(encoded)
+}
+
+// checkChromemMetric resolves metricType and verifies that it is cosine
+// similarity, the only metric chromem ranks by.
+func checkChromemMetric(metricType string) error {
+	metric, err := ResolveMetric(metricType)
+	if err != nil {
+		return err
+	}
+	if metric != MetricCosine {
+		return fmt.Errorf("chromem only supports %s similarity and cannot be used with %s", MetricCosine, metric)
+	}
+	return nil
+}
+
+// queryFiltered returns the topK documents of col nearest to query that match
+// filter. When chromem cannot express the whole filter, the rest is evaluated
+// in-process on a candidate window that doubles until topK candidates match
+// or the collection is exhausted.
+func queryFiltered(ctx context.Context, col *chromem.Collection, query []float32, topK int, filter *Filter) ([]chromem.Result, error) {
+	where, whereDocument, exact := chromemWhere(filter)
+
+	// chromem rejects nResults larger than the collection
+	count := col.Count()
+	nResults := min(topK, count)
+	for nResults > 0 {
+		results, err := col.QueryEmbedding(ctx, query, nResults, where, whereDocument)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search documents: %w", err)
+		}
+		if exact {
+			return results, nil
+		}
+
+		matched := results[:0]
+		for _, result := range results {
+			candidate := Record{Fields: map[string]interface{}{
+				"Text":     result.Content,
+				"Metadata": result.Metadata,
+			}}
+			if filter.Matches(candidate) {
+				matched = append(matched, result)
+			}
+		}
+		if len(matched) >= topK || len(results) < nResults || nResults == count {
+			return matched[:min(topK, len(matched))], nil
+		}
+		nResults = min(nResults*2, count)
+	}
+	return nil, nil
+}
+
+// chromemWhere translates a filter into chromem's where and whereDocument
+// maps. chromem only supports exact string equality on metadata (ANDed
+// together) and $contains / $not_contains on the document text. The third
+// return value is false when part of the filter could not be translated and
+// results must be checked with Filter.Matches.
+func chromemWhere(f *Filter) (map[string]string, map[string]string, bool) {
+	where := make(map[string]string)
+	whereDocument := make(map[string]string)
+	if f == nil {
+		return where, whereDocument, true
+	}
+
+	exact := true
+	for _, conjunct := range flattenAnd(f) {
+		switch {
+		case conjunct.Op == OpEq && conjunct.Field != TextField:
+			value := chromemMetadataValue(conjunct.Value)
+			if existing, ok := where[conjunct.Field]; ok && existing != value {
+				exact = false
+				continue
+			}
+			where[conjunct.Field] = value
+		case conjunct.Op == OpContains && conjunct.Field == TextField && whereDocument["$contains"] == "":
+			whereDocument["$contains"] = conjunct.Value.(string)
+		case conjunct.Op == OpNot && conjunct.Filters[0].Op == OpContains && conjunct.Filters[0].Field == TextField && whereDocument["$not_contains"] == "":
+			whereDocument["$not_contains"] = conjunct.Filters[0].Value.(string)
+		default:
+			exact = false
+		}
+	}
+	return where, whereDocument, exact
+}
@@ -0,0 +1,199 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestChromem returns an in-memory ChromemDB of three-dimensional
+// documents holding an empty "docs" collection. ChromeM requires an OpenAI
+// key to create collections, although documents come with their embeddings.
+func newTestChromem(t *testing.T) *ChromemDB {
+	t.Helper()
+	t.Setenv("OPENAI_API_KEY", "unused")
+	db, err := newChromemDB(&Config{Parameters: map[string]interface{}{"dimension": 3}})
+	if err != nil {
+		t.Fatalf("newChromemDB: %v", err)
+	}
+	if err := db.CreateCollection(context.Background(), "docs", Schema{}); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	return db
+}
+
+func TestChromemRecordIDs(t *testing.T) {
+	ctx := context.Background()
+	db := newTestChromem(t)
+
+	withID := Record{Fields: map[string]interface{}{"ID": int64(7), "Embedding": Vector{1, 0, 0}, "Text": "seven"}}
+	withDocID := Record{Fields: map[string]interface{}{
+		"Embedding": Vector{0, 1, 0},
+		"Text":      "chunk",
+		"Metadata":  map[string]interface{}{DocIDKey: "a#0"},
+	}}
+	bare := Record{Fields: map[string]interface{}{"Embedding": Vector{0, 0, 1}, "Text": "bare"}}
+	contentID, err := ContentID(bare)
+	if err != nil {
+		t.Fatalf("ContentID: %v", err)
+	}
+
+	// Inserting the records again replaces them rather than adding duplicates
+	for i := 0; i < 2; i++ {
+		if err := db.Insert(ctx, "docs", []Record{withID, withDocID, bare}); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+	}
+	if count, err := db.Count(ctx, "docs"); err != nil || count != 3 {
+		t.Errorf("Count = %d, %v after inserting the records twice, want 3", count, err)
+	}
+
+	for _, want := range []struct {
+		id   int64
+		text string
+	}{{7, "seven"}, {IDFromDocID("a#0"), "chunk"}, {contentID, "bare"}} {
+		records, err := db.Get(ctx, "docs", []int64{want.id})
+		if err != nil || len(records) != 1 || records[0].Fields["Text"] != want.text {
+			t.Errorf("Get(%d) = %v, %v, want the %q record", want.id, records, err, want.text)
+		}
+	}
+
+	unencodable := Record{Fields: map[string]interface{}{"Embedding": Vector{1, 1, 0}, "Text": "x", "Extra": func() {}}}
+	if err := db.Insert(ctx, "docs", []Record{unencodable}); err == nil || !strings.Contains(err.Error(), "cannot derive an ID") {
+		t.Errorf("Insert of a record whose ID cannot be derived: error = %v", err)
+	}
+}
+
+func TestChromemSearch(t *testing.T) {
+	ctx := context.Background()
+	db := newTestChromem(t)
+
+	// Document i lies further from the query {1, 0, 0} as i grows
+	records := make([]Record, 10)
+	for i := range records {
+		records[i] = Record{Fields: map[string]interface{}{
+			"ID":        int64(i + 1),
+			"Embedding": Vector{10 - float64(i), float64(i), 0},
+			"Text":      fmt.Sprintf("doc %d", i),
+			"Metadata":  map[string]interface{}{"chunk": i},
+		}}
+	}
+	if err := db.Insert(ctx, "docs", records); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	query := map[string]Vector{"Embedding": {1, 0, 0}}
+
+	t.Run("metrics", func(t *testing.T) {
+		for _, metric := range []string{"", "L2", "IP"} {
+			if _, err := db.Search(ctx, "docs", query, 1, metric, nil); err == nil || !strings.Contains(err.Error(), "chromem only supports COSINE") {
+				t.Errorf("Search with metric %q: error = %v", metric, err)
+			}
+			if err := db.CreateIndex(ctx, "docs", "Embedding", Index{Type: "HNSW", Metric: metric}); err == nil {
+				t.Errorf("CreateIndex with metric %q succeeded, want an error", metric)
+			}
+		}
+		if err := db.CreateIndex(ctx, "docs", "Embedding", Index{Type: "HNSW", Metric: "cosine"}); err != nil {
+			t.Errorf("CreateIndex with cosine: %v", err)
+		}
+		results, err := db.Search(ctx, "docs", query, 2, "cosine", nil)
+		if err != nil || !reflect.DeepEqual(resultIDs(results), []int64{1, 2}) {
+			t.Fatalf("Search = %v, %v, want documents 1 and 2", resultIDs(results), err)
+		}
+		if results[0].Score != 1 || results[0].Distance != 0 {
+			t.Errorf("exact match has score %v and distance %v, want 1 and 0", results[0].Score, results[0].Distance)
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		tests := []struct {
+			filter string
+			topK   int
+			want   []int64
+		}{
+			{filter: `chunk == 4`, topK: 3, want: []int64{5}},
+			// Evaluated in-process: the nearest matches lie beyond the first windows
+			{filter: `chunk >= 7`, topK: 2, want: []int64{8, 9}},
+			{filter: `chunk IN [9, 0]`, topK: 5, want: []int64{1, 10}},
+			{filter: `chunk == 4 OR Text CONTAINS "doc 8"`, topK: 1, want: []int64{5}},
+			{filter: `chunk > 20`, topK: 2, want: []int64{}},
+		}
+		for _, tt := range tests {
+			results, err := db.Search(ctx, "docs", query, tt.topK, MetricCosine, map[string]interface{}{FilterParam: tt.filter})
+			if err != nil || !reflect.DeepEqual(resultIDs(results), tt.want) {
+				t.Errorf("Search(%s, topK %d) = %v, %v, want %v", tt.filter, tt.topK, resultIDs(results), err, tt.want)
+			}
+		}
+	})
+
+	t.Run("hybrid", func(t *testing.T) {
+		vectors := map[string]Vector{"a": {1, 0, 0}, "b": {0, 1, 0}}
+		results, err := db.HybridSearch(ctx, "docs", vectors, 3, MetricCosine, nil, nil)
+		if err != nil || len(results) != 3 {
+			t.Fatalf("HybridSearch = %v, %v, want 3 results", resultIDs(results), err)
+		}
+		for i := 1; i < len(results); i++ {
+			if results[i].Score > results[i-1].Score {
+				t.Errorf("HybridSearch results are not ordered by fused score: %v", results)
+			}
+		}
+		if _, err := db.HybridSearch(ctx, "docs", vectors, 3, MetricL2, nil, nil); err == nil {
+			t.Error("HybridSearch with L2 succeeded, want an error")
+		}
+		if _, err := db.HybridSearch(ctx, "docs", vectors, 3, MetricCosine, nil, "weighted"); err == nil || !strings.Contains(err.Error(), "invalid reranker type") {
+			t.Errorf("HybridSearch with a string reranker: error = %v", err)
+		}
+	})
+}
+
+func TestChromemIntrospection(t *testing.T) {
+	ctx := context.Background()
+	db := newTestChromem(t)
+	if err := db.CreateCollection(ctx, "archive", Schema{}); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	// The collection newChromemDB creates for its checks is not listed
+	if names, err := db.ListCollections(ctx); err != nil || !reflect.DeepEqual(names, []string{"archive", "docs"}) {
+		t.Errorf("ListCollections = %v, %v, want archive and docs in order", names, err)
+	}
+
+	info, err := db.DescribeCollection(ctx, "docs")
+	if err != nil {
+		t.Fatalf("DescribeCollection: %v", err)
+	}
+	want := &CollectionInfo{Name: "docs", Schema: documentSchema("docs", 3), Indexes: map[string]Index{}}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("DescribeCollection = %+v, want %+v", info, want)
+	}
+
+	records := []Record{
+		{Fields: map[string]interface{}{"ID": int64(1), "Embedding": Vector{1, 0, 0}, "Text": "one"}},
+		{Fields: map[string]interface{}{"ID": int64(2), "Embedding": Vector{0, 1, 0}, "Text": "two"}},
+	}
+	if err := db.Insert(ctx, "docs", records); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if count, err := db.Count(ctx, "docs"); err != nil || count != 2 {
+		t.Errorf("Count = %d, %v, want 2", count, err)
+	}
+	stats, err := db.Stats(ctx, "docs")
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if want := (CollectionStats{Count: 2, Dimension: 3, StorageSize: -1, IndexStatus: IndexStatusNone}); *stats != want {
+		t.Errorf("Stats = %+v, want %+v", *stats, want)
+	}
+
+	if _, err := db.DescribeCollection(ctx, "missing"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("DescribeCollection of a missing collection: error = %v", err)
+	}
+	if _, err := db.Count(ctx, "missing"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Count of a missing collection: error = %v", err)
+	}
+	if _, err := db.Stats(ctx, "missing"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Stats of a missing collection: error = %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// DocIDKey is the Metadata key holding a record's document ID: a stable,
+// caller-supplied string such as ChunkDocID(source, chunk). Document IDs are
+// stored with the metadata, so they round-trip through every backend and are
+// reported in SearchResult.DocID; filter on DocIDKey to find or delete the
+// records of a document.
+const DocIDKey = "doc_id"
+
+// ChunkDocID returns the document ID of a chunk of a source: the hex SHA-256
+// of the source, "#" and the chunk's position, e.g. "9f86d0...#3". It is the
+// same every time the source is processed.
+func ChunkDocID(source string, chunk int) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:]) + "#" + strconv.Itoa(chunk)
+}
+
+// IDFromDocID returns the non-negative record ID derived from a document ID.
+// Every backend that accepts caller-supplied IDs (all but Milvus, whose
+// AutoID primary keys are always generated) gives it to records that have a
+// document ID but no ID, so that upserting a document again replaces it and
+// Get and Delete can find it.
+func IDFromDocID(docID string) int64 {
+	sum := sha256.Sum256([]byte(docID))
+	return int64(binary.BigEndian.Uint64(sum[:8]) & math.MaxInt64)
+}
+
+// ContentID returns the non-negative record ID derived from a record's
+// fields other than its ID. The chromem, Qdrant and Elasticsearch backends
+// give it to records that have neither an ID nor a document ID, so that
+// storing the same record again replaces it rather than adding a duplicate,
+// and so that callers can compute the ID to Get or Delete the record. It
+// fails when a field cannot be encoded as JSON.
+func ContentID(record Record) (int64, error) {
+	fields := make(map[string]interface{}, len(record.Fields))
+	for name, value := range record.Fields {
+		if name != "ID" {
+			fields[name] = value
+		}
+	}
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return 0, fmt.Errorf("cannot derive an ID from the record's fields: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	return int64(binary.BigEndian.Uint64(sum[:8]) & math.MaxInt64), nil
+}
+
+// storedID returns the ID under which the backends that accept caller-
+// supplied IDs store a record: its ID field, else the ID derived from its
+// document ID (see IDFromDocID), else its ContentID.
+func storedID(record Record) (int64, error) {
+	if id, ok := recordID(record); ok {
+		return id, nil
+	}
+	if docID, ok := RecordDocID(record); ok {
+		return IDFromDocID(docID), nil
+	}
+	return ContentID(record)
+}
+
+// RecordDocID returns the document ID stored in a record's metadata.
+func RecordDocID(record Record) (string, bool) {
+	docID := docIDFromFields(record.Fields)
+	return docID, docID != ""
+}
+
+// docIDFromFields reads the document ID from the Metadata of a record or
+// search result, or returns "" if there is none.
+func docIDFromFields(fields map[string]interface{}) string {
+	switch metadata := fields["Metadata"].(type) {
+	case map[string]interface{}:
+		docID, _ := metadata[DocIDKey].(string)
+		return docID
+	case map[string]string:
+		// chromem stores metadata values as strings
+		return metadata[DocIDKey]
+	default:
+		return ""
+	}
+}
+
+// assignDocIDs returns the records with an ID derived from their document ID
+// (see IDFromDocID) set in the primaryKey field of those that have a document
+// ID but no primary key value, copying only the records that change.
+func assignDocIDs(records []Record, primaryKey string) []Record {
+	if primaryKey == "" {
+		return records
+	}
+	assigned, copied := records, false
+	for i, record := range records {
+		if value, ok := record.Fields[primaryKey]; ok && value != nil {
+			continue
+		}
+		docID, ok := RecordDocID(record)
+		if !ok {
+			continue
+		}
+		fields := make(map[string]interface{}, len(record.Fields)+1)
+		for k, v := range record.Fields {
+			fields[k] = v
+		}
+		fields[primaryKey] = IDFromDocID(docID)
+		if !copied {
+			assigned = append([]Record(nil), records...)
+			copied = true
+		}
+		assigned[i] = Record{Fields: fields}
+	}
+	return assigned
+}
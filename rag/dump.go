@@ -0,0 +1,286 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	// dumpFormat identifies a raggo collection dump in its header line
+	dumpFormat = "raggo-dump"
+	// dumpVersion is the version of the dump format written by Export
+	dumpVersion = 1
+)
+
+// dumpHeader is the first line of a dump: the collection's description.
+type dumpHeader struct {
+	Format     string           `json:"format"`
+	Version    int              `json:"version"`
+	Collection string           `json:"collection"`
+	Schema     Schema           `json:"schema"`
+	Indexes    map[string]Index `json:"indexes,omitempty"`
+}
+
+// dumpEntry is any line after the header: a record, or the trailer holding
+// the number of records, which marks the end of a complete dump.
+type dumpEntry struct {
+	Fields map[string]interface{} `json:"fields,omitempty"`
+	Count  *int64                 `json:"count,omitempty"`
+}
+
+// Export writes a collection to w as a portable dump, which Import can load
+// into any backend without re-embedding the records.
+//
+// A dump is JSON Lines: a header holding the collection's schema and index
+// definitions, one line per record with all of its fields, vectors included,
+// and a trailer holding the record count:
+//
+//	{"format":"raggo-dump","version":1,"collection":"docs","schema":{...},"indexes":{...}}
+//	{"fields":{"ID":1,"Embedding":[0.1,0.2],"Text":"...","Metadata":{...}}}
+//	{"count":1}
+func Export(ctx context.Context, db VectorDB, collection string, w io.Writer) error {
+	info, err := db.DescribeCollection(ctx, collection)
+	if err != nil {
+		return fmt.Errorf("failed to describe collection %s: %w", collection, err)
+	}
+
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	encoder.SetEscapeHTML(false)
+
+	header := dumpHeader{
+		Format:     dumpFormat,
+		Version:    dumpVersion,
+		Collection: collection,
+		Schema:     info.Schema,
+		Indexes:    info.Indexes,
+	}
+	if err := encoder.Encode(header); err != nil {
+		return fmt.Errorf("failed to write dump header: %w", err)
+	}
+
+	var count int64
+	err = db.Scan(ctx, collection, DefaultScanBatchSize, func(records []Record) error {
+		for _, record := range records {
+			if err := encoder.Encode(dumpEntry{Fields: record.Fields}); err != nil {
+				return fmt.Errorf("failed to write record: %w", err)
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export collection %s: %w", collection, err)
+	}
+
+	if err := encoder.Encode(dumpEntry{Count: &count}); err != nil {
+		return fmt.Errorf("failed to write dump trailer: %w", err)
+	}
+	return bw.Flush()
+}
+
+// Import loads a dump written by Export into a collection, which defaults to
+// the dumped collection's name when empty. A missing collection is created
+// with the dumped schema and, once the records are written, the dumped
+// indexes. Records are upserted, so importing the same dump twice is safe.
+//
+// Values are restored to the types of the schema: vectors as Vector,
+// integers as int64, JSON fields as maps; numbers in index parameters become
+// int64 or float64. A dump without its trailer is reported as truncated,
+// after the records it holds have been written.
+func Import(ctx context.Context, db VectorDB, collection string, r io.Reader) error {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	decoder.UseNumber()
+
+	var header dumpHeader
+	if err := decoder.Decode(&header); err != nil {
+		return fmt.Errorf("failed to read dump header: %w", err)
+	}
+	if header.Format != dumpFormat {
+		return fmt.Errorf("not a raggo dump (format %q)", header.Format)
+	}
+	if header.Version != dumpVersion {
+		return fmt.Errorf("unsupported dump version %d", header.Version)
+	}
+	if collection == "" {
+		collection = header.Collection
+	}
+	for name, index := range header.Indexes {
+		if index.Parameters != nil {
+			index.Parameters = decodeDumpValue(index.Parameters, false).(map[string]interface{})
+			header.Indexes[name] = index
+		}
+	}
+
+	exists, err := db.HasCollection(ctx, collection)
+	if err != nil {
+		return fmt.Errorf("failed to check collection %s: %w", collection, err)
+	}
+	if !exists {
+		schema := header.Schema
+		schema.Name = collection
+		if err := db.CreateCollection(ctx, collection, schema); err != nil {
+			return fmt.Errorf("failed to create collection %s: %w", collection, err)
+		}
+	}
+
+	fields := make(map[string]Field, len(header.Schema.Fields))
+	for _, field := range header.Schema.Fields {
+		fields[field.Name] = field
+	}
+
+	var imported int64
+	batch := make([]Record, 0, DefaultScanBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := db.Upsert(ctx, collection, batch); err != nil {
+			return fmt.Errorf("failed to import records into %s: %w", collection, err)
+		}
+		imported += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		var entry dumpEntry
+		if err := decoder.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				if err := flush(); err != nil {
+					return err
+				}
+				return fmt.Errorf("dump is truncated: no trailer after %d records", imported)
+			}
+			return fmt.Errorf("failed to read dump record %d: %w", imported+int64(len(batch))+1, err)
+		}
+
+		if entry.Count != nil {
+			if err := flush(); err != nil {
+				return err
+			}
+			if *entry.Count != imported {
+				return fmt.Errorf("dump is corrupt: trailer counts %d records, read %d", *entry.Count, imported)
+			}
+			break
+		}
+
+		record, err := decodeDumpRecord(entry.Fields, fields)
+		if err != nil {
+			return fmt.Errorf("failed to decode dump record %d: %w", imported+int64(len(batch))+1, err)
+		}
+		batch = append(batch, record)
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !exists {
+		names := make([]string, 0, len(header.Indexes))
+		for name := range header.Indexes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := db.CreateIndex(ctx, collection, name, header.Indexes[name]); err != nil {
+				return fmt.Errorf("failed to create index on %s.%s: %w", collection, name, err)
+			}
+		}
+	}
+	return db.Flush(ctx, collection)
+}
+
+// Migrate copies a collection from one database to another, e.g. from Milvus
+// to chromem, by streaming an Export of src into an Import into dst. The
+// target collection has the same name as the source.
+func Migrate(ctx context.Context, src, dst VectorDB, collection string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(Export(ctx, src, collection, pw))
+	}()
+	err := Import(ctx, dst, collection, pr)
+	pr.CloseWithError(err)
+	return err
+}
+
+// decodeDumpRecord converts the JSON values of a dumped record back into the
+// Go types of the schema fields. Fields missing from the schema are decoded
+// generically.
+func decodeDumpRecord(values map[string]interface{}, fields map[string]Field) (Record, error) {
+	record := Record{Fields: make(map[string]interface{}, len(values))}
+	for name, value := range values {
+		field, ok := fields[name]
+		if !ok || field.DataType != "float_vector" {
+			record.Fields[name] = decodeDumpValue(value, ok && isIntegerType(field.DataType))
+			continue
+		}
+
+		items, ok := value.([]interface{})
+		if !ok {
+			return Record{}, fmt.Errorf("field %s: expected a vector, got %T", name, value)
+		}
+		vector := make(Vector, len(items))
+		for i, item := range items {
+			number, ok := item.(json.Number)
+			if !ok {
+				return Record{}, fmt.Errorf("field %s: expected a number, got %T", name, item)
+			}
+			f, err := number.Float64()
+			if err != nil {
+				return Record{}, fmt.Errorf("field %s: %w", name, err)
+			}
+			vector[i] = f
+		}
+		record.Fields[name] = vector
+	}
+	return record, nil
+}
+
+// decodeDumpValue converts a decoded JSON value into the Go value raggo
+// records hold: whole numbers become int64 and other numbers float64, in
+// nested maps and slices too. With integer set, a number is always int64.
+func decodeDumpValue(value interface{}, integer bool) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		if integer {
+			return int64(f)
+		}
+		return f
+	case map[string]interface{}:
+		decoded := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			decoded[key] = decodeDumpValue(item, false)
+		}
+		return decoded
+	case []interface{}:
+		decoded := make([]interface{}, len(v))
+		for i, item := range v {
+			decoded[i] = decodeDumpValue(item, false)
+		}
+		return decoded
+	default:
+		return value
+	}
+}
+
+// isIntegerType reports whether a schema data type holds integers.
+func isIntegerType(dataType string) bool {
+	switch dataType {
+	case "int64", "int32", "int16", "int8", "int":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,191 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newDumpSource returns a MemoryDB whose indexed "docs" collection holds
+// records 1 and 2, with nested metadata.
+func newDumpSource(t *testing.T) *MemoryDB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := newMemoryDB(&Config{})
+	if err != nil {
+		t.Fatalf("newMemoryDB: %v", err)
+	}
+	schema := Schema{Name: "docs", Fields: []Field{
+		{Name: "ID", DataType: "int64", PrimaryKey: true},
+		{Name: "Embedding", DataType: "float_vector", Dimension: 3},
+		{Name: "Text", DataType: "varchar", MaxLength: 100},
+		{Name: "Metadata", DataType: "json"},
+	}}
+	if err := db.CreateCollection(ctx, "docs", schema); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	records := []Record{
+		{Fields: map[string]interface{}{
+			"ID":        int64(1),
+			"Embedding": Vector{0.1, -0.25, 3},
+			"Text":      "first",
+			"Metadata": map[string]interface{}{
+				"source": "a.txt",
+				"chunk":  int64(0),
+				"score":  0.5,
+				"tags":   []interface{}{"x", int64(2)},
+				"author": map[string]interface{}{"name": "Ada"},
+			},
+		}},
+		{Fields: map[string]interface{}{
+			"ID":        int64(2),
+			"Embedding": Vector{1, 0, 0},
+			"Text":      `quoted "<html>" & unicode é`,
+			"Metadata":  map[string]interface{}{"source": "b.txt", "draft": true},
+		}},
+	}
+	if err := db.Insert(ctx, "docs", records); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	index := Index{Type: "HNSW", Metric: MetricCosine, Parameters: map[string]interface{}{"M": int64(8), "efConstruction": int64(64)}}
+	if err := db.CreateIndex(ctx, "docs", "Embedding", index); err != nil {
+		t.Fatalf("CreateIndex: %v", err)
+	}
+	return db
+}
+
+// getRecords returns the records of the "docs" collection with the given IDs.
+func getRecords(t *testing.T, db VectorDB, ids ...int64) []Record {
+	t.Helper()
+	records, err := db.Get(context.Background(), "docs", ids)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	return records
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := newDumpSource(t)
+	records := getRecords(t, src, 1, 2) // As stored, with vectors in float32 precision
+	srcInfo, err := src.DescribeCollection(ctx, "docs")
+	if err != nil {
+		t.Fatalf("DescribeCollection: %v", err)
+	}
+
+	dst, _ := newMemoryDB(&Config{})
+	if err := Migrate(ctx, src, dst, "docs"); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	info, err := dst.DescribeCollection(ctx, "docs")
+	if err != nil {
+		t.Fatalf("DescribeCollection after Migrate: %v", err)
+	}
+	if !reflect.DeepEqual(info.Schema, srcInfo.Schema) {
+		t.Errorf("schema after Migrate = %+v, want %+v", info.Schema, srcInfo.Schema)
+	}
+	if !reflect.DeepEqual(info.Indexes, srcInfo.Indexes) {
+		t.Errorf("indexes after Migrate = %+v, want %+v", info.Indexes, srcInfo.Indexes)
+	}
+	if got := getRecords(t, dst, 1, 2); !reflect.DeepEqual(got, records) {
+		t.Errorf("records after Migrate = %v, want %v", got, records)
+	}
+
+	// Importing into another name keeps the dumped schema under that name
+	var dump bytes.Buffer
+	if err := Export(ctx, src, "docs", &dump); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if err := Import(ctx, dst, "copy", bytes.NewReader(dump.Bytes())); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if info, err := dst.DescribeCollection(ctx, "copy"); err != nil || info.Schema.Name != "copy" || len(info.Indexes) != 1 {
+		t.Errorf("DescribeCollection(copy) = %+v, %v", info, err)
+	}
+}
+
+func TestImportExisting(t *testing.T) {
+	ctx := context.Background()
+	src := newDumpSource(t)
+	records := getRecords(t, src, 1, 2)
+	var dump bytes.Buffer
+	if err := Export(ctx, src, "docs", &dump); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	// The existing collection has a record the dump replaces and one it lacks
+	dst, _ := newMemoryDB(&Config{})
+	if err := dst.CreateCollection(ctx, "docs", Schema{Name: "docs"}); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	kept := Record{Fields: map[string]interface{}{"ID": int64(3), "Embedding": Vector{0, 0, 1}, "Text": "kept"}}
+	stale := Record{Fields: map[string]interface{}{"ID": int64(1), "Embedding": Vector{0, 1, 0}, "Text": "stale"}}
+	if err := dst.Insert(ctx, "docs", []Record{stale, kept}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	// Records are upserted, so a second import changes nothing
+	for i := 0; i < 2; i++ {
+		if err := Import(ctx, dst, "", bytes.NewReader(dump.Bytes())); err != nil {
+			t.Fatalf("Import %d: %v", i+1, err)
+		}
+	}
+	if count, err := dst.Count(ctx, "docs"); err != nil || count != 3 {
+		t.Errorf("Count = %d, %v, want 3", count, err)
+	}
+	want := append(append([]Record{}, records...), kept)
+	if got := getRecords(t, dst, 1, 2, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("records after Import = %v, want %v", got, want)
+	}
+
+	// The existing collection keeps its schema and gets no index
+	info, err := dst.DescribeCollection(ctx, "docs")
+	if err != nil {
+		t.Fatalf("DescribeCollection: %v", err)
+	}
+	if len(info.Schema.Fields) != 0 || len(info.Indexes) != 0 {
+		t.Errorf("existing collection after Import = %+v, want its schema and no index", info)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	ctx := context.Background()
+	src := newDumpSource(t)
+	var dump bytes.Buffer
+	if err := Export(ctx, src, "docs", &dump); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(dump.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("dump has %d lines, want a header, 2 records and a trailer:\n%s", len(lines), dump.String())
+	}
+
+	tests := []struct {
+		name      string
+		dump      string
+		wantErr   string
+		wantCount int64 // Records written despite the error
+	}{
+		{name: "truncated", dump: strings.Join(lines[:3], ""), wantErr: "dump is truncated: no trailer after 2 records", wantCount: 2},
+		{name: "wrong count", dump: strings.Join(lines[:2], "") + lines[3], wantErr: "trailer counts 2 records, read 1", wantCount: 1},
+		{name: "not a dump", dump: `{"format":"other","version":1}`, wantErr: `not a raggo dump (format "other")`},
+		{name: "future version", dump: `{"format":"raggo-dump","version":99}`, wantErr: "unsupported dump version 99"},
+		{name: "bad vector", dump: lines[0] + `{"fields":{"ID":5,"Embedding":"x"}}`, wantErr: "field Embedding: expected a vector, got string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst, _ := newMemoryDB(&Config{})
+			err := Import(ctx, dst, "", strings.NewReader(tt.dump))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Import error = %v, want one containing %q", err, tt.wantErr)
+			}
+			count, _ := dst.Count(ctx, "docs")
+			if count != tt.wantCount {
+				t.Errorf("Import wrote %d records, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
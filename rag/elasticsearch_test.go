@@ -0,0 +1,472 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// elasticsearchMapping is the mapping of a "docs" index with one
+// three-dimensional l2_norm vector.
+const elasticsearchMapping = `{"docs": {"mappings": {"properties": {
+	"Embedding": {"type": "dense_vector", "dims": 3, "similarity": "l2_norm", "index_options": {"type": "hnsw", "m": 16, "ef_construction": 200}},
+	"Text": {"type": "text"},
+	"Metadata": {"type": "object"}}}}}`
+
+// openSearchMapping is elasticsearchMapping for OpenSearch.
+const openSearchMapping = `{"docs": {"mappings": {"properties": {
+	"Embedding": {"type": "knn_vector", "dimension": 3, "method": {"name": "hnsw", "space_type": "l2", "parameters": {"m": 16, "ef_construction": 200}}},
+	"Text": {"type": "text"},
+	"Metadata": {"type": "object"}}}}}`
+
+// newTestElasticsearch returns an ElasticsearchDB of the given flavor
+// talking to a restServer.
+func newTestElasticsearch(t *testing.T, flavor string, responses map[string][]restResponse) (*ElasticsearchDB, *restServer) {
+	t.Helper()
+	server := newRESTServer(t, responses)
+	db, err := newElasticsearchDB(&Config{Address: server.URL, Parameters: map[string]interface{}{"api_key": "secret"}}, flavor)
+	if err != nil {
+		t.Fatalf("newElasticsearchDB: %v", err)
+	}
+	return db, server
+}
+
+func TestElasticsearchRequests(t *testing.T) {
+	mapping := map[string][]restResponse{"GET /docs/_mapping": reply(elasticsearchMapping)}
+	openSearch := map[string][]restResponse{"GET /docs/_mapping": reply(openSearchMapping)}
+	mappingRequest := restRequest{Method: http.MethodGet, Path: "/docs/_mapping"}
+	schema := Schema{Fields: []Field{
+		{Name: "ID", DataType: "int64", PrimaryKey: true},
+		{Name: "Embedding", DataType: "float_vector", Dimension: 3},
+		{Name: "Text", DataType: "varchar"},
+		{Name: "Metadata", DataType: "json"},
+	}}
+	query := map[string]Vector{"Embedding": {1, 0, 0}}
+
+	tests := []struct {
+		name      string
+		flavor    string
+		responses map[string][]restResponse
+		call      func(ctx context.Context, db *ElasticsearchDB) error
+		want      []restRequest
+	}{
+		{
+			name:   "create collection",
+			flavor: flavorElasticsearch,
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				return db.CreateCollection(ctx, "docs", schema)
+			},
+			want: []restRequest{
+				{Method: http.MethodPut, Path: "/docs", Body: `{"mappings": {
+					"dynamic_templates": [{"metadata_strings": {"path_match": "Metadata.*", "match_mapping_type": "string", "mapping": {"type": "keyword"}}}],
+					"properties": {
+						"Embedding": {"type": "dense_vector", "dims": 3, "index": true, "similarity": "l2_norm",
+							"index_options": {"type": "hnsw", "m": 16, "ef_construction": 200}},
+						"Text": {"type": "text"},
+						"Metadata": {"type": "object"}}}}`},
+			},
+		},
+		{
+			name:   "create OpenSearch collection",
+			flavor: flavorOpenSearch,
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				return db.CreateCollection(ctx, "docs", schema)
+			},
+			want: []restRequest{
+				{Method: http.MethodPut, Path: "/docs", Body: `{
+					"settings": {"index.knn": true},
+					"mappings": {
+						"dynamic_templates": [{"metadata_strings": {"path_match": "Metadata.*", "match_mapping_type": "string", "mapping": {"type": "keyword"}}}],
+						"properties": {
+							"Embedding": {"type": "knn_vector", "dimension": 3,
+								"method": {"name": "hnsw", "engine": "lucene", "space_type": "l2", "parameters": {"m": 16, "ef_construction": 200}}},
+							"Text": {"type": "text"},
+							"Metadata": {"type": "object"}}}}`},
+			},
+		},
+		{
+			name:      "upsert",
+			flavor:    flavorElasticsearch,
+			responses: mapping,
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				return db.Upsert(ctx, "docs", []Record{{Fields: map[string]interface{}{
+					"ID":        int64(7),
+					"Embedding": []float32{1, 2, 3},
+					"Text":      "hello",
+					"Metadata":  map[string]interface{}{"source": "a.txt"},
+				}}})
+			},
+			want: []restRequest{
+				mappingRequest,
+				{Method: http.MethodPost, Path: "/_bulk?refresh=wait_for", Body: `
+					{"index": {"_index": "docs", "_id": "7"}}
+					{"Embedding": [1, 2, 3], "Text": "hello", "Metadata": {"source": "a.txt"}}`},
+			},
+		},
+		{
+			name:   "delete",
+			flavor: flavorElasticsearch,
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				return db.Delete(ctx, "docs", []int64{1, 2})
+			},
+			want: []restRequest{
+				{Method: http.MethodPost, Path: "/docs/_delete_by_query?refresh=true&conflicts=proceed", Body: `{"query": {"ids": {"values": ["1", "2"]}}}`},
+			},
+		},
+		{
+			name:   "delete by filter",
+			flavor: flavorElasticsearch,
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				return db.DeleteByFilter(ctx, "docs", Eq("source", "a.txt"))
+			},
+			want: []restRequest{
+				{Method: http.MethodPost, Path: "/docs/_delete_by_query?refresh=true&conflicts=proceed", Body: `{"query": {"bool": {"filter": [
+					{"term": {"Metadata.source": "a.txt"}}]}}}`},
+			},
+		},
+		{
+			name:      "search",
+			flavor:    flavorElasticsearch,
+			responses: mapping,
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				_, err := db.Search(ctx, "docs", query, 5, "L2", map[string]interface{}{"ef": 64, FilterParam: Gt("page", 2)})
+				return err
+			},
+			want: []restRequest{
+				mappingRequest,
+				{Method: http.MethodPost, Path: "/docs/_search", Body: `{
+					"knn": {"field": "Embedding", "query_vector": [1, 0, 0], "k": 5, "num_candidates": 64,
+						"filter": {"range": {"Metadata.page": {"gt": 2}}}},
+					"size": 5,
+					"_source": {"excludes": ["Embedding"]}}`},
+			},
+		},
+		{
+			name:      "OpenSearch search",
+			flavor:    flavorOpenSearch,
+			responses: openSearch,
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				_, err := db.Search(ctx, "docs", query, 5, "L2", map[string]interface{}{"ef": 64, FilterParam: Gt("page", 2)})
+				return err
+			},
+			want: []restRequest{
+				mappingRequest,
+				{Method: http.MethodPost, Path: "/docs/_search", Body: `{
+					"query": {"knn": {"Embedding": {"vector": [1, 0, 0], "k": 5,
+						"filter": {"range": {"Metadata.page": {"gt": 2}}},
+						"method_parameters": {"ef_search": 64}}}},
+					"size": 5,
+					"_source": {"excludes": ["Embedding"]}}`},
+			},
+		},
+		{
+			name:   "search batch",
+			flavor: flavorElasticsearch,
+			responses: map[string][]restResponse{
+				"GET /docs/_mapping": reply(elasticsearchMapping),
+				"POST /_msearch":     reply(`{"responses": [{"hits": {"hits": []}}, {"hits": {"hits": []}}]}`),
+			},
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				db.SetColumnNames([]string{"Text"})
+				_, err := db.SearchBatch(ctx, "docs", "Embedding", []Vector{{1, 0, 0}, {0, 1, 0}}, 2, "L2", nil)
+				return err
+			},
+			want: []restRequest{
+				mappingRequest,
+				{Method: http.MethodPost, Path: "/_msearch", Body: `
+					{"index": "docs"}
+					{"knn": {"field": "Embedding", "query_vector": [1, 0, 0], "k": 2, "num_candidates": 100}, "size": 2, "_source": ["Text"]}
+					{"index": "docs"}
+					{"knn": {"field": "Embedding", "query_vector": [0, 1, 0], "k": 2, "num_candidates": 100}, "size": 2, "_source": ["Text"]}`},
+			},
+		},
+		{
+			name:      "hybrid search with RRF",
+			flavor:    flavorElasticsearch,
+			responses: mapping,
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				_, err := db.HybridSearch(ctx, "docs", query, 3, "L2",
+					map[string]interface{}{QueryTextParam: "vector search", FilterParam: Eq("lang", "en")}, nil)
+				return err
+			},
+			want: []restRequest{
+				mappingRequest,
+				{Method: http.MethodPost, Path: "/docs/_search", Body: `{
+					"size": 3,
+					"_source": {"excludes": ["Embedding"]},
+					"retriever": {"rrf": {
+						"retrievers": [
+							{"knn": {"field": "Embedding", "query_vector": [1, 0, 0], "k": 3, "num_candidates": 100,
+								"filter": {"term": {"Metadata.lang": "en"}}}},
+							{"standard": {"query": {"bool": {
+								"must": [{"match": {"Text": {"query": "vector search"}}}],
+								"filter": [{"term": {"Metadata.lang": "en"}}]}}}}],
+						"rank_constant": 60,
+						"rank_window_size": 3}}}`},
+			},
+		},
+		{
+			name:      "weighted hybrid search",
+			flavor:    flavorElasticsearch,
+			responses: mapping,
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				_, err := db.HybridSearch(ctx, "docs", query, 3, "L2",
+					map[string]interface{}{QueryTextParam: "vector search"}, NewWeightedReranker(3, 1))
+				return err
+			},
+			want: []restRequest{
+				mappingRequest,
+				{Method: http.MethodPost, Path: "/docs/_search", Body: `{
+					"size": 3,
+					"_source": {"excludes": ["Embedding"]},
+					"knn": [{"field": "Embedding", "query_vector": [1, 0, 0], "k": 3, "num_candidates": 100, "boost": 0.75}],
+					"query": {"bool": {"must": [{"match": {"Text": {"query": "vector search", "boost": 0.25}}}]}}}`},
+			},
+		},
+		{
+			name:      "OpenSearch hybrid search with RRF",
+			flavor:    flavorOpenSearch,
+			responses: openSearch,
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				_, err := db.HybridSearch(ctx, "docs", query, 3, "L2",
+					map[string]interface{}{QueryTextParam: "vector search"}, NewRRFReranker(20))
+				return err
+			},
+			want: []restRequest{
+				mappingRequest,
+				{Method: http.MethodPut, Path: "/_search/pipeline/raggo-rrf-20", Body: `{
+					"description": "Reciprocal Rank Fusion for raggo hybrid search",
+					"phase_results_processors": [{"score-ranker-processor": {"combination": {"technique": "rrf", "rank_constant": 20}}}]}`},
+				{Method: http.MethodPost, Path: "/docs/_search?search_pipeline=raggo-rrf-20", Body: `{
+					"size": 3,
+					"_source": {"excludes": ["Embedding"]},
+					"query": {"hybrid": {"queries": [
+						{"knn": {"Embedding": {"vector": [1, 0, 0], "k": 3}}},
+						{"bool": {"must": [{"match": {"Text": {"query": "vector search"}}}]}}]}}}`},
+			},
+		},
+		{
+			name:   "scan",
+			flavor: flavorElasticsearch,
+			responses: map[string][]restResponse{
+				"GET /docs/_mapping":           reply(elasticsearchMapping),
+				"POST /docs/_search?scroll=1m": reply(`{"_scroll_id": "s1", "hits": {"hits": [{"_id": "1", "_source": {}}]}}`),
+				"POST /_search/scroll":         reply(`{"_scroll_id": "s1", "hits": {"hits": []}}`),
+			},
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				return db.Scan(ctx, "docs", 1, func([]Record) error { return nil })
+			},
+			want: []restRequest{
+				mappingRequest,
+				{Method: http.MethodPost, Path: "/docs/_search?scroll=1m", Body: `{"size": 1, "query": {"match_all": {}}, "sort": ["_doc"]}`},
+				{Method: http.MethodPost, Path: "/_search/scroll", Body: `{"scroll": "1m", "scroll_id": "s1"}`},
+				{Method: http.MethodDelete, Path: "/_search/scroll", Body: `{"scroll_id": "s1"}`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, server := newTestElasticsearch(t, tt.flavor, tt.responses)
+			if err := tt.call(context.Background(), db); err != nil {
+				t.Fatalf("call failed: %v", err)
+			}
+			checkRequests(t, server.received(), tt.want)
+			if got := server.header.Get("Authorization"); got != "ApiKey secret" {
+				t.Errorf("Authorization header = %q, want %q", got, "ApiKey secret")
+			}
+		})
+	}
+}
+
+func TestElasticsearchSearchResults(t *testing.T) {
+	tests := []struct {
+		similarity   string
+		metric       string
+		score        float64
+		wantScore    float64
+		wantDistance float64
+	}{
+		{similarity: "l2_norm", metric: "L2", score: 0.5, wantScore: 0.5, wantDistance: 1},
+		{similarity: "max_inner_product", metric: "IP", score: 4, wantScore: 3, wantDistance: -3},
+		{similarity: "max_inner_product", metric: "IP", score: 0.5, wantScore: -1, wantDistance: 1},
+		{similarity: "cosine", metric: "COSINE", score: 0.9, wantScore: 0.8, wantDistance: 0.2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.similarity+" "+mustJSON(t, tt.score), func(t *testing.T) {
+			db, _ := newTestElasticsearch(t, flavorElasticsearch, map[string][]restResponse{
+				"GET /docs/_mapping": reply(strings.Replace(elasticsearchMapping, "l2_norm", tt.similarity, 1)),
+				"POST /docs/_search": reply(`{"hits": {"hits": [{"_id": "4", "_score": ` + mustJSON(t, tt.score) + `,
+					"_source": {"Text": "hello", "Metadata": {"doc_id": "a.txt#0"}}}]}}`),
+			})
+			results, err := db.Search(context.Background(), "docs", map[string]Vector{"Embedding": {1, 0, 0}}, 1, tt.metric, nil)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			r := results[0]
+			if r.ID != 4 || r.DocID != "a.txt#0" || r.Fields["Text"] != "hello" {
+				t.Errorf("result = %+v, want ID 4, DocID a.txt#0 and Text hello", r)
+			}
+			if math.Abs(r.Score-tt.wantScore) > 1e-9 || math.Abs(r.Distance-tt.wantDistance) > 1e-9 {
+				t.Errorf("score, distance = %v, %v, want %v, %v", r.Score, r.Distance, tt.wantScore, tt.wantDistance)
+			}
+		})
+	}
+}
+
+func TestElasticsearchErrors(t *testing.T) {
+	notFound := restResponse{Status: http.StatusNotFound, Body: `{"error": {"type": "index_not_found_exception", "reason": "no such index [missing]"}, "status": 404}`}
+
+	tests := []struct {
+		name      string
+		responses map[string][]restResponse
+		call      func(ctx context.Context, db *ElasticsearchDB) error
+		wantIs    error  // Sentinel the error must match, if any
+		wantText  string // Text the error must contain
+	}{
+		{
+			name:      "missing index",
+			responses: map[string][]restResponse{"GET /missing/_count": {notFound}},
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				_, err := db.Count(ctx, "missing")
+				return err
+			},
+			wantIs:   ErrCollectionNotFound,
+			wantText: "HTTP 404: index_not_found_exception: no such index [missing]",
+		},
+		{
+			name: "existing index",
+			responses: map[string][]restResponse{
+				"PUT /docs": {{Status: http.StatusBadRequest, Body: `{"error": {"type": "resource_already_exists_exception", "reason": "index [docs] already exists"}}`}},
+			},
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				return db.CreateCollection(ctx, "docs", Schema{Fields: []Field{{Name: "Embedding", DataType: "float_vector", Dimension: 3}}})
+			},
+			wantIs: ErrCollectionExists,
+		},
+		{
+			name: "server error without JSON",
+			responses: map[string][]restResponse{
+				"POST /docs/_delete_by_query?refresh=true&conflicts=proceed": {{Status: http.StatusBadGateway, Body: "upstream unavailable"}},
+			},
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				return db.Delete(ctx, "docs", []int64{1})
+			},
+			wantText: "search engine returned HTTP 502: upstream unavailable",
+		},
+		{
+			name: "bulk item failure",
+			responses: map[string][]restResponse{
+				"GET /docs/_mapping": reply(elasticsearchMapping),
+				"POST /_bulk?refresh=wait_for": reply(`{"errors": true, "items": [{"create": {"_id": "1", "status": 409,
+					"error": {"type": "version_conflict_engine_exception", "reason": "document already exists"}}}]}`),
+			},
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				return db.Insert(ctx, "docs", []Record{{Fields: map[string]interface{}{"ID": int64(1), "Embedding": Vector{1, 2, 3}}}})
+			},
+			wantText: "failed to write record 0 (id 1): version_conflict_engine_exception: document already exists",
+		},
+		{
+			name: "search batch query failure",
+			responses: map[string][]restResponse{
+				"GET /docs/_mapping": reply(elasticsearchMapping),
+				"POST /_msearch": reply(`{"responses": [{"hits": {"hits": []}},
+					{"status": 400, "error": {"type": "search_phase_execution_exception", "reason": "bad query"}}]}`),
+			},
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				_, err := db.SearchBatch(ctx, "docs", "Embedding", []Vector{{1, 0, 0}, {0, 1, 0}}, 2, "L2", nil)
+				return err
+			},
+			wantText: "(query 1): search engine returned HTTP 400: search_phase_execution_exception: bad query",
+		},
+		{
+			name:      "metric mismatch",
+			responses: map[string][]restResponse{"GET /docs/_mapping": reply(elasticsearchMapping)},
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				_, err := db.Search(ctx, "docs", map[string]Vector{"Embedding": {1, 0, 0}}, 1, "COSINE", nil)
+				return err
+			},
+			wantText: "uses L2 and cannot be searched with COSINE",
+		},
+		{
+			name:      "dimension mismatch",
+			responses: map[string][]restResponse{"GET /docs/_mapping": reply(elasticsearchMapping)},
+			call: func(ctx context.Context, db *ElasticsearchDB) error {
+				return db.Insert(ctx, "docs", []Record{{Fields: map[string]interface{}{"ID": int64(1), "Embedding": Vector{1, 2}}}})
+			},
+			wantIs: ErrDimensionMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newTestElasticsearch(t, flavorElasticsearch, tt.responses)
+			err := tt.call(context.Background(), db)
+			if err == nil {
+				t.Fatal("call succeeded, want an error")
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("error %q does not match %v", err, tt.wantIs)
+			}
+			if !strings.Contains(err.Error(), tt.wantText) {
+				t.Errorf("error %q does not contain %q", err, tt.wantText)
+			}
+		})
+	}
+
+	t.Run("has collection", func(t *testing.T) {
+		db, _ := newTestElasticsearch(t, flavorElasticsearch, map[string][]restResponse{"HEAD /missing": {{Status: http.StatusNotFound}}})
+		exists, err := db.HasCollection(context.Background(), "missing")
+		if err != nil || exists {
+			t.Errorf("HasCollection = %v, %v, want false, nil", exists, err)
+		}
+	})
+}
+
+func TestElasticsearchFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *Filter
+		want    string
+		wantErr bool
+	}{
+		{name: "equality", filter: Eq("source", "a.txt"), want: `{"term": {"Metadata.source": "a.txt"}}`},
+		{name: "inequality", filter: Ne("lang", "fr"), want: `{"bool": {"must_not": [{"term": {"Metadata.lang": "fr"}}]}}`},
+		{name: "range", filter: Lte("page", 10), want: `{"range": {"Metadata.page": {"lte": 10}}}`},
+		{name: "in", filter: In("lang", "en", "de"), want: `{"terms": {"Metadata.lang": ["en", "de"]}}`},
+		{name: "not in", filter: NotIn("lang", "fr"), want: `{"bool": {"must_not": [{"terms": {"Metadata.lang": ["fr"]}}]}}`},
+		{name: "metadata contains", filter: Contains("title", "a*b?"), want: `{"wildcard": {"Metadata.title": {"value": "*a\\*b\\?*"}}}`},
+		{name: "text contains", filter: Contains(TextField, "vector search"), want: `{"match_phrase": {"Text": "vector search"}}`},
+		{
+			name:   "nested logic",
+			filter: And(Eq("lang", "en"), Or(Gt("page", 2), Not(Eq("draft", true)))),
+			want: `{"bool": {"filter": [
+				{"term": {"Metadata.lang": "en"}},
+				{"bool": {"minimum_should_match": 1, "should": [
+					{"range": {"Metadata.page": {"gt": 2}}},
+					{"bool": {"must_not": [{"term": {"Metadata.draft": true}}]}}]}}]}}`,
+		},
+		{name: "text equality", filter: Eq(TextField, "hello"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := elasticsearchFilter(tt.filter)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("elasticsearchFilter = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("elasticsearchFilter: %v", err)
+			}
+			if encoded := mustJSON(t, got); !jsonEqual(encoded, tt.want) {
+				t.Errorf("elasticsearchFilter =\n%s\nwant\n%s", encoded, tt.want)
+			}
+		})
+	}
+}
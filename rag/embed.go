@@ -4,7 +4,10 @@ package rag
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/teilomillet/raggo/rag/providers"
 )
@@ -26,6 +29,8 @@ type EmbedderOption func(*EmbedderConfig)
 // Common providers include:
 // - "openai": OpenAI's text-embedding-ada-002 and other models
 // - "cohere": Cohere's embedding models
+// - "ollama": Models served by a local Ollama server (e.g. "nomic-embed-text")
+// - "openai-compatible": Azure OpenAI, vLLM, LiteLLM and other OpenAI-like APIs
 // - "local": Local embedding models
 func SetProvider(provider string) EmbedderOption {
 	return func(c *EmbedderConfig) {
@@ -52,6 +57,23 @@ func SetAPIKey(apiKey string) EmbedderOption {
 	}
 }
 
+// SetHeaders sets extra HTTP headers sent with every embedding request,
+// for gateways that need them. Providers that do not support headers
+// ignore them.
+func SetHeaders(headers map[string]string) EmbedderOption {
+	return func(c *EmbedderConfig) {
+		c.Options["headers"] = headers
+	}
+}
+
+// SetMaxRetries sets how many times a failed embedding request is retried
+// with backoff; 0 disables retries. Providers without retries ignore it.
+func SetMaxRetries(n int) EmbedderOption {
+	return func(c *EmbedderConfig) {
+		c.Options["max_retries"] = n
+	}
+}
+
 // SetOption sets a custom option for the Embedder.
 // This allows for provider-specific configuration options
 // that aren't covered by the standard options.
@@ -98,49 +120,144 @@ type EmbeddedChunk struct {
 	Metadata map[string]interface{} `json:"metadata"`
 }
 
+// Default settings of an EmbeddingService.
+const (
+	// defaultEmbeddingConcurrency is the number of batches embedded at once
+	defaultEmbeddingConcurrency = 4
+	// defaultEmbeddingBatchSize is the number of chunks per EmbedBatch call
+	defaultEmbeddingBatchSize = 100
+)
+
+// FailureMode selects how EmbedChunks handles chunks that fail to embed.
+type FailureMode int
+
+const (
+	// FailFast stops at the first failed batch, cancels the batches still
+	// running and returns its error without results.
+	FailFast FailureMode = iota
+	// CollectErrors embeds every batch and returns all chunks, those that
+	// failed with no embeddings, along with a ChunkErrors listing them.
+	CollectErrors
+)
+
+// ChunkError records why one chunk could not be embedded.
+type ChunkError struct {
+	// Index is the position of the chunk in the slice given to EmbedChunks
+	Index int
+	// Err is the error of the batch the chunk belonged to
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// ChunkErrors is the error EmbedChunks returns in CollectErrors mode when
+// some chunks could not be embedded, ordered by chunk index.
+type ChunkErrors []*ChunkError
+
+// Error implements the error interface.
+func (e ChunkErrors) Error() string {
+	if len(e) == 1 {
+		return fmt.Sprintf("failed to embed 1 chunk: %v", e[0])
+	}
+	return fmt.Sprintf("failed to embed %d chunks, first: %v", len(e), e[0])
+}
+
+// Unwrap returns the errors of the failed chunks, for errors.Is and errors.As.
+func (e ChunkErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
 // EmbeddingService handles the process of converting text chunks into
 // vector embeddings. It encapsulates the embedding provider and provides
 // a high-level interface for embedding operations.
+//
+// Chunks are embedded in batches through the embedder's EmbedBatch, with a
+// bounded number of batches in flight at once, as embedding is dominated by
+// the latency of the provider's API.
 type EmbeddingService struct {
-	embedder providers.Embedder
+	embedder    providers.Embedder
+	concurrency int         // Maximum number of batches embedded at once
+	batchSize   int         // Number of chunks per EmbedBatch call
+	failureMode FailureMode // How failed batches are reported
+}
+
+// EmbeddingServiceOption is a function type for configuring an EmbeddingService.
+type EmbeddingServiceOption func(*EmbeddingService)
+
+// SetEmbeddingConcurrency sets the maximum number of batches embedded at
+// once. The default is 4; values below 1 are ignored.
+func SetEmbeddingConcurrency(concurrency int) EmbeddingServiceOption {
+	return func(s *EmbeddingService) {
+		if concurrency > 0 {
+			s.concurrency = concurrency
+		}
+	}
+}
+
+// SetEmbeddingBatchSize sets the number of chunks passed to each EmbedBatch
+// call. The provider may still split a batch to respect its own request
+// limits. The default is 100; values below 1 are ignored.
+func SetEmbeddingBatchSize(batchSize int) EmbeddingServiceOption {
+	return func(s *EmbeddingService) {
+		if batchSize > 0 {
+			s.batchSize = batchSize
+		}
+	}
+}
+
+// SetEmbeddingFailureMode sets how EmbedChunks handles failed batches.
+// The default is FailFast.
+func SetEmbeddingFailureMode(mode FailureMode) EmbeddingServiceOption {
+	return func(s *EmbeddingService) {
+		s.failureMode = mode
+	}
 }
 
 // NewEmbeddingService creates a new embedding service with the specified embedder.
 // The embedder must be properly configured and ready to generate embeddings.
-func NewEmbeddingService(embedder providers.Embedder) *EmbeddingService {
-	return &EmbeddingService{embedder: embedder}
+func NewEmbeddingService(embedder providers.Embedder, opts ...EmbeddingServiceOption) *EmbeddingService {
+	s := &EmbeddingService{
+		embedder:    embedder,
+		concurrency: defaultEmbeddingConcurrency,
+		batchSize:   defaultEmbeddingBatchSize,
+		failureMode: FailFast,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // EmbedChunks processes a slice of text chunks and generates embeddings for each one.
-// It handles the embedding process in sequence, with debug output for monitoring.
-// The function:
-// 1. Allocates space for the results
-// 2. Processes each chunk through the embedder
-// 3. Creates EmbeddedChunk instances with the results
-// 4. Provides progress information via debug output
+// The chunks are split into batches that up to the configured number of
+// workers embed concurrently, each through one EmbedBatch call. The function:
+// 1. Creates an EmbeddedChunk for every chunk, in chunk order
+// 2. Embeds the batches on the worker pool
+// 3. Stores each embedding under the "default" key of its chunk
+// 4. Reports failed batches according to the failure mode
 //
-// Returns an error if any chunk fails to embed properly.
+// In FailFast mode the first failure cancels the remaining batches and is
+// returned. In CollectErrors mode every chunk is returned, those of failed
+// batches without embeddings, together with a ChunkErrors. Cancelling ctx
+// stops the embedding and returns its error in both modes.
 func (s *EmbeddingService) EmbedChunks(ctx context.Context, chunks []Chunk) ([]EmbeddedChunk, error) {
-	embeddedChunks := make([]EmbeddedChunk, 0, len(chunks))
-
-	// Debug output
-	fmt.Printf("Processing %d chunks for embedding\n", len(chunks))
-
+	embeddedChunks := make([]EmbeddedChunk, len(chunks))
 	for i, chunk := range chunks {
-		// Debug output for each chunk
-		fmt.Printf("Processing chunk %d/%d (length: %d)\n", i+1, len(chunks), len(chunk.Text))
-		fmt.Printf("Chunk preview: %s\n", truncateString(chunk.Text, 100))
-
-		embedding, err := s.embedder.Embed(ctx, chunk.Text)
-		if err != nil {
-			return nil, fmt.Errorf("error embedding chunk %d: %w", i+1, err)
-		}
-
-		embeddedChunk := EmbeddedChunk{
-			Text: chunk.Text,
-			Embeddings: map[string][]float64{
-				"default": embedding,
-			},
+		embeddedChunks[i] = EmbeddedChunk{
+			Text:       chunk.Text,
+			Embeddings: map[string][]float64{},
 			Metadata: map[string]interface{}{
 				"token_size":     chunk.TokenSize,
 				"start_sentence": chunk.StartSentence,
@@ -148,12 +265,62 @@ func (s *EmbeddingService) EmbedChunks(ctx context.Context, chunks []Chunk) ([]E
 				"chunk_index":    i,
 			},
 		}
-		embeddedChunks = append(embeddedChunks, embeddedChunk)
+	}
+
+	batches := (len(chunks) + s.batchSize - 1) / s.batchSize
+	GlobalLogger.Debug("Embedding chunks", "chunks", len(chunks), "batches", batches, "concurrency", s.concurrency)
+
+	var (
+		mu       sync.Mutex
+		failures ChunkErrors
+	)
+	err := runEach(ctx, batches, s.concurrency, func(ctx context.Context, b int) error {
+		start := b * s.batchSize
+		end := min(start+s.batchSize, len(chunks))
+
+		texts := make([]string, end-start)
+		for i := range texts {
+			texts[i] = chunks[start+i].Text
+		}
 
-		// Debug output for successful embedding
-		fmt.Printf("Successfully embedded chunk %d (embedding size: %d)\n", i+1, len(embedding))
+		embeddings, err := s.embedder.EmbedBatch(ctx, texts)
+		if err == nil && len(embeddings) != len(texts) {
+			err = fmt.Errorf("embedder returned %d embeddings for %d chunks", len(embeddings), len(texts))
+		}
+		var tooLong *providers.InputTooLongError
+		if errors.As(err, &tooLong) {
+			// Number the input by chunk rather than by position in the batch
+			err = &providers.InputTooLongError{Index: start + tooLong.Index, Tokens: tooLong.Tokens, Limit: tooLong.Limit}
+		}
+		if err != nil {
+			if s.failureMode == FailFast || ctx.Err() != nil {
+				return fmt.Errorf("error embedding chunks %d to %d: %w", start, end-1, err)
+			}
+			GlobalLogger.Debug("Failed to embed batch", "first", start, "last", end-1, "error", err)
+			mu.Lock()
+			for i := start; i < end; i++ {
+				failures = append(failures, &ChunkError{Index: i, Err: err})
+			}
+			mu.Unlock()
+			return nil
+		}
+
+		for i, embedding := range embeddings {
+			embeddedChunks[start+i].Embeddings["default"] = embedding
+		}
+		GlobalLogger.Debug("Embedded batch", "first", start, "last", end-1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	if len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool {
+			return failures[i].Index < failures[j].Index
+		})
+		return embeddedChunks, failures
+	}
 	return embeddedChunks, nil
 }
 
@@ -0,0 +1,199 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/teilomillet/raggo/rag/providers"
+)
+
+// batchEmbedder is a providers.Embedder whose batches are embedded by a
+// test function.
+type batchEmbedder struct {
+	embed func(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+func (e batchEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	embeddings, err := e.embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+func (e batchEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	return e.embed(ctx, texts)
+}
+
+func (e batchEmbedder) GetDimension() (int, error) {
+	return 1, nil
+}
+
+// numberChunks returns n chunks whose texts are their indices.
+func numberChunks(n int) []Chunk {
+	chunks := make([]Chunk, n)
+	for i := range chunks {
+		chunks[i] = Chunk{Text: strconv.Itoa(i)}
+	}
+	return chunks
+}
+
+// numberEmbeddings embeds each number text as a one-dimensional vector
+// holding the number.
+func numberEmbeddings(texts []string) [][]float64 {
+	embeddings := make([][]float64, len(texts))
+	for i, text := range texts {
+		n, _ := strconv.Atoi(text)
+		embeddings[i] = []float64{float64(n)}
+	}
+	return embeddings
+}
+
+func TestEmbedChunksOrder(t *testing.T) {
+	// The first batch only finishes once the last one has
+	lastDone := make(chan struct{})
+	embedder := batchEmbedder{embed: func(ctx context.Context, texts []string) ([][]float64, error) {
+		switch texts[0] {
+		case "0":
+			<-lastDone
+		case "4":
+			defer close(lastDone)
+		}
+		return numberEmbeddings(texts), nil
+	}}
+	service := NewEmbeddingService(embedder, SetEmbeddingBatchSize(2), SetEmbeddingConcurrency(3))
+
+	embedded, err := service.EmbedChunks(context.Background(), numberChunks(5))
+	if err != nil {
+		t.Fatalf("EmbedChunks: %v", err)
+	}
+	if len(embedded) != 5 {
+		t.Fatalf("EmbedChunks returned %d chunks, want 5", len(embedded))
+	}
+	for i, chunk := range embedded {
+		if chunk.Text != strconv.Itoa(i) || chunk.Metadata["chunk_index"] != i {
+			t.Errorf("chunk %d is %q with index %v", i, chunk.Text, chunk.Metadata["chunk_index"])
+		}
+		if got := chunk.Embeddings["default"]; !reflect.DeepEqual(got, []float64{float64(i)}) {
+			t.Errorf("chunk %d has embedding %v", i, got)
+		}
+	}
+}
+
+func TestEmbedChunksFailFast(t *testing.T) {
+	// Batch 1 fails once the other batches are in flight, waiting for
+	// cancellation
+	var inFlight sync.WaitGroup
+	inFlight.Add(2)
+	var cancelled atomic.Int32
+	embedder := batchEmbedder{embed: func(ctx context.Context, texts []string) ([][]float64, error) {
+		if texts[0] == "1" {
+			inFlight.Wait()
+			return nil, errors.New("rate limited")
+		}
+		inFlight.Done()
+		<-ctx.Done()
+		cancelled.Add(1)
+		return nil, ctx.Err()
+	}}
+	service := NewEmbeddingService(embedder, SetEmbeddingBatchSize(1), SetEmbeddingConcurrency(3))
+
+	embedded, err := service.EmbedChunks(context.Background(), numberChunks(3))
+	if err == nil || err.Error() != "error embedding chunks 1 to 1: rate limited" {
+		t.Errorf("EmbedChunks error = %v, want the failure of batch 1", err)
+	}
+	if embedded != nil {
+		t.Errorf("EmbedChunks returned %d chunks with the error, want none", len(embedded))
+	}
+	if n := cancelled.Load(); n != 2 {
+		t.Errorf("%d batches in flight were cancelled, want 2", n)
+	}
+}
+
+func TestEmbedChunksCollectErrors(t *testing.T) {
+	// Batches of two chunks: [0 1] succeeds, [2 3] fails after [4], whose
+	// input is too long
+	lastFailed := make(chan struct{})
+	embedder := batchEmbedder{embed: func(ctx context.Context, texts []string) ([][]float64, error) {
+		switch texts[0] {
+		case "2":
+			<-lastFailed
+			return nil, errors.New("server error")
+		case "4":
+			defer close(lastFailed)
+			return nil, &providers.InputTooLongError{Index: 0, Tokens: 9000, Limit: 8191}
+		}
+		return numberEmbeddings(texts), nil
+	}}
+	service := NewEmbeddingService(embedder, SetEmbeddingBatchSize(2), SetEmbeddingConcurrency(3), SetEmbeddingFailureMode(CollectErrors))
+
+	embedded, err := service.EmbedChunks(context.Background(), numberChunks(5))
+	var failures ChunkErrors
+	if !errors.As(err, &failures) {
+		t.Fatalf("EmbedChunks error = %v, want ChunkErrors", err)
+	}
+	var indices []int
+	for _, failure := range failures {
+		indices = append(indices, failure.Index)
+	}
+	if !reflect.DeepEqual(indices, []int{2, 3, 4}) {
+		t.Errorf("failed chunks = %v, want 2, 3 and 4 in order", indices)
+	}
+	if !strings.HasPrefix(err.Error(), "failed to embed 3 chunks, first: chunk 2: server error") {
+		t.Errorf("error = %q", err)
+	}
+
+	// The input is numbered by chunk rather than by position in its batch
+	var tooLong *providers.InputTooLongError
+	if !errors.As(err, &tooLong) || tooLong.Index != 4 {
+		t.Errorf("InputTooLongError = %+v, want the one of chunk 4", tooLong)
+	}
+
+	if len(embedded) != 5 {
+		t.Fatalf("EmbedChunks returned %d chunks, want all 5", len(embedded))
+	}
+	for i, chunk := range embedded {
+		_, ok := chunk.Embeddings["default"]
+		if want := i < 2; ok != want {
+			t.Errorf("chunk %d has an embedding: %v, want %v", i, ok, want)
+		}
+	}
+}
+
+func TestEmbedChunksCancel(t *testing.T) {
+	for name, mode := range map[string]FailureMode{"fail fast": FailFast, "collect errors": CollectErrors} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Each batch waits for cancellation; the first one cancels
+			var calls atomic.Int32
+			embedder := batchEmbedder{embed: func(ctx context.Context, texts []string) ([][]float64, error) {
+				if calls.Add(1) == 1 {
+					cancel()
+				}
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}}
+			service := NewEmbeddingService(embedder, SetEmbeddingBatchSize(1), SetEmbeddingConcurrency(2), SetEmbeddingFailureMode(mode))
+
+			_, err := service.EmbedChunks(ctx, numberChunks(10))
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("EmbedChunks error = %v, want context.Canceled", err)
+			}
+			var failures ChunkErrors
+			if errors.As(err, &failures) {
+				t.Errorf("EmbedChunks returned ChunkErrors %v on cancellation", failures)
+			}
+			if n := calls.Load(); n > 2 {
+				t.Errorf("%d batches started, want no more after cancellation than the 2 workers held", n)
+			}
+		})
+	}
+}
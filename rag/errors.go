@@ -0,0 +1,140 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Errors returned by every VectorDB backend. They are wrapped with details
+// about the collection, record or field involved, so test for them with
+// errors.Is.
+var (
+	// ErrCollectionNotFound means the collection does not exist
+	ErrCollectionNotFound = errors.New("collection not found")
+	// ErrCollectionExists means a collection with that name already exists
+	ErrCollectionExists = errors.New("collection already exists")
+	// ErrMissingField means a record lacks a field the schema requires
+	ErrMissingField = errors.New("missing required field")
+	// ErrFieldType means a value does not have the type its field declares
+	ErrFieldType = errors.New("invalid field type")
+	// ErrDimensionMismatch means a vector's length differs from its field's dimension
+	ErrDimensionMismatch = errors.New("vector dimension mismatch")
+	// ErrFieldTooLong means a varchar value is longer than its field's MaxLength
+	ErrFieldTooLong = errors.New("field value too long")
+	// ErrUnsupportedDataType means a schema uses a data type the backend cannot store
+	ErrUnsupportedDataType = errors.New("unsupported data type")
+)
+
+// collectionNotFound returns ErrCollectionNotFound for the named collection.
+func collectionNotFound(name string) error {
+	return fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+}
+
+// ValidateRecords checks records against the schema of the collection they
+// are written to. Backends call it on every Insert and Upsert, before writing
+// anything, so that a bad record fails the whole batch. A record must:
+//   - hold every schema field, except an AutoID primary key and JSON fields
+//   - hold vectors (Vector, []float64 or []float32) whose length is the
+//     field's Dimension, when it is set
+//   - hold strings no longer than a varchar field's MaxLength in bytes,
+//     when it is set
+//   - hold integers in integer fields, numbers in float fields and booleans
+//     in bool fields
+//
+// Fields missing from the schema are not checked. The error wraps
+// ErrMissingField, ErrFieldType, ErrDimensionMismatch or ErrFieldTooLong.
+func ValidateRecords(schema Schema, records []Record) error {
+	for i, record := range records {
+		for _, field := range schema.Fields {
+			value, ok := record.Fields[field.Name]
+			if !ok || value == nil {
+				if (field.PrimaryKey && field.AutoID) || field.DataType == "json" {
+					continue
+				}
+				return fmt.Errorf("record %d: %w: %s", i, ErrMissingField, field.Name)
+			}
+			if err := validateValue(field, value); err != nil {
+				return fmt.Errorf("record %d: field %s: %w", i, field.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateQueryVectors checks that query vectors have the dimension of the
+// schema fields they search. The error wraps ErrDimensionMismatch.
+func validateQueryVectors(schema Schema, vectors map[string]Vector) error {
+	for _, field := range schema.Fields {
+		vector, ok := vectors[field.Name]
+		if ok && field.DataType == "float_vector" && field.Dimension > 0 && len(vector) != field.Dimension {
+			return fmt.Errorf("query vector %s: %w: expected %d, got %d", field.Name, ErrDimensionMismatch, field.Dimension, len(vector))
+		}
+	}
+	return nil
+}
+
+// validateValue checks one value against the field that holds it.
+func validateValue(field Field, value interface{}) error {
+	switch field.DataType {
+	case "float_vector":
+		length, ok := vectorLength(value)
+		if !ok {
+			return fmt.Errorf("%w: expected a vector, got %T", ErrFieldType, value)
+		}
+		if field.Dimension > 0 && length != field.Dimension {
+			return fmt.Errorf("%w: expected %d, got %d", ErrDimensionMismatch, field.Dimension, length)
+		}
+	case "varchar", "string":
+		var length int
+		switch v := value.(type) {
+		case string:
+			length = len(v)
+		case map[string]interface{}:
+			// Stored as JSON text by backends that keep metadata in a varchar
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrFieldType, err)
+			}
+			length = len(encoded)
+		default:
+			return fmt.Errorf("%w: expected a string, got %T", ErrFieldType, value)
+		}
+		if field.MaxLength > 0 && length > field.MaxLength {
+			return fmt.Errorf("%w: %d bytes, maximum %d", ErrFieldTooLong, length, field.MaxLength)
+		}
+	case "int64", "int32", "int16", "int8", "int":
+		switch value.(type) {
+		case int, int8, int16, int32, int64, uint8, uint16, uint32:
+		default:
+			return fmt.Errorf("%w: expected an integer, got %T", ErrFieldType, value)
+		}
+	case "float", "double":
+		switch value.(type) {
+		case float32, float64, int, int8, int16, int32, int64:
+		default:
+			return fmt.Errorf("%w: expected a number, got %T", ErrFieldType, value)
+		}
+	case "bool":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%w: expected a bool, got %T", ErrFieldType, value)
+		}
+	}
+	return nil
+}
+
+// vectorLength returns the length of a vector value, and whether the value
+// is a vector at all.
+func vectorLength(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case Vector:
+		return len(v), true
+	case []float64:
+		return len(v), true
+	case []float32:
+		return len(v), true
+	default:
+		return 0, false
+	}
+}
@@ -4,6 +4,7 @@ package rag
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -34,6 +35,14 @@ type ExampleDB struct {
 
 // newExampleDB creates a new ExampleDB instance with the given configuration.
 // Initialize your database connection and any required resources here.
+//
+// Register your backend in an init function so that NewVectorDB (and every
+// raggo constructor) can create it by type name, declaring what it supports:
+//
+//	func init() {
+//	    Register("example", func(cfg *Config) (VectorDB, error) { return newExampleDB(cfg) },
+//	        Capabilities{Filters: true})
+//	}
 func newExampleDB(cfg *Config) (*ExampleDB, error) {
 	// Get dimension from config parameters (example)
 	dimension, ok := cfg.Parameters["dimension"].(int)
@@ -92,7 +101,7 @@ func (db *ExampleDB) CreateCollection(ctx context.Context, name string, schema S
 
 	// Validate collection doesn't exist
 	if _, exists := db.collections[name]; exists {
-		return fmt.Errorf("collection %s already exists", name)
+		return fmt.Errorf("%w: %s", ErrCollectionExists, name)
 	}
 
 	// Initialize your collection here
@@ -117,6 +126,12 @@ func (db *ExampleDB) DropCollection(ctx context.Context, name string) error {
 
 // Insert adds new records to a collection.
 func (db *ExampleDB) Insert(ctx context.Context, collectionName string, data []Record) error {
+	// Validate the records against the collection's schema first, and
+	// return the sentinel errors (ErrCollectionNotFound, ...) wrapped:
+	// if err := ValidateRecords(schema, data); err != nil {
+	//     return err
+	// }
+
 	// Example vector conversion if needed:
 	// vectors := make([][]float32, len(data))
 	// for i, record := range data {
@@ -127,12 +142,59 @@ func (db *ExampleDB) Insert(ctx context.Context, collectionName string, data []R
 	return nil
 }
 
+// Upsert inserts records, replacing existing records that share the same ID.
+func (db *ExampleDB) Upsert(ctx context.Context, collectionName string, data []Record) error {
+	// Add your upsert logic here. If your database has no native upsert,
+	// delete the existing IDs and insert the new records.
+	return nil
+}
+
+// Delete removes records by ID.
+func (db *ExampleDB) Delete(ctx context.Context, collectionName string, ids []int64) error {
+	// Add your delete logic here
+	return nil
+}
+
+// DeleteByFilter removes records whose metadata matches the filter.
+func (db *ExampleDB) DeleteByFilter(ctx context.Context, collectionName string, filter *Filter) error {
+	// Translate the filter into your database's query language here
+	return fmt.Errorf("delete by filter not supported")
+}
+
+// Get retrieves records by ID.
+func (db *ExampleDB) Get(ctx context.Context, collectionName string, ids []int64) ([]Record, error) {
+	// Add your lookup logic here
+	return nil, fmt.Errorf("not implemented")
+}
+
+// Scan calls fn with every record of a collection, in batches.
+func (db *ExampleDB) Scan(ctx context.Context, collectionName string, batchSize int, fn func([]Record) error) error {
+	// Page through the collection here, e.g. with a cursor or by keyset on
+	// the primary key, reading scanBatchSize(batchSize) records at a time.
+	// Return the records with vectors, in the same layout as Get.
+	return fmt.Errorf("not implemented")
+}
+
 // Search performs vector similarity search.
 func (db *ExampleDB) Search(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}) ([]SearchResult, error) {
 	// Example implementation steps:
 	// 1. Convert vectors if needed
-	// 2. Perform search
-	// 3. Format results
+	// 2. Translate the optional filter (filterFromParams(searchParams))
+	//    into your database's query language
+	// 3. Perform search
+	// 4. Format results, setting Score ("higher is more similar", see
+	//    ScoreFromDistance), the raw Distance and the DocID stored in the
+	//    Metadata under DocIDKey
+
+	return nil, fmt.Errorf("not implemented")
+}
+
+// SearchBatch searches field with each query vector, returning one result
+// list per query.
+func (db *ExampleDB) SearchBatch(ctx context.Context, collectionName, field string, queries []Vector, topK int, metricType string, searchParams map[string]interface{}) ([][]SearchResult, error) {
+	// Send every query in one request if your database supports it.
+	// Otherwise run Search for each query concurrently with
+	// searchEach(ctx, queries, defaultSearchBatchConcurrency, ...).
 
 	return nil, fmt.Errorf("not implemented")
 }
@@ -159,6 +221,41 @@ func (db *ExampleDB) LoadCollection(ctx context.Context, name string) error {
 	return nil
 }
 
+// ListCollections returns the names of all collections, sorted.
+func (db *ExampleDB) ListCollections(ctx context.Context) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	names := make([]string, 0, len(db.collections))
+	for name := range db.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DescribeCollection returns the schema and indexes of a collection.
+// If your database does not store schemas, report the fields raggo writes.
+func (db *ExampleDB) DescribeCollection(ctx context.Context, name string) (*CollectionInfo, error) {
+	return &CollectionInfo{Name: name, Schema: documentSchema(name, db.dimension), Indexes: map[string]Index{}}, nil
+}
+
+// Count returns the number of records in a collection.
+func (db *ExampleDB) Count(ctx context.Context, collectionName string) (int64, error) {
+	// Add your count logic here
+	return 0, fmt.Errorf("not implemented")
+}
+
+// Stats reports the size and index status of a collection. Use -1 for a
+// StorageSize your database cannot report.
+func (db *ExampleDB) Stats(ctx context.Context, collectionName string) (*CollectionStats, error) {
+	count, err := db.Count(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	return &CollectionStats{Count: count, Dimension: db.dimension, StorageSize: -1, IndexStatus: IndexStatusNone}, nil
+}
+
 // SetColumnNames configures which fields to return in search results.
 func (db *ExampleDB) SetColumnNames(names []string) {
 	db.columnNames = names
@@ -0,0 +1,822 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FilterParam is the searchParams key used to pass a metadata filter to
+// Search and HybridSearch. The value may be a *Filter or a filter expression
+// string, which is parsed with ParseFilter.
+//
+// Example:
+//
+//	params := map[string]interface{}{
+//	    "type":           "HNSW",
+//	    "ef":             64,
+//	    rag.FilterParam: `customer == "acme" AND chunk < 10`,
+//	}
+const FilterParam = "filter"
+
+// TextField is the reserved filter field name that targets the document text
+// (the record's "Text" field) instead of a metadata key.
+const TextField = "Text"
+
+// FilterOp identifies the operation performed by a Filter node.
+type FilterOp string
+
+const (
+	// OpAnd matches when all child filters match
+	OpAnd FilterOp = "AND"
+	// OpOr matches when at least one child filter matches
+	OpOr FilterOp = "OR"
+	// OpNot matches when its single child filter does not match
+	OpNot FilterOp = "NOT"
+	// OpEq matches when the field equals the value
+	OpEq FilterOp = "=="
+	// OpNe matches when the field is missing or differs from the value, on
+	// every backend: Ne(field, v) is the same as Not(Eq(field, v))
+	OpNe FilterOp = "!="
+	// OpLt matches when the field is less than the value
+	OpLt FilterOp = "<"
+	// OpLte matches when the field is less than or equal to the value
+	OpLte FilterOp = "<="
+	// OpGt matches when the field is greater than the value
+	OpGt FilterOp = ">"
+	// OpGte matches when the field is greater than or equal to the value
+	OpGte FilterOp = ">="
+	// OpIn matches when the field equals one of the listed values
+	OpIn FilterOp = "IN"
+	// OpNotIn matches when the field is missing or equals none of the listed
+	// values, on every backend: NotIn(field, vs...) is Not(In(field, vs...))
+	OpNotIn FilterOp = "NOT IN"
+	// OpContains matches when the field is a string containing the value
+	OpContains FilterOp = "CONTAINS"
+)
+
+// Filter is a backend-neutral boolean expression over a record's metadata.
+// Leaf nodes compare a metadata key (Field) with a Value; inner nodes combine
+// child Filters with AND, OR or NOT. Each backend translates a Filter into its
+// own form: a Milvus boolean expression, chromem where/whereDocument maps, or
+// in-process evaluation for MemoryDB.
+//
+// Filters can be built programmatically:
+//
+//	f := rag.And(rag.Eq("source", "x"), rag.Lt("chunk", 10), rag.In("tags", "a", "b"))
+//
+// or parsed from an expression:
+//
+//	f, err := rag.ParseFilter(`source == "x" AND chunk < 10 AND tags IN ["a", "b"]`)
+type Filter struct {
+	// Op is the operation performed by this node
+	Op FilterOp
+	// Field is the metadata key compared by leaf nodes (or TextField)
+	Field string
+	// Value is the comparison operand; a []interface{} for IN and NOT IN
+	Value interface{}
+	// Filters holds the children of AND, OR and NOT nodes
+	Filters []*Filter
+}
+
+// Eq returns a filter matching records whose field equals value.
+func Eq(field string, value interface{}) *Filter {
+	return &Filter{Op: OpEq, Field: field, Value: value}
+}
+
+// Ne returns a filter matching records whose field differs from value or
+// that have no such field.
+func Ne(field string, value interface{}) *Filter {
+	return &Filter{Op: OpNe, Field: field, Value: value}
+}
+
+// Lt returns a filter matching records whose field is less than value.
+func Lt(field string, value interface{}) *Filter {
+	return &Filter{Op: OpLt, Field: field, Value: value}
+}
+
+// Lte returns a filter matching records whose field is at most value.
+func Lte(field string, value interface{}) *Filter {
+	return &Filter{Op: OpLte, Field: field, Value: value}
+}
+
+// Gt returns a filter matching records whose field is greater than value.
+func Gt(field string, value interface{}) *Filter {
+	return &Filter{Op: OpGt, Field: field, Value: value}
+}
+
+// Gte returns a filter matching records whose field is at least value.
+func Gte(field string, value interface{}) *Filter {
+	return &Filter{Op: OpGte, Field: field, Value: value}
+}
+
+// In returns a filter matching records whose field equals one of values.
+func In(field string, values ...interface{}) *Filter {
+	return &Filter{Op: OpIn, Field: field, Value: values}
+}
+
+// NotIn returns a filter matching records whose field equals none of values
+// or that have no such field.
+func NotIn(field string, values ...interface{}) *Filter {
+	return &Filter{Op: OpNotIn, Field: field, Value: values}
+}
+
+// Contains returns a filter matching records whose field is a string that
+// contains substr. Use TextField to search the document text itself.
+func Contains(field, substr string) *Filter {
+	return &Filter{Op: OpContains, Field: field, Value: substr}
+}
+
+// And returns a filter matching records that match all of filters.
+func And(filters ...*Filter) *Filter {
+	return &Filter{Op: OpAnd, Filters: filters}
+}
+
+// Or returns a filter matching records that match at least one of filters.
+func Or(filters ...*Filter) *Filter {
+	return &Filter{Op: OpOr, Filters: filters}
+}
+
+// Not returns a filter matching records that do not match filter.
+func Not(filter *Filter) *Filter {
+	return &Filter{Op: OpNot, Filters: []*Filter{filter}}
+}
+
+// String renders the filter in the expression syntax accepted by ParseFilter.
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	switch f.Op {
+	case OpAnd, OpOr:
+		parts := make([]string, len(f.Filters))
+		for i, child := range f.Filters {
+			parts[i] = "(" + child.String() + ")"
+		}
+		return strings.Join(parts, " "+string(f.Op)+" ")
+	case OpNot:
+		if len(f.Filters) != 1 {
+			return "NOT ()"
+		}
+		return "NOT (" + f.Filters[0].String() + ")"
+	case OpIn, OpNotIn:
+		values := filterValues(f.Value)
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = formatFilterLiteral(v)
+		}
+		return fmt.Sprintf("%s %s [%s]", f.Field, f.Op, strings.Join(parts, ", "))
+	default:
+		return fmt.Sprintf("%s %s %s", f.Field, f.Op, formatFilterLiteral(f.Value))
+	}
+}
+
+// Validate checks that the filter is well formed: leaves have a field,
+// logical nodes have children and every operation is known.
+func (f *Filter) Validate() error {
+	if f == nil {
+		return fmt.Errorf("filter is nil")
+	}
+	switch f.Op {
+	case OpAnd, OpOr:
+		if len(f.Filters) == 0 {
+			return fmt.Errorf("%s filter requires at least one child", f.Op)
+		}
+		for _, child := range f.Filters {
+			if err := child.Validate(); err != nil {
+				return err
+			}
+		}
+	case OpNot:
+		if len(f.Filters) != 1 {
+			return fmt.Errorf("NOT filter requires exactly one child")
+		}
+		return f.Filters[0].Validate()
+	case OpEq, OpNe, OpLt, OpLte, OpGt, OpGte, OpIn, OpNotIn, OpContains:
+		if f.Field == "" {
+			return fmt.Errorf("%s filter requires a field", f.Op)
+		}
+		if f.Op == OpContains {
+			if _, ok := f.Value.(string); !ok {
+				return fmt.Errorf("CONTAINS filter on %s requires a string value", f.Field)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported filter operation: %q", f.Op)
+	}
+	return nil
+}
+
+// Matches evaluates the filter against a record. Field names refer to keys of
+// the record's Metadata map, except TextField which refers to the record text.
+// A nil filter matches every record.
+func (f *Filter) Matches(record Record) bool {
+	if f == nil {
+		return true
+	}
+	metadata := record.Fields["Metadata"]
+	return f.eval(func(field string) (interface{}, bool) {
+		if field == TextField {
+			text, ok := record.Fields["Text"]
+			return text, ok
+		}
+		return lookupMetadata(metadata, field)
+	})
+}
+
+// eval evaluates the filter using lookup to resolve field values.
+func (f *Filter) eval(lookup func(field string) (interface{}, bool)) bool {
+	switch f.Op {
+	case OpAnd:
+		for _, child := range f.Filters {
+			if !child.eval(lookup) {
+				return false
+			}
+		}
+		return true
+	case OpOr:
+		for _, child := range f.Filters {
+			if child.eval(lookup) {
+				return true
+			}
+		}
+		return false
+	case OpNot:
+		return len(f.Filters) == 1 && !f.Filters[0].eval(lookup)
+	}
+
+	got, exists := lookup(f.Field)
+	switch f.Op {
+	case OpEq:
+		return exists && filterEqual(got, f.Value)
+	case OpNe:
+		return !exists || !filterEqual(got, f.Value)
+	case OpLt, OpLte, OpGt, OpGte:
+		if !exists {
+			return false
+		}
+		cmp, ok := compareFilterValues(got, f.Value)
+		if !ok {
+			return false
+		}
+		switch f.Op {
+		case OpLt:
+			return cmp < 0
+		case OpLte:
+			return cmp <= 0
+		case OpGt:
+			return cmp > 0
+		default:
+			return cmp >= 0
+		}
+	case OpIn, OpNotIn:
+		found := exists && filterIn(got, filterValues(f.Value))
+		if f.Op == OpIn {
+			return found
+		}
+		return !found
+	case OpContains:
+		s, ok := got.(string)
+		substr, _ := f.Value.(string)
+		return exists && ok && strings.Contains(s, substr)
+	}
+	return false
+}
+
+// lookupMetadata resolves a key in either of the metadata representations
+// used by the backends (map[string]interface{} or map[string]string).
+func lookupMetadata(metadata interface{}, key string) (interface{}, bool) {
+	switch m := metadata.(type) {
+	case map[string]interface{}:
+		v, ok := m[key]
+		return v, ok
+	case map[string]string:
+		v, ok := m[key]
+		return v, ok
+	default:
+		return nil, false
+	}
+}
+
+// filterIn reports whether got equals one of values. When got is itself a
+// list (e.g. a "tags" metadata entry), it matches if any element is listed.
+func filterIn(got interface{}, values []interface{}) bool {
+	candidates := []interface{}{got}
+	if list, ok := toInterfaceSlice(got); ok {
+		candidates = list
+	}
+	for _, candidate := range candidates {
+		for _, v := range values {
+			if filterEqual(candidate, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filterEqual compares two filter operands, treating numbers of different
+// types (and numeric strings, as stored by chromem) as equal when their
+// values are equal.
+func filterEqual(a, b interface{}) bool {
+	if cmp, ok := compareFilterValues(a, b); ok {
+		return cmp == 0
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// compareFilterValues orders two operands. Numbers are compared numerically,
+// strings lexicographically. The second return value is false when the
+// operands cannot be ordered.
+func compareFilterValues(a, b interface{}) (int, bool) {
+	af, aNum := toFloat(a)
+	bf, bNum := toFloat(b)
+	if aNum && bNum {
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	as, aStr := a.(string)
+	bs, bStr := b.(string)
+	if aStr && bStr {
+		return strings.Compare(as, bs), true
+	}
+
+	// Metadata stored as strings (chromem) compared against a number literal
+	if aStr && bNum {
+		if parsed, err := strconv.ParseFloat(as, 64); err == nil {
+			return compareFilterValues(parsed, bf)
+		}
+	}
+	if bStr && aNum {
+		if parsed, err := strconv.ParseFloat(bs, 64); err == nil {
+			return compareFilterValues(af, parsed)
+		}
+	}
+	return 0, false
+}
+
+// toFloat converts any Go numeric value to float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// toInterfaceSlice converts any slice (other than a string) to []interface{}.
+func toInterfaceSlice(v interface{}) ([]interface{}, bool) {
+	if list, ok := v.([]interface{}); ok {
+		return list, true
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, true
+}
+
+// filterValues returns the operand list of an IN or NOT IN filter.
+func filterValues(v interface{}) []interface{} {
+	if list, ok := toInterfaceSlice(v); ok {
+		return list
+	}
+	return []interface{}{v}
+}
+
+// formatFilterLiteral renders a value as an expression literal.
+func formatFilterLiteral(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return strconv.Quote(val)
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// flattenAnd returns the conjuncts of a filter, flattening nested ANDs.
+func flattenAnd(f *Filter) []*Filter {
+	if f.Op != OpAnd {
+		return []*Filter{f}
+	}
+	var conjuncts []*Filter
+	for _, child := range f.Filters {
+		conjuncts = append(conjuncts, flattenAnd(child)...)
+	}
+	return conjuncts
+}
+
+// filterFromParams extracts the optional filter from search parameters.
+// It accepts a *Filter, a Filter value or an expression string.
+func filterFromParams(params map[string]interface{}) (*Filter, error) {
+	raw, ok := params[FilterParam]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	var f *Filter
+	switch v := raw.(type) {
+	case *Filter:
+		f = v
+	case Filter:
+		f = &v
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		parsed, err := ParseFilter(v)
+		if err != nil {
+			return nil, err
+		}
+		f = parsed
+	default:
+		return nil, fmt.Errorf("unsupported filter type %T", raw)
+	}
+
+	if f == nil {
+		return nil, nil
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ParseFilter parses a filter expression such as
+//
+//	source == "x" AND chunk < 10 AND tags IN ["a", "b"]
+//
+// The grammar supports:
+//   - comparisons: ==, =, !=, <, <=, >, >=
+//   - membership: IN [...], NOT IN [...]
+//   - substring match: CONTAINS "..."
+//   - logical operators: AND, OR, NOT (or &&, ||, !) and parentheses
+//   - literals: double or single quoted strings, numbers, true, false
+//
+// Keywords are case-insensitive. Field names may contain letters, digits,
+// underscores, dots and dashes.
+func ParseFilter(expr string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != filterTokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d in filter", tok.text, tok.pos)
+	}
+	return f, nil
+}
+
+type filterTokenKind int
+
+const (
+	filterTokEOF filterTokenKind = iota
+	filterTokIdent
+	filterTokString
+	filterTokNumber
+	filterTokOp
+	filterTokLParen
+	filterTokRParen
+	filterTokLBracket
+	filterTokRBracket
+	filterTokComma
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int
+}
+
+// tokenizeFilter splits a filter expression into tokens.
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{filterTokLParen, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{filterTokRParen, ")", i})
+			i++
+		case r == '[':
+			tokens = append(tokens, filterToken{filterTokLBracket, "[", i})
+			i++
+		case r == ']':
+			tokens = append(tokens, filterToken{filterTokRBracket, "]", i})
+			i++
+		case r == ',':
+			tokens = append(tokens, filterToken{filterTokComma, ",", i})
+			i++
+		case r == '"' || r == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					sb.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == r {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string at position %d in filter", start)
+			}
+			tokens = append(tokens, filterToken{filterTokString, sb.String(), start})
+		case strings.ContainsRune("=!<>&|", r):
+			start := i
+			op := string(r)
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				switch two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if op == "&" || op == "|" {
+				return nil, fmt.Errorf("unexpected %q at position %d in filter", op, start)
+			}
+			i += len([]rune(op))
+			tokens = append(tokens, filterToken{filterTokOp, op, start})
+		case unicode.IsDigit(r) || ((r == '-' || r == '+' || r == '.') && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || strings.ContainsRune(".eE+-", runes[i])) {
+				i++
+			}
+			tokens = append(tokens, filterToken{filterTokNumber, string(runes[start:i]), start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || strings.ContainsRune("_.-", runes[i])) {
+				i++
+			}
+			tokens = append(tokens, filterToken{filterTokIdent, string(runes[start:i]), start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d in filter", r, i)
+		}
+	}
+	tokens = append(tokens, filterToken{kind: filterTokEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+// filterParser is a recursive-descent parser over filter tokens.
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != filterTokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isKeyword reports whether tok is the given case-insensitive keyword.
+func (tok filterToken) isKeyword(keyword string) bool {
+	return tok.kind == filterTokIdent && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *filterParser) parseOr() (*Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []*Filter{left}
+	for {
+		tok := p.peek()
+		if !tok.isKeyword("OR") && !(tok.kind == filterTokOp && tok.text == "||") {
+			break
+		}
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return Or(children...), nil
+}
+
+func (p *filterParser) parseAnd() (*Filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	children := []*Filter{left}
+	for {
+		tok := p.peek()
+		if !tok.isKeyword("AND") && !(tok.kind == filterTokOp && tok.text == "&&") {
+			break
+		}
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return And(children...), nil
+}
+
+func (p *filterParser) parseUnary() (*Filter, error) {
+	tok := p.peek()
+	if tok.isKeyword("NOT") || (tok.kind == filterTokOp && tok.text == "!") {
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not(inner), nil
+	}
+	if tok.kind == filterTokLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != filterTokRParen {
+			return nil, fmt.Errorf("expected ')' at position %d in filter", closing.pos)
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (*Filter, error) {
+	fieldTok := p.next()
+	if fieldTok.kind != filterTokIdent {
+		return nil, fmt.Errorf("expected field name at position %d in filter", fieldTok.pos)
+	}
+	field := fieldTok.text
+
+	opTok := p.next()
+	switch {
+	case opTok.kind == filterTokOp:
+		var op FilterOp
+		switch opTok.text {
+		case "==", "=":
+			op = OpEq
+		case "!=":
+			op = OpNe
+		case "<":
+			op = OpLt
+		case "<=":
+			op = OpLte
+		case ">":
+			op = OpGt
+		case ">=":
+			op = OpGte
+		default:
+			return nil, fmt.Errorf("unexpected operator %q at position %d in filter", opTok.text, opTok.pos)
+		}
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &Filter{Op: op, Field: field, Value: value}, nil
+	case opTok.isKeyword("IN"):
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return In(field, values...), nil
+	case opTok.isKeyword("NOT"):
+		if in := p.next(); !in.isKeyword("IN") {
+			return nil, fmt.Errorf("expected IN after NOT at position %d in filter", in.pos)
+		}
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return NotIn(field, values...), nil
+	case opTok.isKeyword("CONTAINS"):
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("CONTAINS requires a string at position %d in filter", opTok.pos)
+		}
+		return Contains(field, s), nil
+	default:
+		return nil, fmt.Errorf("expected operator after %s at position %d in filter", field, opTok.pos)
+	}
+}
+
+func (p *filterParser) parseList() ([]interface{}, error) {
+	if open := p.next(); open.kind != filterTokLBracket {
+		return nil, fmt.Errorf("expected '[' at position %d in filter", open.pos)
+	}
+	var values []interface{}
+	if p.peek().kind == filterTokRBracket {
+		p.next()
+		return values, nil
+	}
+	for {
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		tok := p.next()
+		if tok.kind == filterTokRBracket {
+			return values, nil
+		}
+		if tok.kind != filterTokComma {
+			return nil, fmt.Errorf("expected ',' or ']' at position %d in filter", tok.pos)
+		}
+	}
+}
+
+func (p *filterParser) parseLiteral() (interface{}, error) {
+	tok := p.next()
+	switch tok.kind {
+	case filterTokString:
+		return tok.text, nil
+	case filterTokNumber:
+		if n, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return n, nil
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d in filter", tok.text, tok.pos)
+		}
+		return f, nil
+	case filterTokIdent:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return nil, fmt.Errorf("expected value at position %d in filter", tok.pos)
+}
@@ -0,0 +1,240 @@
+package rag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr string
+		want *Filter
+	}{
+		{expr: `source == "x"`, want: Eq("source", "x")},
+		{expr: `source = 'x'`, want: Eq("source", "x")},
+		{expr: `title == "say \"hi\" \\ 'there'"`, want: Eq("title", `say "hi" \ 'there'`)},
+		{expr: `title == 'it\'s'`, want: Eq("title", "it's")},
+		{expr: `flag == true AND done != FALSE`, want: And(Eq("flag", true), Ne("done", false))},
+		{expr: `x > 1 && y <= -2.5`, want: And(Gt("x", int64(1)), Lte("y", -2.5))},
+		{expr: `meta.key-name >= 1e3`, want: Gte("meta.key-name", 1000.0)},
+		{expr: `_private < +3`, want: Lt("_private", int64(3))},
+
+		// AND binds tighter than OR, NOT tighter than AND
+		{expr: `a == 1 OR b == 2 AND c == 3`, want: Or(Eq("a", int64(1)), And(Eq("b", int64(2)), Eq("c", int64(3))))},
+		{expr: `(a == 1 OR b == 2) AND c == 3`, want: And(Or(Eq("a", int64(1)), Eq("b", int64(2))), Eq("c", int64(3)))},
+		{expr: `NOT a == 1 AND b == 2`, want: And(Not(Eq("a", int64(1))), Eq("b", int64(2)))},
+		{expr: `!(a == 1 || b == 2)`, want: Not(Or(Eq("a", int64(1)), Eq("b", int64(2))))},
+		{expr: `a == 1 or b == 2 or c == 3`, want: Or(Eq("a", int64(1)), Eq("b", int64(2)), Eq("c", int64(3)))},
+		{expr: `not not a == 1`, want: Not(Not(Eq("a", int64(1))))},
+
+		// Lists and substrings
+		{expr: `tags IN ["a", 'b', 3]`, want: In("tags", "a", "b", int64(3))},
+		{expr: `tags not in []`, want: NotIn("tags")},
+		{expr: `tags NOT IN [true]`, want: NotIn("tags", true)},
+		{expr: `Text CONTAINS "go"`, want: Contains(TextField, "go")},
+	}
+	for _, tt := range tests {
+		got, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%s): %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseFilter(%s) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{expr: ``, wantErr: "expected field name at position 0"},
+		{expr: `== 1`, wantErr: "expected field name at position 0"},
+		{expr: `source ==`, wantErr: "expected value at position 9"},
+		{expr: `source`, wantErr: "expected operator after source at position 6"},
+		{expr: `source == other`, wantErr: "expected value at position 10"},
+		{expr: `source == "x`, wantErr: "unterminated string at position 10"},
+		{expr: `a == 1 b == 2`, wantErr: `unexpected "b" at position 7`},
+		{expr: `(a == 1`, wantErr: "expected ')' at position 7"},
+		{expr: `a == 1)`, wantErr: `unexpected ")" at position 6`},
+		{expr: `a == 1 & b == 2`, wantErr: `unexpected "&" at position 7`},
+		{expr: `a ~ 1`, wantErr: `unexpected character '~' at position 2`},
+		{expr: `a ! 1`, wantErr: `unexpected operator "!" at position 2`},
+		{expr: `a IN 1`, wantErr: "expected '[' at position 5"},
+		{expr: `a IN [1 2]`, wantErr: "expected ',' or ']' at position 8"},
+		{expr: `a IN [1,]`, wantErr: "expected value at position 8"},
+		{expr: `a NOT 1`, wantErr: "expected IN after NOT at position 6"},
+		{expr: `a CONTAINS 1`, wantErr: "CONTAINS requires a string at position 2"},
+		{expr: `a == 1.2.3`, wantErr: `invalid number "1.2.3" at position 5`},
+		{expr: `a == 1 AND`, wantErr: "expected field name at position 10"},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err == nil {
+			t.Errorf("ParseFilter(%s) = %s, want an error", tt.expr, f)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ParseFilter(%s) error %q does not contain %q", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFilterStringRoundTrip(t *testing.T) {
+	filters := []*Filter{
+		Eq("source", `docs/"quoted".md`),
+		Or(Eq("a", int64(1)), And(Ne("b", 2.5), Not(Lt("c", int64(-3))))),
+		And(In("tags", "go", int64(1), true), NotIn("lang"), Contains(TextField, "vector")),
+		Gte("year", int64(2020)),
+	}
+	for _, f := range filters {
+		parsed, err := ParseFilter(f.String())
+		if err != nil {
+			t.Errorf("ParseFilter(%s): %v", f, err)
+			continue
+		}
+		if !reflect.DeepEqual(parsed, f) {
+			t.Errorf("ParseFilter(%s) = %s, want the same filter", f, parsed)
+		}
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *Filter
+		wantErr string // "" if the filter is valid
+	}{
+		{name: "nested", filter: And(Eq("a", 1), Or(Not(In("b", 1, 2)), Contains(TextField, "x")))},
+		{name: "nil", filter: nil, wantErr: "filter is nil"},
+		{name: "empty AND", filter: And(), wantErr: "AND filter requires at least one child"},
+		{name: "empty OR", filter: Or(), wantErr: "OR filter requires at least one child"},
+		{name: "NOT with two children", filter: &Filter{Op: OpNot, Filters: []*Filter{Eq("a", 1), Eq("b", 2)}}, wantErr: "NOT filter requires exactly one child"},
+		{name: "missing field", filter: And(Eq("a", 1), Lt("", 2)), wantErr: "< filter requires a field"},
+		{name: "CONTAINS number", filter: &Filter{Op: OpContains, Field: "a", Value: 1}, wantErr: "CONTAINS filter on a requires a string value"},
+		{name: "unknown operation", filter: Not(&Filter{Op: "LIKE", Field: "a", Value: "x"}), wantErr: `unsupported filter operation: "LIKE"`},
+	}
+	for _, tt := range tests {
+		err := tt.filter.Validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: Validate: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%s: Validate error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	record := Record{Fields: map[string]interface{}{
+		"Text": "Hello vector world",
+		"Metadata": map[string]interface{}{
+			"source": "docs/a.txt",
+			"chunk":  3,
+			"tags":   []interface{}{"go", "db"},
+			"score":  "0.5",
+			"draft":  false,
+		},
+	}}
+	// chromem keeps every metadata value as a string
+	chromemRecord := Record{Fields: map[string]interface{}{
+		"Metadata": map[string]string{"chunk": "3", "source": "docs/a.txt"},
+	}}
+
+	tests := []struct {
+		filter      string
+		want        bool
+		wantChromem bool
+	}{
+		{filter: `source == "docs/a.txt"`, want: true, wantChromem: true},
+		{filter: `chunk == 3`, want: true, wantChromem: true},
+		{filter: `chunk == 3.0`, want: true, wantChromem: true},
+		{filter: `chunk == "3"`, want: true, wantChromem: true},
+		{filter: `draft == false`, want: true},
+
+		// A missing field matches != and NOT IN, and nothing else
+		{filter: `source != "docs/b.txt"`, want: true, wantChromem: true},
+		{filter: `source != "docs/a.txt"`, want: false},
+		{filter: `missing != "x"`, want: true, wantChromem: true},
+		{filter: `missing NOT IN ["x"]`, want: true, wantChromem: true},
+		{filter: `missing == "x"`, want: false},
+		{filter: `missing IN ["x"]`, want: false},
+		{filter: `missing < 1`, want: false},
+		{filter: `missing CONTAINS ""`, want: false},
+		{filter: `NOT missing == "x"`, want: true, wantChromem: true},
+
+		{filter: `chunk < 10 AND chunk >= 3`, want: true, wantChromem: true},
+		{filter: `chunk > 3 OR chunk <= 2`, want: false},
+		{filter: `score > 0.25`, want: true},
+		{filter: `source < 1`, want: false},
+		{filter: `chunk IN [1, 2, 3]`, want: true, wantChromem: true},
+		{filter: `chunk NOT IN [1, 2, 3]`, want: false},
+		{filter: `tags IN ["db", "rust"]`, want: true},
+		{filter: `tags IN ["rust"]`, want: false},
+		{filter: `tags NOT IN ["rust"]`, want: true, wantChromem: true},
+		{filter: `source CONTAINS "a.txt"`, want: true, wantChromem: true},
+		{filter: `chunk CONTAINS "3"`, want: false, wantChromem: true},
+		{filter: `Text CONTAINS "vector"`, want: true},
+		{filter: `Text == "Hello vector world" AND NOT Text CONTAINS "bye"`, want: true},
+		{filter: `(source == "x" OR chunk == 3) AND NOT (draft == true)`, want: true, wantChromem: true},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("ParseFilter(%s): %v", tt.filter, err)
+		}
+		if got := f.Matches(record); got != tt.want {
+			t.Errorf("%s matches = %v, want %v", tt.filter, got, tt.want)
+		}
+		if got := f.Matches(chromemRecord); got != tt.wantChromem {
+			t.Errorf("%s matches chromem record = %v, want %v", tt.filter, got, tt.wantChromem)
+		}
+	}
+
+	var none *Filter
+	if !none.Matches(record) {
+		t.Error("nil filter does not match, want it to match every record")
+	}
+}
+
+func TestFilterFromParams(t *testing.T) {
+	eq := Eq("source", "x")
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    *Filter
+		wantErr string
+	}{
+		{name: "no filter", params: map[string]interface{}{"ef": 64}},
+		{name: "nil filter", params: map[string]interface{}{FilterParam: nil}},
+		{name: "blank expression", params: map[string]interface{}{FilterParam: "  "}},
+		{name: "expression", params: map[string]interface{}{FilterParam: `source == "x"`}, want: eq},
+		{name: "filter pointer", params: map[string]interface{}{FilterParam: eq}, want: eq},
+		{name: "filter value", params: map[string]interface{}{FilterParam: *eq}, want: eq},
+		{name: "bad expression", params: map[string]interface{}{FilterParam: `source ==`}, wantErr: "expected value"},
+		{name: "invalid filter", params: map[string]interface{}{FilterParam: And()}, wantErr: "AND filter requires at least one child"},
+		{name: "unsupported type", params: map[string]interface{}{FilterParam: 42}, wantErr: "unsupported filter type int"},
+	}
+	for _, tt := range tests {
+		got, err := filterFromParams(tt.params)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want one containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: filter = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// defaultHNSWM is the number of links per node on the upper layers
+	defaultHNSWM = 16
+	// defaultHNSWEfConstruction is the candidate list size used while building
+	defaultHNSWEfConstruction = 200
+	// defaultHNSWEf is the candidate list size used at query time
+	defaultHNSWEf = 64
+	// hnswRebuildRatio is the fraction of deleted nodes that triggers a rebuild
+	hnswRebuildRatio = 0.5
+	// hnswMinRebuildSize is the minimum graph size before tombstones trigger a rebuild
+	hnswMinRebuildSize = 1024
+)
+
+// hnswGraph is an in-process Hierarchical Navigable Small World index over one
+// vector field, following Malkov & Yashunin (2016).
+//
+// The graph does not copy the vectors it indexes: each node holds the row of
+// its vector in a vectorColumn owned by the caller, such as the column of a
+// MemoryDB collection, and distances are computed on the float32 rows.
+//
+// Nodes are never removed from the graph: deleted records are tombstoned so
+// that they still serve for navigation but are never returned. Once tombstones
+// make up hnswRebuildRatio of the graph, the owning collection rebuilds it.
+type hnswGraph struct {
+	field          string  // Indexed vector field
+	metric         string  // Metric the graph was built with
+	m              int     // Max links per node on layers above 0
+	mMax0          int     // Max links per node on layer 0
+	efConstruction int     // Candidate list size while inserting
+	levelMult      float64 // Normalisation factor for level generation
+	nodes          []hnswNode
+	entry          int32         // Entry point node, -1 when the graph is empty
+	maxLevel       int           // Level of the entry point
+	deleted        int           // Number of tombstoned nodes
+	nodeOf         []int32       // Node of each record in Collection.Data, -1 if not indexed
+	column         *vectorColumn // Rows holding the vectors of live nodes
+	detach         bool          // Whether rows of tombstoned nodes are reused by the owner
+	detached       *vectorColumn // Vectors of tombstoned nodes, when detach is set
+	rng            *rand.Rand
+}
+
+// hnswNode is a single indexed row.
+type hnswNode struct {
+	row     int32     // Row of the vector in column, or in detached once tombstoned
+	links   [][]int32 // Neighbour lists, one per level
+	deleted bool
+}
+
+// hnswCandidate pairs a node with its distance to the current query.
+type hnswCandidate struct {
+	id   int32
+	dist float64
+}
+
+// newHNSWGraph creates an empty graph for field using the M and
+// efConstruction parameters of index, falling back to sensible defaults.
+// When detach is set, the owner reuses the rows of removed nodes for other
+// vectors, so remove copies the vector of a tombstoned node aside.
+func newHNSWGraph(field string, index Index, detach bool) *hnswGraph {
+	metric, _ := ResolveMetric(index.Metric)
+	m := intParam(index.Parameters, "M", defaultHNSWM)
+	if m < 2 {
+		m = 2
+	}
+	efConstruction := intParam(index.Parameters, "efConstruction", defaultHNSWEfConstruction)
+	if efConstruction < m {
+		efConstruction = m
+	}
+	return &hnswGraph{
+		field:          field,
+		metric:         metric,
+		m:              m,
+		mMax0:          2 * m,
+		efConstruction: efConstruction,
+		levelMult:      1 / math.Log(float64(m)),
+		entry:          -1,
+		detach:         detach,
+		rng:            rand.New(rand.NewSource(42)),
+	}
+}
+
+// needsRebuild reports whether tombstones make up enough of the graph that
+// rebuilding it from the live records is worthwhile.
+func (g *hnswGraph) needsRebuild() bool {
+	return len(g.nodes) >= hnswMinRebuildSize && float64(g.deleted) >= hnswRebuildRatio*float64(len(g.nodes))
+}
+
+// vector returns the values and norm of the vector of node id.
+func (g *hnswGraph) vector(id int32) ([]float32, float32) {
+	node := &g.nodes[id]
+	column := g.column
+	if node.deleted && g.detach {
+		column = g.detached
+	}
+	return column.row(int(node.row)), column.Norms[node.row]
+}
+
+// distanceTo returns the distance between a query and node id.
+func (g *hnswGraph) distanceTo(query []float32, norm float32, id int32) float64 {
+	values, valuesNorm := g.vector(id)
+	return distance32(g.metric, query, norm, values, valuesNorm)
+}
+
+// between returns the distance between nodes a and b.
+func (g *hnswGraph) between(a, b int32) float64 {
+	values, norm := g.vector(a)
+	return g.distanceTo(values, norm, b)
+}
+
+// add inserts row of column into the graph and returns its node, or -1 when
+// the row holds no vector. All rows of a graph must come from the same column.
+func (g *hnswGraph) add(column *vectorColumn, row int) int32 {
+	if column == nil || row >= column.rows() || !column.Present[row] {
+		return -1
+	}
+	g.column = column
+	query, norm := column.row(row), column.Norms[row]
+
+	level := int(math.Floor(-math.Log(1-g.rng.Float64()) * g.levelMult))
+	id := int32(len(g.nodes))
+	g.nodes = append(g.nodes, hnswNode{
+		row:   int32(row),
+		links: make([][]int32, level+1),
+	})
+
+	if g.entry < 0 {
+		g.entry = id
+		g.maxLevel = level
+		return id
+	}
+
+	ep := hnswCandidate{id: g.entry, dist: g.distanceTo(query, norm, g.entry)}
+	for l := g.maxLevel; l > level; l-- {
+		ep = g.greedyClosest(query, norm, ep, l)
+	}
+
+	for l := min(level, g.maxLevel); l >= 0; l-- {
+		candidates := g.searchLayer(query, norm, []hnswCandidate{ep}, g.efConstruction, l)
+		neighbours := g.selectNeighbours(candidates, g.m)
+		g.nodes[id].links[l] = neighbours
+		for _, nb := range neighbours {
+			g.link(nb, id, l)
+		}
+		ep = candidates[0]
+	}
+
+	if level > g.maxLevel {
+		g.entry = id
+		g.maxLevel = level
+	}
+	return id
+}
+
+// remove tombstones node id. It must be called before the owner reuses the
+// node's row, whose vector is then copied aside for navigation.
+func (g *hnswGraph) remove(id int32) {
+	if id < 0 || g.nodes[id].deleted {
+		return
+	}
+	node := &g.nodes[id]
+	if g.detach {
+		if g.detached == nil {
+			g.detached = newVectorColumn(g.column.Dim, 0)
+		}
+		row := g.detached.rows()
+		g.detached.grow(row + 1)
+		g.detached.move32(row, g.column, int(node.row))
+		node.row = int32(row)
+	}
+	node.deleted = true
+	g.deleted++
+}
+
+// setRow records that the owner moved the vector of node id to another row.
+func (g *hnswGraph) setRow(id int32, row int) {
+	if id >= 0 {
+		g.nodes[id].row = int32(row)
+	}
+}
+
+// link adds a connection from node from to node to on level l, pruning the
+// neighbour list of from with the selection heuristic when it overflows.
+func (g *hnswGraph) link(from, to int32, l int) {
+	links := append(g.nodes[from].links[l], to)
+	maxLinks := g.m
+	if l == 0 {
+		maxLinks = g.mMax0
+	}
+	if len(links) <= maxLinks {
+		g.nodes[from].links[l] = links
+		return
+	}
+
+	candidates := make([]hnswCandidate, len(links))
+	for i, nb := range links {
+		candidates[i] = hnswCandidate{id: nb, dist: g.between(from, nb)}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].dist < candidates[j].dist
+	})
+	g.nodes[from].links[l] = g.selectNeighbours(candidates, maxLinks)
+}
+
+// selectNeighbours picks up to m neighbours from candidates (sorted by
+// ascending distance) using the diversity heuristic of the HNSW paper: a
+// candidate is kept only if it is closer to the query than to any neighbour
+// already selected. Pruned candidates fill any remaining slots.
+func (g *hnswGraph) selectNeighbours(candidates []hnswCandidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var pruned []int32
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		good := true
+		for _, s := range selected {
+			if g.between(c.id, s) < c.dist {
+				good = false
+				break
+			}
+		}
+		if good {
+			selected = append(selected, c.id)
+		} else {
+			pruned = append(pruned, c.id)
+		}
+	}
+	for _, id := range pruned {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, id)
+	}
+	return selected
+}
+
+// greedyClosest walks level l from ep towards query until no neighbour is closer.
+func (g *hnswGraph) greedyClosest(query []float32, norm float32, ep hnswCandidate, l int) hnswCandidate {
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range g.nodes[ep.id].links[l] {
+			if d := g.distanceTo(query, norm, nb); d < ep.dist {
+				ep = hnswCandidate{id: nb, dist: d}
+				changed = true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer performs a best-first search of level l starting from
+// entryPoints and returns up to ef nodes closest to query, sorted by
+// ascending distance. Tombstoned nodes are included.
+func (g *hnswGraph) searchLayer(query []float32, norm float32, entryPoints []hnswCandidate, ef int, l int) []hnswCandidate {
+	visited := make([]uint64, (len(g.nodes)+63)/64)
+	candidates := &hnswMinHeap{}
+	results := &hnswMaxHeap{}
+	for _, ep := range entryPoints {
+		visited[ep.id/64] |= 1 << (uint(ep.id) % 64)
+		heap.Push(candidates, ep)
+		heap.Push(results, ep)
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && c.dist > (*results)[0].dist {
+			break
+		}
+		for _, nb := range g.nodes[c.id].links[l] {
+			word, bit := nb/64, uint64(1)<<(uint(nb)%64)
+			if visited[word]&bit != 0 {
+				continue
+			}
+			visited[word] |= bit
+
+			d := g.distanceTo(query, norm, nb)
+			if results.Len() < ef || d < (*results)[0].dist {
+				heap.Push(candidates, hnswCandidate{id: nb, dist: d})
+				heap.Push(results, hnswCandidate{id: nb, dist: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sorted := make([]hnswCandidate, results.Len())
+	for i := len(sorted) - 1; i >= 0; i-- {
+		sorted[i] = heap.Pop(results).(hnswCandidate)
+	}
+	return sorted
+}
+
+// search returns up to k live rows closest to query whose row satisfies
+// accept, sorted by ascending distance. ef controls the breadth of the
+// search; it is doubled until k accepted rows are found or the whole graph
+// has been explored, so restrictive filters and tombstones do not starve the
+// result list.
+func (g *hnswGraph) search(query Vector, k, ef int, accept func(row int) bool) []flatCandidate {
+	if g.entry < 0 || k <= 0 || len(query) != g.column.Dim {
+		return nil
+	}
+	if ef < k {
+		ef = k
+	}
+
+	values := toFloat32Slice(query)
+	norm := float32(math.Sqrt(float64(dot32(values, values))))
+	ep := hnswCandidate{id: g.entry, dist: g.distanceTo(values, norm, g.entry)}
+	for l := g.maxLevel; l > 0; l-- {
+		ep = g.greedyClosest(values, norm, ep, l)
+	}
+
+	for {
+		candidates := g.searchLayer(values, norm, []hnswCandidate{ep}, ef, 0)
+		results := make([]flatCandidate, 0, k)
+		for _, c := range candidates {
+			node := &g.nodes[c.id]
+			if node.deleted || (accept != nil && !accept(int(node.row))) {
+				continue
+			}
+			results = append(results, flatCandidate{index: int(node.row), dist: c.dist})
+			if len(results) == k {
+				return results
+			}
+		}
+		if ef >= len(g.nodes) {
+			return results
+		}
+		ef *= 2
+	}
+}
+
+// hnswMinHeap orders candidates by ascending distance.
+type hnswMinHeap []hnswCandidate
+
+func (h hnswMinHeap) Len() int            { return len(h) }
+func (h hnswMinHeap) Less(i, j int) bool  { return h[i].dist < h[j].dist }
+func (h hnswMinHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hnswMinHeap) Push(x interface{}) { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMinHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// hnswMaxHeap orders candidates by descending distance.
+type hnswMaxHeap []hnswCandidate
+
+func (h hnswMaxHeap) Len() int            { return len(h) }
+func (h hnswMaxHeap) Less(i, j int) bool  { return h[i].dist > h[j].dist }
+func (h hnswMaxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hnswMaxHeap) Push(x interface{}) { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMaxHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// intParam reads an integer parameter that may have been decoded as any
+// numeric type (e.g. float64 from JSON), returning def when absent.
+func intParam(params map[string]interface{}, key string, def int) int {
+	if v, ok := toFloat(params[key]); ok && v > 0 {
+		return int(v)
+	}
+	return def
+}
@@ -100,7 +100,8 @@ func WithLogger(logger Logger) LoaderOption {
 // 3. Stores the file in the temporary directory
 // 4. Returns the path to the downloaded file
 //
-// The downloaded file's name is derived from the URL's base name.
+// The downloaded file's name is unique and ends with the URL's base name,
+// so that downloads of different URLs never overwrite each other.
 func (l *Loader) LoadURL(ctx context.Context, url string) (string, error) {
 	l.logger.Debug("Starting LoadURL", "url", url)
 	ctx, cancel := context.WithTimeout(ctx, l.timeout)
@@ -119,15 +120,13 @@ func (l *Loader) LoadURL(ctx context.Context, url string) (string, error) {
 	}
 	defer resp.Body.Close()
 
-	filename := filepath.Base(url)
-	destPath := filepath.Join(l.tempDir, filename)
-
-	out, err := os.Create(destPath)
+	out, err := os.CreateTemp(l.tempDir, "*-"+filepath.Base(url))
 	if err != nil {
-		l.logger.Error("Failed to create file", "path", destPath, "error", err)
+		l.logger.Error("Failed to create file", "dir", l.tempDir, "error", err)
 		return "", err
 	}
 	defer out.Close()
+	destPath := out.Name()
 
 	_, err = io.Copy(out, resp.Body)
 	if err != nil {
@@ -146,6 +145,8 @@ func (l *Loader) LoadURL(ctx context.Context, url string) (string, error) {
 // 3. Returns the path to the copied file
 //
 // This ensures that the original file remains unchanged during processing.
+// The copy's name is unique and ends with the file's base name, so that
+// files sharing a base name in different directories never collide.
 func (l *Loader) LoadFile(ctx context.Context, path string) (string, error) {
 	l.logger.Debug("Starting LoadFile", "path", path)
 
@@ -155,9 +156,6 @@ func (l *Loader) LoadFile(ctx context.Context, path string) (string, error) {
 		return "", err
 	}
 
-	filename := filepath.Base(path)
-	destPath := filepath.Join(l.tempDir, filename)
-
 	src, err := os.Open(path)
 	if err != nil {
 		l.logger.Error("Failed to open source file", "path", path, "error", err)
@@ -165,12 +163,13 @@ func (l *Loader) LoadFile(ctx context.Context, path string) (string, error) {
 	}
 	defer src.Close()
 
-	dest, err := os.Create(destPath)
+	dest, err := os.CreateTemp(l.tempDir, "*-"+filepath.Base(path))
 	if err != nil {
-		l.logger.Error("Failed to create destination file", "path", destPath, "error", err)
+		l.logger.Error("Failed to create destination file", "dir", l.tempDir, "error", err)
 		return "", err
 	}
 	defer dest.Close()
+	destPath := dest.Name()
 
 	_, err = io.Copy(dest, src)
 	if err != nil {
@@ -1,12 +1,14 @@
 // Package rag provides an in-memory vector database implementation that serves
 // as a lightweight solution for vector similarity search. It's ideal for testing,
-// prototyping, and small-scale applications that don't require persistence.
+// prototyping, CLI tools and edge deployments. An optional data directory makes
+// it durable across restarts.
 package rag
 
 import (
 	"context"
 	"fmt"
 	"math"
+	"runtime"
 	"sort"
 	"sync"
 )
@@ -21,6 +23,14 @@ type MemoryDB struct {
 	mu sync.RWMutex
 	// columnNames specifies which fields to include in search results
 	columnNames []string
+	// dataDir is the optional directory used to persist collections
+	dataDir string
+	// snapshotInterval is the number of logged operations between snapshots
+	snapshotInterval int
+	// syncWrites fsyncs the operation log after every write
+	syncWrites bool
+	// store persists operations when dataDir is set; nil otherwise
+	store *memoryStore
 }
 
 // Collection represents a named set of records with a defined schema.
@@ -28,28 +38,103 @@ type MemoryDB struct {
 type Collection struct {
 	// Schema defines the structure of records in this collection
 	Schema Schema
-	// Data holds the actual records in the collection
+	// Data holds the actual records in the collection, without the vectors
+	// stored in Vectors
 	Data []Record
+	// Vectors holds each vector field as contiguous float32 storage, one row
+	// per record of Data
+	Vectors map[string]*vectorColumn
+	// Indexes holds the index definition of each indexed vector field
+	Indexes map[string]Index
+	// graphs holds the HNSW graph built for each indexed vector field
+	graphs map[string]*hnswGraph
+	// quantized holds the codes of each vector field with an SQ8 or BINARY
+	// index, one row per record of Data
+	quantized map[string]*quantizedIndex
+}
+
+func init() {
+	Register("memory", func(cfg *Config) (VectorDB, error) { return newMemoryDB(cfg) }, Capabilities{Hybrid: true, Filters: true, Delete: true, Persistence: true})
 }
 
 // newMemoryDB creates a new in-memory vector database instance.
 // It initializes an empty collection map and returns a ready-to-use database.
+//
+// When cfg.Address is set it names a data directory in which every mutating
+// operation is written to an append-only log, periodically compacted into a
+// snapshot. The stored state is replayed on Connect. Supported parameters:
+// - "snapshot_interval" (int): logged operations between snapshots (default 1000)
+// - "sync_writes" (bool): fsync the log after every operation (default false)
 func newMemoryDB(cfg *Config) (*MemoryDB, error) {
-	return &MemoryDB{
+	m := &MemoryDB{
 		collections: make(map[string]*Collection),
-	}, nil
+		dataDir:     cfg.Address,
+	}
+	if interval, ok := cfg.Parameters["snapshot_interval"].(int); ok {
+		m.snapshotInterval = interval
+	}
+	if syncWrites, ok := cfg.Parameters["sync_writes"].(bool); ok {
+		m.syncWrites = syncWrites
+	}
+	return m, nil
 }
 
-// Connect is a no-op for the in-memory database as no connection is needed.
-// It's implemented to satisfy the VectorDB interface.
+// Connect loads the persisted state when a data directory is configured:
+// the latest snapshot is read and the operations logged after it are replayed.
+// It must be called before any other operation, as it replaces the in-memory
+// state. Without a data directory it is a no-op.
 func (m *MemoryDB) Connect(ctx context.Context) error {
+	if m.dataDir == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.store != nil {
+		return nil
+	}
+
+	store, err := openMemoryStore(m.dataDir, m.snapshotInterval, m.syncWrites)
+	if err != nil {
+		return err
+	}
+	collections, entries, err := store.load()
+	if err != nil {
+		return err
+	}
+
+	m.collections = collections
+	for _, collection := range collections {
+		collection.loadVectors()
+		collection.rebuildIndexes()
+	}
+	for _, entry := range entries {
+		if err := m.apply(entry); err != nil {
+			store.close()
+			return fmt.Errorf("failed to replay MemoryDB log: %w", err)
+		}
+	}
+	m.store = store
+	GlobalLogger.Debug("Loaded MemoryDB data directory", "dir", m.dataDir, "collections", len(collections), "replayed", len(entries))
 	return nil
 }
 
-// Close is a no-op for the in-memory database as no cleanup is needed.
-// It's implemented to satisfy the VectorDB interface.
+// Close writes a final snapshot and closes the operation log when a data
+// directory is configured. Without a data directory it is a no-op.
 func (m *MemoryDB) Close() error {
-	return nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.store == nil {
+		return nil
+	}
+
+	snapshotErr := m.store.snapshot(m.collections)
+	closeErr := m.store.close()
+	m.store = nil
+	if snapshotErr != nil {
+		return snapshotErr
+	}
+	return closeErr
 }
 
 // HasCollection checks if a collection with the given name exists in the database.
@@ -66,8 +151,7 @@ func (m *MemoryDB) HasCollection(ctx context.Context, name string) (bool, error)
 func (m *MemoryDB) DropCollection(ctx context.Context, name string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	delete(m.collections, name)
-	return nil
+	return m.commit(memoryLogEntry{Op: memoryOpDrop, Collection: name})
 }
 
 // CreateCollection creates a new collection with the specified schema.
@@ -77,36 +161,175 @@ func (m *MemoryDB) CreateCollection(ctx context.Context, name string, schema Sch
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if _, exists := m.collections[name]; exists {
-		return fmt.Errorf("collection %s already exists", name)
+		return fmt.Errorf("%w: %s", ErrCollectionExists, name)
 	}
-	m.collections[name] = &Collection{Schema: schema}
-	return nil
+	return m.commit(memoryLogEntry{Op: memoryOpCreate, Collection: name, Schema: schema})
 }
 
-// Insert adds new records to the specified collection.
-// Returns an error if the collection doesn't exist.
+// Insert adds new records to the specified collection. Records without an
+// ID get one derived from their document ID, if they have one (see DocIDKey).
+// Returns an error if the collection doesn't exist or a record does not
+// match its schema (see ValidateRecords).
 // This operation is thread-safe and uses a write lock.
 func (m *MemoryDB) Insert(ctx context.Context, collectionName string, data []Record) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	collection, exists := m.collections[collectionName]
 	if !exists {
-		return fmt.Errorf("collection %s does not exist", collectionName)
+		return collectionNotFound(collectionName)
+	}
+	if err := ValidateRecords(collection.Schema, data); err != nil {
+		return err
+	}
+	return m.commit(memoryLogEntry{Op: memoryOpInsert, Collection: collectionName, Records: assignDocIDs(normalizeVectors(collection.Schema, data), "ID")})
+}
+
+// Upsert inserts records into the specified collection, replacing any existing
+// record with the same ID. Records without an ID or document ID are simply
+// appended.
+// This operation is thread-safe and uses a write lock.
+func (m *MemoryDB) Upsert(ctx context.Context, collectionName string, data []Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	collection, exists := m.collections[collectionName]
+	if !exists {
+		return collectionNotFound(collectionName)
+	}
+	if err := ValidateRecords(collection.Schema, data); err != nil {
+		return err
+	}
+	return m.commit(memoryLogEntry{Op: memoryOpUpsert, Collection: collectionName, Records: assignDocIDs(normalizeVectors(collection.Schema, data), "ID")})
+}
+
+// Delete removes the records with the given IDs from the specified collection.
+// IDs that are not present in the collection are ignored.
+// This operation is thread-safe and uses a write lock.
+func (m *MemoryDB) Delete(ctx context.Context, collectionName string, ids []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.collections[collectionName]; !exists {
+		return collectionNotFound(collectionName)
+	}
+	return m.commit(memoryLogEntry{Op: memoryOpDelete, Collection: collectionName, IDs: ids})
+}
+
+// DeleteByFilter removes every record matching filter. A nil filter is
+// rejected to avoid accidentally wiping the whole collection.
+// This operation is thread-safe and uses a write lock.
+func (m *MemoryDB) DeleteByFilter(ctx context.Context, collectionName string, filter *Filter) error {
+	if filter == nil {
+		return fmt.Errorf("delete filter must not be empty")
+	}
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.collections[collectionName]; !exists {
+		return collectionNotFound(collectionName)
+	}
+	return m.commit(memoryLogEntry{Op: memoryOpDeleteByFilter, Collection: collectionName, Filter: filter})
+}
+
+// Get returns the records with the given IDs, in the order the IDs were given.
+// IDs that are not present in the collection are skipped.
+// This operation is thread-safe and uses a read lock.
+func (m *MemoryDB) Get(ctx context.Context, collectionName string, ids []int64) ([]Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	collection, exists := m.collections[collectionName]
+	if !exists {
+		return nil, collectionNotFound(collectionName)
+	}
+
+	byID := make(map[int64]int, len(collection.Data))
+	for i, record := range collection.Data {
+		if id, ok := recordID(record); ok {
+			byID[id] = i
+		}
+	}
+
+	records := make([]Record, 0, len(ids))
+	for _, id := range ids {
+		if i, found := byID[id]; found {
+			records = append(records, collection.record(i))
+		}
+	}
+	return records, nil
+}
+
+// Scan calls fn with every record of a collection, in insertion order.
+// The records are read under a read lock, which is released before fn is
+// called, so fn may write to the database.
+func (m *MemoryDB) Scan(ctx context.Context, collectionName string, batchSize int, fn func([]Record) error) error {
+	m.mu.RLock()
+	collection, exists := m.collections[collectionName]
+	if !exists {
+		m.mu.RUnlock()
+		return collectionNotFound(collectionName)
+	}
+	// Copy the float32 columns rather than restoring every vector up front,
+	// so the batches are materialised one at a time.
+	snapshot := collection.copyData()
+	m.mu.RUnlock()
+	defer snapshot.closeSpills()
+
+	batchSize = scanBatchSize(batchSize)
+	for start := 0; start < len(snapshot.Data); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := min(start+batchSize, len(snapshot.Data))
+		records := make([]Record, 0, end-start)
+		for i := start; i < end; i++ {
+			records = append(records, snapshot.record(i))
+		}
+		if err := fn(records); err != nil {
+			return err
+		}
 	}
-	collection.Data = append(collection.Data, data...)
 	return nil
 }
 
-// Flush is a no-op for the in-memory database as all operations are immediate.
-// It's implemented to satisfy the VectorDB interface.
+// Flush syncs the operation log to disk when a data directory is configured.
+// Without a data directory it is a no-op, as all operations are immediate.
 func (m *MemoryDB) Flush(ctx context.Context, collectionName string) error {
-	return nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.store == nil {
+		return nil
+	}
+	return m.store.sync()
 }
 
-// CreateIndex is a no-op for the in-memory database as it uses linear search.
-// Future implementations could add indexing for better performance.
+// CreateIndex builds an in-process index on the specified vector field.
+// An index of Type "HNSW" builds a Hierarchical Navigable Small World graph,
+// honouring the "M" and "efConstruction" parameters; it is kept up to date
+// by later inserts, upserts and deletes. An index of Type IndexTypeSQ8 or
+// IndexTypeBinary keeps int8 or sign-bit codes of the field: single-field
+// searches scan the codes for topK * "oversample" candidates (parameter or
+// search parameter, default 3 for SQ8 and 10 for BINARY) and rescore them
+// against the float32 vectors. Those vectors move from memory to an
+// unlinked temporary file once the field is quantized and only the rescored
+// candidates are read back, though multi-field and hybrid searches on the
+// field and snapshots of a data directory read every row. Other index
+// types are a no-op and searches on the field use an exact linear scan.
+// This operation is thread-safe and uses a write lock.
 func (m *MemoryDB) CreateIndex(ctx context.Context, collectionName, field string, index Index) error {
-	return nil
+	if index.Type != "HNSW" && !isQuantizedIndex(index.Type) {
+		return nil
+	}
+	if _, err := ResolveMetric(index.Metric); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.collections[collectionName]; !exists {
+		return collectionNotFound(collectionName)
+	}
+	return m.commit(memoryLogEntry{Op: memoryOpCreateIndex, Collection: collectionName, Field: field, Index: index})
 }
 
 // LoadCollection is a no-op for the in-memory database as all data is always loaded.
@@ -115,133 +338,304 @@ func (m *MemoryDB) LoadCollection(ctx context.Context, name string) error {
 	return nil
 }
 
+// ListCollections returns the names of all collections, sorted.
+// This operation is thread-safe and uses a read lock.
+func (m *MemoryDB) ListCollections(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.collections))
+	for name := range m.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DescribeCollection returns the schema a collection was created with and
+// the HNSW indexes built on it.
+// This operation is thread-safe and uses a read lock.
+func (m *MemoryDB) DescribeCollection(ctx context.Context, name string) (*CollectionInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	collection, exists := m.collections[name]
+	if !exists {
+		return nil, collectionNotFound(name)
+	}
+
+	indexes := make(map[string]Index, len(collection.Indexes))
+	for field, index := range collection.Indexes {
+		indexes[field] = index
+	}
+	return &CollectionInfo{Name: name, Schema: collection.Schema, Indexes: indexes}, nil
+}
+
+// Count returns the number of records in a collection.
+// This operation is thread-safe and uses a read lock.
+func (m *MemoryDB) Count(ctx context.Context, collectionName string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	collection, exists := m.collections[collectionName]
+	if !exists {
+		return 0, collectionNotFound(collectionName)
+	}
+	return int64(len(collection.Data)), nil
+}
+
+// Stats reports the size of a collection. StorageSize estimates the memory
+// held by the records' values, vectors and quantized codes, leaving out the
+// vectors of quantized fields, which are kept on disk; the index is ready
+// as soon as it exists, as indexes are updated synchronously.
+// This operation is thread-safe and uses a read lock.
+func (m *MemoryDB) Stats(ctx context.Context, collectionName string) (*CollectionStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	collection, exists := m.collections[collectionName]
+	if !exists {
+		return nil, collectionNotFound(collectionName)
+	}
+
+	var size int64
+	for _, record := range collection.Data {
+		size += approximateSize(record.Fields)
+	}
+	for _, column := range collection.Vectors {
+		size += int64(len(column.Values))*4 + int64(len(column.Norms))*4
+	}
+	for _, q := range collection.quantized {
+		size += q.size()
+	}
+	status := IndexStatusNone
+	if len(collection.Indexes) > 0 {
+		status = IndexStatusReady
+	}
+	return &CollectionStats{
+		Count:       int64(len(collection.Data)),
+		Dimension:   vectorDimension(collection.Schema),
+		StorageSize: size,
+		IndexStatus: status,
+	}, nil
+}
+
+// approximateSize estimates the number of bytes held by a record value.
+func approximateSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case Vector:
+		return int64(len(v)) * 8
+	case []float64:
+		return int64(len(v)) * 8
+	case []float32:
+		return int64(len(v)) * 4
+	case string:
+		return int64(len(v))
+	case map[string]interface{}:
+		var size int64
+		for k, item := range v {
+			size += int64(len(k)) + approximateSize(item)
+		}
+		return size
+	case []interface{}:
+		var size int64
+		for _, item := range v {
+			size += approximateSize(item)
+		}
+		return size
+	default:
+		return 8
+	}
+}
+
 // Search performs vector similarity search in the specified collection.
-// It supports different distance metrics and returns the top K most similar vectors.
+// It supports the L2, IP and COSINE metrics and returns the top K most similar
+// vectors, with scores on the scale documented on SearchResult.
 // The search process:
-// 1. Validates the collection exists
-// 2. Computes distances between query vectors and stored vectors
-// 3. Sorts results by similarity score
-// 4. Returns the top K results with specified fields
+//  1. Validates the collection exists
+//  2. Uses the field's HNSW index when one exists for the requested metric,
+//     exploring "ef" candidates (searchParams, default 64)
+//  3. Uses the field's SQ8 or BINARY index when one exists, rescoring
+//     topK * "oversample" candidates (searchParams) with the float32 vectors
+//  4. Otherwise scans the float32 vector columns in parallel shards, skipping
+//     records rejected by the optional FilterParam filter and keeping the
+//     closest records in a bounded top-K heap per shard. A record is scored on
+//     the first query field (by name) it has a vector for
+//  5. Returns the top K results with specified fields
 func (m *MemoryDB) Search(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}) ([]SearchResult, error) {
-	// The implementation remains largely the same, but now we can use metricType and searchParams
-	// For simplicity, we'll ignore these new parameters in this example
+	metric, err := ResolveMetric(metricType)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := filterFromParams(searchParams)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	collection, exists := m.collections[collectionName]
 	if !exists {
-		return nil, fmt.Errorf("collection %s does not exist", collectionName)
+		return nil, collectionNotFound(collectionName)
 	}
+	return m.search(ctx, collection, vectors, topK, metric, filter, searchParams)
+}
 
-	var results []SearchResult
+// SearchBatch runs the searches of the query vectors on field concurrently,
+// on up to GOMAXPROCS goroutines, under a single read lock. Each search is
+// performed as by Search.
+func (m *MemoryDB) SearchBatch(ctx context.Context, collectionName, field string, queries []Vector, topK int, metricType string, searchParams map[string]interface{}) ([][]SearchResult, error) {
+	metric, err := ResolveMetric(metricType)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := filterFromParams(searchParams)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, record := range collection.Data {
-		for fieldName, searchVector := range vectors {
-			if v, ok := record.Fields[fieldName].(Vector); ok {
-				distance := m.calculateDistance(searchVector, v, metricType)
-				fields := make(map[string]interface{})
-				for _, name := range m.columnNames {
-					if value, exists := record.Fields[name]; exists {
-						fields[name] = value
-					}
-				}
-				results = append(results, SearchResult{
-					ID:     record.Fields["ID"].(int64),
-					Score:  distance,
-					Fields: fields,
-				})
-				break
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	collection, exists := m.collections[collectionName]
+	if !exists {
+		return nil, collectionNotFound(collectionName)
+	}
+	return searchEach(ctx, queries, runtime.GOMAXPROCS(0), func(ctx context.Context, query Vector) ([]SearchResult, error) {
+		return m.search(ctx, collection, map[string]Vector{field: query}, topK, metric, filter, searchParams)
+	})
+}
+
+// search performs a Search on collection with a resolved metric.
+// The caller must hold at least the read lock.
+func (m *MemoryDB) search(ctx context.Context, collection *Collection, vectors map[string]Vector, topK int, metric string, filter *Filter, searchParams map[string]interface{}) ([]SearchResult, error) {
+	if err := validateQueryVectors(collection.Schema, vectors); err != nil {
+		return nil, err
+	}
+
+	if graph := collection.graphFor(vectors, metric); graph != nil {
+		var accept func(int) bool
+		if filter != nil {
+			accept = func(i int) bool {
+				return filter.Matches(collection.Data[i])
 			}
 		}
+		ef := intParam(searchParams, "ef", defaultHNSWEf)
+		return m.flatResults(collection, graph.search(vectors[graph.field], topK, ef, accept), metric), nil
 	}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score < results[j].Score
+	queries := collection.newFlatQueries(vectors, metric)
+	if q := collection.quantizedFor(vectors); q != nil {
+		candidates, err := collection.searchQuantized(ctx, q, &queries[0], topK, filter, searchParams)
+		if err != nil {
+			return nil, err
+		}
+		return m.flatResults(collection, candidates, metric), nil
+	}
+	candidates, err := collection.scan(ctx, topK, filter, func(i int) (float64, bool) {
+		for q := range queries {
+			if distance, ok := queries[q].distance(collection, i); ok {
+				return distance, true
+			}
+		}
+		return 0, false
 	})
-
-	if len(results) > topK {
-		results = results[:topK]
+	if err != nil {
+		return nil, err
 	}
-
-	return results, nil
+	return m.flatResults(collection, candidates, metric), nil
 }
 
 // HybridSearch performs a multi-vector similarity search with optional reranking.
 // It's similar to Search but supports searching across multiple vector fields
 // and combining the results. The process:
 // 1. Validates the collection exists
-// 2. Computes distances for each vector field
-// 3. Combines distances using average
-// 4. Sorts and returns top K results
+// 2. Skips records rejected by the optional FilterParam filter
+// 3. Computes distances for each vector field, in parallel shards as Search
+// 4. Combines distances using average
+// 5. Returns the top K results, kept in a bounded heap per shard
 func (m *MemoryDB) HybridSearch(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}, reranker interface{}) ([]SearchResult, error) {
-	// The implementation remains largely the same, but now we can use metricType, searchParams, and reranker
-	// For simplicity, we'll ignore these new parameters in this example
+	metric, err := ResolveMetric(metricType)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	collection, exists := m.collections[collectionName]
 	if !exists {
-		return nil, fmt.Errorf("collection %s does not exist", collectionName)
+		return nil, collectionNotFound(collectionName)
+	}
+	if err := validateQueryVectors(collection.Schema, vectors); err != nil {
+		return nil, err
 	}
 
-	var results []SearchResult
-	for _, record := range collection.Data {
-		var totalDistance float64
-		var fieldsMatched int
-		for fieldName, searchVector := range vectors {
-			if v, ok := record.Fields[fieldName].(Vector); ok {
-				totalDistance += m.calculateDistance(searchVector, v, metricType)
-				fieldsMatched++
-			}
-		}
+	filter, err := filterFromParams(searchParams)
+	if err != nil {
+		return nil, err
+	}
 
-		if fieldsMatched == len(vectors) {
-			fields := make(map[string]interface{})
-			for _, name := range m.columnNames {
-				if value, exists := record.Fields[name]; exists {
-					fields[name] = value
-				}
+	queries := collection.newFlatQueries(vectors, metric)
+	candidates, err := collection.scan(ctx, topK, filter, func(i int) (float64, bool) {
+		var totalDistance float64
+		for q := range queries {
+			distance, ok := queries[q].distance(collection, i)
+			if !ok {
+				return 0, false
 			}
-			results = append(results, SearchResult{
-				ID:     record.Fields["ID"].(int64),
-				Score:  totalDistance / float64(len(vectors)),
-				Fields: fields,
-			})
+			totalDistance += distance
 		}
-	}
-
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score < results[j].Score
+		return totalDistance / float64(len(queries)), true
 	})
-
-	if len(results) > topK {
-		results = results[:topK]
+	if err != nil {
+		return nil, err
 	}
-
-	return results, nil
+	return m.flatResults(collection, candidates, metric), nil
 }
 
 // calculateDistance computes the distance between two vectors using the specified metric.
 // Supported metrics:
 // - "L2": Euclidean distance (default)
 // - "IP": Inner product (negative, as larger means more similar)
-// Returns a float64 representing the distance/similarity score.
+// - "COSINE": 1 - cosine similarity
+// Returns a float64 distance where lower means more similar.
 func (m *MemoryDB) calculateDistance(a, b Vector, metricType string) float64 {
-	var sum float64
-	switch metricType {
-	case "L2":
-		for i := range a {
-			diff := a[i] - b[i]
-			sum += diff * diff
-		}
-		return math.Sqrt(sum)
-	case "IP":
-		for i := range a {
-			sum += a[i] * b[i]
-		}
-		return -sum // Negative because larger IP means closer vectors
+	return distanceFunc(metricType)(a, b)
+}
+
+// distanceFunc returns the distance function for metricType, where a lower
+// value always means more similar. Callers validate the metric with ResolveMetric.
+func distanceFunc(metricType string) func(a, b Vector) float64 {
+	metric, _ := ResolveMetric(metricType)
+	switch metric {
+	case MetricIP:
+		return negativeInnerProduct
+	case MetricCosine:
+		return cosineDistance
 	default:
-		// Default to L2
-		for i := range a {
-			diff := a[i] - b[i]
-			sum += diff * diff
-		}
-		return math.Sqrt(sum)
+		return euclideanDistance
+	}
+}
+
+// negativeInnerProduct returns the negated inner product of two vectors,
+// so that larger inner products sort first like smaller distances.
+func negativeInnerProduct(a, b Vector) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return -sum
+}
+
+// cosineDistance returns 1 - the cosine similarity of two vectors.
+// A zero vector is treated as orthogonal to every other vector.
+func cosineDistance(a, b Vector) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
 	}
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+	return 1 - dot/(math.Sqrt(normA)*math.Sqrt(normB))
 }
 
 // euclideanDistance computes the L2 (Euclidean) distance between two vectors.
@@ -258,5 +652,281 @@ func euclideanDistance(a, b Vector) float64 {
 // SetColumnNames configures which fields should be included in search results.
 // This allows for selective field retrieval to optimize response size.
 func (m *MemoryDB) SetColumnNames(names []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.columnNames = names
 }
+
+// newSearchResult builds a search result for record, copying the configured
+// columns and deriving the score from the distance under metric.
+func (m *MemoryDB) newSearchResult(record Record, metric string, distance float64) SearchResult {
+	fields := make(map[string]interface{})
+	for _, name := range m.columnNames {
+		if value, exists := record.Fields[name]; exists {
+			fields[name] = value
+		}
+	}
+	id, _ := recordID(record)
+	return SearchResult{
+		ID:       id,
+		DocID:    docIDFromFields(record.Fields),
+		Score:    ScoreFromDistance(metric, distance),
+		Distance: distance,
+		Fields:   fields,
+	}
+}
+
+// flatResults builds the search results of the candidates of a linear scan.
+func (m *MemoryDB) flatResults(collection *Collection, candidates []flatCandidate, metric string) []SearchResult {
+	var results []SearchResult
+	for _, c := range candidates {
+		results = append(results, m.newSearchResult(collection.record(c.index), metric, c.dist))
+	}
+	return results
+}
+
+// recordID extracts the int64 primary key stored in a record's "ID" field.
+// The second return value is false when the record has no usable ID.
+func recordID(record Record) (int64, bool) {
+	switch id := record.Fields["ID"].(type) {
+	case int64:
+		return id, true
+	case int:
+		return int64(id), true
+	default:
+		return 0, false
+	}
+}
+
+// commit records a mutating operation in the log (when persistence is enabled),
+// applies it to the in-memory state and compacts the log once enough entries
+// have accumulated. The caller must hold the write lock.
+func (m *MemoryDB) commit(entry memoryLogEntry) error {
+	if m.store != nil {
+		if err := m.store.append(entry); err != nil {
+			return err
+		}
+	}
+	if err := m.apply(entry); err != nil {
+		return err
+	}
+	if m.store != nil && m.store.needsSnapshot() {
+		if err := m.store.snapshot(m.collections); err != nil {
+			GlobalLogger.Warn("Failed to compact MemoryDB log", "error", err)
+		}
+	}
+	return nil
+}
+
+// apply performs a logged operation on the in-memory state. It is used both
+// for live operations and for replaying the log. The caller must hold the write lock.
+func (m *MemoryDB) apply(entry memoryLogEntry) error {
+	if entry.Op == memoryOpCreate {
+		m.collections[entry.Collection] = &Collection{Schema: entry.Schema}
+		return nil
+	}
+	if entry.Op == memoryOpDrop {
+		if collection, exists := m.collections[entry.Collection]; exists {
+			collection.closeSpills()
+		}
+		delete(m.collections, entry.Collection)
+		return nil
+	}
+
+	collection, exists := m.collections[entry.Collection]
+	if !exists {
+		return collectionNotFound(entry.Collection)
+	}
+	switch entry.Op {
+	case memoryOpInsert:
+		collection.insert(entry.Records)
+	case memoryOpUpsert:
+		collection.upsert(entry.Records)
+	case memoryOpDelete:
+		collection.delete(entry.IDs)
+	case memoryOpDeleteByFilter:
+		collection.deleteByFilter(entry.Filter)
+	case memoryOpCreateIndex:
+		collection.createIndex(entry.Field, entry.Index)
+	default:
+		return fmt.Errorf("unknown MemoryDB operation %q", entry.Op)
+	}
+	return nil
+}
+
+// insert appends records to the collection and its indexes.
+func (collection *Collection) insert(data []Record) {
+	for _, record := range data {
+		collection.Data = append(collection.Data, collection.storeVectors(len(collection.Data), record))
+		collection.quantize(len(collection.Data) - 1)
+		for _, graph := range collection.graphs {
+			graph.nodeOf = append(graph.nodeOf, graph.add(collection.Vectors[graph.field], len(collection.Data)-1))
+		}
+	}
+}
+
+// upsert replaces records that share an ID with one of data and appends the
+// rest. Records without an ID are simply appended.
+func (collection *Collection) upsert(data []Record) {
+	positions := make(map[int64]int, len(collection.Data))
+	for i, record := range collection.Data {
+		if id, ok := recordID(record); ok {
+			positions[id] = i
+		}
+	}
+
+	for _, record := range data {
+		id, ok := recordID(record)
+		if !ok {
+			collection.insert([]Record{record})
+			continue
+		}
+		if i, exists := positions[id]; exists {
+			// Tombstone the old nodes before their rows are overwritten
+			for _, graph := range collection.graphs {
+				graph.remove(graph.nodeOf[i])
+			}
+			collection.Data[i] = collection.storeVectors(i, record)
+			collection.quantize(i)
+			for _, graph := range collection.graphs {
+				graph.nodeOf[i] = graph.add(collection.Vectors[graph.field], i)
+			}
+			continue
+		}
+		positions[id] = len(collection.Data)
+		collection.insert([]Record{record})
+	}
+	collection.compactIndexes()
+}
+
+// delete removes the records with the given IDs.
+func (collection *Collection) delete(ids []int64) {
+	remove := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		remove[id] = struct{}{}
+	}
+
+	collection.retain(func(record Record) bool {
+		if id, ok := recordID(record); ok {
+			if _, found := remove[id]; found {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// deleteByFilter removes every record matching filter.
+func (collection *Collection) deleteByFilter(filter *Filter) {
+	collection.retain(func(record Record) bool {
+		return !filter.Matches(record)
+	})
+}
+
+// retain keeps only the records for which keep returns true, tombstoning
+// the index nodes of the removed records.
+func (collection *Collection) retain(keep func(Record) bool) {
+	// Tombstone first, while the removed records' rows still hold their vectors
+	keeps := make([]bool, len(collection.Data))
+	for i, record := range collection.Data {
+		keeps[i] = keep(record)
+		if !keeps[i] {
+			for _, graph := range collection.graphs {
+				graph.remove(graph.nodeOf[i])
+			}
+		}
+	}
+
+	kept := collection.Data[:0]
+	for i, record := range collection.Data {
+		if !keeps[i] {
+			continue
+		}
+		for _, column := range collection.Vectors {
+			column.move(len(kept), i)
+		}
+		for _, q := range collection.quantized {
+			q.move(len(kept), i)
+		}
+		for _, graph := range collection.graphs {
+			graph.nodeOf[len(kept)] = graph.nodeOf[i]
+			graph.setRow(graph.nodeOf[i], len(kept))
+		}
+		kept = append(kept, record)
+	}
+	for _, column := range collection.Vectors {
+		column.truncate(len(kept))
+	}
+	for _, q := range collection.quantized {
+		q.truncate(len(kept))
+	}
+	for _, graph := range collection.graphs {
+		graph.nodeOf = graph.nodeOf[:len(kept)]
+	}
+	collection.Data = kept
+	collection.compactIndexes()
+}
+
+// createIndex records the index definition for field and builds its graph.
+func (collection *Collection) createIndex(field string, index Index) {
+	if collection.Indexes == nil {
+		collection.Indexes = make(map[string]Index)
+	}
+	collection.Indexes[field] = index
+	collection.buildIndex(field)
+}
+
+// buildIndex (re)builds the graph or quantized codes of field from the live
+// records, replacing any index of the other kind.
+func (collection *Collection) buildIndex(field string) {
+	if index := collection.Indexes[field]; isQuantizedIndex(index.Type) {
+		delete(collection.graphs, field)
+		collection.buildQuantized(field, index)
+		return
+	}
+	delete(collection.quantized, field)
+	if column, ok := collection.Vectors[field]; ok {
+		column.unspill()
+	}
+
+	graph := newHNSWGraph(field, collection.Indexes[field], true)
+	graph.nodeOf = make([]int32, len(collection.Data))
+	for i := range collection.Data {
+		graph.nodeOf[i] = graph.add(collection.Vectors[field], i)
+	}
+	if collection.graphs == nil {
+		collection.graphs = make(map[string]*hnswGraph)
+	}
+	collection.graphs[field] = graph
+}
+
+// rebuildIndexes builds the graph of every indexed field, e.g. after the
+// collection has been loaded from a snapshot.
+func (collection *Collection) rebuildIndexes() {
+	for field := range collection.Indexes {
+		collection.buildIndex(field)
+	}
+}
+
+// compactIndexes rebuilds graphs in which deleted records have piled up.
+func (collection *Collection) compactIndexes() {
+	for field, graph := range collection.graphs {
+		if graph.needsRebuild() {
+			collection.buildIndex(field)
+		}
+	}
+}
+
+// graphFor returns the HNSW graph that can answer a single-field query with
+// the resolved metricType, or nil when the search must fall back to a linear scan.
+func (collection *Collection) graphFor(vectors map[string]Vector, metricType string) *hnswGraph {
+	if len(vectors) != 1 {
+		return nil
+	}
+	for field := range vectors {
+		if graph, ok := collection.graphs[field]; ok && graph.metric == metricType {
+			return graph
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+)
+
+// vectorSpill holds the rows of a vectorColumn in a temporary file instead
+// of memory. MemoryDB spills the columns of fields with an SQ8 or BINARY
+// index, whose codes answer searches, and reads back only the rows it
+// rescores or returns. The file is unlinked as soon as it is created where
+// the platform allows it, so nothing is left behind, and is closed when the
+// column is dropped or garbage collected.
+type vectorSpill struct {
+	file *os.File
+	dim  int
+}
+
+// newVectorSpill creates an empty spill file for rows of dimension dim.
+func newVectorSpill(dim int) (*vectorSpill, error) {
+	file, err := os.CreateTemp("", "raggo-vectors-*.f32")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vector spill file: %w", err)
+	}
+	// Unlink right away; the open file stays readable until it is closed.
+	os.Remove(file.Name())
+	s := &vectorSpill{file: file, dim: dim}
+	runtime.SetFinalizer(s, (*vectorSpill).close)
+	return s, nil
+}
+
+// read decodes row i into dst. Rows past the end of the file read as zeros.
+func (s *vectorSpill) read(i int, dst []float32) {
+	buf := make([]byte, 4*s.dim)
+	n, err := s.file.ReadAt(buf, int64(i)*int64(len(buf)))
+	if err != nil && err != io.EOF {
+		GlobalLogger.Error("Failed to read spilled vector", "row", i, "error", err)
+	}
+	clear(buf[n:])
+	for j := range dst {
+		dst[j] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*j:]))
+	}
+}
+
+// write encodes row into row i of the file.
+func (s *vectorSpill) write(i int, row []float32) {
+	buf := make([]byte, 4*s.dim)
+	for j, value := range row {
+		binary.LittleEndian.PutUint32(buf[4*j:], math.Float32bits(value))
+	}
+	if _, err := s.file.WriteAt(buf, int64(i)*int64(len(buf))); err != nil {
+		GlobalLogger.Error("Failed to write spilled vector", "row", i, "error", err)
+	}
+}
+
+// truncate keeps the first n rows.
+func (s *vectorSpill) truncate(n int) {
+	if err := s.file.Truncate(int64(n) * int64(4*s.dim)); err != nil {
+		GlobalLogger.Error("Failed to truncate spilled vectors", "rows", n, "error", err)
+	}
+}
+
+// clone copies the file into a new spill, for snapshots that must not see
+// later writes.
+func (s *vectorSpill) clone() (*vectorSpill, error) {
+	copied, err := newVectorSpill(s.dim)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(copied.file, io.NewSectionReader(s.file, 0, math.MaxInt64)); err != nil {
+		copied.close()
+		return nil, fmt.Errorf("failed to copy spilled vectors: %w", err)
+	}
+	return copied, nil
+}
+
+// close closes the file, which the system then deletes.
+func (s *vectorSpill) close() {
+	runtime.SetFinalizer(s, nil)
+	s.file.Close()
+}
+
+// spill moves the rows of the column to a spill file, keeping only the norms
+// and presence flags in memory. It is a no-op for spilled columns.
+func (c *vectorColumn) spill() error {
+	if c.spilled != nil {
+		return nil
+	}
+	s, err := newVectorSpill(c.Dim)
+	if err != nil {
+		return err
+	}
+	for i := range c.Present {
+		if c.Present[i] {
+			s.write(i, c.row(i))
+		}
+	}
+	c.spilled = s
+	c.Values = nil
+	return nil
+}
+
+// unspill reads the rows of a spilled column back into memory.
+func (c *vectorColumn) unspill() {
+	if c.spilled == nil {
+		return
+	}
+	values := make([]float32, c.rows()*c.Dim)
+	for i := range c.Present {
+		if c.Present[i] {
+			c.spilled.read(i, values[i*c.Dim:(i+1)*c.Dim])
+		}
+	}
+	c.spilled.close()
+	c.spilled = nil
+	c.Values = values
+}
+
+// inMemory returns the column with its rows in memory: the column itself,
+// or a copy read back from its spill file.
+func (c *vectorColumn) inMemory() *vectorColumn {
+	if c.spilled == nil {
+		return c
+	}
+	copied := &vectorColumn{
+		Dim:     c.Dim,
+		Values:  make([]float32, c.rows()*c.Dim),
+		Norms:   c.Norms,
+		Present: c.Present,
+	}
+	for i := range c.Present {
+		if c.Present[i] {
+			c.spilled.read(i, copied.row(i))
+		}
+	}
+	return copied
+}
+
+// spillColumn spills the column of a quantized field. When no spill file
+// can be created the rows stay in memory, which only costs the saving.
+func (collection *Collection) spillColumn(field string) {
+	column, ok := collection.Vectors[field]
+	if !ok {
+		return
+	}
+	if err := column.spill(); err != nil {
+		GlobalLogger.Warn("Keeping quantized vectors in memory", "field", field, "error", err)
+	}
+}
+
+// closeSpills closes the spill files of the collection's columns.
+func (collection *Collection) closeSpills() {
+	for _, column := range collection.Vectors {
+		if column.spilled != nil {
+			column.spilled.close()
+			column.spilled = nil
+		}
+	}
+}
+
+// withColumnsInMemory returns the collections with every spilled column read
+// back into memory, for encoding them into a snapshot. Collections without
+// spilled columns are returned as they are.
+func withColumnsInMemory(collections map[string]*Collection) map[string]*Collection {
+	copied := make(map[string]*Collection, len(collections))
+	for name, collection := range collections {
+		copied[name] = collection
+		for field, column := range collection.Vectors {
+			if column.spilled == nil {
+				continue
+			}
+			if copied[name] == collection {
+				shallow := *collection
+				shallow.Vectors = make(map[string]*vectorColumn, len(collection.Vectors))
+				for k, v := range collection.Vectors {
+					shallow.Vectors[k] = v
+				}
+				copied[name] = &shallow
+			}
+			copied[name].Vectors[field] = column.inMemory()
+		}
+	}
+	return copied
+}
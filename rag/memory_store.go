@@ -0,0 +1,303 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// memoryLogFile is the append-only operation log inside the data directory
+	memoryLogFile = "memory.log"
+	// memorySnapshotFile is the compacted snapshot inside the data directory
+	memorySnapshotFile = "memory.snapshot"
+	// defaultSnapshotInterval is the number of logged operations after which
+	// the log is compacted into a new snapshot
+	defaultSnapshotInterval = 1000
+)
+
+// memoryOp identifies a mutating operation recorded in the MemoryDB log.
+type memoryOp string
+
+const (
+	memoryOpCreate         memoryOp = "create"
+	memoryOpDrop           memoryOp = "drop"
+	memoryOpInsert         memoryOp = "insert"
+	memoryOpUpsert         memoryOp = "upsert"
+	memoryOpDelete         memoryOp = "delete"
+	memoryOpDeleteByFilter memoryOp = "delete_by_filter"
+	memoryOpCreateIndex    memoryOp = "create_index"
+)
+
+// memoryLogEntry is a single operation in the MemoryDB log. Only the fields
+// relevant to Op are set.
+type memoryLogEntry struct {
+	Seq        uint64
+	Op         memoryOp
+	Collection string
+	Schema     Schema
+	Records    []Record
+	IDs        []int64
+	Filter     *Filter
+	Field      string
+	Index      Index
+}
+
+// memorySnapshot is the compacted state of every collection at one point in time.
+type memorySnapshot struct {
+	// Seq is the sequence number of the last log entry included in the snapshot
+	Seq         uint64
+	Collections map[string]*Collection
+}
+
+func init() {
+	// Record fields and filter values are stored as interface{} values, so
+	// every concrete type that commonly appears in them must be registered.
+	gob.Register(Vector{})
+	gob.Register(map[string]interface{}{})
+	gob.Register(map[string]string{})
+	gob.Register([]interface{}{})
+}
+
+// memoryStore persists MemoryDB state in a data directory as a compacted
+// snapshot plus an append-only log of the operations applied since.
+//
+// Each log entry is framed as a 4-byte length, a 4-byte CRC32 checksum and a
+// gob-encoded memoryLogEntry. A torn or corrupt frame at the end of the log
+// (e.g. after a crash mid-write) is discarded on replay. Entries carry a
+// sequence number so that entries already folded into the snapshot are
+// skipped if a crash happens between writing the snapshot and truncating the log.
+type memoryStore struct {
+	dir              string   // Data directory
+	log              *os.File // Open append-only log
+	seq              uint64   // Sequence number of the last entry written
+	entries          int      // Entries written since the last snapshot
+	snapshotInterval int      // Entries between automatic snapshots
+	syncWrites       bool     // Fsync the log after every entry
+}
+
+// openMemoryStore prepares the data directory and returns a store ready to
+// load. The log is not opened until load is called.
+func openMemoryStore(dir string, snapshotInterval int, syncWrites bool) (*memoryStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create MemoryDB data directory %s: %w", dir, err)
+	}
+	if snapshotInterval <= 0 {
+		snapshotInterval = defaultSnapshotInterval
+	}
+	return &memoryStore{
+		dir:              dir,
+		snapshotInterval: snapshotInterval,
+		syncWrites:       syncWrites,
+	}, nil
+}
+
+// load reads the latest snapshot and returns it together with the log
+// entries written after it. The log is then opened for appending.
+func (s *memoryStore) load() (map[string]*Collection, []memoryLogEntry, error) {
+	snapshot, err := s.readSnapshot()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logPath := filepath.Join(s.dir, memoryLogFile)
+	file, err := os.OpenFile(logPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open MemoryDB log: %w", err)
+	}
+
+	entries, validSize, err := readMemoryLog(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	// Drop any torn frame so new entries are appended after the last good one.
+	if err := file.Truncate(validSize); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to truncate MemoryDB log: %w", err)
+	}
+	if _, err := file.Seek(validSize, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to seek MemoryDB log: %w", err)
+	}
+
+	// Skip entries that the snapshot already reflects.
+	s.seq = snapshot.Seq
+	pending := entries[:0]
+	for _, entry := range entries {
+		if entry.Seq <= snapshot.Seq {
+			continue
+		}
+		pending = append(pending, entry)
+		s.seq = entry.Seq
+	}
+
+	s.log = file
+	s.entries = len(pending)
+	return snapshot.Collections, pending, nil
+}
+
+// readSnapshot decodes the snapshot file, returning an empty state when no
+// snapshot has been written yet.
+func (s *memoryStore) readSnapshot() (*memorySnapshot, error) {
+	file, err := os.Open(filepath.Join(s.dir, memorySnapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &memorySnapshot{Collections: make(map[string]*Collection)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MemoryDB snapshot: %w", err)
+	}
+	defer file.Close()
+
+	var snapshot memorySnapshot
+	if err := gob.NewDecoder(bufio.NewReader(file)).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode MemoryDB snapshot: %w", err)
+	}
+	if snapshot.Collections == nil {
+		snapshot.Collections = make(map[string]*Collection)
+	}
+	return &snapshot, nil
+}
+
+// readMemoryLog decodes every intact frame of the log. It returns the
+// entries and the byte offset just past the last intact frame.
+func readMemoryLog(r io.Reader) ([]memoryLogEntry, int64, error) {
+	reader := bufio.NewReader(r)
+	var entries []memoryLogEntry
+	var offset int64
+	header := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			// EOF or a partial header: the log ends here.
+			return entries, offset, nil
+		}
+		size := binary.LittleEndian.Uint32(header[0:4])
+		checksum := binary.LittleEndian.Uint32(header[4:8])
+
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			GlobalLogger.Warn("Discarding truncated MemoryDB log entry", "offset", offset)
+			return entries, offset, nil
+		}
+		if crc32.ChecksumIEEE(payload) != checksum {
+			GlobalLogger.Warn("Discarding corrupt MemoryDB log entry", "offset", offset)
+			return entries, offset, nil
+		}
+
+		var entry memoryLogEntry
+		if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&entry); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode MemoryDB log entry at offset %d: %w", offset, err)
+		}
+		entries = append(entries, entry)
+		offset += int64(len(header)) + int64(size)
+	}
+}
+
+// append assigns the next sequence number to entry and writes it to the log.
+func (s *memoryStore) append(entry memoryLogEntry) error {
+	entry.Seq = s.seq + 1
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(entry); err != nil {
+		return fmt.Errorf("failed to encode MemoryDB log entry: %w", err)
+	}
+
+	frame := make([]byte, 8, 8+payload.Len())
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(payload.Len()))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload.Bytes()))
+	frame = append(frame, payload.Bytes()...)
+
+	offset, err := s.log.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to seek MemoryDB log: %w", err)
+	}
+	if _, err := s.log.Write(frame); err != nil {
+		// Roll back a partially written frame so later entries stay readable.
+		s.log.Truncate(offset)
+		s.log.Seek(offset, io.SeekStart)
+		return fmt.Errorf("failed to write MemoryDB log entry: %w", err)
+	}
+	if s.syncWrites {
+		if err := s.log.Sync(); err != nil {
+			return fmt.Errorf("failed to sync MemoryDB log: %w", err)
+		}
+	}
+	s.seq = entry.Seq
+	s.entries++
+	return nil
+}
+
+// needsSnapshot reports whether enough entries have accumulated to compact the log.
+func (s *memoryStore) needsSnapshot() bool {
+	return s.entries >= s.snapshotInterval
+}
+
+// snapshot atomically replaces the snapshot file with the given state and
+// truncates the log, whose entries are now all reflected in the snapshot.
+func (s *memoryStore) snapshot(collections map[string]*Collection) error {
+	tmpPath := filepath.Join(s.dir, memorySnapshotFile+".tmp")
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create MemoryDB snapshot: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	if err := gob.NewEncoder(writer).Encode(memorySnapshot{Seq: s.seq, Collections: withColumnsInMemory(collections)}); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to encode MemoryDB snapshot: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write MemoryDB snapshot: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync MemoryDB snapshot: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close MemoryDB snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, memorySnapshotFile)); err != nil {
+		return fmt.Errorf("failed to install MemoryDB snapshot: %w", err)
+	}
+
+	if err := s.log.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate MemoryDB log: %w", err)
+	}
+	if _, err := s.log.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek MemoryDB log: %w", err)
+	}
+	s.entries = 0
+	return nil
+}
+
+// sync flushes the log to stable storage.
+func (s *memoryStore) sync() error {
+	if err := s.log.Sync(); err != nil {
+		return fmt.Errorf("failed to sync MemoryDB log: %w", err)
+	}
+	return nil
+}
+
+// close closes the log file.
+func (s *memoryStore) close() error {
+	if s.log == nil {
+		return nil
+	}
+	err := s.log.Close()
+	s.log = nil
+	return err
+}
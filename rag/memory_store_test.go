@@ -0,0 +1,186 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// openTestMemoryDB connects a MemoryDB persisted in dir.
+func openTestMemoryDB(t *testing.T, dir string, params map[string]interface{}) *MemoryDB {
+	t.Helper()
+	db, err := newMemoryDB(&Config{Address: dir, Parameters: params})
+	if err != nil {
+		t.Fatalf("newMemoryDB: %v", err)
+	}
+	if err := db.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return db
+}
+
+// crash abandons db without the snapshot Close writes, as if the process
+// had died, leaving the state in the log.
+func crash(t *testing.T, db *MemoryDB) {
+	t.Helper()
+	if err := db.store.close(); err != nil {
+		t.Fatalf("closing the log: %v", err)
+	}
+	db.store = nil
+}
+
+// storedTexts returns the Text of every record of the "docs" collection by
+// ID, failing the test if an ID is stored twice.
+func storedTexts(t *testing.T, db *MemoryDB) map[int64]string {
+	t.Helper()
+	texts := make(map[int64]string)
+	err := db.Scan(context.Background(), "docs", 0, func(records []Record) error {
+		for _, record := range records {
+			id := record.Fields["ID"].(int64)
+			if _, ok := texts[id]; ok {
+				t.Errorf("record %d is stored twice", id)
+			}
+			texts[id] = record.Fields["Text"].(string)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	return texts
+}
+
+func textRecord(id int64, text string) Record {
+	return Record{Fields: map[string]interface{}{
+		"ID":        id,
+		"Embedding": Vector{float64(id), 1},
+		"Text":      text,
+		"Metadata":  map[string]interface{}{"chunk": int(id)},
+	}}
+}
+
+// writeOps applies inserts, an upsert and deletes to a new "docs" collection.
+func writeOps(t *testing.T, db *MemoryDB) {
+	t.Helper()
+	ctx := context.Background()
+	if err := db.CreateCollection(ctx, "docs", Schema{}); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	if err := db.Insert(ctx, "docs", []Record{textRecord(1, "one"), textRecord(2, "two"), textRecord(3, "three")}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := db.Upsert(ctx, "docs", []Record{textRecord(2, "two v2"), textRecord(4, "four")}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if err := db.Delete(ctx, "docs", []int64{1}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := db.DeleteByFilter(ctx, "docs", Eq("chunk", 3)); err != nil {
+		t.Fatalf("DeleteByFilter: %v", err)
+	}
+}
+
+func TestMemoryStoreReplay(t *testing.T) {
+	want := map[int64]string{2: "two v2", 4: "four"}
+
+	t.Run("log only", func(t *testing.T) {
+		dir := t.TempDir()
+		db := openTestMemoryDB(t, dir, nil)
+		writeOps(t, db)
+		crash(t, db)
+
+		db = openTestMemoryDB(t, dir, nil)
+		defer db.Close()
+		if got := storedTexts(t, db); !reflect.DeepEqual(got, want) {
+			t.Errorf("records after replay = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("snapshot and log", func(t *testing.T) {
+		// Snapshots are taken every two operations, so the last one is only logged
+		dir := t.TempDir()
+		params := map[string]interface{}{"snapshot_interval": 2}
+		db := openTestMemoryDB(t, dir, params)
+		writeOps(t, db)
+		crash(t, db)
+		if _, err := os.Stat(filepath.Join(dir, memorySnapshotFile)); err != nil {
+			t.Fatalf("no snapshot was written: %v", err)
+		}
+
+		db = openTestMemoryDB(t, dir, params)
+		defer db.Close()
+		if got := storedTexts(t, db); !reflect.DeepEqual(got, want) {
+			t.Errorf("records after replay = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("log not truncated after snapshot", func(t *testing.T) {
+		// A crash between writing the snapshot and truncating the log leaves
+		// entries the snapshot already reflects; replaying them again would
+		// recreate the collection and bring back record 4.
+		dir := t.TempDir()
+		db := openTestMemoryDB(t, dir, nil)
+		writeOps(t, db)
+		logPath := filepath.Join(dir, memoryLogFile)
+		stale, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Delete(context.Background(), "docs", []int64{4}); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := db.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if err := os.WriteFile(logPath, stale, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		db = openTestMemoryDB(t, dir, nil)
+		defer db.Close()
+		want := map[int64]string{2: "two v2"}
+		if got := storedTexts(t, db); !reflect.DeepEqual(got, want) {
+			t.Errorf("records after replay = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("truncated trailing op", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+		db := openTestMemoryDB(t, dir, nil)
+		writeOps(t, db)
+		if err := db.Upsert(ctx, "docs", []Record{textRecord(5, "five")}); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+		crash(t, db)
+
+		// Tear the frame of the last upsert, as a crash mid-write would
+		logPath := filepath.Join(dir, memoryLogFile)
+		info, err := os.Stat(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Truncate(logPath, info.Size()-3); err != nil {
+			t.Fatal(err)
+		}
+
+		db = openTestMemoryDB(t, dir, nil)
+		if got := storedTexts(t, db); !reflect.DeepEqual(got, want) {
+			t.Errorf("records after replay = %v, want %v without the torn upsert", got, want)
+		}
+
+		// The torn frame is dropped, so operations logged after it replay too
+		if err := db.Upsert(ctx, "docs", []Record{textRecord(6, "six")}); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+		crash(t, db)
+		db = openTestMemoryDB(t, dir, nil)
+		defer db.Close()
+		want := map[int64]string{2: "two v2", 4: "four", 6: "six"}
+		if got := storedTexts(t, db); !reflect.DeepEqual(got, want) {
+			t.Errorf("records after the second replay = %v, want %v", got, want)
+		}
+	})
+}
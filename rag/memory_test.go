@@ -0,0 +1,550 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+)
+
+// clusteredVectors draws vectors from a mixture of Gaussian clusters, which
+// resembles the structure of text embeddings better than uniform noise.
+func clusteredVectors(rng *rand.Rand, n, dim int) []Vector {
+	const clusters = 64
+	centers := make([]Vector, clusters)
+	for i := range centers {
+		centers[i] = make(Vector, dim)
+		for j := range centers[i] {
+			centers[i][j] = rng.NormFloat64()
+		}
+	}
+
+	vectors := make([]Vector, n)
+	for i := range vectors {
+		center := centers[rng.Intn(clusters)]
+		vectors[i] = make(Vector, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = center[j] + 0.5*rng.NormFloat64()
+		}
+	}
+	return vectors
+}
+
+// benchRecords wraps vectors into records with sequential IDs from first.
+func benchRecords(vectors []Vector, first int) []Record {
+	records := make([]Record, len(vectors))
+	for i, v := range vectors {
+		records[i] = Record{Fields: map[string]interface{}{
+			"ID":        int64(first + i),
+			"Embedding": v,
+		}}
+	}
+	return records
+}
+
+// newBenchDB creates an in-memory database holding a "bench" collection
+// populated with data, indexed with index first when its Type is set.
+func newBenchDB(tb testing.TB, data []Vector, index Index) *MemoryDB {
+	tb.Helper()
+	ctx := context.Background()
+	db, err := newMemoryDB(&Config{Type: "memory"})
+	if err != nil {
+		tb.Fatalf("newMemoryDB: %v", err)
+	}
+	if err := db.CreateCollection(ctx, "bench", Schema{Name: "bench"}); err != nil {
+		tb.Fatalf("CreateCollection: %v", err)
+	}
+	if index.Type != "" {
+		if err := db.CreateIndex(ctx, "bench", "Embedding", index); err != nil {
+			tb.Fatalf("CreateIndex: %v", err)
+		}
+	}
+	if err := db.Insert(ctx, "bench", benchRecords(data, 0)); err != nil {
+		tb.Fatalf("Insert: %v", err)
+	}
+	return db
+}
+
+// searchIDs returns the IDs of the k results of a single-field search.
+func searchIDs(tb testing.TB, db VectorDB, query Vector, k int, params map[string]interface{}) []int64 {
+	tb.Helper()
+	results, err := db.Search(context.Background(), "bench", map[string]Vector{"Embedding": query}, k, "L2", params)
+	if err != nil {
+		tb.Fatalf("Search: %v", err)
+	}
+	return resultIDs(results)
+}
+
+// resultIDs returns the IDs of results in order.
+func resultIDs(results []SearchResult) []int64 {
+	ids := make([]int64, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+// recall returns the fraction of the exact results of each query, computed
+// by a linear scan of exact, that db also returns.
+func recall(tb testing.TB, db, exact VectorDB, queries []Vector, k int, params map[string]interface{}) float64 {
+	tb.Helper()
+	var hits int
+	for _, q := range queries {
+		truth := make(map[int64]bool, k)
+		for _, id := range searchIDs(tb, exact, q, k, nil) {
+			truth[id] = true
+		}
+		for _, id := range searchIDs(tb, db, q, k, params) {
+			if truth[id] {
+				hits++
+			}
+		}
+	}
+	return float64(hits) / float64(len(queries)*k)
+}
+
+var hnswIndex = Index{
+	Type:       "HNSW",
+	Metric:     "L2",
+	Parameters: map[string]interface{}{"M": 16, "efConstruction": 200},
+}
+
+func TestHNSWRecall(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := clusteredVectors(rng, 3000, 32)
+	queries := clusteredVectors(rng, 50, 32)
+
+	tests := []struct {
+		name   string
+		mutate func(t *testing.T, db *MemoryDB)
+	}{
+		{name: "insert"},
+		{
+			// Deleting compacts the rows, so live nodes must follow their
+			// vectors and tombstones keep theirs
+			name: "delete",
+			mutate: func(t *testing.T, db *MemoryDB) {
+				ids := make([]int64, 0, len(data)/3)
+				for id := 0; id < len(data); id += 3 {
+					ids = append(ids, int64(id))
+				}
+				if err := db.Delete(context.Background(), "bench", ids); err != nil {
+					t.Fatalf("Delete: %v", err)
+				}
+			},
+		},
+		{
+			// Upserting overwrites rows in place
+			name: "upsert",
+			mutate: func(t *testing.T, db *MemoryDB) {
+				replaced := clusteredVectors(rand.New(rand.NewSource(2)), len(data)/2, 32)
+				if err := db.Upsert(context.Background(), "bench", benchRecords(replaced, 0)); err != nil {
+					t.Fatalf("Upsert: %v", err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexed := newBenchDB(t, data, hnswIndex)
+			exact := newBenchDB(t, data, Index{})
+			if tt.mutate != nil {
+				tt.mutate(t, indexed)
+				tt.mutate(t, exact)
+			}
+			if got := recall(t, indexed, exact, queries, 10, map[string]interface{}{"ef": 64}); got < 0.9 {
+				t.Errorf("recall@10 = %.3f, want at least 0.9", got)
+			}
+		})
+	}
+}
+
+func TestHNSWFilter(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := clusteredVectors(rng, 500, 16)
+	records := benchRecords(data, 0)
+	for i, record := range records {
+		record.Fields["Metadata"] = map[string]interface{}{"even": i%2 == 0}
+	}
+
+	ctx := context.Background()
+	db := newBenchDB(t, nil, hnswIndex)
+	if err := db.Insert(ctx, "bench", records); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	params := map[string]interface{}{FilterParam: Eq("even", true)}
+	for _, id := range searchIDs(t, db, data[1], 10, params) {
+		if id%2 != 0 {
+			t.Errorf("filtered search returned odd ID %d", id)
+		}
+	}
+}
+
+func TestMemorySearchBatch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := clusteredVectors(rng, 300, 8)
+	records := benchRecords(data, 0)
+	for i, record := range records {
+		record.Fields["Metadata"] = map[string]interface{}{"even": i%2 == 0}
+	}
+	queries := clusteredVectors(rng, 40, 8)
+
+	ctx := context.Background()
+	for _, index := range []Index{{}, hnswIndex} {
+		name := index.Type
+		if name == "" {
+			name = "flat"
+		}
+		t.Run(name, func(t *testing.T) {
+			db := newBenchDB(t, nil, index)
+			if err := db.Insert(ctx, "bench", records); err != nil {
+				t.Fatalf("Insert: %v", err)
+			}
+
+			// Every result list is the one Search returns for its query
+			for _, params := range []map[string]interface{}{nil, {FilterParam: Eq("even", true)}} {
+				batch, err := db.SearchBatch(ctx, "bench", "Embedding", queries, 5, "L2", params)
+				if err != nil {
+					t.Fatalf("SearchBatch: %v", err)
+				}
+				if len(batch) != len(queries) {
+					t.Fatalf("SearchBatch returned %d result lists for %d queries", len(batch), len(queries))
+				}
+				for i, query := range queries {
+					if got, want := resultIDs(batch[i]), searchIDs(t, db, query, 5, params); !reflect.DeepEqual(got, want) {
+						t.Errorf("params %v, query %d: SearchBatch found %v, Search found %v", params, i, got, want)
+					}
+				}
+			}
+		})
+	}
+
+	db := newBenchDB(t, data, Index{})
+	if batch, err := db.SearchBatch(ctx, "bench", "Embedding", nil, 5, "L2", nil); err != nil || len(batch) != 0 {
+		t.Errorf("SearchBatch without queries = %v, %v, want no result lists", batch, err)
+	}
+	if _, err := db.SearchBatch(ctx, "missing", "Embedding", queries, 5, "L2", nil); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("SearchBatch on a missing collection: error = %v", err)
+	}
+
+	// One bad query fails the whole batch
+	schema := Schema{Name: "sized", Fields: []Field{{Name: "Embedding", DataType: "float_vector", Dimension: 8}}}
+	if err := db.CreateCollection(ctx, "sized", schema); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	bad := append(append([]Vector{}, queries...), Vector{1, 2})
+	if _, err := db.SearchBatch(ctx, "sized", "Embedding", bad, 5, "L2", nil); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("SearchBatch with a short query: error = %v, want ErrDimensionMismatch", err)
+	}
+}
+
+func TestMemoryIntrospection(t *testing.T) {
+	ctx := context.Background()
+	db, _ := newMemoryDB(&Config{})
+	if names, err := db.ListCollections(ctx); err != nil || len(names) != 0 {
+		t.Errorf("ListCollections of an empty database = %v, %v", names, err)
+	}
+
+	schema := Schema{Name: "docs", Fields: []Field{
+		{Name: "ID", DataType: "int64", PrimaryKey: true},
+		{Name: "Embedding", DataType: "float_vector", Dimension: 3},
+	}}
+	for _, name := range []string{"docs", "archive"} {
+		if err := db.CreateCollection(ctx, name, schema); err != nil {
+			t.Fatalf("CreateCollection(%s): %v", name, err)
+		}
+	}
+	if names, err := db.ListCollections(ctx); err != nil || !reflect.DeepEqual(names, []string{"archive", "docs"}) {
+		t.Errorf("ListCollections = %v, %v, want archive and docs in order", names, err)
+	}
+
+	stats, err := db.Stats(ctx, "docs")
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	want := CollectionStats{Dimension: 3, IndexStatus: IndexStatusNone}
+	if *stats != want {
+		t.Errorf("Stats of an empty collection = %+v, want %+v", *stats, want)
+	}
+
+	records := benchRecords([]Vector{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}, 0)
+	if err := db.Insert(ctx, "docs", records); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := db.Delete(ctx, "docs", []int64{1}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if count, err := db.Count(ctx, "docs"); err != nil || count != 2 {
+		t.Errorf("Count = %d, %v, want 2", count, err)
+	}
+	filled, err := db.Stats(ctx, "docs")
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if filled.Count != 2 || filled.StorageSize <= stats.StorageSize || filled.IndexStatus != IndexStatusNone {
+		t.Errorf("Stats after inserting 2 records = %+v, want them counted and sized", *filled)
+	}
+
+	// Creating an index shows in the description and the stats
+	info, err := db.DescribeCollection(ctx, "docs")
+	if err != nil {
+		t.Fatalf("DescribeCollection: %v", err)
+	}
+	if info.Name != "docs" || !reflect.DeepEqual(info.Schema, schema) || len(info.Indexes) != 0 {
+		t.Errorf("DescribeCollection = %+v, want the schema and no index", info)
+	}
+	if err := db.CreateIndex(ctx, "docs", "Embedding", hnswIndex); err != nil {
+		t.Fatalf("CreateIndex: %v", err)
+	}
+	info, err = db.DescribeCollection(ctx, "docs")
+	if err != nil {
+		t.Fatalf("DescribeCollection: %v", err)
+	}
+	if !reflect.DeepEqual(info.Indexes, map[string]Index{"Embedding": hnswIndex}) {
+		t.Errorf("indexes after CreateIndex = %+v", info.Indexes)
+	}
+	if stats, err := db.Stats(ctx, "docs"); err != nil || stats.IndexStatus != IndexStatusReady {
+		t.Errorf("Stats after CreateIndex = %+v, %v, want a ready index", stats, err)
+	}
+
+	// The description is a copy the caller cannot change the collection through
+	delete(info.Indexes, "Embedding")
+	if info, _ := db.DescribeCollection(ctx, "docs"); len(info.Indexes) != 1 {
+		t.Errorf("changing a description changed the collection's indexes to %+v", info.Indexes)
+	}
+
+	if _, err := db.DescribeCollection(ctx, "missing"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("DescribeCollection of a missing collection: error = %v", err)
+	}
+	if _, err := db.Count(ctx, "missing"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Count of a missing collection: error = %v", err)
+	}
+	if _, err := db.Stats(ctx, "missing"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Stats of a missing collection: error = %v", err)
+	}
+}
+
+// heapInUse returns the bytes of live heap objects after a collection.
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// TestQuantizedMemory checks that quantizing a field releases its float32
+// vectors from the heap, and that searches rescored from the spilled copy
+// keep their recall through deletes and a snapshot reload.
+func TestQuantizedMemory(t *testing.T) {
+	const n, dim = 20000, 256
+	rng := rand.New(rand.NewSource(1))
+	data := clusteredVectors(rng, n, dim)
+	// Query near stored vectors, as clusteredVectors draws new centers
+	queries := make([]Vector, 50)
+	for i := range queries {
+		queries[i] = make(Vector, dim)
+		for j, value := range data[rng.Intn(n)] {
+			queries[i][j] = value + 0.2*rng.NormFloat64()
+		}
+	}
+	floatBytes := float64(n * dim * 4)
+
+	tests := []struct {
+		index Index
+		// codes is the share of the float32 size the codes take up
+		codes float64
+	}{
+		{index: Index{Type: IndexTypeSQ8, Metric: "L2"}, codes: 0.25},
+		// Sign bits are coarse within a cluster, hence the larger oversample
+		{index: Index{Type: IndexTypeBinary, Metric: "L2", Parameters: map[string]interface{}{"oversample": 40}}, codes: 1.0 / 32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.index.Type, func(t *testing.T) {
+			ctx := context.Background()
+			db, err := newMemoryDB(&Config{Type: "memory", Address: t.TempDir()})
+			if err != nil {
+				t.Fatalf("newMemoryDB: %v", err)
+			}
+			if err := db.Connect(ctx); err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+			if err := db.CreateCollection(ctx, "bench", Schema{Name: "bench"}); err != nil {
+				t.Fatalf("CreateCollection: %v", err)
+			}
+			if err := db.Insert(ctx, "bench", benchRecords(data, 0)); err != nil {
+				t.Fatalf("Insert: %v", err)
+			}
+			exact := newBenchDB(t, data, Index{})
+
+			before := heapInUse()
+			if err := db.CreateIndex(ctx, "bench", "Embedding", tt.index); err != nil {
+				t.Fatalf("CreateIndex: %v", err)
+			}
+			after := heapInUse()
+			saved := (float64(before) - float64(after)) / floatBytes
+			t.Logf("heap %d -> %d bytes, %.2f of the float32 vectors released", before, after, saved)
+			if want := 0.9 - tt.codes; saved < want {
+				t.Errorf("quantizing released %.2f of the float32 vectors, want at least %.2f", saved, want)
+			}
+
+			if got := recall(t, db, exact, queries, 10, nil); got < 0.9 {
+				t.Errorf("recall@10 = %.3f, want at least 0.9", got)
+			}
+
+			ids := make([]int64, 0, n/3)
+			for id := 0; id < n; id += 3 {
+				ids = append(ids, int64(id))
+			}
+			for _, store := range []VectorDB{db, exact} {
+				if err := store.Delete(ctx, "bench", ids); err != nil {
+					t.Fatalf("Delete: %v", err)
+				}
+			}
+			if got := recall(t, db, exact, queries, 10, nil); got < 0.9 {
+				t.Errorf("recall@10 after delete = %.3f, want at least 0.9", got)
+			}
+
+			// Scan reads the compacted rows back from a copy of the spill file
+			err = db.Scan(ctx, "bench", 0, func(records []Record) error {
+				for _, record := range records {
+					id := record.Fields["ID"].(int64)
+					got, want := record.Fields["Embedding"].(Vector), data[id]
+					for j := range want {
+						if float32(got[j]) != float32(want[j]) {
+							return fmt.Errorf("record %d: Embedding[%d] = %v, want %v", id, j, got[j], want[j])
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+
+			if err := db.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			reopened, err := newMemoryDB(&Config{Type: "memory", Address: db.dataDir})
+			if err != nil {
+				t.Fatalf("newMemoryDB: %v", err)
+			}
+			if err := reopened.Connect(ctx); err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+			defer reopened.Close()
+			if got := recall(t, reopened, exact, queries, 10, nil); got < 0.9 {
+				t.Errorf("recall@10 after reload = %.3f, want at least 0.9", got)
+			}
+		})
+	}
+}
+
+func BenchmarkHNSWInsert(b *testing.B) {
+	data := clusteredVectors(rand.New(rand.NewSource(1)), 10000, 128)
+	for i := 0; i < b.N; i++ {
+		newBenchDB(b, data, hnswIndex)
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(data)), "ns/vector")
+}
+
+// BenchmarkHNSWSearch measures the query latency of the HNSW index for
+// several ef values and reports its recall@10 against the exact linear scan.
+func BenchmarkHNSWSearch(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	data := clusteredVectors(rng, 20000, 128)
+	queries := clusteredVectors(rng, 100, 128)
+	indexed := newBenchDB(b, data, hnswIndex)
+	exact := newBenchDB(b, data, Index{})
+
+	for _, ef := range []int{16, 64, 256} {
+		params := map[string]interface{}{"ef": ef}
+		b.Run(fmt.Sprintf("ef=%d", ef), func(b *testing.B) {
+			r := recall(b, indexed, exact, queries, 10, params)
+			if ef >= 256 && r < 0.95 {
+				b.Fatalf("recall@10 = %.3f, want at least 0.95", r)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				searchIDs(b, indexed, queries[i%len(queries)], 10, params)
+			}
+			b.ReportMetric(r, "recall@10")
+		})
+	}
+}
+
+// naiveSearch is the linear scan MemoryDB used to perform: float64 L2
+// distances to every vector on one goroutine, fully sorted to take the top k.
+func naiveSearch(data []Vector, q Vector, k int) []int64 {
+	type result struct {
+		id       int64
+		distance float64
+	}
+	results := make([]result, 0, len(data))
+	for i, v := range data {
+		var sum float64
+		for j := range q {
+			diff := q[j] - v[j]
+			sum += diff * diff
+		}
+		results = append(results, result{id: int64(i), distance: math.Sqrt(sum)})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].distance < results[j].distance
+	})
+
+	ids := make([]int64, 0, k)
+	for _, r := range results[:min(k, len(results))] {
+		ids = append(ids, r.id)
+	}
+	return ids
+}
+
+// BenchmarkFlatSearch measures the linear scan of MemoryDB, over float32
+// columns sharded across workers with a bounded top-K heap, against the
+// naive float64 scan it replaced, on one worker and on GOMAXPROCS workers.
+// The scan is exact, so it must return the naive scan's results.
+func BenchmarkFlatSearch(b *testing.B) {
+	sizes := []struct{ n, dim int }{
+		{n: 100000, dim: 128},
+		{n: 20000, dim: 1536},
+	}
+	for _, size := range sizes {
+		rng := rand.New(rand.NewSource(1))
+		data := clusteredVectors(rng, size.n, size.dim)
+		queries := clusteredVectors(rng, 20, size.dim)
+		db := newBenchDB(b, data, Index{})
+		db.SetColumnNames([]string{"ID"})
+
+		for _, q := range queries[:3] {
+			got, want := searchIDs(b, db, q, 10, nil), naiveSearch(data, q, 10)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				b.Fatalf("flat scan returned %v, want %v", got, want)
+			}
+		}
+
+		name := fmt.Sprintf("n=%d/dim=%d", size.n, size.dim)
+		b.Run(name+"/naive", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				naiveSearch(data, queries[i%len(queries)], 10)
+			}
+		})
+		procs := runtime.GOMAXPROCS(0)
+		for _, workers := range []int{1, procs} {
+			b.Run(fmt.Sprintf("%s/workers=%d", name, workers), func(b *testing.B) {
+				defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(workers))
+				for i := 0; i < b.N; i++ {
+					searchIDs(b, db, queries[i%len(queries)], 10, nil)
+				}
+			})
+			if workers == procs {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,536 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+const (
+	// flatMinShard is the minimum number of records scanned by one worker, so
+	// that small collections are not split across more goroutines than pay off
+	flatMinShard = 4096
+	// flatCheckInterval is the number of records a worker scans between checks
+	// for context cancellation
+	flatCheckInterval = 16384
+)
+
+// vectorColumn holds the values of one vector field of a MemoryDB collection
+// as contiguous float32 storage, one row per record of Collection.Data. The
+// records themselves no longer carry the vectors stored in a column, which
+// halves their memory and lets the linear scan stream through one slab.
+type vectorColumn struct {
+	// Dim is the number of values per row
+	Dim int
+	// Values holds row i at [i*Dim, (i+1)*Dim); absent rows are zeroed
+	// in memory
+	Values []float32
+	// Norms holds the Euclidean norm of each row, used by the COSINE metric
+	Norms []float32
+	// Present reports whether each row holds a vector
+	Present []bool
+
+	// spilled holds the rows instead of Values, which is then nil, once the
+	// field is quantized
+	spilled *vectorSpill
+}
+
+// newVectorColumn creates a column of dimension dim with rows absent rows.
+func newVectorColumn(dim, rows int) *vectorColumn {
+	column := &vectorColumn{Dim: dim}
+	column.grow(rows)
+	return column
+}
+
+// rows returns the number of rows of the column.
+func (c *vectorColumn) rows() int {
+	return len(c.Present)
+}
+
+// grow appends absent rows until the column has n rows.
+func (c *vectorColumn) grow(n int) {
+	for c.rows() < n {
+		if c.spilled == nil {
+			c.Values = append(c.Values, make([]float32, c.Dim)...)
+		}
+		c.Norms = append(c.Norms, 0)
+		c.Present = append(c.Present, false)
+	}
+}
+
+// row returns the values of row i. For a spilled column it returns a copy
+// read from the spill file, which writes to the slice do not reach.
+func (c *vectorColumn) row(i int) []float32 {
+	if c.spilled != nil {
+		row := make([]float32, c.Dim)
+		c.spilled.read(i, row)
+		return row
+	}
+	return c.Values[i*c.Dim : (i+1)*c.Dim]
+}
+
+// set stores vector in row i.
+func (c *vectorColumn) set(i int, vector Vector) {
+	var row []float32
+	if c.spilled != nil {
+		row = make([]float32, c.Dim)
+	} else {
+		row = c.row(i)
+	}
+	var norm float64
+	for j, value := range vector {
+		row[j] = float32(value)
+		norm += float64(row[j]) * float64(row[j])
+	}
+	if c.spilled != nil {
+		c.spilled.write(i, row)
+	}
+	c.Norms[i] = float32(math.Sqrt(norm))
+	c.Present[i] = true
+}
+
+// clear marks row i as absent. Spilled rows keep their stale values, which
+// are never read while the row is absent.
+func (c *vectorColumn) clear(i int) {
+	if c.spilled == nil {
+		clear(c.row(i))
+	}
+	c.Norms[i] = 0
+	c.Present[i] = false
+}
+
+// move copies row src over row dst.
+func (c *vectorColumn) move(dst, src int) {
+	if c.spilled != nil {
+		if c.Present[src] {
+			c.spilled.write(dst, c.row(src))
+		}
+	} else {
+		copy(c.row(dst), c.row(src))
+	}
+	c.Norms[dst] = c.Norms[src]
+	c.Present[dst] = c.Present[src]
+}
+
+// move32 copies row src of column from over row dst.
+func (c *vectorColumn) move32(dst int, from *vectorColumn, src int) {
+	copy(c.row(dst), from.row(src))
+	c.Norms[dst] = from.Norms[src]
+	c.Present[dst] = from.Present[src]
+}
+
+// truncate keeps the first n rows.
+func (c *vectorColumn) truncate(n int) {
+	if c.spilled != nil {
+		c.spilled.truncate(n)
+	} else {
+		c.Values = c.Values[:n*c.Dim]
+	}
+	c.Norms = c.Norms[:n]
+	c.Present = c.Present[:n]
+}
+
+// vector returns row i as a Vector, or nil when the row is absent.
+func (c *vectorColumn) vector(i int) Vector {
+	if !c.Present[i] {
+		return nil
+	}
+	vector := make(Vector, c.Dim)
+	for j, value := range c.row(i) {
+		vector[j] = float64(value)
+	}
+	return vector
+}
+
+// storeVectors moves the vectors of record into row i of the collection's
+// columns, creating a column for any vector field seen for the first time,
+// and returns the record without them. Row i may be one past the last row.
+// Vectors whose length differs from their column's stay on the record.
+func (collection *Collection) storeVectors(i int, record Record) Record {
+	for _, column := range collection.Vectors {
+		column.grow(i + 1)
+		column.clear(i)
+	}
+
+	var fields map[string]interface{}
+	for name, value := range record.Fields {
+		vector, ok := value.(Vector)
+		if !ok || len(vector) == 0 {
+			continue
+		}
+		column, exists := collection.Vectors[name]
+		if !exists {
+			if collection.Vectors == nil {
+				collection.Vectors = make(map[string]*vectorColumn)
+			}
+			column = newVectorColumn(len(vector), max(i+1, len(collection.Data)))
+			collection.Vectors[name] = column
+			if _, quantized := collection.quantized[name]; quantized {
+				collection.spillColumn(name)
+			}
+		}
+		if len(vector) != column.Dim {
+			continue
+		}
+		column.set(i, vector)
+		if fields == nil {
+			fields = make(map[string]interface{}, len(record.Fields))
+			for k, v := range record.Fields {
+				fields[k] = v
+			}
+		}
+		delete(fields, name)
+	}
+	if fields == nil {
+		return record
+	}
+	return Record{Fields: fields}
+}
+
+// record returns record i of the collection with its vectors restored.
+func (collection *Collection) record(i int) Record {
+	record := collection.Data[i]
+	var fields map[string]interface{}
+	for name, column := range collection.Vectors {
+		if !column.Present[i] {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]interface{}, len(record.Fields)+len(collection.Vectors))
+			for k, v := range record.Fields {
+				fields[k] = v
+			}
+		}
+		fields[name] = column.vector(i)
+	}
+	if fields == nil {
+		return record
+	}
+	return Record{Fields: fields}
+}
+
+// copyData returns a copy of the collection's records and vector columns
+// that stays valid once the lock is released.
+func (collection *Collection) copyData() *Collection {
+	snapshot := &Collection{
+		Data:    append([]Record(nil), collection.Data...),
+		Vectors: make(map[string]*vectorColumn, len(collection.Vectors)),
+	}
+	for name, column := range collection.Vectors {
+		copied := &vectorColumn{
+			Dim:     column.Dim,
+			Values:  append([]float32(nil), column.Values...),
+			Norms:   append([]float32(nil), column.Norms...),
+			Present: append([]bool(nil), column.Present...),
+		}
+		if column.spilled != nil {
+			// Copy the spill file rather than read the rows into memory;
+			// if that fails, fall back to reading them.
+			spilled, err := column.spilled.clone()
+			if err != nil {
+				GlobalLogger.Warn("Reading spilled vectors into memory", "field", name, "error", err)
+				copied.Values = column.inMemory().Values
+			} else {
+				copied.spilled = spilled
+			}
+		}
+		snapshot.Vectors[name] = copied
+	}
+	return snapshot
+}
+
+// loadVectors moves the vectors still held by the records into columns. It
+// migrates collections read from snapshots written before columns existed;
+// collections that already have columns are left as they are.
+func (collection *Collection) loadVectors() {
+	if collection.Vectors != nil {
+		return
+	}
+	for i, record := range collection.Data {
+		collection.Data[i] = collection.storeVectors(i, record)
+	}
+}
+
+// flatQuery is a query vector prepared for scanning one vector field.
+type flatQuery struct {
+	field    string
+	column   *vectorColumn // nil when no record has the field
+	vector   Vector
+	values   []float32
+	norm     float32
+	metric   string
+	fallback func(a, b Vector) float64
+}
+
+// newFlatQueries prepares the query vectors for a scan under metric, in
+// field name order so that Search picks fields deterministically.
+func (collection *Collection) newFlatQueries(vectors map[string]Vector, metric string) []flatQuery {
+	queries := make([]flatQuery, 0, len(vectors))
+	for field, vector := range vectors {
+		q := flatQuery{
+			field:    field,
+			column:   collection.Vectors[field],
+			vector:   vector,
+			values:   make([]float32, len(vector)),
+			metric:   metric,
+			fallback: distanceFunc(metric),
+		}
+		for i, value := range vector {
+			q.values[i] = float32(value)
+		}
+		q.norm = float32(math.Sqrt(float64(dot32(q.values, q.values))))
+		queries = append(queries, q)
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].field < queries[j].field
+	})
+	return queries
+}
+
+// distance returns the distance between the query and the field of record i,
+// and false when the record has no vector of the query's dimension in it.
+func (q *flatQuery) distance(collection *Collection, i int) (float64, bool) {
+	if q.column != nil && q.column.Present[i] {
+		if q.column.Dim != len(q.values) {
+			return 0, false
+		}
+		return distance32(q.metric, q.values, q.norm, q.column.row(i), q.column.Norms[i]), true
+	}
+	// Vectors that did not fit their column are still on the record.
+	if v, ok := collection.Data[i].Fields[q.field].(Vector); ok && len(v) == len(q.vector) {
+		return q.fallback(q.vector, v), true
+	}
+	return 0, false
+}
+
+// distance32 returns the distance under a resolved metric between two
+// float32 vectors of equal length, given with their Euclidean norms.
+func distance32(metric string, a []float32, aNorm float32, b []float32, bNorm float32) float64 {
+	switch metric {
+	case MetricIP:
+		return -float64(dot32(a, b))
+	case MetricCosine:
+		if aNorm == 0 || bNorm == 0 {
+			return 1
+		}
+		return 1 - float64(dot32(a, b)/(aNorm*bNorm))
+	default:
+		return math.Sqrt(float64(squaredL232(a, b)))
+	}
+}
+
+// dot32 returns the inner product of two float32 vectors of equal length.
+// The loop keeps four independent sums so that the additions pipeline.
+func dot32(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// squaredL232 returns the squared Euclidean distance between two float32
+// vectors of equal length.
+func squaredL232(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		d0 := a[i] - b[i]
+		d1 := a[i+1] - b[i+1]
+		d2 := a[i+2] - b[i+2]
+		d3 := a[i+3] - b[i+3]
+		s0 += d0 * d0
+		s1 += d1 * d1
+		s2 += d2 * d2
+		s3 += d3 * d3
+	}
+	for ; i < len(a); i++ {
+		d := a[i] - b[i]
+		s0 += d * d
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// flatCandidate pairs a record position with its distance to the query.
+type flatCandidate struct {
+	index int
+	dist  float64
+}
+
+// flatMaxHeap keeps the closest candidates seen so far with the farthest on
+// top, so that a closer candidate can replace it in O(log k).
+type flatMaxHeap []flatCandidate
+
+func (h flatMaxHeap) Len() int            { return len(h) }
+func (h flatMaxHeap) Less(i, j int) bool  { return h[i].dist > h[j].dist }
+func (h flatMaxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *flatMaxHeap) Push(x interface{}) { *h = append(*h, x.(flatCandidate)) }
+func (h *flatMaxHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// scan returns the topK records closest to a query, sorted by ascending
+// distance. distance reports the distance of record i, or false to skip it;
+// records rejected by filter are skipped too.
+// The caller must hold at least the read lock.
+func (collection *Collection) scan(ctx context.Context, topK int, filter *Filter, distance func(i int) (float64, bool)) ([]flatCandidate, error) {
+	return scanTopK(ctx, len(collection.Data), topK, func(i int) (float64, bool) {
+		if !filter.Matches(collection.Data[i]) {
+			return 0, false
+		}
+		return distance(i)
+	})
+}
+
+// scanTopK returns the topK of n rows closest to a query, sorted by ascending
+// distance. distance reports the distance of row i, or false to skip it. The
+// rows are split into contiguous shards scanned by up to GOMAXPROCS workers,
+// each keeping a bounded heap of its best candidates, which are merged at the
+// end. distance must be safe for concurrent use.
+func scanTopK(ctx context.Context, n, topK int, distance func(i int) (float64, bool)) ([]flatCandidate, error) {
+	if topK <= 0 || n == 0 {
+		return nil, nil
+	}
+
+	workers := min(runtime.GOMAXPROCS(0), (n+flatMinShard-1)/flatMinShard)
+	shard := (n + workers - 1) / workers
+	heaps := make([]flatMaxHeap, workers)
+	errs := make([]error, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			h := make(flatMaxHeap, 0, topK)
+			start, end := w*shard, min((w+1)*shard, n)
+			for i := start; i < end; i++ {
+				if (i-start)%flatCheckInterval == 0 {
+					if err := ctx.Err(); err != nil {
+						errs[w] = err
+						return
+					}
+				}
+				dist, ok := distance(i)
+				if !ok {
+					continue
+				}
+				if len(h) < topK {
+					heap.Push(&h, flatCandidate{index: i, dist: dist})
+				} else if dist < h[0].dist {
+					h[0] = flatCandidate{index: i, dist: dist}
+					heap.Fix(&h, 0)
+				}
+			}
+			heaps[w] = h
+		}(w)
+	}
+	wg.Wait()
+
+	var candidates []flatCandidate
+	for w := range heaps {
+		if errs[w] != nil {
+			return nil, errs[w]
+		}
+		candidates = append(candidates, heaps[w]...)
+	}
+	sortCandidates(candidates)
+	if len(candidates) > topK {
+		candidates = candidates[:topK]
+	}
+	return candidates, nil
+}
+
+// sortCandidates sorts candidates by ascending distance, then position.
+func sortCandidates(candidates []flatCandidate) {
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].dist != candidates[j].dist {
+			return candidates[i].dist < candidates[j].dist
+		}
+		return candidates[i].index < candidates[j].index
+	})
+}
+
+// quantize encodes row i into the collection's quantized indexes.
+func (collection *Collection) quantize(i int) {
+	for field, q := range collection.quantized {
+		q.grow(i + 1)
+		if column, ok := collection.Vectors[field]; ok && column.Present[i] {
+			q.set(i, column.row(i))
+		} else {
+			q.clear(i)
+		}
+	}
+}
+
+// buildQuantized (re)builds the quantized codes of field from its column.
+func (collection *Collection) buildQuantized(field string, index Index) {
+	var dim int
+	if column, ok := collection.Vectors[field]; ok {
+		dim = column.Dim
+	}
+	if collection.quantized == nil {
+		collection.quantized = make(map[string]*quantizedIndex)
+	}
+	collection.quantized[field] = newQuantizedIndex(index, dim)
+	for i := range collection.Data {
+		collection.quantize(i)
+	}
+	collection.spillColumn(field)
+}
+
+// quantizedFor returns the quantized index that can answer a single-field
+// query, or nil when the search must use another path.
+func (collection *Collection) quantizedFor(vectors map[string]Vector) *quantizedIndex {
+	if len(vectors) != 1 {
+		return nil
+	}
+	for field := range vectors {
+		return collection.quantized[field]
+	}
+	return nil
+}
+
+// searchQuantized scans the codes of q for the closest candidates and
+// returns the topK of them by their exact distance to query.
+// The caller must hold at least the read lock.
+func (collection *Collection) searchQuantized(ctx context.Context, q *quantizedIndex, query *flatQuery, topK int, filter *Filter, searchParams map[string]interface{}) ([]flatCandidate, error) {
+	encoded, ok := q.prepare(query.values)
+	if !ok {
+		return nil, nil
+	}
+	candidates, err := collection.scan(ctx, q.candidates(topK, searchParams), filter, func(i int) (float64, bool) {
+		return q.estimate(encoded, i, query.metric)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rescored := candidates[:0]
+	for _, c := range candidates {
+		if distance, ok := query.distance(collection, c.index); ok {
+			rescored = append(rescored, flatCandidate{index: c.index, dist: distance})
+		}
+	}
+	sortCandidates(rescored)
+	if len(rescored) > topK {
+		rescored = rescored[:topK]
+	}
+	return rescored, nil
+}
@@ -4,12 +4,22 @@ package rag
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 )
 
+// milvusIDField is the name of the primary key field used by raggo collections.
+const milvusIDField = "ID"
+
 // MilvusDB implements a vector database interface using Milvus.
 // It provides high-performance vector similarity search with:
 // - HNSW indexing for fast approximate nearest neighbor search
@@ -20,12 +30,27 @@ type MilvusDB struct {
 	client      client.Client        // Milvus client connection
 	config      *Config             // Database configuration
 	columnNames []string            // Names of columns to retrieve in search results
+	collections map[string]*milvusCollection // Cached collection descriptions
+	mu          sync.Mutex          // Protects collections
+}
+
+// milvusCollection describes the fields of a collection, as declared in Milvus.
+type milvusCollection struct {
+	schema     Schema                       // The fields as schema fields, for validating writes
+	fieldTypes map[string]entity.FieldType // Milvus type of each field
+}
+
+func init() {
+	Register("milvus", func(cfg *Config) (VectorDB, error) { return newMilvusDB(cfg) }, Capabilities{Hybrid: true, Filters: true, Delete: true, Persistence: true})
 }
 
 // newMilvusDB creates a new MilvusDB instance with the given configuration.
 // Note: This doesn't establish the connection - call Connect() separately.
 func newMilvusDB(cfg *Config) (*MilvusDB, error) {
-	return &MilvusDB{config: cfg}, nil
+	return &MilvusDB{
+		config:     cfg,
+		collections: make(map[string]*milvusCollection),
+	}, nil
 }
 
 // Connect establishes a connection to the Milvus server.
@@ -61,6 +86,7 @@ func (m *MilvusDB) HasCollection(ctx context.Context, name string) (bool, error)
 // DropCollection removes a collection and all its data from the database.
 // Warning: This operation is irreversible.
 func (m *MilvusDB) DropCollection(ctx context.Context, name string) error {
+	m.forgetCollection(name)
 	return m.client.DropCollection(ctx, name)
 }
 
@@ -71,10 +97,25 @@ func (m *MilvusDB) DropCollection(ctx context.Context, name string) error {
 // - Auto-ID settings
 // - Vector dimensions
 // - VARCHAR field lengths
+//
+// Declare the Metadata field with DataType "json" to make it filterable.
 func (m *MilvusDB) CreateCollection(ctx context.Context, name string, schema Schema) error {
+	exists, err := m.client.HasCollection(ctx, name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrCollectionExists, name)
+	}
+
+	m.forgetCollection(name)
 	milvusSchema := entity.NewSchema().WithName(name).WithDescription(schema.Description)
 	for _, field := range schema.Fields {
-		f := entity.NewField().WithName(field.Name).WithDataType(m.convertDataType(field.DataType))
+		dataType := m.convertDataType(field.DataType)
+		if dataType == entity.FieldTypeNone {
+			return fmt.Errorf("%w %q for field %s", ErrUnsupportedDataType, field.DataType, field.Name)
+		}
+		f := entity.NewField().WithName(field.Name).WithDataType(dataType)
 
 		if field.PrimaryKey {
 			f.WithIsPrimaryKey(true)
@@ -96,21 +137,175 @@ func (m *MilvusDB) CreateCollection(ctx context.Context, name string, schema Sch
 }
 
 // Insert adds new records to a collection.
+// The records are converted to columns by buildColumns and inserted in a
+// single batch for efficiency.
+func (m *MilvusDB) Insert(ctx context.Context, collectionName string, data []Record) error {
+	columnList, err := m.buildColumns(ctx, collectionName, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.client.Insert(ctx, collectionName, "", columnList...)
+	if err != nil {
+		GlobalLogger.Error("Failed to insert data", "collection", collectionName, "error", err)
+	}
+	return err
+}
+
+// Upsert inserts records, replacing existing entities with the same primary key.
+// Milvus requires the primary key to be supplied, so on collections whose ID
+// field uses AutoID, records without an ID are inserted with a generated one
+// and replace nothing.
+func (m *MilvusDB) Upsert(ctx context.Context, collectionName string, data []Record) error {
+	coll, err := m.collection(ctx, collectionName)
+	if err != nil {
+		return fmt.Errorf("failed to describe collection %s: %w", collectionName, err)
+	}
+	var keyed, unkeyed []Record
+	for _, record := range data {
+		if _, ok := record.Fields[milvusIDField]; ok || !coll.autoID() {
+			keyed = append(keyed, record)
+		} else {
+			unkeyed = append(unkeyed, record)
+		}
+	}
+	if len(unkeyed) > 0 {
+		if err := m.Insert(ctx, collectionName, unkeyed); err != nil {
+			return err
+		}
+	}
+	if len(keyed) == 0 {
+		return nil
+	}
+
+	columnList, err := m.buildColumns(ctx, collectionName, keyed)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.client.Upsert(ctx, collectionName, "", columnList...)
+	if err != nil {
+		GlobalLogger.Error("Failed to upsert data", "collection", collectionName, "error", err)
+	}
+	return err
+}
+
+// Delete removes the entities with the given primary keys from a collection.
+func (m *MilvusDB) Delete(ctx context.Context, collectionName string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return m.client.DeleteByPks(ctx, collectionName, "", entity.NewColumnInt64(milvusIDField, ids))
+}
+
+// DeleteByFilter removes every entity matching filter. The filter is
+// translated into a Milvus boolean expression over the Metadata field (see
+// filterExpr), e.g. Metadata["source"] == "doc.pdf".
+func (m *MilvusDB) DeleteByFilter(ctx context.Context, collectionName string, filter *Filter) error {
+	if filter == nil {
+		return fmt.Errorf("delete filter must not be empty")
+	}
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	expr, err := m.filterExpr(ctx, collectionName, filter)
+	if err != nil {
+		return err
+	}
+
+	GlobalLogger.Debug("Deleting by filter", "collection", collectionName, "expr", expr)
+	return m.client.Delete(ctx, collectionName, "", expr)
+}
+
+// Get retrieves the entities with the given primary keys. The returned records
+// contain the ID plus the fields configured through SetColumnNames.
+func (m *MilvusDB) Get(ctx context.Context, collectionName string, ids []int64) ([]Record, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	idStrings := make([]string, len(ids))
+	for i, id := range ids {
+		idStrings[i] = strconv.FormatInt(id, 10)
+	}
+	expr := fmt.Sprintf("%s in [%s]", milvusIDField, strings.Join(idStrings, ","))
+
+	outputFields := append([]string{milvusIDField}, m.columnNames...)
+	resultSet, err := m.client.Query(ctx, collectionName, nil, expr, outputFields)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.wrapQueryResults(resultSet), nil
+}
+
+// Scan calls fn with every record of a collection, using a Milvus query
+// iterator that pages through the collection by primary key. The collection
+// must be loaded.
+func (m *MilvusDB) Scan(ctx context.Context, collectionName string, batchSize int, fn func([]Record) error) error {
+	coll, err := m.collection(ctx, collectionName)
+	if err != nil {
+		return fmt.Errorf("failed to describe collection %s: %w", collectionName, err)
+	}
+	outputFields := make([]string, 0, len(coll.fieldTypes))
+	for name := range coll.fieldTypes {
+		outputFields = append(outputFields, name)
+	}
+	sort.Strings(outputFields)
+
+	option := client.NewQueryIteratorOption(collectionName).
+		WithOutputFields(outputFields...).
+		WithBatchSize(scanBatchSize(batchSize))
+	iterator, err := m.client.QueryIterator(ctx, option)
+	if err != nil {
+		return fmt.Errorf("failed to scan collection %s: %w", collectionName, err)
+	}
+	for {
+		resultSet, err := iterator.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to scan collection %s: %w", collectionName, err)
+		}
+		if err := fn(m.wrapQueryResults(resultSet)); err != nil {
+			return err
+		}
+	}
+}
+
+// buildColumns converts records into Milvus columns, one column per field.
 // It handles multiple data types and automatically creates appropriate columns.
 // The function:
-// 1. Creates columns for each field type
-// 2. Appends values to respective columns
-// 3. Performs batch insertion for efficiency
-func (m *MilvusDB) Insert(ctx context.Context, collectionName string, data []Record) error {
+// 1. Looks up the collection's declared fields
+// 2. Validates the records against them (see ValidateRecords)
+// 3. Creates columns for each field type
+// 4. Appends values to respective columns
+// 5. Returns the columns ready for a batch insert or upsert
+func (m *MilvusDB) buildColumns(ctx context.Context, collectionName string, data []Record) ([]entity.Column, error) {
+	coll, err := m.collection(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe collection %s: %w", collectionName, err)
+	}
+	if err := ValidateRecords(coll.schema, data); err != nil {
+		return nil, err
+	}
+
 	columns := make(map[string]entity.Column)
-	for _, record := range data {
+	for i, record := range data {
 		for fieldName, fieldValue := range record.Fields {
 			if _, ok := columns[fieldName]; !ok {
-				col := m.createColumn(fieldName, fieldValue)
+				col, err := m.createColumn(fieldName, fieldValue, coll.fieldTypes[fieldName])
+				if err != nil {
+					return nil, fmt.Errorf("record %d: %w", i, err)
+				}
 				columns[fieldName] = col
 				GlobalLogger.Debug("Created column", "field", fieldName, "type", fmt.Sprintf("%T", col))
 			}
-			m.appendToColumn(columns[fieldName], fieldValue)
+			if err := m.appendToColumn(columns[fieldName], fieldValue); err != nil {
+				return nil, fmt.Errorf("record %d: field %s: %w", i, fieldName, err)
+			}
 		}
 	}
 
@@ -119,12 +314,56 @@ func (m *MilvusDB) Insert(ctx context.Context, collectionName string, data []Rec
 		columnList = append(columnList, col)
 		GlobalLogger.Debug("Inserting column", "field", fieldName, "type", fmt.Sprintf("%T", col), "values", col.Len())
 	}
+	return columnList, nil
+}
 
-	_, err := m.client.Insert(ctx, collectionName, "", columnList...)
+// autoID reports whether the collection's primary key is generated by Milvus.
+func (c *milvusCollection) autoID() bool {
+	for _, field := range c.schema.Fields {
+		if field.PrimaryKey {
+			return field.AutoID
+		}
+	}
+	return false
+}
+
+// collection returns the fields declared for a collection. The result is
+// cached so that inserts do not describe the collection every time.
+func (m *MilvusDB) collection(ctx context.Context, collectionName string) (*milvusCollection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if coll, ok := m.collections[collectionName]; ok {
+		return coll, nil
+	}
+
+	described, err := m.client.DescribeCollection(ctx, collectionName)
 	if err != nil {
-		GlobalLogger.Error("Failed to insert data", "collection", collectionName, "error", err)
+		if isMilvusCollectionNotFound(err) {
+			return nil, collectionNotFound(collectionName)
+		}
+		return nil, err
 	}
-	return err
+
+	coll := &milvusCollection{
+		schema:     Schema{Name: collectionName},
+		fieldTypes: make(map[string]entity.FieldType),
+	}
+	if described.Schema != nil {
+		for _, field := range described.Schema.Fields {
+			coll.schema.Fields = append(coll.schema.Fields, m.schemaField(field))
+			coll.fieldTypes[field.Name] = field.DataType
+		}
+	}
+	m.collections[collectionName] = coll
+	return coll, nil
+}
+
+// forgetCollection drops the cached description of a collection.
+func (m *MilvusDB) forgetCollection(collectionName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.collections, collectionName)
 }
 
 // Flush ensures all inserted data is persisted to disk.
@@ -136,14 +375,18 @@ func (m *MilvusDB) Flush(ctx context.Context, collectionName string) error {
 // CreateIndex builds an index on a specified field to optimize search performance.
 // Currently supports:
 // - HNSW index type with configurable M and efConstruction parameters
-// - Different metric types (L2, IP, etc.)
+// - Different metric types (L2, IP, COSINE)
 func (m *MilvusDB) CreateIndex(ctx context.Context, collectionName, field string, index Index) error {
 	var idx entity.Index
-	var err error
+
+	metric, err := m.convertMetricType(index.Metric)
+	if err != nil {
+		return err
+	}
 
 	switch index.Type {
 	case "HNSW":
-		idx, err = entity.NewIndexHNSW(m.convertMetricType(index.Metric), index.Parameters["M"].(int), index.Parameters["efConstruction"].(int))
+		idx, err = entity.NewIndexHNSW(metric, index.Parameters["M"].(int), index.Parameters["efConstruction"].(int))
 	default:
 		return fmt.Errorf("unsupported index type: %s", index.Type)
 	}
@@ -161,13 +404,121 @@ func (m *MilvusDB) LoadCollection(ctx context.Context, name string) error {
 	return m.client.LoadCollection(ctx, name, false)
 }
 
+// ListCollections returns the names of all collections, sorted.
+func (m *MilvusDB) ListCollections(ctx context.Context) ([]string, error) {
+	collections, err := m.client.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(collections))
+	for i, coll := range collections {
+		names[i] = coll.Name
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DescribeCollection returns the schema of a collection, read from Milvus,
+// and the index built on each of its vector fields.
+func (m *MilvusDB) DescribeCollection(ctx context.Context, name string) (*CollectionInfo, error) {
+	coll, err := m.client.DescribeCollection(ctx, name)
+	if err != nil {
+		if isMilvusCollectionNotFound(err) {
+			return nil, collectionNotFound(name)
+		}
+		return nil, err
+	}
+
+	info := &CollectionInfo{Name: name, Schema: Schema{Name: name}, Indexes: make(map[string]Index)}
+	if coll.Schema == nil {
+		return info, nil
+	}
+	info.Schema.Description = coll.Schema.Description
+	for _, field := range coll.Schema.Fields {
+		info.Schema.Fields = append(info.Schema.Fields, m.schemaField(field))
+		if field.DataType != entity.FieldTypeFloatVector {
+			continue
+		}
+
+		indexes, err := m.client.DescribeIndex(ctx, name, field.Name)
+		if isMilvusIndexNotFound(err) || (err == nil && len(indexes) == 0) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		info.Indexes[field.Name] = milvusIndexDefinition(indexes[0])
+	}
+	return info, nil
+}
+
+// Count returns the number of entities in a collection, as reported by the
+// collection statistics. Entities that have not been flushed yet may be missing.
+func (m *MilvusDB) Count(ctx context.Context, collectionName string) (int64, error) {
+	stats, err := m.client.GetCollectionStatistics(ctx, collectionName)
+	if err != nil {
+		return 0, err
+	}
+	count, err := strconv.ParseInt(stats["row_count"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid row count %q for collection %s", stats["row_count"], collectionName)
+	}
+	return count, nil
+}
+
+// Stats reports the entity count of a collection and the build progress of
+// the index on its vector field. Milvus does not report storage sizes.
+func (m *MilvusDB) Stats(ctx context.Context, collectionName string) (*CollectionStats, error) {
+	info, err := m.DescribeCollection(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	count, err := m.Count(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &CollectionStats{
+		Count:       count,
+		Dimension:   vectorDimension(info.Schema),
+		StorageSize: -1,
+		IndexStatus: IndexStatusNone,
+	}
+	for _, field := range info.Schema.Fields {
+		if _, indexed := info.Indexes[field.Name]; !indexed || field.DataType != "float_vector" {
+			continue
+		}
+		total, indexedRows, err := m.client.GetIndexBuildProgress(ctx, collectionName, field.Name)
+		switch {
+		case err != nil && strings.Contains(err.Error(), "index build failed"):
+			stats.IndexStatus = IndexStatusFailed
+		case err != nil:
+			return nil, err
+		case indexedRows >= total:
+			stats.IndexStatus = IndexStatusReady
+		default:
+			stats.IndexStatus = IndexStatusBuilding
+		}
+		break
+	}
+	return stats, nil
+}
+
 // Search performs vector similarity search on a single field.
 // Parameters:
 // - vectors: Map of field name to vector values
 // - topK: Number of results to return
-// - metricType: Distance metric (L2, IP, etc.)
-// - searchParams: Index-specific search parameters
+// - metricType: Distance metric (L2, IP, COSINE)
+// - searchParams: Index-specific search parameters, plus an optional
+//   FilterParam that is translated into a Milvus boolean expression
+//
+// Milvus scores are converted to the common scale documented on SearchResult.
 func (m *MilvusDB) Search(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}) ([]SearchResult, error) {
+	metric, err := m.convertMetricType(metricType)
+	if err != nil {
+		return nil, err
+	}
+
 	// Assume we're searching only one field for simplicity
 	var fieldName string
 	var vector Vector
@@ -187,14 +538,60 @@ func (m *MilvusDB) Search(ctx context.Context, collectionName string, vectors ma
 		return nil, err
 	}
 
-	result, err := m.client.Search(ctx, collectionName, nil, "", m.columnNames,
+	expr, err := m.searchExpr(ctx, collectionName, searchParams)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := m.client.Search(ctx, collectionName, nil, expr, m.columnNames,
 		[]entity.Vector{entity.FloatVector(floatVector)},
-		fieldName, m.convertMetricType(metricType), topK, sp)
+		fieldName, metric, topK, sp)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.wrapSearchResults(result, metric), nil
+}
+
+// SearchBatch sends every query vector to Milvus in a single search request
+// on field and splits the response into one result list per query.
+func (m *MilvusDB) SearchBatch(ctx context.Context, collectionName, field string, queries []Vector, topK int, metricType string, searchParams map[string]interface{}) ([][]SearchResult, error) {
+	batch := make([][]SearchResult, len(queries))
+	if len(queries) == 0 {
+		return batch, nil
+	}
+	metric, err := m.convertMetricType(metricType)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([]entity.Vector, len(queries))
+	for i, query := range queries {
+		vectors[i] = entity.FloatVector(toFloat32Slice(query))
+	}
+
+	sp, err := m.createSearchParam(searchParams)
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := m.searchExpr(ctx, collectionName, searchParams)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := m.client.Search(ctx, collectionName, nil, expr, m.columnNames, vectors, field, metric, topK, sp)
 	if err != nil {
 		return nil, err
 	}
+	if len(result) != len(queries) {
+		return nil, fmt.Errorf("milvus returned %d result sets for %d queries", len(result), len(queries))
+	}
 
-	return m.wrapSearchResults(result), nil
+	for i := range result {
+		batch[i] = m.wrapSearchResults(result[i:i+1], metric)
+	}
+	return batch, nil
 }
 
 // HybridSearch performs search across multiple vector fields with reranking.
@@ -202,7 +599,14 @@ func (m *MilvusDB) Search(ctx context.Context, collectionName string, vectors ma
 // 1. Individual ANN searches on each vector field
 // 2. Reranking of combined results (default: RRF reranker)
 // 3. Final top-K selection
+//
+// Result scores are the reranker's fused scores; their Distance is zero.
 func (m *MilvusDB) HybridSearch(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}, reranker interface{}) ([]SearchResult, error) {
+	metric, err := m.convertMetricType(metricType)
+	if err != nil {
+		return nil, err
+	}
+
 	limit := topK
 	subRequests := make([]*client.ANNSearchRequest, 0, len(vectors))
 
@@ -211,12 +615,17 @@ func (m *MilvusDB) HybridSearch(ctx context.Context, collectionName string, vect
 		return nil, err
 	}
 
+	expr, err := m.searchExpr(ctx, collectionName, searchParams)
+	if err != nil {
+		return nil, err
+	}
+
 	for fieldName, vector := range vectors {
 		floatVector := make([]float32, len(vector))
 		for i, v := range vector {
 			floatVector[i] = float32(v)
 		}
-		subRequests = append(subRequests, client.NewANNSearchRequest(fieldName, m.convertMetricType(metricType), "", []entity.Vector{entity.FloatVector(floatVector)}, sp, topK))
+		subRequests = append(subRequests, client.NewANNSearchRequest(fieldName, metric, expr, []entity.Vector{entity.FloatVector(floatVector)}, sp, topK))
 	}
 
 	var milvusReranker client.Reranker
@@ -236,7 +645,44 @@ func (m *MilvusDB) HybridSearch(ctx context.Context, collectionName string, vect
 		return nil, err
 	}
 
-	return m.wrapSearchResults(result), nil
+	return m.wrapSearchResults(result, ""), nil
+}
+
+// searchExpr translates the optional filter in searchParams into a Milvus
+// boolean expression. It returns an empty expression when no filter is set.
+func (m *MilvusDB) searchExpr(ctx context.Context, collectionName string, searchParams map[string]interface{}) (string, error) {
+	filter, err := filterFromParams(searchParams)
+	if err != nil || filter == nil {
+		return "", err
+	}
+	expr, err := m.filterExpr(ctx, collectionName, filter)
+	if err != nil {
+		return "", err
+	}
+	GlobalLogger.Debug("Using filter expression", "expr", expr)
+	return expr, nil
+}
+
+// filterExpr translates a filter into a Milvus boolean expression for a
+// collection. Metadata is a JSON field in the collections raggo creates, and
+// filters address its keys as JSON paths. Collections created by earlier
+// versions of raggo store Metadata as JSON text in a varchar field; their
+// equality, IN and NOT filters are matched with LIKE patterns over that text
+// (see milvusTextFilterExpr), and other filters are rejected with
+// ErrVarcharMetadata.
+func (m *MilvusDB) filterExpr(ctx context.Context, collectionName string, filter *Filter) (string, error) {
+	coll, err := m.collection(ctx, collectionName)
+	if err != nil {
+		return "", fmt.Errorf("failed to describe collection %s: %w", collectionName, err)
+	}
+	if coll.fieldTypes["Metadata"] == entity.FieldTypeVarChar {
+		expr, err := milvusTextFilterExpr(filter)
+		if err != nil {
+			return "", fmt.Errorf("collection %s: %w", collectionName, err)
+		}
+		return expr, nil
+	}
+	return milvusFilterExpr(filter)
 }
 
 // createSearchParam creates search parameters for the specified index type.
@@ -254,20 +700,70 @@ func (m *MilvusDB) createSearchParam(params map[string]interface{}) (entity.Sear
 }
 
 // convertMetricType converts string metric types to Milvus entity.MetricType.
-// Supported types: L2, IP (Inner Product), COSINE, etc.
-func (m *MilvusDB) convertMetricType(metricType string) entity.MetricType {
-	switch metricType {
-	case "L2":
-		return entity.L2
-	case "IP":
-		return entity.IP
+// Supported types: L2, IP (Inner Product) and COSINE. An empty metric defaults
+// to L2; unknown metrics are rejected.
+func (m *MilvusDB) convertMetricType(metricType string) (entity.MetricType, error) {
+	metric, err := ResolveMetric(metricType)
+	if err != nil {
+		return "", err
+	}
+	switch metric {
+	case MetricIP:
+		return entity.IP, nil
+	case MetricCosine:
+		return entity.COSINE, nil
+	default:
+		return entity.L2, nil
+	}
+}
+
+// milvusDistance converts a raw Milvus score into the distance documented on
+// SearchResult. Milvus reports squared Euclidean distance for L2, the inner
+// product for IP and the cosine similarity for COSINE.
+func milvusDistance(metric entity.MetricType, score float64) float64 {
+	switch metric {
+	case entity.IP:
+		return -score
+	case entity.COSINE:
+		return 1 - score
+	default:
+		return math.Sqrt(math.Max(score, 0))
+	}
+}
+
+// schemaField converts a Milvus field definition to a schema Field.
+func (m *MilvusDB) schemaField(field *entity.Field) Field {
+	dimension, _ := strconv.Atoi(field.TypeParams[entity.TypeParamDim])
+	maxLength, _ := strconv.Atoi(field.TypeParams[entity.TypeParamMaxLength])
+	return Field{
+		Name:       field.Name,
+		DataType:   m.dataTypeName(field.DataType),
+		PrimaryKey: field.PrimaryKey,
+		AutoID:     field.AutoID,
+		Dimension:  dimension,
+		MaxLength:  maxLength,
+	}
+}
+
+// dataTypeName converts a Milvus entity.FieldType back to the data type
+// names used in Schema.
+func (m *MilvusDB) dataTypeName(fieldType entity.FieldType) string {
+	switch fieldType {
+	case entity.FieldTypeInt64:
+		return "int64"
+	case entity.FieldTypeFloatVector:
+		return "float_vector"
+	case entity.FieldTypeVarChar:
+		return "varchar"
+	case entity.FieldTypeJSON:
+		return "json"
 	default:
-		return entity.L2 // Default to L2 if unknown
+		return strings.ToLower(fieldType.Name())
 	}
 }
 
 // convertDataType converts string data types to Milvus entity.FieldType.
-// Supports: int64, float, string, float_vector, etc.
+// Supports: int64, float, string, float_vector, json, etc.
 func (m *MilvusDB) convertDataType(dataType string) entity.FieldType {
 	switch dataType {
 	case "int64":
@@ -276,29 +772,39 @@ func (m *MilvusDB) convertDataType(dataType string) entity.FieldType {
 		return entity.FieldTypeFloatVector
 	case "varchar":
 		return entity.FieldTypeVarChar
+	case "json":
+		return entity.FieldTypeJSON
 	default:
 		return entity.FieldTypeNone
 	}
 }
 
 // createColumn creates a new column with appropriate type based on the field value.
-// Handles: Int64, Float32, String, FloatVector, etc.
-func (m *MilvusDB) createColumn(fieldName string, fieldValue interface{}) entity.Column {
+// Handles: Int64, Float32, String, FloatVector, JSON, etc.
+// When the collection declares the field as JSON, a JSON column is created
+// regardless of the Go value type.
+func (m *MilvusDB) createColumn(fieldName string, fieldValue interface{}, fieldType entity.FieldType) (entity.Column, error) {
+	if fieldType == entity.FieldTypeJSON {
+		return entity.NewColumnJSONBytes(fieldName, [][]byte{}), nil
+	}
+
 	switch v := fieldValue.(type) {
-	case int64:
-		return entity.NewColumnInt64(fieldName, []int64{})
+	case int, int32, int64:
+		return entity.NewColumnInt64(fieldName, []int64{}), nil
+	case Vector:
+		return entity.NewColumnFloatVector(fieldName, len(v), [][]float32{}), nil
 	case []float64:
-		return entity.NewColumnFloatVector(fieldName, len(v), [][]float32{})
+		return entity.NewColumnFloatVector(fieldName, len(v), [][]float32{}), nil
 	case []float32:
-		return entity.NewColumnFloatVector(fieldName, len(v), [][]float32{})
+		return entity.NewColumnFloatVector(fieldName, len(v), [][]float32{}), nil
 	case string:
-		return entity.NewColumnVarChar(fieldName, []string{})
+		return entity.NewColumnVarChar(fieldName, []string{}), nil
 	case map[string]interface{}:
 		// For metadata fields, we just create a varchar column
 		// The actual JSON conversion happens in appendToColumn
-		return entity.NewColumnVarChar(fieldName, []string{})
+		return entity.NewColumnVarChar(fieldName, []string{}), nil
 	default:
-		panic(fmt.Sprintf("unsupported field type for %s: %T", fieldName, fieldValue))
+		return nil, fmt.Errorf("field %s: %w: unsupported value type %T", fieldName, ErrFieldType, fieldValue)
 	}
 }
 
@@ -309,13 +815,24 @@ func (m *MilvusDB) SetColumnNames(names []string) {
 
 // appendToColumn adds a value to the appropriate type of column.
 // Handles type conversion and validation for different field types.
-func (m *MilvusDB) appendToColumn(col entity.Column, value interface{}) {
+func (m *MilvusDB) appendToColumn(col entity.Column, value interface{}) error {
 	switch c := col.(type) {
 	case *entity.ColumnInt64:
-		c.AppendValue(value.(int64))
+		switch v := value.(type) {
+		case int64:
+			c.AppendValue(v)
+		case int32:
+			c.AppendValue(int64(v))
+		case int:
+			c.AppendValue(int64(v))
+		default:
+			return fmt.Errorf("%w: expected an integer, got %T", ErrFieldType, value)
+		}
 	case *entity.ColumnFloatVector:
 		var floatVector []float32
 		switch v := value.(type) {
+		case Vector:
+			floatVector = toFloat32Slice(v)
 		case []float64:
 			floatVector = make([]float32, len(v))
 			for i, val := range v {
@@ -324,9 +841,18 @@ func (m *MilvusDB) appendToColumn(col entity.Column, value interface{}) {
 		case []float32:
 			floatVector = v
 		default:
-			panic(fmt.Sprintf("unsupported vector type: %T", value))
+			return fmt.Errorf("%w: expected a vector, got %T", ErrFieldType, value)
+		}
+		if len(floatVector) != c.Dim() {
+			return fmt.Errorf("%w: expected %d, got %d", ErrDimensionMismatch, c.Dim(), len(floatVector))
 		}
 		c.AppendValue(floatVector)
+	case *entity.ColumnJSONBytes:
+		jsonBytes, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON field: %w", err)
+		}
+		c.AppendValue(jsonBytes)
 	case *entity.ColumnVarChar:
 		switch v := value.(type) {
 		case string:
@@ -335,20 +861,23 @@ func (m *MilvusDB) appendToColumn(col entity.Column, value interface{}) {
 			// Handle metadata by converting to JSON string
 			jsonStr, err := json.Marshal(v)
 			if err != nil {
-				panic(fmt.Sprintf("failed to marshal metadata: %v", err))
+				return fmt.Errorf("failed to marshal metadata: %w", err)
 			}
 			c.AppendValue(string(jsonStr))
 		default:
-			panic(fmt.Sprintf("unsupported varchar value type: %T", value))
+			return fmt.Errorf("%w: expected a string, got %T", ErrFieldType, value)
 		}
 	default:
-		panic(fmt.Sprintf("unsupported column type: %T", col))
+		return fmt.Errorf("%w: column type %T", ErrUnsupportedDataType, col)
 	}
+	return nil
 }
 
 // wrapSearchResults converts Milvus search results to the internal SearchResult format.
-// It extracts scores, IDs, and field values from the Milvus results.
-func (m *MilvusDB) wrapSearchResults(result []client.SearchResult) []SearchResult {
+// It extracts scores, IDs, and field values from the Milvus results. Scores are
+// normalised for metric; an empty metric marks reranked results, whose scores
+// are kept as-is.
+func (m *MilvusDB) wrapSearchResults(result []client.SearchResult, metric entity.MetricType) []SearchResult {
 	var searchResults []SearchResult
 	for _, rs := range result {
 		for i := 0; i < rs.ResultCount; i++ {
@@ -358,17 +887,298 @@ func (m *MilvusDB) wrapSearchResults(result []client.SearchResult) []SearchResul
 			for _, fieldName := range m.columnNames {
 				if column := rs.Fields.GetColumn(fieldName); column != nil {
 					if value, err := column.Get(i); err == nil {
-						fields[fieldName] = value
+						fields[fieldName] = decodeMilvusValue(fieldName, value)
 					}
 				}
 			}
 
+			score := float64(rs.Scores[i])
+			var distance float64
+			if metric != "" {
+				distance = milvusDistance(metric, score)
+				score = ScoreFromDistance(string(metric), distance)
+			}
+
 			searchResults = append(searchResults, SearchResult{
-				ID:     id,
-				Score:  float64(rs.Scores[i]),
-				Fields: fields,
+				ID:       id,
+				DocID:    docIDFromFields(fields),
+				Score:    score,
+				Distance: distance,
+				Fields:   fields,
 			})
 		}
 	}
 	return searchResults
 }
+
+// wrapQueryResults converts a Milvus query result set into records.
+// Each row of the column-oriented result set becomes one Record.
+func (m *MilvusDB) wrapQueryResults(resultSet client.ResultSet) []Record {
+	if len(resultSet) == 0 {
+		return nil
+	}
+
+	records := make([]Record, resultSet[0].Len())
+	for i := range records {
+		fields := make(map[string]interface{}, len(resultSet))
+		for _, column := range resultSet {
+			if value, err := column.Get(i); err == nil {
+				fields[column.Name()] = decodeMilvusValue(column.Name(), value)
+			}
+		}
+		records[i] = Record{Fields: fields}
+	}
+	return records
+}
+
+// decodeMilvusValue converts JSON values returned by Milvus back into Go
+// values. JSON columns come back as raw bytes, and Metadata stored in a
+// varchar column comes back as a JSON string; both are decoded so callers
+// always see a map[string]interface{}.
+func decodeMilvusValue(fieldName string, value interface{}) interface{} {
+	var raw []byte
+	switch v := value.(type) {
+	case []byte:
+		raw = v
+	case string:
+		if fieldName != "Metadata" || !strings.HasPrefix(v, "{") {
+			return value
+		}
+		raw = []byte(v)
+	default:
+		return value
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return value
+	}
+	return decoded
+}
+
+// milvusFilterExpr translates a Filter into a Milvus boolean expression.
+// Metadata keys are addressed as JSON paths (Metadata["key"]) and TextField
+// maps to the Text field. CONTAINS becomes a LIKE pattern, and != and NOT IN
+// become negated == and IN so that they match entities missing the key.
+func milvusFilterExpr(f *Filter) (string, error) {
+	switch f.Op {
+	case OpAnd, OpOr:
+		joiner := " && "
+		if f.Op == OpOr {
+			joiner = " || "
+		}
+		parts := make([]string, len(f.Filters))
+		for i, child := range f.Filters {
+			expr, err := milvusFilterExpr(child)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = "(" + expr + ")"
+		}
+		return strings.Join(parts, joiner), nil
+	case OpNot:
+		inner, err := milvusFilterExpr(f.Filters[0])
+		if err != nil {
+			return "", err
+		}
+		return "not (" + inner + ")", nil
+	}
+
+	field := fmt.Sprintf("Metadata[%q]", f.Field)
+	if f.Field == TextField {
+		field = TextField
+	}
+
+	switch f.Op {
+	case OpNe:
+		// Milvus' != does not match entities missing the key
+		eq, err := milvusFilterExpr(Eq(f.Field, f.Value))
+		if err != nil {
+			return "", err
+		}
+		return "not (" + eq + ")", nil
+	case OpNotIn:
+		in, err := milvusFilterExpr(In(f.Field, filterValues(f.Value)...))
+		if err != nil {
+			return "", err
+		}
+		return "not (" + in + ")", nil
+	case OpEq, OpLt, OpLte, OpGt, OpGte:
+		value, err := milvusLiteral(f.Value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s %s", field, f.Op, value), nil
+	case OpIn:
+		values := filterValues(f.Value)
+		parts := make([]string, len(values))
+		for i, v := range values {
+			literal, err := milvusLiteral(v)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = literal
+		}
+		return fmt.Sprintf("%s in [%s]", field, strings.Join(parts, ", ")), nil
+	case OpContains:
+		return fmt.Sprintf("%s like %s", field, strconv.Quote("%"+f.Value.(string)+"%")), nil
+	default:
+		return "", fmt.Errorf("unsupported filter operation for Milvus: %q", f.Op)
+	}
+}
+
+// ErrVarcharMetadata is returned for filters that cannot be evaluated on a
+// Milvus collection whose Metadata field is a varchar, as created by earlier
+// versions of raggo. Such collections only support equality, IN and NOT IN
+// filters, combined with AND, OR and NOT. To use every filter, migrate the
+// collection to a JSON Metadata field: create a new collection whose Metadata
+// field has the "json" data type and Import an Export of the old one into
+// it, or re-ingest the documents into it.
+var ErrVarcharMetadata = errors.New("filter needs a JSON Metadata field, but the collection stores Metadata as varchar")
+
+// milvusTextFilterExpr translates a Filter into a Milvus boolean expression
+// over a varchar Metadata field holding the metadata as compact JSON text,
+// as written by json.Marshal. An equality becomes LIKE patterns matching the
+// key followed by the JSON encoding of the value, e.g.
+// Metadata like "%\"source\":\"doc.pdf\"%". The patterns may also match
+// a key of the same name nested in another value, and "_" and "%" in the
+// key or value match any character, so the match is approximate.
+func milvusTextFilterExpr(f *Filter) (string, error) {
+	switch f.Op {
+	case OpAnd, OpOr:
+		joiner := " && "
+		if f.Op == OpOr {
+			joiner = " || "
+		}
+		parts := make([]string, len(f.Filters))
+		for i, child := range f.Filters {
+			expr, err := milvusTextFilterExpr(child)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = "(" + expr + ")"
+		}
+		return strings.Join(parts, joiner), nil
+	case OpNot:
+		inner, err := milvusTextFilterExpr(f.Filters[0])
+		if err != nil {
+			return "", err
+		}
+		return "not (" + inner + ")", nil
+	}
+	if f.Field == TextField {
+		return milvusFilterExpr(f)
+	}
+
+	switch f.Op {
+	case OpEq, OpNe, OpIn, OpNotIn:
+		values := []interface{}{f.Value}
+		if f.Op == OpIn || f.Op == OpNotIn {
+			values = filterValues(f.Value)
+		}
+		var patterns []string
+		for _, v := range values {
+			if _, err := milvusLiteral(v); err != nil {
+				return "", err
+			}
+			key, _ := json.Marshal(f.Field)
+			value, err := json.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			match := string(key) + ":" + string(value)
+			if _, ok := v.(string); ok {
+				patterns = append(patterns, "%"+match+"%")
+			} else {
+				// Keep 1 from matching 10: the value ends the member
+				patterns = append(patterns, "%"+match+",%", "%"+match+"}%")
+			}
+		}
+		parts := make([]string, len(patterns))
+		for i, pattern := range patterns {
+			parts[i] = "Metadata like " + strconv.Quote(pattern)
+		}
+		expr := "Metadata in []"
+		if len(parts) > 0 {
+			expr = strings.Join(parts, " || ")
+		}
+		if f.Op == OpNe || f.Op == OpNotIn {
+			return "not (" + expr + ")", nil
+		}
+		return "(" + expr + ")", nil
+	default:
+		return "", fmt.Errorf("%w: %s on %s", ErrVarcharMetadata, f.Op, f.Field)
+	}
+}
+
+// milvusLiteral renders a filter value as a Milvus expression literal.
+func milvusLiteral(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return strconv.Quote(val), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	default:
+		if _, ok := toFloat(v); ok {
+			return fmt.Sprint(val), nil
+		}
+		return "", fmt.Errorf("unsupported filter value type for Milvus: %T", v)
+	}
+}
+
+// milvusIndexDefinition converts an index described by Milvus into an Index.
+// Milvus reports parameters as strings, either flattened or JSON-encoded
+// under "params"; numeric values are converted back to ints.
+func milvusIndexDefinition(idx entity.Index) Index {
+	params := idx.Params()
+	index := Index{
+		Type:       string(idx.IndexType()),
+		Metric:     params["metric_type"],
+		Parameters: make(map[string]interface{}),
+	}
+
+	raw := make(map[string]interface{})
+	if encoded, ok := params["params"]; ok {
+		if err := json.Unmarshal([]byte(encoded), &raw); err != nil {
+			GlobalLogger.Warn("Failed to decode Milvus index parameters", "params", encoded, "error", err)
+		}
+	}
+	for k, v := range params {
+		if k != "params" && k != "index_type" && k != "metric_type" {
+			raw[k] = v
+		}
+	}
+	for k, v := range raw {
+		switch value := v.(type) {
+		case string:
+			if n, err := strconv.Atoi(value); err == nil {
+				index.Parameters[k] = n
+				continue
+			}
+		case float64:
+			if value == math.Trunc(value) {
+				index.Parameters[k] = int(value)
+				continue
+			}
+		}
+		index.Parameters[k] = v
+	}
+	return index
+}
+
+// isMilvusIndexNotFound reports whether err means that a field has no index.
+func isMilvusIndexNotFound(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "index not found")
+}
+
+// isMilvusCollectionNotFound reports whether err is Milvus saying that a
+// collection does not exist: the client checks some calls itself, and the
+// server reports others.
+func isMilvusCollectionNotFound(err error) bool {
+	var notExists client.ErrCollectionNotExists
+	if errors.As(err, &notExists) {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "collection not found") || strings.Contains(message, "can't find collection")
+}
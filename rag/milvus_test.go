@@ -0,0 +1,109 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// milvusWithMetadata returns a MilvusDB whose "docs" collection is known to
+// store Metadata with the given Milvus type, so no server is needed.
+func milvusWithMetadata(metadataType entity.FieldType) *MilvusDB {
+	m, _ := newMilvusDB(&Config{})
+	m.collections["docs"] = &milvusCollection{fieldTypes: map[string]entity.FieldType{
+		"ID":       entity.FieldTypeInt64,
+		"Text":     entity.FieldTypeVarChar,
+		"Metadata": metadataType,
+	}}
+	return m
+}
+
+func TestMilvusFilterExpr(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   *Filter
+		wantJSON string // Expression on a JSON Metadata field
+		wantText string // Expression on a varchar Metadata field, "" if rejected
+	}{
+		{
+			name:     "string equality",
+			filter:   Eq("source", "a.txt"),
+			wantJSON: `Metadata["source"] == "a.txt"`,
+			wantText: `(Metadata like "%\"source\":\"a.txt\"%")`,
+		},
+		{
+			name:     "number equality ends the member",
+			filter:   Eq("chunk", 1),
+			wantJSON: `Metadata["chunk"] == 1`,
+			wantText: `(Metadata like "%\"chunk\":1,%" || Metadata like "%\"chunk\":1}%")`,
+		},
+		{
+			name:     "in list",
+			filter:   In("lang", "en", "fr"),
+			wantJSON: `Metadata["lang"] in ["en", "fr"]`,
+			wantText: `(Metadata like "%\"lang\":\"en\"%" || Metadata like "%\"lang\":\"fr\"%")`,
+		},
+		{
+			name:     "empty in list",
+			filter:   In("lang"),
+			wantJSON: `Metadata["lang"] in []`,
+			wantText: `(Metadata in [])`,
+		},
+		{
+			name:     "not equal matches missing keys",
+			filter:   Ne("lang", "en"),
+			wantJSON: `not (Metadata["lang"] == "en")`,
+			wantText: `not (Metadata like "%\"lang\":\"en\"%")`,
+		},
+		{
+			name:     "not in matches missing keys",
+			filter:   NotIn("chunk", 1, 2),
+			wantJSON: `not (Metadata["chunk"] in [1, 2])`,
+			wantText: `not (Metadata like "%\"chunk\":1,%" || Metadata like "%\"chunk\":1}%" || Metadata like "%\"chunk\":2,%" || Metadata like "%\"chunk\":2}%")`,
+		},
+		{
+			name:     "prune of earlier revisions",
+			filter:   And(Eq("source", "a.txt"), Not(Eq("revision", "r2")), Not(In("chunk", 1))),
+			wantJSON: `(Metadata["source"] == "a.txt") && (not (Metadata["revision"] == "r2")) && (not (Metadata["chunk"] in [1]))`,
+			wantText: `((Metadata like "%\"source\":\"a.txt\"%")) && (not ((Metadata like "%\"revision\":\"r2\"%"))) && (not ((Metadata like "%\"chunk\":1,%" || Metadata like "%\"chunk\":1}%")))`,
+		},
+		{
+			name:     "text field is a column",
+			filter:   Or(Contains(TextField, "go"), Eq("a", true)),
+			wantJSON: `(Text like "%go%") || (Metadata["a"] == true)`,
+			wantText: `(Text like "%go%") || ((Metadata like "%\"a\":true,%" || Metadata like "%\"a\":true}%"))`,
+		},
+		{
+			name:     "range needs JSON",
+			filter:   Lt("chunk", 10),
+			wantJSON: `Metadata["chunk"] < 10`,
+		},
+		{
+			name:     "contains needs JSON",
+			filter:   Contains("source", "docs/"),
+			wantJSON: `Metadata["source"] like "%docs/%"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			got, err := milvusWithMetadata(entity.FieldTypeJSON).filterExpr(ctx, "docs", tt.filter)
+			if err != nil || got != tt.wantJSON {
+				t.Errorf("JSON Metadata: filterExpr = %s, %v, want %s", got, err, tt.wantJSON)
+			}
+
+			got, err = milvusWithMetadata(entity.FieldTypeVarChar).filterExpr(ctx, "docs", tt.filter)
+			if tt.wantText == "" {
+				if !errors.Is(err, ErrVarcharMetadata) {
+					t.Errorf("varchar Metadata: filterExpr = %s, %v, want ErrVarcharMetadata", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.wantText {
+				t.Errorf("varchar Metadata: filterExpr = %s, %v, want %s", got, err, tt.wantText)
+			}
+		})
+	}
+}
@@ -0,0 +1,1343 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// defaultPgVectorDSN is the connection string of a local PostgreSQL server
+	defaultPgVectorDSN = "postgres://postgres@localhost:5432/postgres?sslmode=disable"
+	// defaultPgVectorDriver is the database/sql driver used when none is configured
+	defaultPgVectorDriver = "pgx"
+	// defaultIVFFlatLists is the number of IVFFlat lists when nlist is not given
+	defaultIVFFlatLists = 100
+)
+
+// PgVectorDB implements the VectorDB interface on PostgreSQL with the
+// pgvector extension, using database/sql. Each collection is a table:
+//   - float_vector fields become vector(n) columns
+//   - json fields (Metadata) become JSONB columns, filterable with Filter
+//   - other fields become regular columns, and the primary key is an
+//     identity column when AutoID is set
+//
+// No driver is linked in: import one (e.g. github.com/jackc/pgx/v5/stdlib,
+// registered as "pgx", or github.com/lib/pq, registered as "postgres") and
+// name it with the "driver" parameter, or pass an existing *sql.DB with the
+// "db" parameter to share a connection pool with the rest of the application.
+type PgVectorDB struct {
+	db          *sql.DB             // Connection pool, opened by Connect unless provided
+	ownsDB      bool                // Whether Close should close the pool
+	driver      string              // database/sql driver name
+	dsn         string              // Connection string passed to the driver
+	maxConns    int                 // Maximum number of open connections (0 = unlimited)
+	columnNames []string            // Columns to retrieve in search results
+	tables      map[string]*pgTable // Cached table descriptions
+	mu          sync.Mutex          // Protects tables
+}
+
+// pgTable describes the columns of a collection table.
+type pgTable struct {
+	columns    []pgColumn
+	primaryKey string
+	schema     Schema // The columns as schema fields, for validating writes
+}
+
+// pgColumn describes one column of a collection table.
+type pgColumn struct {
+	name string
+	kind pgColumnKind
+}
+
+// pgColumnKind tells how a column's values are encoded in queries.
+type pgColumnKind int
+
+const (
+	pgScalar pgColumnKind = iota
+	pgVector
+	pgJSON
+)
+
+// column returns the named column of the table.
+func (t *pgTable) column(name string) (pgColumn, bool) {
+	for _, c := range t.columns {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return pgColumn{}, false
+}
+
+func init() {
+	Register("pgvector", func(cfg *Config) (VectorDB, error) { return newPgVectorDB(cfg) }, Capabilities{Hybrid: true, Filters: true, Delete: true, Persistence: true})
+}
+
+// newPgVectorDB creates a new PgVectorDB instance with the given configuration.
+// Note: This doesn't establish the connection - call Connect() separately.
+//
+// cfg.Address is the connection string (default
+// postgres://postgres@localhost:5432/postgres?sslmode=disable). Supported
+// parameters:
+// - "driver" (string): database/sql driver name, "pgx" by default
+// - "db" (*sql.DB): existing connection pool; Address and driver are then ignored
+func newPgVectorDB(cfg *Config) (*PgVectorDB, error) {
+	p := &PgVectorDB{
+		driver:   defaultPgVectorDriver,
+		dsn:      cfg.Address,
+		maxConns: cfg.MaxPoolSize,
+		tables:   make(map[string]*pgTable),
+	}
+	if p.dsn == "" {
+		p.dsn = defaultPgVectorDSN
+	}
+	if driver, ok := cfg.Parameters["driver"].(string); ok && driver != "" {
+		p.driver = driver
+	}
+	if db, ok := cfg.Parameters["db"]; ok {
+		sqlDB, ok := db.(*sql.DB)
+		if !ok || sqlDB == nil {
+			return nil, fmt.Errorf("pgvector parameter db must be a *sql.DB, got %T", db)
+		}
+		p.db = sqlDB
+	}
+	return p, nil
+}
+
+// Connect opens the connection pool (unless one was provided), checks that
+// the server is reachable and enables the vector extension.
+func (p *PgVectorDB) Connect(ctx context.Context) error {
+	GlobalLogger.Debug("Attempting to connect to PostgreSQL", "driver", p.driver)
+
+	if p.db == nil {
+		db, err := sql.Open(p.driver, p.dsn)
+		if err != nil {
+			return fmt.Errorf("failed to open PostgreSQL connection with driver %q (is it imported?): %w", p.driver, err)
+		}
+		if p.maxConns > 0 {
+			db.SetMaxOpenConns(p.maxConns)
+		}
+		p.db = db
+		p.ownsDB = true
+	}
+
+	if err := p.db.PingContext(ctx); err != nil {
+		GlobalLogger.Error("Failed to connect to PostgreSQL", "error", err)
+		return fmt.Errorf("failed to connect to PostgreSQL: %w\nPlease ensure PostgreSQL with pgvector is running (e.g., with 'docker run -e POSTGRES_HOST_AUTH_METHOD=trust -p 5432:5432 pgvector/pgvector:pg16')", err)
+	}
+	if _, err := p.db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS vector"); err != nil {
+		return fmt.Errorf("failed to enable the pgvector extension: %w", err)
+	}
+
+	GlobalLogger.Debug("Successfully connected to PostgreSQL")
+	return nil
+}
+
+// Close closes the connection pool if it was opened by Connect.
+func (p *PgVectorDB) Close() error {
+	if p.db == nil || !p.ownsDB {
+		return nil
+	}
+	err := p.db.Close()
+	p.db = nil
+	return err
+}
+
+// HasCollection checks if a table with the given name exists in the current schema.
+func (p *PgVectorDB) HasCollection(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	if err := p.db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", pgIdent(name)).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check collection %s: %w", name, err)
+	}
+	return exists, nil
+}
+
+// DropCollection drops the table of a collection, including its indexes.
+// Warning: This operation is irreversible.
+func (p *PgVectorDB) DropCollection(ctx context.Context, name string) error {
+	p.forgetTable(name)
+	if _, err := p.db.ExecContext(ctx, "DROP TABLE IF EXISTS "+pgIdent(name)); err != nil {
+		return fmt.Errorf("failed to drop collection %s: %w", name, err)
+	}
+	return nil
+}
+
+// CreateCollection creates a table with one column per schema field. Field
+// names are kept as-is (quoted), so the standard schema yields the columns
+// "ID", "Embedding", "Text" and "Metadata".
+func (p *PgVectorDB) CreateCollection(ctx context.Context, name string, schema Schema) error {
+	definitions := make([]string, 0, len(schema.Fields))
+	for _, field := range schema.Fields {
+		columnType, err := pgColumnType(field)
+		if err != nil {
+			return err
+		}
+		definitions = append(definitions, pgIdent(field.Name)+" "+columnType)
+	}
+	if len(definitions) == 0 {
+		return fmt.Errorf("schema for collection %s has no fields", name)
+	}
+	exists, err := p.HasCollection(ctx, name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrCollectionExists, name)
+	}
+
+	query := fmt.Sprintf("CREATE TABLE %s (%s)", pgIdent(name), strings.Join(definitions, ", "))
+	GlobalLogger.Debug("Creating collection", "name", name, "query", query)
+	p.forgetTable(name)
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create collection %s: %w", name, err)
+	}
+	return nil
+}
+
+// Insert adds records to a collection in a single transaction. Records
+// without a primary key value get one derived from their document ID (see
+// DocIDKey), or from the identity column.
+func (p *PgVectorDB) Insert(ctx context.Context, collectionName string, data []Record) error {
+	return p.write(ctx, collectionName, data, false)
+}
+
+// Upsert inserts records, replacing the rows that share the same primary key,
+// in a single transaction.
+func (p *PgVectorDB) Upsert(ctx context.Context, collectionName string, data []Record) error {
+	return p.write(ctx, collectionName, data, true)
+}
+
+// write inserts or upserts records in one transaction, so a failing record
+// leaves the collection unchanged.
+func (p *PgVectorDB) write(ctx context.Context, collectionName string, data []Record, upsert bool) error {
+	if len(data) == 0 {
+		return nil
+	}
+	table, err := p.describe(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+	if err := ValidateRecords(table.schema, data); err != nil {
+		return err
+	}
+	data = assignDocIDs(data, table.primaryKey)
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, record := range data {
+		query, args, err := pgWriteQuery(collectionName, table, record, upsert)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			GlobalLogger.Error("Failed to write record", "collection", collectionName, "index", i, "error", err)
+			return fmt.Errorf("failed to write record %d: %w", i, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit records: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the rows with the given primary keys.
+func (p *PgVectorDB) Delete(ctx context.Context, collectionName string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	table, err := p.describe(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+
+	placeholders, args := pgIDList(ids)
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s IN (%s)", pgIdent(collectionName), pgIdent(table.primaryKey), placeholders)
+	if _, err := p.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete records: %w", err)
+	}
+	return nil
+}
+
+// DeleteByFilter removes every row matching filter.
+func (p *PgVectorDB) DeleteByFilter(ctx context.Context, collectionName string, filter *Filter) error {
+	if filter == nil {
+		return fmt.Errorf("delete filter must not be empty")
+	}
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := p.describe(ctx, collectionName); err != nil {
+		return err
+	}
+
+	var args []interface{}
+	where, err := pgFilterExpr(filter, &args)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", pgIdent(collectionName), where)
+	if _, err := p.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete records by filter: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves the rows with the given primary keys, with every column.
+// IDs that are not present in the collection are skipped.
+func (p *PgVectorDB) Get(ctx context.Context, collectionName string, ids []int64) ([]Record, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	table, err := p.describe(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	placeholders, args := pgIDList(ids)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IN (%s)",
+		pgSelectList(table.columns), pgIdent(collectionName), pgIdent(table.primaryKey), placeholders)
+	records, err := p.queryRecords(ctx, query, table.columns, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+	return records, nil
+}
+
+// Scan calls fn with every row of a collection, ordered by primary key. Each
+// batch is read with a keyset query starting after the last key of the
+// previous batch, so rows are not held open while fn runs.
+func (p *PgVectorDB) Scan(ctx context.Context, collectionName string, batchSize int, fn func([]Record) error) error {
+	table, err := p.describe(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+	batchSize = scanBatchSize(batchSize)
+
+	var after interface{}
+	for {
+		query := fmt.Sprintf("SELECT %s FROM %s", pgSelectList(table.columns), pgIdent(collectionName))
+		args := []interface{}{batchSize}
+		if after != nil {
+			query += fmt.Sprintf(" WHERE %s > $2", pgIdent(table.primaryKey))
+			args = append(args, after)
+		}
+		query += fmt.Sprintf(" ORDER BY %s LIMIT $1", pgIdent(table.primaryKey))
+
+		records, err := p.queryRecords(ctx, query, table.columns, args...)
+		if err != nil {
+			return fmt.Errorf("failed to scan collection %s: %w", collectionName, err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := fn(records); err != nil {
+			return err
+		}
+		if len(records) < batchSize {
+			return nil
+		}
+		after = records[len(records)-1].Fields[table.primaryKey]
+	}
+}
+
+// queryRecords runs a query selecting the given columns with pgSelectList.
+func (p *PgVectorDB) queryRecords(ctx context.Context, query string, columns []pgColumn, args ...interface{}) ([]Record, error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		fields, err := pgScanRow(rows, columns)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, Record{Fields: fields})
+	}
+	return records, rows.Err()
+}
+
+// Flush is a no-op: writes are durable once their transaction commits.
+func (p *PgVectorDB) Flush(ctx context.Context, collectionName string) error {
+	return nil
+}
+
+// CreateIndex creates an index on a column.
+// Vector columns support:
+// - "HNSW": parameters "M" (default 16) and "efConstruction" (default 200)
+// - "IVF_FLAT": parameter "nlist" (default 100), the number of lists
+// The operator class follows the index metric, and only searches with the
+// same metric can use the index. Other columns support "BTREE" and, for
+// JSONB columns such as Metadata, "GIN", which speeds up equality filters.
+func (p *PgVectorDB) CreateIndex(ctx context.Context, collectionName, field string, index Index) error {
+	table, err := p.describe(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+	column, ok := table.column(field)
+	if !ok {
+		return fmt.Errorf("collection %s has no field %s", collectionName, field)
+	}
+
+	var method, opclass, with string
+	if column.kind == pgVector {
+		metric, err := ResolveMetric(index.Metric)
+		if err != nil {
+			return err
+		}
+		opclass = pgOperatorClass(metric)
+		switch strings.ToUpper(index.Type) {
+		case "HNSW":
+			method = "hnsw"
+			with = fmt.Sprintf(" WITH (m = %d, ef_construction = %d)",
+				intParam(index.Parameters, "M", defaultHNSWM),
+				intParam(index.Parameters, "efConstruction", defaultHNSWEfConstruction))
+		case "IVF_FLAT", "IVFFLAT":
+			method = "ivfflat"
+			with = fmt.Sprintf(" WITH (lists = %d)", intParam(index.Parameters, "nlist", defaultIVFFlatLists))
+		default:
+			return fmt.Errorf("unsupported index type: %s", index.Type)
+		}
+	} else {
+		switch strings.ToUpper(index.Type) {
+		case "BTREE":
+			method = "btree"
+		case "GIN":
+			if column.kind != pgJSON {
+				return fmt.Errorf("GIN indexes require a json field, %s is not one", field)
+			}
+			method = "gin"
+		default:
+			return fmt.Errorf("unsupported index type: %s", index.Type)
+		}
+	}
+
+	name := collectionName + "_" + field + "_" + method
+	if opclass != "" {
+		name += "_" + strings.TrimSuffix(strings.TrimPrefix(opclass, "vector_"), "_ops")
+	}
+	name = strings.ToLower(name + "_idx")
+	target := pgIdent(field)
+	if opclass != "" {
+		target += " " + opclass
+	}
+	query := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING %s (%s)%s",
+		pgIdent(name), pgIdent(collectionName), method, target, with)
+	GlobalLogger.Debug("Creating index", "collection", collectionName, "query", query)
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+	return nil
+}
+
+// LoadCollection checks that the collection exists. PostgreSQL serves
+// tables without an explicit load step.
+func (p *PgVectorDB) LoadCollection(ctx context.Context, name string) error {
+	exists, err := p.HasCollection(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return collectionNotFound(name)
+	}
+	return nil
+}
+
+// ListCollections returns the names of the tables in the current schema
+// that have a vector column, sorted.
+func (p *PgVectorDB) ListCollections(ctx context.Context) ([]string, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT DISTINCT c.relname
+		FROM pg_class c
+		JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+		JOIN pg_type t ON t.oid = a.atttypid
+		WHERE c.relkind IN ('r', 'p') AND c.relnamespace = current_schema()::regnamespace AND t.typname = 'vector'
+		ORDER BY c.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to list collections: %w", err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	return names, nil
+}
+
+// DescribeCollection returns the schema of a collection, read from the
+// table's columns, and the index built on each column. Expression indexes
+// are not reported.
+func (p *PgVectorDB) DescribeCollection(ctx context.Context, name string) (*CollectionInfo, error) {
+	table, err := p.describe(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	schema := table.schema
+	schema.Fields = append([]Field(nil), table.schema.Fields...)
+
+	indexes, err := p.indexes(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	info := &CollectionInfo{Name: name, Schema: schema, Indexes: make(map[string]Index, len(indexes))}
+	for _, index := range indexes {
+		info.Indexes[index.column] = index.definition()
+	}
+	return info, nil
+}
+
+// Count returns the number of rows in a collection.
+func (p *PgVectorDB) Count(ctx context.Context, collectionName string) (int64, error) {
+	if _, err := p.describe(ctx, collectionName); err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := p.db.QueryRowContext(ctx, "SELECT count(*) FROM "+pgIdent(collectionName)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count collection %s: %w", collectionName, err)
+	}
+	return count, nil
+}
+
+// Stats reports the row count of a collection, the total size of its table
+// and indexes, and whether its vector index is ready: an index created
+// concurrently stays invalid until its build completes.
+func (p *PgVectorDB) Stats(ctx context.Context, collectionName string) (*CollectionStats, error) {
+	info, err := p.DescribeCollection(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	count, err := p.Count(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	var size int64
+	if err := p.db.QueryRowContext(ctx, "SELECT pg_total_relation_size(to_regclass($1))", pgIdent(collectionName)).Scan(&size); err != nil {
+		return nil, fmt.Errorf("failed to measure collection %s: %w", collectionName, err)
+	}
+	indexes, err := p.indexes(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	status := IndexStatusNone
+	for _, index := range indexes {
+		if index.method != "hnsw" && index.method != "ivfflat" {
+			continue
+		}
+		if !index.valid {
+			status = IndexStatusBuilding
+			break
+		}
+		status = IndexStatusReady
+	}
+	return &CollectionStats{
+		Count:       count,
+		Dimension:   vectorDimension(info.Schema),
+		StorageSize: size,
+		IndexStatus: status,
+	}, nil
+}
+
+// pgIndex describes an index on a single column of a collection table.
+type pgIndex struct {
+	column  string
+	method  string   // Access method: hnsw, ivfflat, btree, gin, ...
+	opclass string   // Operator class of the indexed column
+	options []string // Storage parameters, as key=value
+	valid   bool     // Whether the index can be used by queries
+}
+
+// indexes returns the single-column indexes of a collection table, other
+// than the primary key, in creation order.
+func (p *PgVectorDB) indexes(ctx context.Context, collectionName string) ([]pgIndex, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT a.attname, am.amname, opc.opcname,
+		COALESCE(array_to_string(ic.reloptions, ','), ''), i.indisvalid
+		FROM pg_index i
+		JOIN pg_class ic ON ic.oid = i.indexrelid
+		JOIN pg_am am ON am.oid = ic.relam
+		JOIN pg_opclass opc ON opc.oid = i.indclass[0]
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = i.indkey[0]
+		WHERE i.indrelid = to_regclass($1) AND NOT i.indisprimary AND i.indnatts = 1
+		ORDER BY i.indexrelid`, pgIdent(collectionName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes of collection %s: %w", collectionName, err)
+	}
+	defer rows.Close()
+
+	var indexes []pgIndex
+	for rows.Next() {
+		var index pgIndex
+		var options string
+		if err := rows.Scan(&index.column, &index.method, &index.opclass, &options, &index.valid); err != nil {
+			return nil, fmt.Errorf("failed to list indexes of collection %s: %w", collectionName, err)
+		}
+		if options != "" {
+			index.options = strings.Split(options, ",")
+		}
+		indexes = append(indexes, index)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list indexes of collection %s: %w", collectionName, err)
+	}
+	return indexes, nil
+}
+
+// definition converts the index into the Index that CreateIndex would take
+// to create it. Parameters left at their PostgreSQL defaults are omitted.
+func (i pgIndex) definition() Index {
+	index := Index{Type: strings.ToUpper(i.method), Parameters: make(map[string]interface{})}
+	switch i.method {
+	case "hnsw", "ivfflat":
+		if i.method == "ivfflat" {
+			index.Type = "IVF_FLAT"
+		}
+		switch i.opclass {
+		case pgOperatorClass(MetricIP):
+			index.Metric = MetricIP
+		case pgOperatorClass(MetricCosine):
+			index.Metric = MetricCosine
+		default:
+			index.Metric = MetricL2
+		}
+	}
+
+	names := map[string]string{"m": "M", "ef_construction": "efConstruction", "lists": "nlist"}
+	for _, option := range i.options {
+		key, value, _ := strings.Cut(option, "=")
+		if name, ok := names[key]; ok {
+			key = name
+		}
+		if n, err := strconv.Atoi(value); err == nil {
+			index.Parameters[key] = n
+		} else {
+			index.Parameters[key] = value
+		}
+	}
+	return index
+}
+
+// Search performs vector similarity search with the pgvector distance
+// operator of the metric: <-> for L2, <#> for IP and <=> for COSINE.
+// Parameters:
+//   - vectors: Map of field name to vector values (exactly one entry)
+//   - topK: Number of results to return
+//   - metricType: Distance metric; an index is only used if it has the same metric
+//   - searchParams: Optional "ef" (hnsw.ef_search), "nprobe" (ivfflat.probes)
+//     and FilterParam, which is translated into a WHERE clause
+func (p *PgVectorDB) Search(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}) ([]SearchResult, error) {
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("pgvector search requires exactly one vector, got %d", len(vectors))
+	}
+
+	metric, err := ResolveMetric(metricType)
+	if err != nil {
+		return nil, err
+	}
+	var fieldName string
+	var vector Vector
+	for f, v := range vectors {
+		fieldName = f
+		vector = v
+	}
+
+	return p.searchColumn(ctx, collectionName, fieldName, vector, topK, metric, searchParams)
+}
+
+// SearchBatch runs the searches of the query vectors on field concurrently,
+// up to 8 at a time, each on its own pooled connection. Each search is
+// performed as by Search.
+func (p *PgVectorDB) SearchBatch(ctx context.Context, collectionName, field string, queries []Vector, topK int, metricType string, searchParams map[string]interface{}) ([][]SearchResult, error) {
+	metric, err := ResolveMetric(metricType)
+	if err != nil {
+		return nil, err
+	}
+	return searchEach(ctx, queries, defaultSearchBatchConcurrency, func(ctx context.Context, query Vector) ([]SearchResult, error) {
+		return p.searchColumn(ctx, collectionName, field, query, topK, metric, searchParams)
+	})
+}
+
+// HybridSearch searches each vector column separately and fuses the rankings
+// with Reciprocal Rank Fusion. The reranker may be nil (RRF with k = 60) or
+// a *RRFReranker. Result scores are the fused scores; their Distance is zero.
+func (p *PgVectorDB) HybridSearch(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}, reranker interface{}) ([]SearchResult, error) {
+	rrf := NewRRFReranker(0)
+	if reranker != nil {
+		var ok bool
+		if rrf, ok = reranker.(*RRFReranker); !ok {
+			return nil, fmt.Errorf("invalid reranker type %T for pgvector", reranker)
+		}
+	}
+
+	metric, err := ResolveMetric(metricType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Search fields in a stable order so that ties fuse deterministically
+	fields := make([]string, 0, len(vectors))
+	for field := range vectors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	rankings := make([][]SearchResult, 0, len(fields))
+	for _, field := range fields {
+		ranking, err := p.searchColumn(ctx, collectionName, field, vectors[field], topK, metric, searchParams)
+		if err != nil {
+			return nil, err
+		}
+		rankings = append(rankings, ranking)
+	}
+
+	results := rrf.fuse(rankings...)
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+// SetColumnNames sets the columns to retrieve in search results. When empty,
+// every column except vectors is returned.
+func (p *PgVectorDB) SetColumnNames(names []string) {
+	p.columnNames = names
+}
+
+// searchColumn runs a nearest-neighbour query against one vector column.
+// Query-time index parameters are set with SET LOCAL, which requires a
+// transaction; without them the query runs directly on the pool.
+func (p *PgVectorDB) searchColumn(ctx context.Context, collectionName, field string, vector Vector, topK int, metric string, searchParams map[string]interface{}) ([]SearchResult, error) {
+	table, err := p.describe(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	column, ok := table.column(field)
+	if !ok || column.kind != pgVector {
+		return nil, fmt.Errorf("collection %s has no vector field %s", collectionName, field)
+	}
+
+	columns := p.resultColumns(table)
+	distance := fmt.Sprintf("%s %s $1::text::vector", pgIdent(field), pgDistanceOperator(metric))
+	args := []interface{}{pgVectorLiteral(vector)}
+
+	var where string
+	filter, err := filterFromParams(searchParams)
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		expr, err := pgFilterExpr(filter, &args)
+		if err != nil {
+			return nil, err
+		}
+		where = " WHERE " + expr
+	}
+
+	args = append(args, topK)
+	query := fmt.Sprintf("SELECT %s, %s FROM %s%s ORDER BY %s LIMIT $%d",
+		pgSelectList(columns), distance, pgIdent(collectionName), where, distance, len(args))
+
+	var settings []string
+	if ef := intParam(searchParams, "ef", 0); ef > 0 {
+		settings = append(settings, fmt.Sprintf("SET LOCAL hnsw.ef_search = %d", ef))
+	}
+	if probes := intParam(searchParams, "nprobe", 0); probes > 0 {
+		settings = append(settings, fmt.Sprintf("SET LOCAL ivfflat.probes = %d", probes))
+	}
+
+	var rows *sql.Rows
+	if len(settings) == 0 {
+		rows, err = p.db.QueryContext(ctx, query, args...)
+	} else {
+		var tx *sql.Tx
+		if tx, err = p.db.BeginTx(ctx, nil); err != nil {
+			return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		// The transaction only scopes the settings, so it is always rolled back
+		defer tx.Rollback()
+		for _, setting := range settings {
+			if _, err := tx.ExecContext(ctx, setting); err != nil {
+				return nil, fmt.Errorf("failed to apply search parameters: %w", err)
+			}
+		}
+		rows, err = tx.QueryContext(ctx, query, args...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search collection %s: %w", collectionName, err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var dist float64
+		fields, err := pgScanRow(rows, columns, &dist)
+		if err != nil {
+			return nil, err
+		}
+		id, _ := pgInt64(fields[table.primaryKey])
+		if !p.selectsColumn(table.primaryKey) {
+			delete(fields, table.primaryKey)
+		}
+
+		results = append(results, SearchResult{
+			ID:       id,
+			DocID:    docIDFromFields(fields),
+			Score:    ScoreFromDistance(metric, dist),
+			Distance: dist,
+			Fields:   fields,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read search results: %w", err)
+	}
+	return results, nil
+}
+
+// resultColumns returns the columns selected by a search: the primary key
+// followed by the configured columns, or every non-vector column.
+func (p *PgVectorDB) resultColumns(table *pgTable) []pgColumn {
+	columns := []pgColumn{{name: table.primaryKey}}
+	for _, c := range table.columns {
+		if c.name == table.primaryKey {
+			continue
+		}
+		if len(p.columnNames) == 0 && c.kind != pgVector || p.selectsColumn(c.name) {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// selectsColumn reports whether name was requested with SetColumnNames.
+func (p *PgVectorDB) selectsColumn(name string) bool {
+	for _, c := range p.columnNames {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// describe returns the columns and primary key of a collection table,
+// reading them from the catalog on first use.
+func (p *PgVectorDB) describe(ctx context.Context, collectionName string) (*pgTable, error) {
+	p.mu.Lock()
+	table, ok := p.tables[collectionName]
+	p.mu.Unlock()
+	if ok {
+		return table, nil
+	}
+
+	exists, err := p.HasCollection(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, collectionNotFound(collectionName)
+	}
+
+	rows, err := p.db.QueryContext(ctx, `SELECT a.attname, format_type(a.atttypid, a.atttypmod), a.attidentity <> '',
+		EXISTS (SELECT 1 FROM pg_index i WHERE i.indrelid = a.attrelid AND i.indisprimary AND a.attnum = ANY(i.indkey))
+		FROM pg_attribute a
+		WHERE a.attrelid = to_regclass($1) AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`, pgIdent(collectionName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe collection %s: %w", collectionName, err)
+	}
+	defer rows.Close()
+
+	table = &pgTable{schema: Schema{Name: collectionName}}
+	for rows.Next() {
+		var name, columnType string
+		var identity, primary bool
+		if err := rows.Scan(&name, &columnType, &identity, &primary); err != nil {
+			return nil, fmt.Errorf("failed to describe collection %s: %w", collectionName, err)
+		}
+		field := pgField(name, columnType)
+		field.PrimaryKey = primary
+		field.AutoID = identity
+		table.schema.Fields = append(table.schema.Fields, field)
+
+		column := pgColumn{name: name}
+		switch {
+		case strings.HasPrefix(columnType, "vector"):
+			column.kind = pgVector
+		case columnType == "jsonb" || columnType == "json":
+			column.kind = pgJSON
+		}
+		table.columns = append(table.columns, column)
+		if primary {
+			table.primaryKey = name
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to describe collection %s: %w", collectionName, err)
+	}
+	if table.primaryKey == "" {
+		return nil, fmt.Errorf("collection %s has no primary key", collectionName)
+	}
+
+	p.mu.Lock()
+	p.tables[collectionName] = table
+	p.mu.Unlock()
+	return table, nil
+}
+
+// forgetTable drops the cached description of a collection table.
+func (p *PgVectorDB) forgetTable(collectionName string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.tables, collectionName)
+}
+
+// pgField converts a column and its formatted PostgreSQL type back into a
+// schema field, the reverse of pgColumnType.
+func pgField(name, columnType string) Field {
+	field := Field{Name: name, DataType: columnType}
+	base, modifier, _ := strings.Cut(columnType, "(")
+	size, _ := strconv.Atoi(strings.TrimSuffix(modifier, ")"))
+	switch base {
+	case "bigint":
+		field.DataType = "int64"
+	case "integer":
+		field.DataType = "int32"
+	case "smallint":
+		field.DataType = "int16"
+	case "real":
+		field.DataType = "float"
+	case "double precision":
+		field.DataType = "double"
+	case "boolean":
+		field.DataType = "bool"
+	case "text":
+		field.DataType = "varchar"
+	case "character varying":
+		field.DataType = "varchar"
+		field.MaxLength = size
+	case "json", "jsonb":
+		field.DataType = "json"
+	case "vector":
+		field.DataType = "float_vector"
+		field.Dimension = size
+	}
+	return field
+}
+
+// pgColumnType returns the PostgreSQL column definition of a schema field.
+func pgColumnType(field Field) (string, error) {
+	var columnType string
+	switch field.DataType {
+	case "int64":
+		columnType = "BIGINT"
+	case "int32", "int":
+		columnType = "INTEGER"
+	case "int16", "int8":
+		columnType = "SMALLINT"
+	case "float":
+		columnType = "REAL"
+	case "double":
+		columnType = "DOUBLE PRECISION"
+	case "bool":
+		columnType = "BOOLEAN"
+	case "varchar", "string":
+		columnType = "TEXT"
+		if field.MaxLength > 0 {
+			columnType = fmt.Sprintf("VARCHAR(%d)", field.MaxLength)
+		}
+	case "json":
+		columnType = "JSONB"
+	case "float_vector":
+		if field.Dimension <= 0 {
+			return "", fmt.Errorf("vector field %s requires a positive dimension", field.Name)
+		}
+		columnType = fmt.Sprintf("vector(%d)", field.Dimension)
+	default:
+		return "", fmt.Errorf("%w %q for field %s", ErrUnsupportedDataType, field.DataType, field.Name)
+	}
+
+	if field.PrimaryKey {
+		if field.AutoID {
+			// BY DEFAULT rather than ALWAYS, so that records can still be
+			// upserted with an explicit ID
+			columnType += " GENERATED BY DEFAULT AS IDENTITY"
+		}
+		columnType += " PRIMARY KEY"
+	}
+	return columnType, nil
+}
+
+// pgWriteQuery builds the INSERT statement of one record. Upserts update
+// every written column when the primary key already exists.
+func pgWriteQuery(collectionName string, table *pgTable, record Record, upsert bool) (string, []interface{}, error) {
+	names := make([]string, 0, len(record.Fields))
+	for name := range record.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	columns := make([]string, len(names))
+	placeholders := make([]string, len(names))
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		column, ok := table.column(name)
+		if !ok {
+			return "", nil, fmt.Errorf("collection %s has no field %s", collectionName, name)
+		}
+		placeholder, arg, err := pgEncodeValue(column, record.Fields[name])
+		if err != nil {
+			return "", nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		columns[i] = pgIdent(name)
+		placeholders[i] = fmt.Sprintf(placeholder, i+1)
+		args[i] = arg
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		pgIdent(collectionName), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+	if _, hasKey := record.Fields[table.primaryKey]; upsert && hasKey {
+		var updates []string
+		for _, name := range names {
+			if name != table.primaryKey {
+				updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", pgIdent(name), pgIdent(name)))
+			}
+		}
+		if len(updates) == 0 {
+			query += fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", pgIdent(table.primaryKey))
+		} else {
+			query += fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", pgIdent(table.primaryKey), strings.Join(updates, ", "))
+		}
+	}
+	return query, args, nil
+}
+
+// pgEncodeValue converts a field value into a query argument. Vectors and
+// JSON are sent as text and cast by the server, which works the same with
+// every driver. The returned placeholder is a format string for the
+// parameter number.
+func pgEncodeValue(column pgColumn, value interface{}) (string, interface{}, error) {
+	switch column.kind {
+	case pgVector:
+		switch v := value.(type) {
+		case Vector:
+			return "$%d::text::vector", pgVectorLiteral(v), nil
+		case []float64:
+			return "$%d::text::vector", pgVectorLiteral(v), nil
+		case []float32:
+			vector := make(Vector, len(v))
+			for i, f := range v {
+				vector[i] = float64(f)
+			}
+			return "$%d::text::vector", pgVectorLiteral(vector), nil
+		default:
+			return "", nil, fmt.Errorf("%w: expected a vector, got %T", ErrFieldType, value)
+		}
+	case pgJSON:
+		if value == nil {
+			return "$%d", nil, nil
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to encode json: %w", err)
+		}
+		return "$%d::text::jsonb", string(encoded), nil
+	default:
+		return "$%d", value, nil
+	}
+}
+
+// pgDecodeValue converts a scanned column value back into a Go value.
+// Vectors and JSON are selected as text (see pgSelectList).
+func pgDecodeValue(column pgColumn, value interface{}) interface{} {
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	s, isString := value.(string)
+	switch {
+	case column.kind == pgVector && isString:
+		vector, err := pgParseVector(s)
+		if err != nil {
+			return value
+		}
+		return vector
+	case column.kind == pgJSON && isString:
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(s), &decoded); err != nil {
+			return value
+		}
+		return decoded
+	}
+	return value
+}
+
+// pgSelectList renders the select list of columns, casting vectors and JSON
+// to text so that they decode the same with every driver.
+func pgSelectList(columns []pgColumn) string {
+	parts := make([]string, len(columns))
+	for i, c := range columns {
+		parts[i] = pgIdent(c.name)
+		if c.kind != pgScalar {
+			parts[i] += "::text"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// pgScanRow scans a row selected with pgSelectList into a field map. Extra
+// destinations receive the values selected after the columns.
+func pgScanRow(rows *sql.Rows, columns []pgColumn, extra ...interface{}) (map[string]interface{}, error) {
+	values := make([]interface{}, len(columns), len(columns)+len(extra))
+	for i := range values {
+		values[i] = new(interface{})
+	}
+	values = append(values, extra...)
+	if err := rows.Scan(values...); err != nil {
+		return nil, fmt.Errorf("failed to read record: %w", err)
+	}
+
+	fields := make(map[string]interface{}, len(columns))
+	for i, c := range columns {
+		fields[c.name] = pgDecodeValue(c, *values[i].(*interface{}))
+	}
+	return fields, nil
+}
+
+// pgVectorLiteral renders a vector in pgvector's text format, e.g. [1,2,3].
+func pgVectorLiteral(v Vector) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, f := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(f, 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// pgParseVector parses pgvector's text format.
+func pgParseVector(s string) (Vector, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("invalid vector literal %q", s)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	if s == "" {
+		return Vector{}, nil
+	}
+	parts := strings.Split(s, ",")
+	vector := make(Vector, len(parts))
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector literal %q: %w", s, err)
+		}
+		vector[i] = f
+	}
+	return vector, nil
+}
+
+// pgInt64 reads an integer column value as returned by the driver.
+func pgInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	case string:
+		id, err := strconv.ParseInt(v, 10, 64)
+		return id, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// pgIDList returns the placeholders and arguments of an IN list of IDs.
+func pgIDList(ids []int64) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+		args[i] = id
+	}
+	return strings.Join(placeholders, ", "), args
+}
+
+// pgIdent quotes an identifier, preserving its case.
+func pgIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// pgDistanceOperator returns the pgvector distance operator of a metric.
+// <#> returns the negated inner product, matching SearchResult.Distance.
+func pgDistanceOperator(metric string) string {
+	switch metric {
+	case MetricIP:
+		return "<#>"
+	case MetricCosine:
+		return "<=>"
+	default:
+		return "<->"
+	}
+}
+
+// pgOperatorClass returns the index operator class of a metric.
+func pgOperatorClass(metric string) string {
+	switch metric {
+	case MetricIP:
+		return "vector_ip_ops"
+	case MetricCosine:
+		return "vector_cosine_ops"
+	default:
+		return "vector_l2_ops"
+	}
+}
+
+// pgFilterExpr translates a Filter into a SQL boolean expression, appending
+// its parameters to args. Metadata keys are matched with JSONB containment
+// (which a GIN index on Metadata accelerates) and compared with jsonpath,
+// so values of mismatched types never match instead of failing the query.
+// TextField maps to the Text column. Negations treat NULL as false, like
+// Filter.Matches treats missing keys.
+func pgFilterExpr(f *Filter, args *[]interface{}) (string, error) {
+	param := func(value interface{}) string {
+		*args = append(*args, value)
+		return "$" + strconv.Itoa(len(*args))
+	}
+
+	switch f.Op {
+	case OpAnd, OpOr:
+		joiner := " AND "
+		if f.Op == OpOr {
+			joiner = " OR "
+		}
+		parts := make([]string, len(f.Filters))
+		for i, child := range f.Filters {
+			expr, err := pgFilterExpr(child, args)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = "(" + expr + ")"
+		}
+		return strings.Join(parts, joiner), nil
+	case OpNot:
+		inner, err := pgFilterExpr(f.Filters[0], args)
+		if err != nil {
+			return "", err
+		}
+		return "NOT COALESCE((" + inner + "), false)", nil
+	}
+
+	if f.Field == TextField {
+		column := pgIdent(TextField)
+		switch f.Op {
+		case OpEq, OpLt, OpLte, OpGt, OpGte:
+			value, ok := f.Value.(string)
+			if !ok {
+				return "", fmt.Errorf("filter on %s requires a string value, got %T", TextField, f.Value)
+			}
+			return fmt.Sprintf("%s %s %s", column, pgComparison(f.Op), param(value)), nil
+		case OpNe:
+			return fmt.Sprintf("%s IS DISTINCT FROM %s", column, param(fmt.Sprint(f.Value))), nil
+		case OpIn, OpNotIn:
+			values := filterValues(f.Value)
+			if len(values) == 0 {
+				return strconv.FormatBool(f.Op == OpNotIn), nil
+			}
+			placeholders := make([]string, len(values))
+			for i, v := range values {
+				placeholders[i] = param(fmt.Sprint(v))
+			}
+			expr := fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", "))
+			if f.Op == OpNotIn {
+				expr = "NOT COALESCE(" + expr + ", false)"
+			}
+			return expr, nil
+		case OpContains:
+			return fmt.Sprintf("strpos(%s, %s) > 0", column, param(f.Value)), nil
+		}
+		return "", fmt.Errorf("unsupported filter operation for pgvector: %q", f.Op)
+	}
+
+	metadata := pgIdent("Metadata")
+	contains := func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(map[string]interface{}{f.Field: value})
+		if err != nil {
+			return "", fmt.Errorf("unsupported filter value type for pgvector: %T", value)
+		}
+		return fmt.Sprintf("%s @> %s::text::jsonb", metadata, param(string(encoded))), nil
+	}
+
+	switch f.Op {
+	case OpEq:
+		return contains(f.Value)
+	case OpNe:
+		expr, err := contains(f.Value)
+		if err != nil {
+			return "", err
+		}
+		return "NOT COALESCE(" + expr + ", false)", nil
+	case OpLt, OpLte, OpGt, OpGte:
+		key, _ := json.Marshal(f.Field)
+		vars, err := json.Marshal(map[string]interface{}{"v": f.Value})
+		if err != nil {
+			return "", fmt.Errorf("unsupported filter value type for pgvector: %T", f.Value)
+		}
+		path := fmt.Sprintf("$.%s ? (@ %s $v)", key, pgComparison(f.Op))
+		return fmt.Sprintf("jsonb_path_exists(%s, %s::text::jsonpath, %s::text::jsonb)", metadata, param(path), param(string(vars))), nil
+	case OpIn, OpNotIn:
+		values := filterValues(f.Value)
+		if len(values) == 0 {
+			return strconv.FormatBool(f.Op == OpNotIn), nil
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			expr, err := contains(v)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = expr
+		}
+		expr := "(" + strings.Join(parts, " OR ") + ")"
+		if f.Op == OpNotIn {
+			expr = "NOT COALESCE(" + expr + ", false)"
+		}
+		return expr, nil
+	case OpContains:
+		return fmt.Sprintf("strpos(%s->>%s::text, %s) > 0", metadata, param(f.Field), param(f.Value)), nil
+	default:
+		return "", fmt.Errorf("unsupported filter operation for pgvector: %q", f.Op)
+	}
+}
+
+// pgComparison returns the SQL comparison operator of a filter operation.
+// Both SQL and jsonpath use the same spelling.
+func pgComparison(op FilterOp) string {
+	if op == OpEq {
+		return "="
+	}
+	return string(op)
+}
@@ -0,0 +1,116 @@
+package rag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPgFilterExpr(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   *Filter
+		args     []interface{} // Arguments already bound before the filter
+		want     string
+		wantArgs []interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "equality",
+			filter:   Eq("source", "a.txt"),
+			want:     `"Metadata" @> $1::text::jsonb`,
+			wantArgs: []interface{}{`{"source":"a.txt"}`},
+		},
+		{
+			name:     "numbering after bound arguments",
+			filter:   Eq("page", 3),
+			args:     []interface{}{"[1,0,0]"},
+			want:     `"Metadata" @> $2::text::jsonb`,
+			wantArgs: []interface{}{"[1,0,0]", `{"page":3}`},
+		},
+		{
+			name:     "inequality",
+			filter:   Ne("lang", "fr"),
+			want:     `NOT COALESCE("Metadata" @> $1::text::jsonb, false)`,
+			wantArgs: []interface{}{`{"lang":"fr"}`},
+		},
+		{
+			name:     "range",
+			filter:   Gte("page", 2),
+			want:     `jsonb_path_exists("Metadata", $1::text::jsonpath, $2::text::jsonb)`,
+			wantArgs: []interface{}{`$."page" ? (@ >= $v)`, `{"v":2}`},
+		},
+		{
+			name:     "in",
+			filter:   In("lang", "en", "de"),
+			want:     `("Metadata" @> $1::text::jsonb OR "Metadata" @> $2::text::jsonb)`,
+			wantArgs: []interface{}{`{"lang":"en"}`, `{"lang":"de"}`},
+		},
+		{
+			name:     "not in",
+			filter:   NotIn("lang", "fr"),
+			want:     `NOT COALESCE(("Metadata" @> $1::text::jsonb), false)`,
+			wantArgs: []interface{}{`{"lang":"fr"}`},
+		},
+		{name: "empty in", filter: In("lang"), want: "false"},
+		{name: "empty not in", filter: NotIn("lang"), want: "true"},
+		{
+			name:     "metadata contains",
+			filter:   Contains("title", "go"),
+			want:     `strpos("Metadata"->>$1::text, $2) > 0`,
+			wantArgs: []interface{}{"title", "go"},
+		},
+		{
+			name:     "text equality",
+			filter:   Eq(TextField, "hello"),
+			want:     `"Text" = $1`,
+			wantArgs: []interface{}{"hello"},
+		},
+		{
+			name:     "text inequality",
+			filter:   Ne(TextField, "hello"),
+			want:     `"Text" IS DISTINCT FROM $1`,
+			wantArgs: []interface{}{"hello"},
+		},
+		{
+			name:     "text in",
+			filter:   In(TextField, "a", "b"),
+			want:     `"Text" IN ($1, $2)`,
+			wantArgs: []interface{}{"a", "b"},
+		},
+		{
+			name:     "text contains",
+			filter:   Contains(TextField, "vector"),
+			want:     `strpos("Text", $1) > 0`,
+			wantArgs: []interface{}{"vector"},
+		},
+		{
+			name:   "nested logic",
+			filter: And(Eq("a", 1), Not(Or(Gt("b", 2), Contains(TextField, "x")))),
+			want: `("Metadata" @> $1::text::jsonb) AND ` +
+				`(NOT COALESCE(((jsonb_path_exists("Metadata", $2::text::jsonpath, $3::text::jsonb)) OR (strpos("Text", $4) > 0)), false))`,
+			wantArgs: []interface{}{`{"a":1}`, `$."b" ? (@ > $v)`, `{"v":2}`, "x"},
+		},
+		{name: "numeric text range", filter: Lt(TextField, 3), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]interface{}(nil), tt.args...)
+			got, err := pgFilterExpr(tt.filter, &args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("pgFilterExpr = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("pgFilterExpr: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("pgFilterExpr =\n%s\nwant\n%s", got, tt.want)
+			}
+			if len(args) != len(tt.wantArgs) || (len(args) > 0 && !reflect.DeepEqual(args, tt.wantArgs)) {
+				t.Errorf("args = %q, want %q", args, tt.wantArgs)
+			}
+		})
+	}
+}
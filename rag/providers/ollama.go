@@ -0,0 +1,207 @@
+// Package providers implements embedding service providers for the Raggo framework.
+// The Ollama provider embeds text with models served by a local Ollama server,
+// such as nomic-embed-text or mxbai-embed-large, without any API key.
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+func init() {
+	// Register the Ollama provider when the package is initialized
+	RegisterEmbedder("ollama", NewOllamaEmbedder)
+}
+
+// Default settings for the Ollama embedder
+const (
+	// defaultOllamaAPI is the embedding endpoint of a local Ollama server
+	defaultOllamaAPI = "http://localhost:11434/api/embed"
+	// defaultOllamaModel is a small general purpose embedding model
+	defaultOllamaModel = "nomic-embed-text"
+	// defaultOllamaBatchSize is the number of texts sent per request
+	defaultOllamaBatchSize = 64
+)
+
+// OllamaEmbedder implements the Embedder interface using the embed endpoint
+// of an Ollama server. Models run locally, so no API key is needed; the
+// model must have been pulled beforehand (e.g. `ollama pull nomic-embed-text`).
+// The embedder is safe for concurrent use.
+type OllamaEmbedder struct {
+	client    *http.Client // HTTP client with timeout
+	apiURL    string       // Embed endpoint URL
+	modelName string       // Selected embedding model
+	batchSize int          // Maximum inputs per request
+	retry     retryPolicy  // Retries of failed requests
+
+	mu        sync.Mutex // Guards dimension
+	dimension int        // Dimension probed from the model, 0 until known
+}
+
+// NewOllamaEmbedder creates a new Ollama embedding provider with the given
+// configuration. All settings are optional:
+// - model: The embedding model to use (defaults to nomic-embed-text)
+// - api_url: The embed endpoint (defaults to http://localhost:11434/api/embed)
+// - timeout: Custom timeout duration, which should allow for model loading
+// - batch_size: Maximum texts per request (defaults to 64)
+// - max_retries, retry_base_delay, retry_max_delay: Retries, as for OpenAI
+//
+// Example config:
+//
+//	config := map[string]interface{}{
+//	    "model":   "mxbai-embed-large",
+//	    "api_url": "http://gpu-box:11434/api/embed",
+//	}
+func NewOllamaEmbedder(config map[string]interface{}) (Embedder, error) {
+	e := &OllamaEmbedder{
+		client:    &http.Client{Timeout: 2 * time.Minute},
+		apiURL:    defaultOllamaAPI,
+		modelName: defaultOllamaModel,
+		batchSize: defaultOllamaBatchSize,
+		retry:     newRetryPolicy(config),
+	}
+
+	if model, ok := config["model"].(string); ok && model != "" {
+		e.modelName = model
+	}
+
+	if apiURL, ok := config["api_url"].(string); ok && apiURL != "" {
+		e.apiURL = apiURL
+	}
+
+	if timeout, ok := config["timeout"].(time.Duration); ok {
+		e.client.Timeout = timeout
+	}
+
+	if batchSize, ok := config["batch_size"].(int); ok && batchSize > 0 {
+		e.batchSize = batchSize
+	}
+
+	return e, nil
+}
+
+// ollamaEmbedRequest represents the JSON structure for embed requests
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"` // Model to use
+	Input []string `json:"input"` // Texts to embed
+}
+
+// ollamaEmbedResponse represents the JSON structure for embed responses
+type ollamaEmbedResponse struct {
+	Embeddings [][]float64 `json:"embeddings"` // One vector per input, in order
+}
+
+// Embed converts the input text into a vector representation using the
+// configured Ollama model.
+func (e *OllamaEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	embeddings, err := e.request(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch converts several texts into vectors, sending up to batch_size
+// texts per request. The embeddings are returned in the order of texts.
+func (e *OllamaEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	embeddings := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += e.batchSize {
+		end := min(start+e.batchSize, len(texts))
+		batch, err := e.request(ctx, texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("error embedding texts %d to %d: %w", start+1, end, err)
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	return embeddings, nil
+}
+
+// request sends one embed request for texts, retrying failed attempts
+// according to the retry policy, and returns one embedding per text.
+func (e *OllamaEmbedder) request(ctx context.Context, texts []string) ([][]float64, error) {
+	reqBody, err := json.Marshal(ollamaEmbedRequest{
+		Model: e.modelName,
+		Input: texts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	var embedResp ollamaEmbedResponse
+	err = e.retry.do(ctx, func() error {
+		return e.send(ctx, reqBody, &embedResp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(embedResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings in response, got %d", len(texts), len(embedResp.Embeddings))
+	}
+	return embedResp.Embeddings, nil
+}
+
+// send posts one request body and decodes the response into embedResp.
+// Transport failures are returned as retryable requestErrors and non-200
+// statuses as an *APIError carrying Ollama's error message.
+func (e *OllamaEmbedder) send(ctx context.Context, reqBody []byte, embedResp *ollamaEmbedResponse) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", e.apiURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return &requestError{fmt.Errorf("error sending request: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &requestError{fmt.Errorf("error reading response body: %w", err)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &APIError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Message:    errorMessage(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	if err := json.Unmarshal(body, embedResp); err != nil {
+		return fmt.Errorf("error unmarshaling response: %w", err)
+	}
+	return nil
+}
+
+// GetDimension returns the output dimension of the configured model. Ollama
+// serves arbitrary models, so the dimension is probed by embedding a short
+// text on the first call and cached; a failed probe is retried on the next
+// call.
+func (e *OllamaEmbedder) GetDimension() (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.dimension > 0 {
+		return e.dimension, nil
+	}
+
+	embedding, err := e.Embed(context.Background(), "dimension probe")
+	if err != nil {
+		return 0, fmt.Errorf("error probing dimension of model %s: %w", e.modelName, err)
+	}
+	if len(embedding) == 0 {
+		return 0, fmt.Errorf("model %s returned an empty embedding", e.modelName)
+	}
+	e.dimension = len(embedding)
+	return e.dimension, nil
+}
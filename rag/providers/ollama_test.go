@@ -0,0 +1,196 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ollamaEmbeddings answers an embed request with lengthEmbeddings.
+func ollamaEmbeddings(t *testing.T) func(req embedRequest, n int) embedResponse {
+	return func(req embedRequest, n int) embedResponse {
+		return embedResponse{Body: mustJSON(t, map[string]interface{}{"embeddings": lengthEmbeddings(inputs(req, "input"))})}
+	}
+}
+
+func TestOllamaRequests(t *testing.T) {
+	texts := make([]string, 130)
+	for i := range texts {
+		texts[i] = strings.Repeat("a", i+1)
+	}
+
+	tests := []struct {
+		name      string
+		config    map[string]interface{}
+		texts     []string
+		wantModel string
+		wantSizes []int // Inputs per request
+	}{
+		{name: "single text", texts: texts[:1], wantModel: defaultOllamaModel, wantSizes: []int{1}},
+		{name: "default batch size", texts: texts, wantModel: defaultOllamaModel, wantSizes: []int{64, 64, 2}},
+		{
+			name:      "configured model and batch size",
+			config:    map[string]interface{}{"model": "mxbai-embed-large", "batch_size": 50},
+			texts:     texts,
+			wantModel: "mxbai-embed-large",
+			wantSizes: []int{50, 50, 30},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newEmbedServer(t, ollamaEmbeddings(t))
+			config := map[string]interface{}{"api_url": server.URL + "/api/embed"}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+			embedder, err := NewOllamaEmbedder(config)
+			if err != nil {
+				t.Fatalf("NewOllamaEmbedder: %v", err)
+			}
+
+			embeddings, err := embedder.EmbedBatch(context.Background(), tt.texts)
+			if err != nil {
+				t.Fatalf("EmbedBatch: %v", err)
+			}
+			if want := lengthEmbeddings(tt.texts); !reflect.DeepEqual(embeddings, want) {
+				t.Errorf("embeddings are not in input order:\n got %v\nwant %v", embeddings, want)
+			}
+
+			requests := server.received()
+			var sizes []int
+			var sent []string
+			for _, req := range requests {
+				if req.URL != "/api/embed" {
+					t.Errorf("request URL = %s, want /api/embed", req.URL)
+				}
+				if req.Body["model"] != tt.wantModel {
+					t.Errorf("request model = %v, want %s", req.Body["model"], tt.wantModel)
+				}
+				if got := req.Header.Get("Content-Type"); got != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", got)
+				}
+				batch := inputs(req, "input")
+				sizes = append(sizes, len(batch))
+				sent = append(sent, batch...)
+			}
+			if !reflect.DeepEqual(sizes, tt.wantSizes) {
+				t.Errorf("inputs per request = %v, want %v", sizes, tt.wantSizes)
+			}
+			if !reflect.DeepEqual(sent, tt.texts) {
+				t.Errorf("sent texts do not match the input texts")
+			}
+		})
+	}
+}
+
+func TestOllamaErrors(t *testing.T) {
+	notFound := embedResponse{Status: http.StatusNotFound, Body: `{"error": "model \"missing\" not found, try pulling it first"}`}
+
+	tests := []struct {
+		name         string
+		respond      func(req embedRequest, n int) embedResponse
+		texts        []string
+		wantStatus   int    // Status of the APIError, 0 if the error is not one
+		wantText     string // Text the error must contain
+		wantRequests int
+	}{
+		{
+			name:         "missing model",
+			respond:      func(embedRequest, int) embedResponse { return notFound },
+			texts:        []string{"a"},
+			wantStatus:   http.StatusNotFound,
+			wantText:     `model "missing" not found, try pulling it first`,
+			wantRequests: 1,
+		},
+		{
+			name: "server error retried",
+			respond: func(embedRequest, int) embedResponse {
+				return embedResponse{Status: http.StatusInternalServerError, Body: `{"error": "out of memory"}`}
+			},
+			texts:        []string{"a"},
+			wantStatus:   http.StatusInternalServerError,
+			wantText:     "giving up after 3 attempts",
+			wantRequests: 3,
+		},
+		{
+			name: "missing embeddings",
+			respond: func(embedRequest, int) embedResponse {
+				return embedResponse{Body: `{"embeddings": [[1, 2]]}`}
+			},
+			texts:        []string{"a", "b"},
+			wantText:     "expected 2 embeddings in response, got 1",
+			wantRequests: 1,
+		},
+		{
+			name: "failed second batch",
+			respond: func(req embedRequest, n int) embedResponse {
+				if n == 1 {
+					return notFound
+				}
+				return ollamaEmbeddings(t)(req, n)
+			},
+			texts:        make([]string, 70),
+			wantStatus:   http.StatusNotFound,
+			wantText:     "error embedding texts 65 to 70",
+			wantRequests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newEmbedServer(t, tt.respond)
+			embedder, err := NewOllamaEmbedder(map[string]interface{}{
+				"api_url":          server.URL + "/api/embed",
+				"max_retries":      2,
+				"retry_base_delay": time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("NewOllamaEmbedder: %v", err)
+			}
+
+			_, err = embedder.EmbedBatch(context.Background(), tt.texts)
+			if err == nil {
+				t.Fatal("EmbedBatch succeeded, want an error")
+			}
+			var apiErr *APIError
+			if errors.As(err, &apiErr) != (tt.wantStatus != 0) || (apiErr != nil && apiErr.StatusCode != tt.wantStatus) {
+				t.Errorf("error %q, want an APIError with status %d", err, tt.wantStatus)
+			}
+			if !strings.Contains(err.Error(), tt.wantText) {
+				t.Errorf("error %q does not contain %q", err, tt.wantText)
+			}
+			if got := len(server.received()); got != tt.wantRequests {
+				t.Errorf("sent %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestOllamaGetDimension(t *testing.T) {
+	server := newEmbedServer(t, func(req embedRequest, n int) embedResponse {
+		if n == 0 {
+			return embedResponse{Status: http.StatusServiceUnavailable, Body: `{"error": "loading model"}`}
+		}
+		return embedResponse{Body: `{"embeddings": [[0.1, 0.2, 0.3]]}`}
+	})
+	embedder, err := NewOllamaEmbedder(map[string]interface{}{"api_url": server.URL, "max_retries": 0})
+	if err != nil {
+		t.Fatalf("NewOllamaEmbedder: %v", err)
+	}
+
+	if _, err := embedder.GetDimension(); err == nil {
+		t.Fatal("GetDimension succeeded while the model was loading, want an error")
+	}
+	for i := 0; i < 2; i++ {
+		dimension, err := embedder.GetDimension()
+		if err != nil || dimension != 3 {
+			t.Fatalf("GetDimension = %d, %v, want 3, nil", dimension, err)
+		}
+	}
+	if got := len(server.received()); got != 2 {
+		t.Errorf("sent %d requests, want 2: a failed probe, a successful one and none once cached", got)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -23,18 +24,56 @@ const (
 	// defaultEmbeddingAPI is the endpoint for OpenAI's embedding service
 	defaultEmbeddingAPI = "https://api.openai.com/v1/embeddings"
 	// defaultModelName is the recommended model for most use cases
-	defaultModelName    = "text-embedding-3-small"
+	defaultModelName = "text-embedding-3-small"
+	// defaultBatchSize is the most inputs OpenAI accepts in one request
+	defaultBatchSize = 2048
+	// defaultBatchTokens is the most tokens OpenAI accepts across all the
+	// inputs of one request
+	defaultBatchTokens = 300000
+	// defaultInputTokens is the most tokens OpenAI embedding models accept
+	// in one input
+	defaultInputTokens = 8191
 )
 
+// InputTooLongError is returned, before any request is sent, when an input
+// is estimated to exceed the tokens the model accepts per input. The API
+// would reject the whole request with a permanent 400 error.
+type InputTooLongError struct {
+	Index  int // Position of the input among the texts passed to the embedder
+	Tokens int // Estimated tokens of the input
+	Limit  int // Most tokens accepted per input
+}
+
+// Error implements the error interface.
+func (e *InputTooLongError) Error() string {
+	return fmt.Sprintf("input %d has about %d tokens, more than the %d the model accepts; split it into smaller chunks", e.Index, e.Tokens, e.Limit)
+}
+
 // OpenAIEmbedder implements the Embedder interface using OpenAI's API.
 // It supports various embedding models and handles API communication,
-// rate limiting, and error recovery. The embedder is designed to be
+// client-side rate limiting, and retries with backoff. The embedder is designed to be
 // thread-safe and can be used concurrently.
 type OpenAIEmbedder struct {
-	apiKey    string        // API key for authentication
-	client    *http.Client  // HTTP client with timeout
-	apiURL    string        // API endpoint URL
-	modelName string        // Selected embedding model
+	apiKey      string       // API key for authentication
+	client      *http.Client // HTTP client with timeout
+	apiURL      string       // API endpoint URL
+	modelName   string       // Selected embedding model
+	batchSize   int          // Maximum inputs per request
+	batchTokens int          // Maximum estimated tokens per request
+	inputTokens int          // Maximum estimated tokens per input, 0 if unlimited
+	retry       retryPolicy  // Retries of failed requests
+	limiter     *RateLimiter // Client-side rate limiter, nil if unlimited
+
+	authHeader string                 // Header carrying the API key
+	headers    map[string]string      // Extra request headers
+	inputField string                 // Request body field holding the texts
+	dimensions int                    // Requested output dimension, 0 for the model's
+	encoding   string                 // Requested encoding_format, "" for the API default
+	extraBody  map[string]interface{} // Extra request body fields
+	response   responseFormat         // Location of the embeddings in responses
+
+	mu        sync.Mutex // Guards dimension
+	dimension int        // Dimension probed from the model, 0 until known
 }
 
 // NewOpenAIEmbedder creates a new OpenAI embedding provider with the given
@@ -42,6 +81,21 @@ type OpenAIEmbedder struct {
 // - model: The embedding model to use (defaults to text-embedding-3-small)
 // - api_url: Custom API endpoint URL
 // - timeout: Custom timeout duration
+// - batch_size: Maximum texts per request (defaults to 2048)
+// - batch_tokens: Maximum estimated tokens per request (defaults to 300000)
+// - input_tokens: Maximum estimated tokens per input (defaults to 8191)
+// - max_retries: Retries of a failed request (defaults to 3, 0 disables them)
+// - retry_base_delay, retry_max_delay: Backoff bounds (500ms and 30s)
+// - rate_limiter: A *RateLimiter, possibly shared with other embedders
+// - requests_per_minute, tokens_per_minute: Limits of a private rate limiter
+//
+// It also accepts the request and response settings of the
+// "openai-compatible" provider, such as dimensions, encoding_format and
+// headers; see NewOpenAICompatibleEmbedder.
+//
+// Requests failing with 408, 409, 429, 5xx statuses or transport errors are
+// retried with jittered exponential backoff, waiting at least as long as
+// the Retry-After header asks. Other failures are returned at once.
 //
 // Example config:
 //
@@ -57,12 +111,30 @@ func NewOpenAIEmbedder(config map[string]interface{}) (Embedder, error) {
 	}
 
 	e := &OpenAIEmbedder{
-		apiKey:    apiKey,
-		client:    &http.Client{Timeout: 30 * time.Second},
-		apiURL:    defaultEmbeddingAPI,
-		modelName: defaultModelName,
+		apiKey:      apiKey,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		apiURL:      defaultEmbeddingAPI,
+		modelName:   defaultModelName,
+		batchSize:   defaultBatchSize,
+		batchTokens: defaultBatchTokens,
+		inputTokens: defaultInputTokens,
+		retry:       newRetryPolicy(config),
+		limiter:     newRateLimiter(config),
+		authHeader:  "Authorization",
+		inputField:  "input",
+		response:    defaultResponseFormat(),
 	}
 
+	if err := e.configure(config); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// configure applies the settings shared by the "openai" and
+// "openai-compatible" providers on top of the embedder's defaults.
+func (e *OpenAIEmbedder) configure(config map[string]interface{}) error {
 	if model, ok := config["model"].(string); ok && model != "" {
 		e.modelName = model
 	}
@@ -75,73 +147,185 @@ func NewOpenAIEmbedder(config map[string]interface{}) (Embedder, error) {
 		e.client.Timeout = timeout
 	}
 
-	return e, nil
-}
+	if batchSize, ok := config["batch_size"].(int); ok && batchSize > 0 {
+		e.batchSize = batchSize
+	}
 
-// embeddingRequest represents the JSON structure for API requests
-type embeddingRequest struct {
-	Input string `json:"input"` // Text to embed
-	Model string `json:"model"` // Model to use
-}
+	if batchTokens, ok := config["batch_tokens"].(int); ok && batchTokens > 0 {
+		e.batchTokens = batchTokens
+	}
+
+	if inputTokens, ok := config["input_tokens"].(int); ok && inputTokens >= 0 {
+		e.inputTokens = inputTokens
+	}
 
-// embeddingResponse represents the JSON structure for API responses
-type embeddingResponse struct {
-	Data []struct {
-		Embedding []float64 `json:"embedding"` // Vector representation
-	} `json:"data"`
+	return e.configureCompatible(config)
 }
 
 // Embed converts the input text into a vector representation using the
 // configured OpenAI model. The method handles:
 // - Request preparation and validation
-// - API communication with retry logic
+// - API communication with rate limiting and retry logic
 // - Response parsing and error handling
 //
 // The resulting vector captures the semantic meaning of the input text
 // and can be used for similarity search operations.
 func (e *OpenAIEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
-	reqBody, err := json.Marshal(embeddingRequest{
-		Input: text,
-		Model: e.modelName,
-	})
+	if err := e.checkInputs([]string{text}); err != nil {
+		return nil, err
+	}
+	embeddings, err := e.request(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch converts several texts into vectors, sending them as the input
+// array of as few requests as the per-request limits allow. Texts are split
+// into requests of at most batch_size inputs and batch_tokens estimated
+// tokens, and the embeddings are returned in the order of texts. Texts over
+// the per-input token limit fail the call with an InputTooLongError before
+// anything is sent.
+func (e *OpenAIEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	if err := e.checkInputs(texts); err != nil {
+		return nil, err
+	}
+	embeddings := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); {
+		end, tokens := start, 0
+		for end < len(texts) && end-start < e.batchSize {
+			n := estimateTokens(texts[end])
+			if end > start && tokens+n > e.batchTokens {
+				break
+			}
+			tokens += n
+			end++
+		}
+
+		batch, err := e.request(ctx, texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("error embedding texts %d to %d: %w", start+1, end, err)
+		}
+		embeddings = append(embeddings, batch...)
+		start = end
+	}
+	return embeddings, nil
+}
+
+// checkInputs returns an InputTooLongError for the first of texts estimated
+// to exceed the per-input token limit.
+func (e *OpenAIEmbedder) checkInputs(texts []string) error {
+	if e.inputTokens <= 0 {
+		return nil
+	}
+	for i, text := range texts {
+		if tokens := estimateTokens(text); tokens > e.inputTokens {
+			return &InputTooLongError{Index: i, Tokens: tokens, Limit: e.inputTokens}
+		}
+	}
+	return nil
+}
+
+// request sends one embedding request for texts and returns their
+// embeddings, ordered by the index the API reports for each. Each attempt
+// first waits for the rate limiter; failed attempts are retried according
+// to the retry policy.
+func (e *OpenAIEmbedder) request(ctx context.Context, texts []string) ([][]float64, error) {
+	reqBody, err := e.requestBody(texts)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", e.apiURL, bytes.NewBuffer(reqBody))
+	tokens := 0
+	for _, text := range texts {
+		tokens += estimateTokens(text)
+	}
+
+	var body []byte
+	err = e.retry.do(ctx, func() error {
+		if err := e.limiter.Wait(ctx, tokens); err != nil {
+			return err
+		}
+		body, err = e.send(ctx, reqBody)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return e.response.parse(body, len(texts))
+}
+
+// requestBody returns the JSON body embedding texts: the input array, the
+// model, dimensions and encoding_format when set, and the extra body fields.
+func (e *OpenAIEmbedder) requestBody(texts []string) ([]byte, error) {
+	body := map[string]interface{}{e.inputField: texts}
+	if e.modelName != "" {
+		body["model"] = e.modelName
+	}
+	if e.dimensions > 0 {
+		body["dimensions"] = e.dimensions
+	}
+	if e.encoding != "" {
+		body["encoding_format"] = e.encoding
+	}
+	for key, value := range e.extraBody {
+		if _, ok := body[key]; !ok {
+			body[key] = value
+		}
+	}
+	return json.Marshal(body)
+}
+
+// send posts one request body and returns the response body. Transport
+// failures are returned as retryable requestErrors and non-200 statuses as
+// an *APIError.
+func (e *OpenAIEmbedder) send(ctx context.Context, reqBody []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", e.apiURL, bytes.NewReader(reqBody))
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+	if e.apiKey != "" {
+		if http.CanonicalHeaderKey(e.authHeader) == "Authorization" {
+			req.Header.Set("Authorization", "Bearer "+e.apiKey)
+		} else {
+			req.Header.Set(e.authHeader, e.apiKey)
+		}
+	}
 
 	resp, err := e.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
+		return nil, &requestError{fmt.Errorf("error sending request: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+		return nil, &requestError{fmt.Errorf("error reading response body: %w", err)}
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status code %d: %s", resp.StatusCode, resp.Status)
-	}
-
-	var embeddingResp embeddingResponse
-	err = json.Unmarshal(body, &embeddingResp)
-	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling response: %w", err)
-	}
-
-	if len(embeddingResp.Data) == 0 {
-		return nil, fmt.Errorf("no embedding data in response")
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Message:    errorMessage(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
+	return body, nil
+}
 
-	return embeddingResp.Data[0].Embedding, nil
+// estimateTokens returns a conservative estimate of the number of tokens in
+// text. OpenAI tokenizers average about four bytes of English per token;
+// counting one token per three bytes leaves headroom for other languages.
+func estimateTokens(text string) int {
+	return len(text)/3 + 1
 }
 
 // GetDimension returns the output dimension for the current embedding model.
@@ -150,9 +334,16 @@ func (e *OpenAIEmbedder) Embed(ctx context.Context, text string) ([]float64, err
 // - text-embedding-3-large: 3072 dimensions
 // - text-embedding-ada-002: 1536 dimensions
 //
+// The dimensions setting, when given, takes precedence. Other models, such
+// as those behind OpenAI-compatible gateways, are probed by embedding a
+// short text on the first call, and the result is cached.
+//
 // This information is crucial for configuring vector databases and ensuring
 // compatibility across the system.
 func (e *OpenAIEmbedder) GetDimension() (int, error) {
+	if e.dimensions > 0 {
+		return e.dimensions, nil
+	}
 	switch e.modelName {
 	case "text-embedding-3-small":
 		return 1536, nil
@@ -160,7 +351,20 @@ func (e *OpenAIEmbedder) GetDimension() (int, error) {
 		return 3072, nil
 	case "text-embedding-ada-002":
 		return 1536, nil
-	default:
-		return 0, fmt.Errorf("unknown model: %s", e.modelName)
 	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.dimension > 0 {
+		return e.dimension, nil
+	}
+	embedding, err := e.Embed(context.Background(), "dimension probe")
+	if err != nil {
+		return 0, fmt.Errorf("error probing dimension of model %q: %w", e.modelName, err)
+	}
+	if len(embedding) == 0 {
+		return 0, fmt.Errorf("model %q returned an empty embedding", e.modelName)
+	}
+	e.dimension = len(embedding)
+	return e.dimension, nil
 }
@@ -0,0 +1,322 @@
+// Package providers implements embedding service providers for the Raggo framework.
+// The OpenAI-compatible provider talks to any service that speaks the OpenAI
+// embeddings protocol, such as Azure OpenAI, vLLM, LiteLLM, LocalAI or TEI,
+// with configurable URLs, authentication, headers and response parsing.
+package providers
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func init() {
+	// Register the OpenAI-compatible provider when the package is initialized
+	RegisterEmbedder("openai-compatible", NewOpenAICompatibleEmbedder)
+}
+
+// defaultAzureAPIVersion is the Azure OpenAI API version used when none is
+// configured
+const defaultAzureAPIVersion = "2024-10-21"
+
+// NewOpenAICompatibleEmbedder creates an embedder for a service speaking the
+// OpenAI embeddings protocol. Unlike the "openai" provider, the API key and
+// model are optional, as many gateways need neither. The endpoint is set by
+// either api_url, the full embeddings URL, or by azure_endpoint and
+// azure_deployment, which build an Azure OpenAI deployment URL and send the
+// key in an api-key header. The other settings are:
+// - api_key: The key, sent as "Authorization: Bearer <key>" by default
+// - auth_header: Another header to send the key in, as is (e.g. "x-api-key")
+// - headers: Extra headers, a map[string]string such as config.Config.ExtraHeaders
+// - query: Extra query parameters, a map[string]string
+// - api_version: The api-version query parameter (Azure defaults to 2024-10-21)
+// - model: The model to request, omitted from the body when empty
+// - input_field: The body field holding the texts (defaults to "input")
+// - dimensions: The output dimension, for models that can shorten embeddings
+// - encoding_format: "float" or "base64" (little-endian float32s)
+// - extra_body: Extra request body fields, a map[string]interface{}
+//
+// Responses are parsed like OpenAI's by default, and other shapes are read
+// with these settings:
+// - response_path: Dot-separated keys leading to the list of embeddings ("data")
+// - embedding_field: The field of each list item holding its vector ("embedding")
+// - index_field: The field of each item holding its input position ("index")
+//
+// An empty response_path means the response itself is the list, an empty
+// embedding_field that the items are the vectors, and an empty index_field
+// that items are in input order. The batching, retry and rate limiting
+// settings of the "openai" provider apply as well, except that input_tokens
+// defaults to no limit, as gateway models vary.
+//
+// Example config for an Azure deployment:
+//
+//	config := map[string]interface{}{
+//	    "api_key":          os.Getenv("AZURE_OPENAI_API_KEY"),
+//	    "azure_endpoint":   "https://my-resource.openai.azure.com",
+//	    "azure_deployment": "text-embedding-3-small",
+//	    "dimensions":       512,
+//	}
+//
+// Example config for the native endpoint of Hugging Face TEI:
+//
+//	config := map[string]interface{}{
+//	    "api_url":         "http://localhost:8080/embed",
+//	    "input_field":     "inputs",
+//	    "response_path":   "",
+//	    "embedding_field": "",
+//	}
+func NewOpenAICompatibleEmbedder(config map[string]interface{}) (Embedder, error) {
+	e := &OpenAIEmbedder{
+		client:      &http.Client{Timeout: 30 * time.Second},
+		batchSize:   defaultBatchSize,
+		batchTokens: defaultBatchTokens,
+		retry:       newRetryPolicy(config),
+		limiter:     newRateLimiter(config),
+		authHeader:  "Authorization",
+		inputField:  "input",
+		response:    defaultResponseFormat(),
+	}
+	if apiKey, ok := config["api_key"].(string); ok {
+		e.apiKey = apiKey
+	}
+
+	if endpoint, ok := config["azure_endpoint"].(string); ok && endpoint != "" {
+		deployment, _ := config["azure_deployment"].(string)
+		if deployment == "" {
+			return nil, fmt.Errorf("azure_deployment is required with azure_endpoint")
+		}
+		e.apiURL = strings.TrimRight(endpoint, "/") + "/openai/deployments/" + url.PathEscape(deployment) + "/embeddings"
+		e.authHeader = "api-key"
+		if _, ok := config["api_version"]; !ok {
+			config = withDefault(config, "api_version", defaultAzureAPIVersion)
+		}
+	}
+
+	if err := e.configure(config); err != nil {
+		return nil, err
+	}
+	if e.apiURL == "" {
+		return nil, fmt.Errorf("api_url or azure_endpoint is required for OpenAI-compatible embedder")
+	}
+
+	return e, nil
+}
+
+// configureCompatible applies the authentication, request and response
+// settings of the "openai-compatible" provider. The "openai" provider
+// accepts them too.
+func (e *OpenAIEmbedder) configureCompatible(config map[string]interface{}) error {
+	if header, ok := config["auth_header"].(string); ok && header != "" {
+		e.authHeader = header
+	}
+
+	headers, err := stringMap(config, "headers")
+	if err != nil {
+		return err
+	}
+	e.headers = headers
+
+	query, err := stringMap(config, "query")
+	if err != nil {
+		return err
+	}
+	if version, ok := config["api_version"].(string); ok && version != "" {
+		if query == nil {
+			query = make(map[string]string)
+		}
+		query["api-version"] = version
+	}
+	if len(query) > 0 {
+		apiURL, err := url.Parse(e.apiURL)
+		if err != nil {
+			return fmt.Errorf("invalid api_url %q: %w", e.apiURL, err)
+		}
+		values := apiURL.Query()
+		for key, value := range query {
+			values.Set(key, value)
+		}
+		apiURL.RawQuery = values.Encode()
+		e.apiURL = apiURL.String()
+	}
+
+	if field, ok := config["input_field"].(string); ok && field != "" {
+		e.inputField = field
+	}
+
+	if dimensions, ok := config["dimensions"].(int); ok && dimensions > 0 {
+		e.dimensions = dimensions
+	}
+
+	if encoding, ok := config["encoding_format"].(string); ok && encoding != "" {
+		if encoding != "float" && encoding != "base64" {
+			return fmt.Errorf("unsupported encoding_format %q, expected \"float\" or \"base64\"", encoding)
+		}
+		e.encoding = encoding
+	}
+
+	if extraBody, ok := config["extra_body"].(map[string]interface{}); ok {
+		e.extraBody = extraBody
+	}
+
+	if path, ok := config["response_path"].(string); ok {
+		e.response.path = nil
+		if path != "" {
+			e.response.path = strings.Split(path, ".")
+		}
+	}
+	if field, ok := config["embedding_field"].(string); ok {
+		e.response.embeddingField = field
+	}
+	if field, ok := config["index_field"].(string); ok {
+		e.response.indexField = field
+	}
+
+	return nil
+}
+
+// withDefault returns a copy of config with key set to value, leaving the
+// caller's map untouched.
+func withDefault(config map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(config)+1)
+	for k, v := range config {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
+
+// stringMap reads a map of strings from config, given either as a
+// map[string]string or as a map[string]interface{} holding strings.
+func stringMap(config map[string]interface{}, key string) (map[string]string, error) {
+	switch m := config[key].(type) {
+	case nil:
+		return nil, nil
+	case map[string]string:
+		return m, nil
+	case map[string]interface{}:
+		strs := make(map[string]string, len(m))
+		for k, v := range m {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: value of %q must be a string, got %T", key, k, v)
+			}
+			strs[k] = s
+		}
+		return strs, nil
+	default:
+		return nil, fmt.Errorf("%s must be a map of strings, got %T", key, m)
+	}
+}
+
+// responseFormat locates the embeddings in the JSON response of an
+// embedding API.
+type responseFormat struct {
+	path           []string // Keys leading to the list of embeddings
+	embeddingField string   // Field of each item holding its vector, "" if items are vectors
+	indexField     string   // Field of each item holding its input position, "" if positional
+}
+
+// defaultResponseFormat returns the format of OpenAI responses:
+// {"data": [{"embedding": [...], "index": 0}, ...]}.
+func defaultResponseFormat() responseFormat {
+	return responseFormat{
+		path:           []string{"data"},
+		embeddingField: "embedding",
+		indexField:     "index",
+	}
+}
+
+// parse extracts the embeddings of n inputs from a response body, in input
+// order. Each embedding may be an array of numbers or a base64 string of
+// little-endian float32s.
+func (f responseFormat) parse(body []byte, n int) ([][]float64, error) {
+	list := json.RawMessage(body)
+	for _, key := range f.path {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(list, &object); err != nil {
+			return nil, fmt.Errorf("error unmarshaling response: expected an object holding %q: %w", key, err)
+		}
+		var ok bool
+		if list, ok = object[key]; !ok {
+			return nil, fmt.Errorf("no %q field in response", key)
+		}
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(list, &items); err != nil {
+		return nil, fmt.Errorf("error unmarshaling response: expected a list of embeddings: %w", err)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no embedding data in response")
+	}
+
+	embeddings := make([][]float64, n)
+	for position, item := range items {
+		raw, index := item, position
+		if f.embeddingField != "" {
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(item, &object); err != nil {
+				return nil, fmt.Errorf("error unmarshaling embedding %d: %w", position, err)
+			}
+			raw = object[f.embeddingField]
+			if rawIndex, ok := object[f.indexField]; ok && f.indexField != "" {
+				if err := json.Unmarshal(rawIndex, &index); err != nil {
+					return nil, fmt.Errorf("error unmarshaling index of embedding %d: %w", position, err)
+				}
+			}
+		}
+		if index < 0 || index >= n {
+			return nil, fmt.Errorf("embedding index %d out of range for %d inputs", index, n)
+		}
+
+		embedding, err := decodeEmbedding(raw)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding embedding %d: %w", index, err)
+		}
+		embeddings[index] = embedding
+	}
+	for i, embedding := range embeddings {
+		if embedding == nil {
+			return nil, fmt.Errorf("no embedding in response for input %d", i)
+		}
+	}
+	return embeddings, nil
+}
+
+// decodeEmbedding decodes a JSON embedding given as an array of numbers or
+// as a base64 string of little-endian float32s.
+func decodeEmbedding(raw json.RawMessage) ([]float64, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, fmt.Errorf("missing embedding")
+	}
+	if raw[0] != '"' {
+		var embedding []float64
+		if err := json.Unmarshal(raw, &embedding); err != nil {
+			return nil, err
+		}
+		return embedding, nil
+	}
+
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 embedding: %w", err)
+	}
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("base64 embedding of %d bytes is not a list of float32s", len(data))
+	}
+	embedding := make([]float64, len(data)/4)
+	for i := range embedding {
+		embedding[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:])))
+	}
+	return embedding, nil
+}
@@ -0,0 +1,305 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// openAIEmbeddings answers a request for the texts under field with
+// lengthEmbeddings in OpenAI's format, listing the items in reverse so that
+// the embedder must order them by index.
+func openAIEmbeddings(t *testing.T, field string) func(req embedRequest, n int) embedResponse {
+	return func(req embedRequest, n int) embedResponse {
+		embeddings := lengthEmbeddings(inputs(req, field))
+		data := make([]interface{}, 0, len(embeddings))
+		for i := len(embeddings) - 1; i >= 0; i-- {
+			data = append(data, map[string]interface{}{"object": "embedding", "index": i, "embedding": embeddings[i]})
+		}
+		return embedResponse{Body: mustJSON(t, map[string]interface{}{"object": "list", "data": data})}
+	}
+}
+
+// base64Embedding encodes an embedding as little-endian float32s.
+func base64Embedding(embedding []float64) string {
+	data := make([]byte, 4*len(embedding))
+	for i, f := range embedding {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(float32(f)))
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestOpenAICompatibleRequests(t *testing.T) {
+	texts := []string{"a", "bbb", "cc"}
+
+	tests := []struct {
+		name       string
+		config     map[string]interface{} // api_url and azure_endpoint are relative to the server
+		respond    func(t *testing.T) func(req embedRequest, n int) embedResponse
+		wantURL    string
+		wantHeader map[string]string // Headers the request must carry
+		noHeader   []string          // Headers the request must not carry
+		wantBody   string
+	}{
+		{
+			name: "bearer key, headers and body settings",
+			config: map[string]interface{}{
+				"api_url":         "/v1/embeddings",
+				"api_key":         "secret",
+				"model":           "bge-m3",
+				"headers":         map[string]string{"X-Team": "search", "OpenAI-Organization": "org-1"},
+				"query":           map[string]interface{}{"tenant": "a b"},
+				"dimensions":      256,
+				"encoding_format": "float",
+				"extra_body":      map[string]interface{}{"user": "raggo", "model": "ignored"},
+			},
+			respond:    func(t *testing.T) func(embedRequest, int) embedResponse { return openAIEmbeddings(t, "input") },
+			wantURL:    "/v1/embeddings?tenant=a+b",
+			wantHeader: map[string]string{"Authorization": "Bearer secret", "X-Team": "search", "OpenAI-Organization": "org-1"},
+			wantBody:   `{"input": ["a", "bbb", "cc"], "model": "bge-m3", "dimensions": 256, "encoding_format": "float", "user": "raggo"}`,
+		},
+		{
+			name:       "custom auth header without model",
+			config:     map[string]interface{}{"api_url": "/embeddings", "api_key": "secret", "auth_header": "x-api-key"},
+			respond:    func(t *testing.T) func(embedRequest, int) embedResponse { return openAIEmbeddings(t, "input") },
+			wantURL:    "/embeddings",
+			wantHeader: map[string]string{"X-Api-Key": "secret"},
+			noHeader:   []string{"Authorization"},
+			wantBody:   `{"input": ["a", "bbb", "cc"]}`,
+		},
+		{
+			name:     "no key",
+			config:   map[string]interface{}{"api_url": "/embeddings", "model": "m"},
+			respond:  func(t *testing.T) func(embedRequest, int) embedResponse { return openAIEmbeddings(t, "input") },
+			wantURL:  "/embeddings",
+			noHeader: []string{"Authorization"},
+			wantBody: `{"input": ["a", "bbb", "cc"], "model": "m"}`,
+		},
+		{
+			name: "azure deployment",
+			config: map[string]interface{}{
+				"azure_endpoint":   "/",
+				"azure_deployment": "text-embedding-3-small",
+				"api_key":          "secret",
+			},
+			respond:    func(t *testing.T) func(embedRequest, int) embedResponse { return openAIEmbeddings(t, "input") },
+			wantURL:    "/openai/deployments/text-embedding-3-small/embeddings?api-version=" + defaultAzureAPIVersion,
+			wantHeader: map[string]string{"Api-Key": "secret"},
+			noHeader:   []string{"Authorization"},
+			wantBody:   `{"input": ["a", "bbb", "cc"]}`,
+		},
+		{
+			name: "azure deployment with api version",
+			config: map[string]interface{}{
+				"azure_endpoint":   "",
+				"azure_deployment": "embed",
+				"api_version":      "2025-01-01-preview",
+			},
+			respond:  func(t *testing.T) func(embedRequest, int) embedResponse { return openAIEmbeddings(t, "input") },
+			wantURL:  "/openai/deployments/embed/embeddings?api-version=2025-01-01-preview",
+			wantBody: `{"input": ["a", "bbb", "cc"]}`,
+		},
+		{
+			name:   "base64 embeddings",
+			config: map[string]interface{}{"api_url": "/embeddings", "encoding_format": "base64"},
+			respond: func(t *testing.T) func(embedRequest, int) embedResponse {
+				return func(req embedRequest, n int) embedResponse {
+					var data []interface{}
+					for i, embedding := range lengthEmbeddings(inputs(req, "input")) {
+						data = append(data, map[string]interface{}{"index": i, "embedding": base64Embedding(embedding)})
+					}
+					return embedResponse{Body: mustJSON(t, map[string]interface{}{"data": data})}
+				}
+			},
+			wantURL:  "/embeddings",
+			wantBody: `{"input": ["a", "bbb", "cc"], "encoding_format": "base64"}`,
+		},
+		{
+			name: "bare list of vectors",
+			config: map[string]interface{}{
+				"api_url":         "/embed",
+				"input_field":     "inputs",
+				"response_path":   "",
+				"embedding_field": "",
+			},
+			respond: func(t *testing.T) func(embedRequest, int) embedResponse {
+				return func(req embedRequest, n int) embedResponse {
+					return embedResponse{Body: mustJSON(t, lengthEmbeddings(inputs(req, "inputs")))}
+				}
+			},
+			wantURL:  "/embed",
+			wantBody: `{"inputs": ["a", "bbb", "cc"]}`,
+		},
+		{
+			name: "nested response path",
+			config: map[string]interface{}{
+				"api_url":         "/embed",
+				"response_path":   "result.items",
+				"embedding_field": "vector",
+				"index_field":     "",
+			},
+			respond: func(t *testing.T) func(embedRequest, int) embedResponse {
+				return func(req embedRequest, n int) embedResponse {
+					var items []interface{}
+					for _, embedding := range lengthEmbeddings(inputs(req, "input")) {
+						items = append(items, map[string]interface{}{"vector": embedding})
+					}
+					return embedResponse{Body: mustJSON(t, map[string]interface{}{"result": map[string]interface{}{"items": items}})}
+				}
+			},
+			wantURL:  "/embed",
+			wantBody: `{"input": ["a", "bbb", "cc"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newEmbedServer(t, tt.respond(t))
+			config := make(map[string]interface{})
+			for k, v := range tt.config {
+				if k == "api_url" || k == "azure_endpoint" {
+					v = server.URL + v.(string)
+				}
+				config[k] = v
+			}
+			embedder, err := NewOpenAICompatibleEmbedder(config)
+			if err != nil {
+				t.Fatalf("NewOpenAICompatibleEmbedder: %v", err)
+			}
+
+			embeddings, err := embedder.EmbedBatch(context.Background(), texts)
+			if err != nil {
+				t.Fatalf("EmbedBatch: %v", err)
+			}
+			if want := lengthEmbeddings(texts); !reflect.DeepEqual(embeddings, want) {
+				t.Errorf("embeddings = %v, want %v", embeddings, want)
+			}
+
+			requests := server.received()
+			if len(requests) != 1 {
+				t.Fatalf("sent %d requests, want 1", len(requests))
+			}
+			req := requests[0]
+			if req.URL != tt.wantURL {
+				t.Errorf("request URL = %s, want %s", req.URL, tt.wantURL)
+			}
+			for name, value := range tt.wantHeader {
+				if got := req.Header.Get(name); got != value {
+					t.Errorf("header %s = %q, want %q", name, got, value)
+				}
+			}
+			for _, name := range tt.noHeader {
+				if got := req.Header.Get(name); got != "" {
+					t.Errorf("header %s = %q, want none", name, got)
+				}
+			}
+			var wantBody map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.wantBody), &wantBody); err != nil {
+				t.Fatalf("invalid wantBody: %v", err)
+			}
+			if !reflect.DeepEqual(req.Body, wantBody) {
+				t.Errorf("request body = %s, want %s", mustJSON(t, req.Body), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestOpenAICompatibleResponseErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     map[string]interface{}
+		response   embedResponse
+		wantStatus int    // Status of the APIError, 0 if the error is not one
+		wantText   string // Text the error must contain
+	}{
+		{
+			name:       "OpenAI error body",
+			response:   embedResponse{Status: http.StatusUnauthorized, Body: `{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error"}}`},
+			wantStatus: http.StatusUnauthorized,
+			wantText:   "Incorrect API key provided",
+		},
+		{
+			name:       "string error body",
+			response:   embedResponse{Status: http.StatusRequestEntityTooLarge, Body: `{"error": "Input validation error: inputs must have less than 512 tokens"}`},
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantText:   "inputs must have less than 512 tokens",
+		},
+		{
+			name:     "missing response path",
+			response: embedResponse{Body: `{"embeddings": []}`},
+			wantText: `no "data" field in response`,
+		},
+		{
+			name:     "index out of range",
+			response: embedResponse{Body: `{"data": [{"index": 5, "embedding": [1]}]}`},
+			wantText: "embedding index 5 out of range for 1 inputs",
+		},
+		{
+			name:     "missing embedding",
+			config:   map[string]interface{}{"index_field": ""},
+			response: embedResponse{Body: `{"data": [{"embedding": null}]}`},
+			wantText: "missing embedding",
+		},
+		{
+			name:     "truncated base64",
+			response: embedResponse{Body: `{"data": [{"index": 0, "embedding": "AAAAAAA="}]}`},
+			wantText: "base64 embedding of 5 bytes is not a list of float32s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newEmbedServer(t, func(embedRequest, int) embedResponse { return tt.response })
+			config := map[string]interface{}{"api_url": server.URL}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+			embedder, err := NewOpenAICompatibleEmbedder(config)
+			if err != nil {
+				t.Fatalf("NewOpenAICompatibleEmbedder: %v", err)
+			}
+
+			_, err = embedder.Embed(context.Background(), "text")
+			if err == nil {
+				t.Fatal("Embed succeeded, want an error")
+			}
+			var apiErr *APIError
+			if errors.As(err, &apiErr) != (tt.wantStatus != 0) || (apiErr != nil && apiErr.StatusCode != tt.wantStatus) {
+				t.Errorf("error %q, want an APIError with status %d", err, tt.wantStatus)
+			}
+			if !strings.Contains(err.Error(), tt.wantText) {
+				t.Errorf("error %q does not contain %q", err, tt.wantText)
+			}
+			if got := len(server.received()); got != 1 {
+				t.Errorf("sent %d requests, want 1", got)
+			}
+		})
+	}
+}
+
+func TestOpenAICompatibleConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		wantText string
+	}{
+		{name: "no endpoint", config: map[string]interface{}{"api_key": "secret"}, wantText: "api_url or azure_endpoint is required"},
+		{name: "azure without deployment", config: map[string]interface{}{"azure_endpoint": "https://x.openai.azure.com"}, wantText: "azure_deployment is required"},
+		{name: "unknown encoding", config: map[string]interface{}{"api_url": "http://localhost", "encoding_format": "int8"}, wantText: "unsupported encoding_format"},
+		{name: "non-string header", config: map[string]interface{}{"api_url": "http://localhost", "headers": map[string]interface{}{"X-Retries": 3}}, wantText: `headers: value of "X-Retries" must be a string`},
+		{name: "invalid headers", config: map[string]interface{}{"api_url": "http://localhost", "headers": []string{"X-Team"}}, wantText: "headers must be a map of strings"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewOpenAICompatibleEmbedder(tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantText) {
+				t.Errorf("NewOpenAICompatibleEmbedder error = %v, want one containing %q", err, tt.wantText)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOpenAIBatches(t *testing.T) {
+	texts := func(lengths ...int) []string {
+		texts := make([]string, len(lengths))
+		for i, n := range lengths {
+			texts[i] = strings.Repeat(string(rune('a'+i)), n)
+		}
+		return texts
+	}
+
+	tests := []struct {
+		name      string
+		config    map[string]interface{}
+		texts     []string
+		wantSizes []int // Inputs per request
+	}{
+		{name: "one request", texts: texts(1, 2, 3, 4, 5), wantSizes: []int{5}},
+		{name: "batch size", config: map[string]interface{}{"batch_size": 2}, texts: texts(1, 2, 3, 4, 5), wantSizes: []int{2, 2, 1}},
+		{
+			// Texts of 30 bytes are estimated at 11 tokens
+			name:      "batch tokens",
+			config:    map[string]interface{}{"batch_tokens": 25},
+			texts:     texts(30, 30, 30, 30, 30),
+			wantSizes: []int{2, 2, 1},
+		},
+		{
+			name:      "text over batch tokens sent alone",
+			config:    map[string]interface{}{"batch_tokens": 25, "input_tokens": 0},
+			texts:     texts(30, 300, 30),
+			wantSizes: []int{1, 1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newEmbedServer(t, openAIEmbeddings(t, "input"))
+			config := map[string]interface{}{"api_key": "key", "api_url": server.URL + "/v1/embeddings"}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+			embedder, err := NewOpenAIEmbedder(config)
+			if err != nil {
+				t.Fatalf("NewOpenAIEmbedder: %v", err)
+			}
+
+			embeddings, err := embedder.EmbedBatch(context.Background(), tt.texts)
+			if err != nil {
+				t.Fatalf("EmbedBatch: %v", err)
+			}
+			if want := lengthEmbeddings(tt.texts); !reflect.DeepEqual(embeddings, want) {
+				t.Errorf("embeddings are not in input order:\n got %v\nwant %v", embeddings, want)
+			}
+
+			var sizes []int
+			var sent []string
+			for _, req := range server.received() {
+				if req.URL != "/v1/embeddings" {
+					t.Errorf("request URL = %s, want /v1/embeddings", req.URL)
+				}
+				if got := req.Header.Get("Authorization"); got != "Bearer key" {
+					t.Errorf("Authorization header = %q, want %q", got, "Bearer key")
+				}
+				if req.Body["model"] != defaultModelName {
+					t.Errorf("request model = %v, want %s", req.Body["model"], defaultModelName)
+				}
+				batch := inputs(req, "input")
+				sizes = append(sizes, len(batch))
+				sent = append(sent, batch...)
+			}
+			if !reflect.DeepEqual(sizes, tt.wantSizes) {
+				t.Errorf("inputs per request = %v, want %v", sizes, tt.wantSizes)
+			}
+			if !reflect.DeepEqual(sent, tt.texts) {
+				t.Errorf("sent texts do not match the input texts")
+			}
+		})
+	}
+}
+
+func TestOpenAIInputTooLong(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, `{"error": {"message": "too long"}}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	long := strings.Repeat("a", 3*defaultInputTokens)
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		texts  []string
+		want   *InputTooLongError
+	}{
+		{
+			name:  "default limit",
+			texts: []string{"short", long, "short"},
+			want:  &InputTooLongError{Index: 1, Tokens: estimateTokens(long), Limit: defaultInputTokens},
+		},
+		{
+			name:   "configured limit",
+			config: map[string]interface{}{"input_tokens": 10},
+			texts:  []string{"short", "short", strings.Repeat("a", 60)},
+			want:   &InputTooLongError{Index: 2, Tokens: 21, Limit: 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := map[string]interface{}{"api_key": "key", "api_url": server.URL}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+			embedder, err := NewOpenAIEmbedder(config)
+			if err != nil {
+				t.Fatalf("NewOpenAIEmbedder: %v", err)
+			}
+
+			_, err = embedder.EmbedBatch(context.Background(), tt.texts)
+			var got *InputTooLongError
+			if !errors.As(err, &got) {
+				t.Fatalf("EmbedBatch error = %v, want an InputTooLongError", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("EmbedBatch error = %+v, want %+v", *got, *tt.want)
+			}
+			if n := requests.Load(); n != 0 {
+				t.Errorf("sent %d requests, want none", n)
+			}
+		})
+	}
+}
@@ -42,6 +42,10 @@ type Embedder interface {
 	// Embed generates embeddings for the given text
 	Embed(ctx context.Context, text string) ([]float64, error)
 
+	// EmbedBatch generates embeddings for several texts, in the order of
+	// texts, using as few requests as the provider allows
+	EmbedBatch(ctx context.Context, texts []string) ([][]float64, error)
+
 	// GetDimension returns the dimension of the embeddings for the current model
 	GetDimension() (int, error)
 }
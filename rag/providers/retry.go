@@ -0,0 +1,264 @@
+// Package providers implements embedding service providers for the Raggo framework.
+// This file holds the retry and rate limiting machinery shared by the HTTP
+// based providers.
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Default retry settings
+const (
+	// defaultMaxRetries is the number of retries after a failed request
+	defaultMaxRetries = 3
+	// defaultRetryBaseDelay is the backoff before the first retry
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	// defaultRetryMaxDelay caps the exponential backoff
+	defaultRetryMaxDelay = 30 * time.Second
+)
+
+// APIError is returned when an embedding API answers with a non-success
+// status. Use errors.As to inspect the status code or the delay the server
+// asked for.
+type APIError struct {
+	StatusCode int           // HTTP status code
+	Status     string        // HTTP status line
+	Message    string        // Error message from the response body, if any
+	RetryAfter time.Duration // Delay requested by the Retry-After header, if any
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("API request failed with status code %d: %s: %s", e.StatusCode, e.Status, e.Message)
+	}
+	return fmt.Sprintf("API request failed with status code %d: %s", e.StatusCode, e.Status)
+}
+
+// errorMessage extracts the error message of an API response body, which
+// OpenAI-style APIs give as {"error": {"message": ...}} and others, such as
+// Ollama, vLLM or TEI, as {"error": "..."}. It returns "" for other bodies.
+func errorMessage(body []byte) string {
+	var errorResp struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &errorResp) != nil || len(errorResp.Error) == 0 {
+		return ""
+	}
+	var message string
+	if json.Unmarshal(errorResp.Error, &message) == nil {
+		return message
+	}
+	var detail struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(errorResp.Error, &detail) == nil {
+		return detail.Message
+	}
+	return ""
+}
+
+// Retryable reports whether the request may succeed if sent again: on rate
+// limiting, timeouts and server errors. Other client errors are permanent.
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return true
+	}
+	return e.StatusCode >= 500
+}
+
+// isRetryable classifies an error returned by a request attempt. API errors
+// are retryable according to their status, context errors are permanent and
+// transport errors, which are wrapped in requestError, are retryable.
+func isRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var reqErr *requestError
+	return errors.As(err, &reqErr)
+}
+
+// requestError marks a failure to send a request or read its response,
+// which is worth retrying unlike errors preparing the request.
+type requestError struct {
+	err error
+}
+
+// Error implements the error interface.
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// retryPolicy controls how often and how patiently failed requests are
+// retried.
+type retryPolicy struct {
+	maxRetries int           // Retries after the first attempt
+	baseDelay  time.Duration // Backoff before the first retry
+	maxDelay   time.Duration // Upper bound of the backoff
+}
+
+// newRetryPolicy reads the "max_retries", "retry_base_delay" and
+// "retry_max_delay" keys of a provider configuration.
+func newRetryPolicy(config map[string]interface{}) retryPolicy {
+	p := retryPolicy{
+		maxRetries: defaultMaxRetries,
+		baseDelay:  defaultRetryBaseDelay,
+		maxDelay:   defaultRetryMaxDelay,
+	}
+	if maxRetries, ok := config["max_retries"].(int); ok && maxRetries >= 0 {
+		p.maxRetries = maxRetries
+	}
+	if delay, ok := config["retry_base_delay"].(time.Duration); ok && delay > 0 {
+		p.baseDelay = delay
+	}
+	if delay, ok := config["retry_max_delay"].(time.Duration); ok && delay > 0 {
+		p.maxDelay = delay
+	}
+	return p
+}
+
+// backoff returns the delay before retry number attempt (starting at 0):
+// the base delay doubled on each attempt, capped by the maximum delay, of
+// which a random half is dropped so that clients do not retry in lockstep.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay := p.maxDelay
+	if attempt < 32 {
+		delay = min(p.baseDelay<<attempt, p.maxDelay)
+	}
+	if delay <= 1 {
+		return delay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)))
+}
+
+// do calls attempt until it succeeds, fails with a permanent error or runs
+// out of retries. Between attempts it waits for the backoff, or for the
+// Retry-After delay of an APIError when that is longer.
+func (p retryPolicy) do(ctx context.Context, attempt func() error) error {
+	for retry := 0; ; retry++ {
+		err := attempt()
+		if err == nil || retry >= p.maxRetries || !isRetryable(err) {
+			if err != nil && retry > 0 {
+				return fmt.Errorf("giving up after %d attempts: %w", retry+1, err)
+			}
+			return err
+		}
+
+		delay := p.backoff(retry)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > delay {
+			delay = apiErr.RetryAfter
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// parseRetryAfter returns the delay requested by a Retry-After header,
+// given either in seconds or as an HTTP date, or 0 when absent or invalid.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return max(0, time.Duration(seconds)*time.Second)
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return max(0, date.Sub(now))
+	}
+	return 0
+}
+
+// RateLimiter is a client-side token bucket limiter for embedding APIs,
+// bounding both requests and tokens per minute. A single RateLimiter can be
+// shared by several embedders, through their "rate_limiter" configuration
+// key, so that together they stay within an account's quota. It is safe
+// for concurrent use.
+type RateLimiter struct {
+	requests *rate.Limiter // Requests per second, nil when unlimited
+	tokens   *rate.Limiter // Tokens per second, nil when unlimited
+}
+
+// NewRateLimiter creates a limiter allowing requestsPerMinute requests and
+// tokensPerMinute tokens per minute, with bursts of up to a minute's worth.
+// A limit of 0 or less disables that limit.
+//
+// Example:
+//
+//	limiter := providers.NewRateLimiter(3000, 1000000)
+//	config := map[string]interface{}{
+//	    "api_key":      os.Getenv("OPENAI_API_KEY"),
+//	    "rate_limiter": limiter,
+//	}
+func NewRateLimiter(requestsPerMinute, tokensPerMinute int) *RateLimiter {
+	l := &RateLimiter{}
+	if requestsPerMinute > 0 {
+		l.requests = rate.NewLimiter(rate.Limit(float64(requestsPerMinute)/60), requestsPerMinute)
+	}
+	if tokensPerMinute > 0 {
+		l.tokens = rate.NewLimiter(rate.Limit(float64(tokensPerMinute)/60), tokensPerMinute)
+	}
+	return l
+}
+
+// Wait blocks until one request carrying the given number of tokens may be
+// sent, or ctx is done. Requests larger than a minute's worth of tokens
+// wait for the whole bucket.
+func (l *RateLimiter) Wait(ctx context.Context, tokens int) error {
+	if l == nil {
+		return nil
+	}
+	if l.requests != nil {
+		if err := l.requests.Wait(ctx); err != nil {
+			return fmt.Errorf("waiting for request rate limit: %w", err)
+		}
+	}
+	if l.tokens != nil && tokens > 0 {
+		if err := l.tokens.WaitN(ctx, min(tokens, l.tokens.Burst())); err != nil {
+			return fmt.Errorf("waiting for token rate limit: %w", err)
+		}
+	}
+	return nil
+}
+
+// newRateLimiter returns the limiter of a provider configuration: the shared
+// *RateLimiter under "rate_limiter" if any, otherwise one built from the
+// "requests_per_minute" and "tokens_per_minute" keys, or nil when neither
+// is set.
+func newRateLimiter(config map[string]interface{}) *RateLimiter {
+	if limiter, ok := config["rate_limiter"].(*RateLimiter); ok && limiter != nil {
+		return limiter
+	}
+	requestsPerMinute, _ := config["requests_per_minute"].(int)
+	tokensPerMinute, _ := config["tokens_per_minute"].(int)
+	if requestsPerMinute <= 0 && tokensPerMinute <= 0 {
+		return nil
+	}
+	return NewRateLimiter(requestsPerMinute, tokensPerMinute)
+}
@@ -0,0 +1,161 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenAIRetries(t *testing.T) {
+	rateLimited := embedResponse{
+		Status: http.StatusTooManyRequests,
+		Header: map[string]string{"Retry-After": "1"},
+		Body:   `{"error": {"message": "Rate limit reached for requests", "type": "requests"}}`,
+	}
+	success := embedResponse{Body: `{"data": [{"index": 0, "embedding": [0.5, 0.25]}]}`}
+
+	tests := []struct {
+		name         string
+		responses    []embedResponse // Answers in turn, the last one repeating
+		maxRetries   int
+		wantStatus   int           // Status of the APIError, 0 on success or transport failure
+		wantText     string        // Text the error must contain, "" on success
+		wantRequests int           // Requests the server must receive, dropped ones included
+		minElapsed   time.Duration // Least time the call must take
+	}{
+		{
+			name:         "retry after rate limit",
+			responses:    []embedResponse{rateLimited, success},
+			maxRetries:   3,
+			wantRequests: 2,
+			minElapsed:   time.Second,
+		},
+		{
+			name:         "retry server errors",
+			responses:    []embedResponse{{Status: http.StatusBadGateway}, {Status: http.StatusServiceUnavailable}, success},
+			maxRetries:   3,
+			wantRequests: 3,
+		},
+		{
+			name:         "retry dropped connection",
+			responses:    []embedResponse{{Drop: true}, success},
+			maxRetries:   3,
+			wantRequests: 2,
+		},
+		{
+			name:         "bad request is permanent",
+			responses:    []embedResponse{{Status: http.StatusBadRequest, Body: `{"error": {"message": "'input' is a required property"}}`}},
+			maxRetries:   3,
+			wantStatus:   http.StatusBadRequest,
+			wantText:     "API request failed with status code 400: 400 Bad Request: 'input' is a required property",
+			wantRequests: 1,
+		},
+		{
+			name:         "retries exhausted",
+			responses:    []embedResponse{{Status: http.StatusInternalServerError, Body: `{"error": {"message": "server overloaded"}}`}},
+			maxRetries:   2,
+			wantStatus:   http.StatusInternalServerError,
+			wantText:     "giving up after 3 attempts: API request failed with status code 500",
+			wantRequests: 3,
+		},
+		{
+			name:         "retries disabled",
+			responses:    []embedResponse{rateLimited},
+			maxRetries:   0,
+			wantStatus:   http.StatusTooManyRequests,
+			wantText:     "Rate limit reached for requests",
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newEmbedServer(t, func(req embedRequest, n int) embedResponse {
+				return tt.responses[min(n, len(tt.responses)-1)]
+			})
+
+			embedder, err := NewOpenAIEmbedder(map[string]interface{}{
+				"api_key":          "key",
+				"api_url":          server.URL,
+				"max_retries":      tt.maxRetries,
+				"retry_base_delay": time.Millisecond,
+				"retry_max_delay":  10 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("NewOpenAIEmbedder: %v", err)
+			}
+
+			start := time.Now()
+			embedding, err := embedder.Embed(context.Background(), "text")
+			elapsed := time.Since(start)
+
+			if tt.wantText == "" {
+				if err != nil {
+					t.Fatalf("Embed: %v", err)
+				}
+				if len(embedding) != 2 || embedding[0] != 0.5 {
+					t.Errorf("embedding = %v, want [0.5 0.25]", embedding)
+				}
+			} else {
+				if err == nil {
+					t.Fatal("Embed succeeded, want an error")
+				}
+				if !strings.Contains(err.Error(), tt.wantText) {
+					t.Errorf("error %q does not contain %q", err, tt.wantText)
+				}
+			}
+			var apiErr *APIError
+			if errors.As(err, &apiErr) != (tt.wantStatus != 0) || (apiErr != nil && apiErr.StatusCode != tt.wantStatus) {
+				t.Errorf("error %v, want an APIError with status %d", err, tt.wantStatus)
+			}
+			if got := len(server.received()); got != tt.wantRequests {
+				t.Errorf("sent %d requests, want %d", got, tt.wantRequests)
+			}
+			if elapsed < tt.minElapsed {
+				t.Errorf("Embed returned after %v, want at least %v", elapsed, tt.minElapsed)
+			}
+		})
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	server := newEmbedServer(t, func(embedRequest, int) embedResponse {
+		return embedResponse{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "60"}}
+	})
+	embedder, err := NewOpenAIEmbedder(map[string]interface{}{"api_key": "key", "api_url": server.URL})
+	if err != nil {
+		t.Fatalf("NewOpenAIEmbedder: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = embedder.Embed(ctx, "text")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Embed error = %v, want context.DeadlineExceeded", err)
+	}
+	if !strings.Contains(err.Error(), "status code 429") {
+		t.Errorf("error %q does not report the last failure", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{header: "", want: 0},
+		{header: "5", want: 5 * time.Second},
+		{header: "-3", want: 0},
+		{header: "Wed, 01 May 2024 12:00:30 GMT", want: 30 * time.Second},
+		{header: "Wed, 01 May 2024 11:59:00 GMT", want: 0},
+		{header: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
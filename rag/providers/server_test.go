@@ -0,0 +1,116 @@
+package providers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// embedRequest is a request received by an embedServer.
+type embedRequest struct {
+	URL    string                 // Path and query, e.g. /v1/embeddings?api-version=1
+	Header http.Header            // Request headers
+	Body   map[string]interface{} // Decoded JSON body
+}
+
+// embedResponse is the answer of an embedServer to one request.
+type embedResponse struct {
+	Status int               // HTTP status, 200 when zero
+	Header map[string]string // Response headers
+	Body   string            // Response body
+	Drop   bool              // Close the connection without answering
+}
+
+// embedServer is an httptest server standing in for an embedding API. It
+// records every request and answers with respond, which is given the
+// request and its position among the requests received.
+type embedServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []embedRequest
+}
+
+// newEmbedServer starts an embedServer that is closed when the test ends.
+func newEmbedServer(t *testing.T, respond func(req embedRequest, n int) embedResponse) *embedServer {
+	t.Helper()
+	s := &embedServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := embedRequest{URL: r.URL.RequestURI(), Header: r.Header.Clone()}
+		payload, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(payload, &req.Body); err != nil {
+			t.Errorf("request body %q is not a JSON object: %v", payload, err)
+		}
+
+		s.mu.Lock()
+		n := len(s.requests)
+		s.requests = append(s.requests, req)
+		s.mu.Unlock()
+
+		response := respond(req, n)
+		if response.Drop {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("Hijack: %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		for name, value := range response.Header {
+			w.Header().Set(name, value)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if response.Status == 0 {
+			response.Status = http.StatusOK
+		}
+		w.WriteHeader(response.Status)
+		io.WriteString(w, response.Body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// received returns the requests received so far.
+func (s *embedServer) received() []embedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]embedRequest(nil), s.requests...)
+}
+
+// inputs returns the texts of a request body under field, or nil when the
+// field is not a list.
+func inputs(req embedRequest, field string) []string {
+	values, ok := req.Body[field].([]interface{})
+	if !ok {
+		return nil
+	}
+	texts := make([]string, len(values))
+	for i, v := range values {
+		texts[i], _ = v.(string)
+	}
+	return texts
+}
+
+// lengthEmbeddings returns one embedding per text holding the text's length,
+// so that tests can check embeddings are returned in input order.
+func lengthEmbeddings(texts []string) [][]float64 {
+	embeddings := make([][]float64, len(texts))
+	for i, text := range texts {
+		embeddings[i] = []float64{float64(len(text)), 1}
+	}
+	return embeddings
+}
+
+// mustJSON encodes v, failing the test on error.
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return string(encoded)
+}
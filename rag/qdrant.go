@@ -0,0 +1,1085 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultQdrantAddress is the REST endpoint of a local Qdrant instance
+	defaultQdrantAddress = "http://localhost:6333"
+	// defaultQdrantTimeout bounds each HTTP request when Config.Timeout is unset
+	defaultQdrantTimeout = 30 * time.Second
+	// qdrantBatchSize is the number of points sent per upsert request
+	qdrantBatchSize = 256
+)
+
+// QdrantDB implements the VectorDB interface on top of Qdrant's REST API.
+// It maps raggo concepts onto Qdrant as follows:
+//   - Each float_vector field of the Schema becomes a named vector
+//   - The record's ID field becomes the point ID
+//   - Every other field (Text, Metadata, ...) is stored in the point payload,
+//     so Metadata keys can be filtered as Metadata.<key>
+//
+// Qdrant fixes the distance of a vector when the collection is created. New
+// collections use the metric given by the "metric" parameter (default L2),
+// and searches with a different metric are rejected.
+type QdrantDB struct {
+	baseURL     string                                   // REST endpoint, e.g. http://localhost:6333
+	apiKey      string                                   // Optional API key sent in the api-key header
+	metric      string                                   // Metric used for new collections
+	client      *http.Client                             // HTTP client used for every request
+	columnNames []string                                 // Payload fields to retrieve in search results
+	vectors     map[string]map[string]qdrantVectorParams // Cached named vectors per collection
+	mu          sync.Mutex                               // Protects vectors
+}
+
+// qdrantError is an error reported by the Qdrant API.
+type qdrantError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *qdrantError) Error() string {
+	return fmt.Sprintf("qdrant returned HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// Is reports a 404 about a collection as ErrCollectionNotFound.
+func (e *qdrantError) Is(target error) bool {
+	return target == ErrCollectionNotFound && e.StatusCode == http.StatusNotFound &&
+		strings.Contains(strings.ToLower(e.Message), "collection")
+}
+
+// qdrantVectorParams describes one vector of a collection.
+type qdrantVectorParams struct {
+	Size       int               `json:"size"`
+	Distance   string            `json:"distance"`
+	HNSWConfig *qdrantHNSWConfig `json:"hnsw_config,omitempty"`
+}
+
+// qdrantHNSWConfig holds the HNSW parameters of a collection or vector.
+type qdrantHNSWConfig struct {
+	M           int `json:"m,omitempty"`
+	EfConstruct int `json:"ef_construct,omitempty"`
+}
+
+// qdrantCollectionInfo is the part of the collection info endpoint's
+// response used to describe a collection.
+type qdrantCollectionInfo struct {
+	Status      string `json:"status"`
+	PointsCount int64  `json:"points_count"`
+	Config      struct {
+		Params struct {
+			Vectors json.RawMessage `json:"vectors"`
+		} `json:"params"`
+		HNSWConfig qdrantHNSWConfig `json:"hnsw_config"`
+	} `json:"config"`
+	PayloadSchema map[string]struct {
+		DataType string `json:"data_type"`
+	} `json:"payload_schema"`
+}
+
+// qdrantPoint is a point as sent to the upsert endpoint.
+type qdrantPoint struct {
+	ID      int64                  `json:"id"`
+	Vector  map[string]Vector      `json:"vector"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+}
+
+// qdrantRecord is a point as returned by the retrieve and search endpoints.
+type qdrantRecord struct {
+	ID      json.RawMessage        `json:"id"`
+	Score   float64                `json:"score"`
+	Payload map[string]interface{} `json:"payload"`
+	Vector  json.RawMessage        `json:"vector"`
+}
+
+func init() {
+	Register("qdrant", func(cfg *Config) (VectorDB, error) { return newQdrantDB(cfg) }, Capabilities{Hybrid: true, Filters: true, Delete: true, Persistence: true})
+}
+
+// newQdrantDB creates a new QdrantDB instance with the given configuration.
+// Note: This doesn't establish the connection - call Connect() separately.
+//
+// cfg.Address is the REST endpoint (default http://localhost:6333). Supported
+// parameters:
+// - "api_key" (string): API key, falling back to the QDRANT_API_KEY environment variable
+// - "metric" (string): metric of new collections, L2 (default), IP or COSINE
+func newQdrantDB(cfg *Config) (*QdrantDB, error) {
+	baseURL := strings.TrimRight(cfg.Address, "/")
+	if baseURL == "" {
+		baseURL = defaultQdrantAddress
+	}
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+
+	apiKey, _ := cfg.Parameters["api_key"].(string)
+	if apiKey == "" {
+		apiKey = os.Getenv("QDRANT_API_KEY")
+	}
+
+	metricType, _ := cfg.Parameters["metric"].(string)
+	metric, err := ResolveMetric(metricType)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultQdrantTimeout
+	}
+
+	return &QdrantDB{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		metric:  metric,
+		client:  &http.Client{Timeout: timeout},
+		vectors: make(map[string]map[string]qdrantVectorParams),
+	}, nil
+}
+
+// Connect checks that the Qdrant server is reachable.
+func (q *QdrantDB) Connect(ctx context.Context) error {
+	GlobalLogger.Debug("Attempting to connect to Qdrant", "address", q.baseURL)
+	if err := q.do(ctx, http.MethodGet, "/collections", nil, nil); err != nil {
+		GlobalLogger.Error("Failed to connect to Qdrant", "error", err, "address", q.baseURL)
+		return fmt.Errorf("failed to connect to Qdrant at %s: %w\nPlease ensure Qdrant is running (e.g., with 'docker run -p 6333:6333 qdrant/qdrant')", q.baseURL, err)
+	}
+	GlobalLogger.Debug("Successfully connected to Qdrant")
+	return nil
+}
+
+// Close releases idle HTTP connections.
+func (q *QdrantDB) Close() error {
+	q.client.CloseIdleConnections()
+	return nil
+}
+
+// HasCollection checks if a collection with the given name exists.
+func (q *QdrantDB) HasCollection(ctx context.Context, name string) (bool, error) {
+	err := q.do(ctx, http.MethodGet, q.collectionPath(name), nil, nil)
+	var apiErr *qdrantError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DropCollection removes a collection and all its points.
+// Warning: This operation is irreversible.
+func (q *QdrantDB) DropCollection(ctx context.Context, name string) error {
+	q.forgetVectors(name)
+	return q.do(ctx, http.MethodDelete, q.collectionPath(name), nil, nil)
+}
+
+// CreateCollection creates a collection with one named vector per
+// float_vector field of the schema, using the database's configured metric.
+// Scalar fields need no declaration: they are stored in the point payload.
+func (q *QdrantDB) CreateCollection(ctx context.Context, name string, schema Schema) error {
+	vectors := make(map[string]qdrantVectorParams)
+	for _, field := range schema.Fields {
+		if field.DataType != "float_vector" {
+			continue
+		}
+		if field.Dimension <= 0 {
+			return fmt.Errorf("vector field %s requires a positive dimension", field.Name)
+		}
+		vectors[field.Name] = qdrantVectorParams{Size: field.Dimension, Distance: qdrantDistance(q.metric)}
+	}
+	if len(vectors) == 0 {
+		return fmt.Errorf("schema for collection %s has no float_vector field", name)
+	}
+
+	exists, err := q.HasCollection(ctx, name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrCollectionExists, name)
+	}
+
+	GlobalLogger.Debug("Creating collection", "name", name, "vectors", fmt.Sprintf("%+v", vectors))
+	if err := q.do(ctx, http.MethodPut, q.collectionPath(name), map[string]interface{}{"vectors": vectors}, nil); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	q.vectors[name] = vectors
+	q.mu.Unlock()
+	return nil
+}
+
+// Insert adds records to a collection. Records without an ID receive one
+// derived from their document ID (see DocIDKey), or a random one, as Qdrant
+// does not generate point IDs. Qdrant overwrites points on ID collision, so
+// Insert and Upsert behave the same.
+func (q *QdrantDB) Insert(ctx context.Context, collectionName string, data []Record) error {
+	return q.upsertPoints(ctx, collectionName, data)
+}
+
+// Upsert inserts records, replacing any existing points that share the same ID.
+func (q *QdrantDB) Upsert(ctx context.Context, collectionName string, data []Record) error {
+	return q.upsertPoints(ctx, collectionName, data)
+}
+
+// upsertPoints validates records against the collection's named vectors (see
+// ValidateRecords), converts them to points and writes them in batches,
+// waiting for each batch to be applied.
+func (q *QdrantDB) upsertPoints(ctx context.Context, collectionName string, data []Record) error {
+	vectors, err := q.vectorParams(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+	schema := Schema{Name: collectionName}
+	for _, name := range slices.Sorted(maps.Keys(vectors)) {
+		schema.Fields = append(schema.Fields, Field{Name: name, DataType: "float_vector", Dimension: vectors[name].Size})
+	}
+	if err := ValidateRecords(schema, data); err != nil {
+		return err
+	}
+
+	points := make([]qdrantPoint, 0, len(data))
+	for i, record := range data {
+		point, err := recordToQdrantPoint(record)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		points = append(points, point)
+	}
+
+	for start := 0; start < len(points); start += qdrantBatchSize {
+		end := min(start+qdrantBatchSize, len(points))
+		body := map[string]interface{}{"points": points[start:end]}
+		if err := q.do(ctx, http.MethodPut, q.collectionPath(collectionName)+"/points?wait=true", body, nil); err != nil {
+			GlobalLogger.Error("Failed to upsert points", "collection", collectionName, "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the points with the given IDs from a collection.
+func (q *QdrantDB) Delete(ctx context.Context, collectionName string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	body := map[string]interface{}{"points": ids}
+	return q.do(ctx, http.MethodPost, q.collectionPath(collectionName)+"/points/delete?wait=true", body, nil)
+}
+
+// DeleteByFilter removes every point matching filter. The filter is
+// translated into a Qdrant filter over the Metadata payload.
+func (q *QdrantDB) DeleteByFilter(ctx context.Context, collectionName string, filter *Filter) error {
+	if filter == nil {
+		return fmt.Errorf("delete filter must not be empty")
+	}
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	qf, err := qdrantFilter(filter)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{"filter": qf}
+	return q.do(ctx, http.MethodPost, q.collectionPath(collectionName)+"/points/delete?wait=true", body, nil)
+}
+
+// Get retrieves the points with the given IDs, including their payload and
+// vectors. IDs that are not present in the collection are skipped.
+func (q *QdrantDB) Get(ctx context.Context, collectionName string, ids []int64) ([]Record, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	body := map[string]interface{}{
+		"ids":          ids,
+		"with_payload": true,
+		"with_vector":  true,
+	}
+	var points []qdrantRecord
+	if err := q.do(ctx, http.MethodPost, q.collectionPath(collectionName)+"/points", body, &points); err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, 0, len(points))
+	for _, point := range points {
+		records = append(records, point.toRecord())
+	}
+	return records, nil
+}
+
+// Scan calls fn with every point of a collection, ordered by ID, using the
+// scroll API.
+func (q *QdrantDB) Scan(ctx context.Context, collectionName string, batchSize int, fn func([]Record) error) error {
+	body := map[string]interface{}{
+		"limit":        scanBatchSize(batchSize),
+		"with_payload": true,
+		"with_vector":  true,
+	}
+	for {
+		var page struct {
+			Points         []qdrantRecord  `json:"points"`
+			NextPageOffset json.RawMessage `json:"next_page_offset"`
+		}
+		if err := q.do(ctx, http.MethodPost, q.collectionPath(collectionName)+"/points/scroll", body, &page); err != nil {
+			return err
+		}
+
+		if len(page.Points) > 0 {
+			records := make([]Record, len(page.Points))
+			for i, point := range page.Points {
+				records[i] = point.toRecord()
+			}
+			if err := fn(records); err != nil {
+				return err
+			}
+		}
+
+		if len(page.NextPageOffset) == 0 || string(page.NextPageOffset) == "null" {
+			return nil
+		}
+		body["offset"] = page.NextPageOffset
+	}
+}
+
+// Flush is a no-op: every write waits until Qdrant has applied it.
+func (q *QdrantDB) Flush(ctx context.Context, collectionName string) error {
+	return nil
+}
+
+// CreateIndex configures indexing for a field.
+//   - On a vector field, an "HNSW" index updates the vector's HNSW parameters
+//     (M and efConstruction). Its metric must match the collection's, as
+//     Qdrant cannot change the distance of an existing vector.
+//   - On any other field, a payload index is created on the matching payload
+//     key, with Index.Type as the Qdrant field schema (keyword, integer, float,
+//     bool, text, ...). Field names refer to Metadata keys, as in filters.
+func (q *QdrantDB) CreateIndex(ctx context.Context, collectionName, field string, index Index) error {
+	vectors, err := q.vectorParams(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+
+	params, isVector := vectors[field]
+	if !isVector {
+		if index.Type == "" {
+			return fmt.Errorf("payload index on %s requires a field schema type", field)
+		}
+		body := map[string]interface{}{
+			"field_name":   qdrantPayloadKey(field),
+			"field_schema": strings.ToLower(index.Type),
+		}
+		return q.do(ctx, http.MethodPut, q.collectionPath(collectionName)+"/index?wait=true", body, nil)
+	}
+
+	metric, err := ResolveMetric(index.Metric)
+	if err != nil {
+		return err
+	}
+	if current := qdrantMetric(params.Distance); metric != current {
+		return fmt.Errorf("vector %s of collection %s uses %s; Qdrant cannot change it to %s", field, collectionName, current, metric)
+	}
+
+	switch index.Type {
+	case "HNSW":
+		hnsw := map[string]interface{}{
+			"m":            intParam(index.Parameters, "M", defaultHNSWM),
+			"ef_construct": intParam(index.Parameters, "efConstruction", defaultHNSWEfConstruction),
+		}
+		body := map[string]interface{}{
+			"vectors": map[string]interface{}{
+				field: map[string]interface{}{"hnsw_config": hnsw},
+			},
+		}
+		return q.do(ctx, http.MethodPatch, q.collectionPath(collectionName), body, nil)
+	default:
+		return fmt.Errorf("unsupported index type: %s", index.Type)
+	}
+}
+
+// LoadCollection checks that the collection exists. Qdrant serves
+// collections without an explicit load step.
+func (q *QdrantDB) LoadCollection(ctx context.Context, name string) error {
+	exists, err := q.HasCollection(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return collectionNotFound(name)
+	}
+	return nil
+}
+
+// ListCollections returns the names of all collections, sorted.
+func (q *QdrantDB) ListCollections(ctx context.Context) ([]string, error) {
+	var result struct {
+		Collections []struct {
+			Name string `json:"name"`
+		} `json:"collections"`
+	}
+	if err := q.do(ctx, http.MethodGet, "/collections", nil, &result); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(result.Collections))
+	for i, coll := range result.Collections {
+		names[i] = coll.Name
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DescribeCollection returns the schema of a collection and its indexes.
+// Qdrant payloads are schemaless, so the schema lists the collection's named
+// vectors alongside the ID, Text and Metadata fields raggo writes. Every
+// vector is indexed with HNSW; payload indexes are reported under the field
+// name used in filters, with their Qdrant field schema as Index.Type.
+func (q *QdrantDB) DescribeCollection(ctx context.Context, name string) (*CollectionInfo, error) {
+	info, err := q.collectionInfo(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := Schema{Name: name, Fields: []Field{{Name: "ID", DataType: "int64", PrimaryKey: true, AutoID: true}}}
+	indexes := make(map[string]Index)
+	vectors := qdrantVectorConfigs(info.Config.Params.Vectors)
+	for _, field := range slices.Sorted(maps.Keys(vectors)) {
+		params := vectors[field]
+		schema.Fields = append(schema.Fields, Field{Name: field, DataType: "float_vector", Dimension: params.Size})
+
+		hnsw := info.Config.HNSWConfig
+		if params.HNSWConfig != nil {
+			if params.HNSWConfig.M > 0 {
+				hnsw.M = params.HNSWConfig.M
+			}
+			if params.HNSWConfig.EfConstruct > 0 {
+				hnsw.EfConstruct = params.HNSWConfig.EfConstruct
+			}
+		}
+		indexes[field] = Index{
+			Type:   "HNSW",
+			Metric: qdrantMetric(params.Distance),
+			Parameters: map[string]interface{}{
+				"M":              hnsw.M,
+				"efConstruction": hnsw.EfConstruct,
+			},
+		}
+	}
+	schema.Fields = append(schema.Fields,
+		Field{Name: "Text", DataType: "varchar"},
+		Field{Name: "Metadata", DataType: "json"},
+	)
+
+	for key, payload := range info.PayloadSchema {
+		field := strings.TrimPrefix(key, "Metadata.")
+		indexes[field] = Index{Type: payload.DataType}
+	}
+	return &CollectionInfo{Name: name, Schema: schema, Indexes: indexes}, nil
+}
+
+// Count returns the exact number of points in a collection.
+func (q *QdrantDB) Count(ctx context.Context, collectionName string) (int64, error) {
+	var result struct {
+		Count int64 `json:"count"`
+	}
+	body := map[string]interface{}{"exact": true}
+	if err := q.do(ctx, http.MethodPost, q.collectionPath(collectionName)+"/points/count", body, &result); err != nil {
+		return 0, err
+	}
+	return result.Count, nil
+}
+
+// Stats reports the point count of a collection and the state of its
+// indexes, derived from the collection status: green when optimized, yellow
+// or grey while segments are being optimized and indexed, red on failure.
+// Qdrant does not report storage sizes.
+func (q *QdrantDB) Stats(ctx context.Context, collectionName string) (*CollectionStats, error) {
+	info, err := q.collectionInfo(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	count, err := q.Count(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	dimension := 0
+	vectors := qdrantVectorConfigs(info.Config.Params.Vectors)
+	if names := slices.Sorted(maps.Keys(vectors)); len(names) > 0 {
+		dimension = vectors[names[0]].Size
+	}
+
+	status := IndexStatusBuilding
+	switch info.Status {
+	case "green":
+		status = IndexStatusReady
+	case "red":
+		status = IndexStatusFailed
+	}
+	return &CollectionStats{
+		Count:       count,
+		Dimension:   dimension,
+		StorageSize: -1,
+		IndexStatus: status,
+	}, nil
+}
+
+// qdrantVectorConfigs decodes the vectors of a collection configuration.
+// Collections created by raggo use named vectors; a collection with a single
+// unnamed vector is exposed under the empty name.
+func qdrantVectorConfigs(raw json.RawMessage) map[string]qdrantVectorParams {
+	vectors := make(map[string]qdrantVectorParams)
+	var named map[string]qdrantVectorParams
+	if err := json.Unmarshal(raw, &named); err == nil {
+		for name, params := range named {
+			if params.Size > 0 {
+				vectors[name] = params
+			}
+		}
+	}
+	if len(vectors) == 0 {
+		var single qdrantVectorParams
+		if err := json.Unmarshal(raw, &single); err == nil && single.Size > 0 {
+			vectors[""] = single
+		}
+	}
+	return vectors
+}
+
+// collectionInfo fetches the description of a collection.
+func (q *QdrantDB) collectionInfo(ctx context.Context, name string) (*qdrantCollectionInfo, error) {
+	var info qdrantCollectionInfo
+	if err := q.do(ctx, http.MethodGet, q.collectionPath(name), nil, &info); err != nil {
+		return nil, fmt.Errorf("failed to describe collection %s: %w", name, err)
+	}
+	return &info, nil
+}
+
+// Search performs vector similarity search on a single named vector.
+// Parameters:
+//   - vectors: Map of field name to vector values (exactly one entry)
+//   - topK: Number of results to return
+//   - metricType: Must match the metric the collection was created with
+//   - searchParams: Optional "ef" (HNSW candidate list size) and FilterParam,
+//     which is translated into a Qdrant filter
+//
+// Qdrant scores are converted to the common scale documented on SearchResult.
+func (q *QdrantDB) Search(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}) ([]SearchResult, error) {
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("qdrant search requires exactly one vector, got %d", len(vectors))
+	}
+
+	var fieldName string
+	var vector Vector
+	for f, v := range vectors {
+		fieldName = f
+		vector = v
+	}
+
+	metric, err := q.checkMetric(ctx, collectionName, fieldName, metricType)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := q.searchPoints(ctx, collectionName, fieldName, vector, topK, searchParams)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, len(records))
+	for i, record := range records {
+		distance := qdrantScoreDistance(metric, record.Score)
+		results[i] = record.toSearchResult(ScoreFromDistance(metric, distance), distance)
+	}
+	return results, nil
+}
+
+// SearchBatch sends every query vector to Qdrant's batch search endpoint in
+// a single request on the named vector field, with the parameters of Search,
+// and returns one result list per query.
+func (q *QdrantDB) SearchBatch(ctx context.Context, collectionName, field string, queries []Vector, topK int, metricType string, searchParams map[string]interface{}) ([][]SearchResult, error) {
+	batch := make([][]SearchResult, len(queries))
+	if len(queries) == 0 {
+		return batch, nil
+	}
+	metric, err := q.checkMetric(ctx, collectionName, field, metricType)
+	if err != nil {
+		return nil, err
+	}
+
+	searches := make([]map[string]interface{}, len(queries))
+	for i, query := range queries {
+		if searches[i], err = q.searchRequest(field, query, topK, searchParams); err != nil {
+			return nil, err
+		}
+	}
+	var responses [][]qdrantRecord
+	body := map[string]interface{}{"searches": searches}
+	if err := q.do(ctx, http.MethodPost, q.collectionPath(collectionName)+"/points/search/batch", body, &responses); err != nil {
+		return nil, err
+	}
+	if len(responses) != len(queries) {
+		return nil, fmt.Errorf("qdrant returned %d result lists for %d queries", len(responses), len(queries))
+	}
+
+	for i, records := range responses {
+		batch[i] = make([]SearchResult, len(records))
+		for j, record := range records {
+			distance := qdrantScoreDistance(metric, record.Score)
+			batch[i][j] = record.toSearchResult(ScoreFromDistance(metric, distance), distance)
+		}
+	}
+	return batch, nil
+}
+
+// HybridSearch searches each named vector separately and fuses the rankings
+// with Reciprocal Rank Fusion. The reranker may be nil (RRF with k = 60) or
+// a *RRFReranker. Result scores are the fused scores; their Distance is zero.
+func (q *QdrantDB) HybridSearch(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}, reranker interface{}) ([]SearchResult, error) {
+	rrf := NewRRFReranker(0)
+	if reranker != nil {
+		var ok bool
+		if rrf, ok = reranker.(*RRFReranker); !ok {
+			return nil, fmt.Errorf("invalid reranker type %T for qdrant", reranker)
+		}
+	}
+
+	// Search fields in a stable order so that ties fuse deterministically
+	fields := make([]string, 0, len(vectors))
+	for field := range vectors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	rankings := make([][]SearchResult, 0, len(fields))
+	for _, field := range fields {
+		if _, err := q.checkMetric(ctx, collectionName, field, metricType); err != nil {
+			return nil, err
+		}
+		records, err := q.searchPoints(ctx, collectionName, field, vectors[field], topK, searchParams)
+		if err != nil {
+			return nil, err
+		}
+		ranking := make([]SearchResult, len(records))
+		for i, record := range records {
+			ranking[i] = record.toSearchResult(0, 0)
+		}
+		rankings = append(rankings, ranking)
+	}
+
+	results := rrf.fuse(rankings...)
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+// SetColumnNames sets the payload fields to retrieve in search results.
+// When empty, the whole payload is returned.
+func (q *QdrantDB) SetColumnNames(names []string) {
+	q.columnNames = names
+}
+
+// searchPoints runs a search request against one named vector.
+func (q *QdrantDB) searchPoints(ctx context.Context, collectionName, field string, vector Vector, topK int, searchParams map[string]interface{}) ([]qdrantRecord, error) {
+	body, err := q.searchRequest(field, vector, topK, searchParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []qdrantRecord
+	if err := q.do(ctx, http.MethodPost, q.collectionPath(collectionName)+"/points/search", body, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// searchRequest builds the body of a search request against one named vector.
+func (q *QdrantDB) searchRequest(field string, vector Vector, topK int, searchParams map[string]interface{}) (map[string]interface{}, error) {
+	body := map[string]interface{}{
+		"vector":       map[string]interface{}{"name": field, "vector": vector},
+		"limit":        topK,
+		"with_payload": true,
+	}
+	if len(q.columnNames) > 0 {
+		body["with_payload"] = q.columnNames
+	}
+	if ef := intParam(searchParams, "ef", 0); ef > 0 {
+		body["params"] = map[string]interface{}{"hnsw_ef": ef}
+	}
+
+	filter, err := filterFromParams(searchParams)
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		qf, err := qdrantFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		body["filter"] = qf
+	}
+	return body, nil
+}
+
+// checkMetric resolves metricType and verifies that it matches the metric
+// the collection's vector field was created with.
+func (q *QdrantDB) checkMetric(ctx context.Context, collectionName, field, metricType string) (string, error) {
+	metric, err := ResolveMetric(metricType)
+	if err != nil {
+		return "", err
+	}
+	vectors, err := q.vectorParams(ctx, collectionName)
+	if err != nil {
+		return "", err
+	}
+	params, ok := vectors[field]
+	if !ok {
+		return "", fmt.Errorf("collection %s has no vector field %s", collectionName, field)
+	}
+	if current := qdrantMetric(params.Distance); current != metric {
+		return "", fmt.Errorf("vector %s of collection %s uses %s and cannot be searched with %s", field, collectionName, current, metric)
+	}
+	return metric, nil
+}
+
+// vectorParams returns the size and distance of each named vector of a
+// collection. The result is cached so that writes and searches do not
+// describe the collection every time.
+func (q *QdrantDB) vectorParams(ctx context.Context, collectionName string) (map[string]qdrantVectorParams, error) {
+	q.mu.Lock()
+	vectors, ok := q.vectors[collectionName]
+	q.mu.Unlock()
+	if ok {
+		return vectors, nil
+	}
+
+	info, err := q.collectionInfo(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	vectors = qdrantVectorConfigs(info.Config.Params.Vectors)
+
+	q.mu.Lock()
+	q.vectors[collectionName] = vectors
+	q.mu.Unlock()
+	return vectors, nil
+}
+
+// forgetVectors drops the cached named vectors of a collection.
+func (q *QdrantDB) forgetVectors(collectionName string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.vectors, collectionName)
+}
+
+// collectionPath returns the API path of a collection.
+func (q *QdrantDB) collectionPath(name string) string {
+	return "/collections/" + url.PathEscape(name)
+}
+
+// do sends a JSON request to the Qdrant API and decodes the "result" member
+// of the response into out, when out is not nil.
+func (q *QdrantDB) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode qdrant request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, q.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create qdrant request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if q.apiKey != "" {
+		req.Header.Set("api-key", q.apiKey)
+	}
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("qdrant request %s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read qdrant response: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		var failure struct {
+			Status struct {
+				Error string `json:"error"`
+			} `json:"status"`
+		}
+		message := strings.TrimSpace(string(payload))
+		if json.Unmarshal(payload, &failure) == nil && failure.Status.Error != "" {
+			message = failure.Status.Error
+		}
+		return &qdrantError{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if out == nil {
+		return nil
+	}
+	envelope := struct {
+		Result interface{} `json:"result"`
+	}{Result: out}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return fmt.Errorf("failed to decode qdrant response: %w", err)
+	}
+	return nil
+}
+
+// recordToQdrantPoint splits a record into a point ID, named vectors and a
+// payload holding every other field. Records without an ID get one derived
+// from their document ID, or else from their content (see ContentID).
+func recordToQdrantPoint(record Record) (qdrantPoint, error) {
+	point := qdrantPoint{
+		Vector:  make(map[string]Vector),
+		Payload: make(map[string]interface{}),
+	}
+
+	id, err := storedID(record)
+	if err != nil {
+		return point, err
+	}
+	if id < 0 {
+		return point, fmt.Errorf("qdrant point IDs must not be negative, got %d", id)
+	}
+	point.ID = id
+
+	for name, value := range record.Fields {
+		if name == "ID" {
+			continue
+		}
+		switch v := value.(type) {
+		case Vector:
+			point.Vector[name] = v
+		case []float64:
+			point.Vector[name] = Vector(v)
+		case []float32:
+			vector := make(Vector, len(v))
+			for i, f := range v {
+				vector[i] = float64(f)
+			}
+			point.Vector[name] = vector
+		default:
+			point.Payload[name] = value
+		}
+	}
+	if len(point.Vector) == 0 {
+		return point, fmt.Errorf("record has no vector field")
+	}
+	return point, nil
+}
+
+// pointID decodes a numeric point ID. Points with UUID IDs, which raggo
+// never creates, report false.
+func (r qdrantRecord) pointID() (int64, bool) {
+	id, err := strconv.ParseInt(string(r.ID), 10, 64)
+	return id, err == nil
+}
+
+// toRecord converts a retrieved point back into a Record with the same field
+// layout used on insert.
+func (r qdrantRecord) toRecord() Record {
+	fields := make(map[string]interface{}, len(r.Payload)+2)
+	for k, v := range r.Payload {
+		fields[k] = v
+	}
+	if id, ok := r.pointID(); ok {
+		fields["ID"] = id
+	}
+
+	var named map[string][]float64
+	if err := json.Unmarshal(r.Vector, &named); err == nil {
+		for name, values := range named {
+			fields[name] = Vector(values)
+		}
+	}
+	return Record{Fields: fields}
+}
+
+// toSearchResult converts a scored point into a SearchResult.
+func (r qdrantRecord) toSearchResult(score, distance float64) SearchResult {
+	id, _ := r.pointID()
+	fields := make(map[string]interface{}, len(r.Payload))
+	for k, v := range r.Payload {
+		fields[k] = v
+	}
+	return SearchResult{
+		ID:       id,
+		DocID:    docIDFromFields(fields),
+		Score:    score,
+		Distance: distance,
+		Fields:   fields,
+	}
+}
+
+// qdrantDistance returns the Qdrant distance name of a resolved metric.
+func qdrantDistance(metric string) string {
+	switch metric {
+	case MetricIP:
+		return "Dot"
+	case MetricCosine:
+		return "Cosine"
+	default:
+		return "Euclid"
+	}
+}
+
+// qdrantMetric returns the metric of a Qdrant distance name.
+func qdrantMetric(distance string) string {
+	switch distance {
+	case "Dot":
+		return MetricIP
+	case "Cosine":
+		return MetricCosine
+	default:
+		return MetricL2
+	}
+}
+
+// qdrantScoreDistance converts a Qdrant score into the distance documented on
+// SearchResult. Qdrant reports the Euclidean distance for Euclid, the inner
+// product for Dot and the cosine similarity for Cosine.
+func qdrantScoreDistance(metric string, score float64) float64 {
+	switch metric {
+	case MetricIP:
+		return -score
+	case MetricCosine:
+		return 1 - score
+	default:
+		return score
+	}
+}
+
+// qdrantPayloadKey returns the payload key addressed by a filter field:
+// TextField maps to the Text payload and any other field to a Metadata key.
+func qdrantPayloadKey(field string) string {
+	if field == TextField {
+		return TextField
+	}
+	return "Metadata." + field
+}
+
+// qdrantFilter translates a Filter into a Qdrant filter object. AND, OR and
+// NOT map to must, should and must_not clauses, which Qdrant allows to nest.
+// CONTAINS becomes a text match, which Qdrant evaluates as a substring match
+// unless the payload key has a full-text index.
+func qdrantFilter(f *Filter) (map[string]interface{}, error) {
+	var clause string
+	children := f.Filters
+	switch f.Op {
+	case OpAnd:
+		clause = "must"
+	case OpOr:
+		clause = "should"
+	case OpNot:
+		clause = "must_not"
+	default:
+		clause = "must"
+		children = []*Filter{f}
+	}
+
+	conditions := make([]interface{}, len(children))
+	for i, child := range children {
+		condition, err := qdrantCondition(child)
+		if err != nil {
+			return nil, err
+		}
+		conditions[i] = condition
+	}
+	return map[string]interface{}{clause: conditions}, nil
+}
+
+// qdrantCondition translates a filter node into a Qdrant condition. Logical
+// nodes become nested filters.
+func qdrantCondition(f *Filter) (interface{}, error) {
+	key := qdrantPayloadKey(f.Field)
+	switch f.Op {
+	case OpAnd, OpOr, OpNot:
+		return qdrantFilter(f)
+	case OpEq:
+		return qdrantMatch(key, f.Value)
+	case OpNe:
+		match, err := qdrantMatch(key, f.Value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"must_not": []interface{}{match}}, nil
+	case OpLt, OpLte, OpGt, OpGte:
+		value, ok := toFloat(f.Value)
+		if !ok {
+			return nil, fmt.Errorf("qdrant range filters require a numeric value, got %T for %s", f.Value, f.Field)
+		}
+		op := map[FilterOp]string{OpLt: "lt", OpLte: "lte", OpGt: "gt", OpGte: "gte"}[f.Op]
+		return map[string]interface{}{"key": key, "range": map[string]interface{}{op: value}}, nil
+	case OpIn, OpNotIn:
+		values := filterValues(f.Value)
+		matches := make([]interface{}, len(values))
+		for i, v := range values {
+			match, err := qdrantMatch(key, v)
+			if err != nil {
+				return nil, err
+			}
+			matches[i] = match
+		}
+		if f.Op == OpNotIn {
+			return map[string]interface{}{"must_not": matches}, nil
+		}
+		if len(matches) == 0 {
+			// An empty IN list matches nothing
+			return map[string]interface{}{"has_id": []int64{}}, nil
+		}
+		return map[string]interface{}{"should": matches}, nil
+	case OpContains:
+		return map[string]interface{}{"key": key, "match": map[string]interface{}{"text": f.Value}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported filter operation for Qdrant: %q", f.Op)
+	}
+}
+
+// qdrantMatch builds an equality condition. Qdrant matches exact keyword,
+// integer and bool values; other numbers are compared with a closed range.
+func qdrantMatch(key string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string, bool:
+		return map[string]interface{}{"key": key, "match": map[string]interface{}{"value": v}}, nil
+	}
+	n, ok := toFloat(value)
+	if !ok {
+		return nil, fmt.Errorf("unsupported filter value type for Qdrant: %T", value)
+	}
+	if n == math.Trunc(n) && math.Abs(n) < 1<<63 {
+		return map[string]interface{}{"key": key, "match": map[string]interface{}{"value": int64(n)}}, nil
+	}
+	return map[string]interface{}{"key": key, "range": map[string]interface{}{"gte": n, "lte": n}}, nil
+}
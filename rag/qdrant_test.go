@@ -0,0 +1,413 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// qdrantInfo is the collection info of a "docs" collection with one
+// three-dimensional Euclid vector.
+const qdrantInfo = `{"result": {"status": "green", "points_count": 2, "config": {
+	"params": {"vectors": {"Embedding": {"size": 3, "distance": "Euclid"}}},
+	"hnsw_config": {"m": 16, "ef_construct": 100}}}}`
+
+// newTestQdrant returns a QdrantDB talking to a restServer.
+func newTestQdrant(t *testing.T, responses map[string][]restResponse) (*QdrantDB, *restServer) {
+	t.Helper()
+	server := newRESTServer(t, responses)
+	db, err := newQdrantDB(&Config{Address: server.URL, Parameters: map[string]interface{}{"api_key": "secret"}})
+	if err != nil {
+		t.Fatalf("newQdrantDB: %v", err)
+	}
+	return db, server
+}
+
+func TestQdrantRequests(t *testing.T) {
+	info := map[string][]restResponse{"GET /collections/docs": reply(qdrantInfo)}
+	infoRequest := restRequest{Method: http.MethodGet, Path: "/collections/docs"}
+
+	tests := []struct {
+		name      string
+		responses map[string][]restResponse
+		call      func(ctx context.Context, db *QdrantDB) error
+		want      []restRequest
+	}{
+		{
+			name: "create collection",
+			responses: map[string][]restResponse{
+				"GET /collections/docs": {{Status: http.StatusNotFound, Body: `{"status": {"error": "Not found: Collection docs doesn't exist!"}}`}},
+			},
+			call: func(ctx context.Context, db *QdrantDB) error {
+				return db.CreateCollection(ctx, "docs", Schema{Fields: []Field{
+					{Name: "ID", DataType: "int64", PrimaryKey: true},
+					{Name: "Embedding", DataType: "float_vector", Dimension: 3},
+					{Name: "Text", DataType: "varchar"},
+				}})
+			},
+			want: []restRequest{
+				infoRequest,
+				{Method: http.MethodPut, Path: "/collections/docs", Body: `{"vectors": {"Embedding": {"size": 3, "distance": "Euclid"}}}`},
+			},
+		},
+		{
+			name:      "upsert",
+			responses: info,
+			call: func(ctx context.Context, db *QdrantDB) error {
+				return db.Upsert(ctx, "docs", []Record{{Fields: map[string]interface{}{
+					"ID":        int64(7),
+					"Embedding": Vector{1, 2, 3},
+					"Text":      "hello",
+					"Metadata":  map[string]interface{}{"source": "a.txt"},
+				}}})
+			},
+			want: []restRequest{
+				infoRequest,
+				{Method: http.MethodPut, Path: "/collections/docs/points?wait=true", Body: `{"points": [{
+					"id": 7,
+					"vector": {"Embedding": [1, 2, 3]},
+					"payload": {"Text": "hello", "Metadata": {"source": "a.txt"}}}]}`},
+			},
+		},
+		{
+			name:      "upsert derives missing IDs",
+			responses: info,
+			call: func(ctx context.Context, db *QdrantDB) error {
+				return db.Upsert(ctx, "docs", []Record{
+					{Fields: map[string]interface{}{"Embedding": Vector{1, 2, 3}, "Metadata": map[string]interface{}{DocIDKey: "a#0"}}},
+					{Fields: map[string]interface{}{"Embedding": Vector{4, 5, 6}, "Text": "bare"}},
+				})
+			},
+			want: []restRequest{
+				infoRequest,
+				{Method: http.MethodPut, Path: "/collections/docs/points?wait=true", Body: fmt.Sprintf(`{"points": [
+					{"id": %d, "vector": {"Embedding": [1, 2, 3]}, "payload": {"Metadata": {"doc_id": "a#0"}}},
+					{"id": %d, "vector": {"Embedding": [4, 5, 6]}, "payload": {"Text": "bare"}}]}`,
+					IDFromDocID("a#0"), mustContentID(Record{Fields: map[string]interface{}{"Embedding": Vector{4, 5, 6}, "Text": "bare"}}))},
+			},
+		},
+		{
+			name: "delete",
+			call: func(ctx context.Context, db *QdrantDB) error {
+				return db.Delete(ctx, "docs", []int64{1, 2})
+			},
+			want: []restRequest{
+				{Method: http.MethodPost, Path: "/collections/docs/points/delete?wait=true", Body: `{"points": [1, 2]}`},
+			},
+		},
+		{
+			name: "delete by filter",
+			call: func(ctx context.Context, db *QdrantDB) error {
+				return db.DeleteByFilter(ctx, "docs", Eq("source", "a.txt"))
+			},
+			want: []restRequest{
+				{Method: http.MethodPost, Path: "/collections/docs/points/delete?wait=true", Body: `{"filter": {"must": [
+					{"key": "Metadata.source", "match": {"value": "a.txt"}}]}}`},
+			},
+		},
+		{
+			name:      "search",
+			responses: info,
+			call: func(ctx context.Context, db *QdrantDB) error {
+				_, err := db.Search(ctx, "docs", map[string]Vector{"Embedding": {1, 0, 0}}, 5, "L2",
+					map[string]interface{}{"ef": 64, FilterParam: Gt("page", 2)})
+				return err
+			},
+			want: []restRequest{
+				infoRequest,
+				{Method: http.MethodPost, Path: "/collections/docs/points/search", Body: `{
+					"vector": {"name": "Embedding", "vector": [1, 0, 0]},
+					"limit": 5,
+					"with_payload": true,
+					"params": {"hnsw_ef": 64},
+					"filter": {"must": [{"key": "Metadata.page", "range": {"gt": 2}}]}}`},
+			},
+		},
+		{
+			name: "search batch",
+			responses: map[string][]restResponse{
+				"GET /collections/docs":                      reply(qdrantInfo),
+				"POST /collections/docs/points/search/batch": reply(`{"result": [[], []]}`),
+			},
+			call: func(ctx context.Context, db *QdrantDB) error {
+				db.SetColumnNames([]string{"Text"})
+				_, err := db.SearchBatch(ctx, "docs", "Embedding", []Vector{{1, 0, 0}, {0, 1, 0}}, 2, "L2", nil)
+				return err
+			},
+			want: []restRequest{
+				infoRequest,
+				{Method: http.MethodPost, Path: "/collections/docs/points/search/batch", Body: `{"searches": [
+					{"vector": {"name": "Embedding", "vector": [1, 0, 0]}, "limit": 2, "with_payload": ["Text"]},
+					{"vector": {"name": "Embedding", "vector": [0, 1, 0]}, "limit": 2, "with_payload": ["Text"]}]}`},
+			},
+		},
+		{
+			name: "scan",
+			responses: map[string][]restResponse{
+				"POST /collections/docs/points/scroll": {
+					{Status: http.StatusOK, Body: `{"result": {"points": [{"id": 1, "payload": {}}], "next_page_offset": 2}}`},
+					{Status: http.StatusOK, Body: `{"result": {"points": [{"id": 2, "payload": {}}], "next_page_offset": null}}`},
+				},
+			},
+			call: func(ctx context.Context, db *QdrantDB) error {
+				return db.Scan(ctx, "docs", 1, func([]Record) error { return nil })
+			},
+			want: []restRequest{
+				{Method: http.MethodPost, Path: "/collections/docs/points/scroll", Body: `{"limit": 1, "with_payload": true, "with_vector": true}`},
+				{Method: http.MethodPost, Path: "/collections/docs/points/scroll", Body: `{"limit": 1, "with_payload": true, "with_vector": true, "offset": 2}`},
+			},
+		},
+		{
+			name:      "payload index",
+			responses: info,
+			call: func(ctx context.Context, db *QdrantDB) error {
+				return db.CreateIndex(ctx, "docs", "source", Index{Type: "KEYWORD"})
+			},
+			want: []restRequest{
+				infoRequest,
+				{Method: http.MethodPut, Path: "/collections/docs/index?wait=true", Body: `{"field_name": "Metadata.source", "field_schema": "keyword"}`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, server := newTestQdrant(t, tt.responses)
+			if err := tt.call(context.Background(), db); err != nil {
+				t.Fatalf("call failed: %v", err)
+			}
+			checkRequests(t, server.received(), tt.want)
+			if got := server.header.Get("api-key"); got != "secret" {
+				t.Errorf("api-key header = %q, want %q", got, "secret")
+			}
+		})
+	}
+}
+
+func TestQdrantSearchResults(t *testing.T) {
+	tests := []struct {
+		distance     string
+		metric       string
+		score        float64
+		wantScore    float64
+		wantDistance float64
+	}{
+		{distance: "Euclid", metric: "L2", score: 1, wantScore: 0.5, wantDistance: 1},
+		{distance: "Dot", metric: "IP", score: 3, wantScore: 3, wantDistance: -3},
+		{distance: "Cosine", metric: "COSINE", score: 0.8, wantScore: 0.8, wantDistance: 0.2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.distance, func(t *testing.T) {
+			db, _ := newTestQdrant(t, map[string][]restResponse{
+				"GET /collections/docs": reply(strings.Replace(qdrantInfo, "Euclid", tt.distance, 1)),
+				"POST /collections/docs/points/search": reply(`{"result": [{"id": 4, "score": ` + mustJSON(t, tt.score) + `,
+					"payload": {"Text": "hello", "Metadata": {"doc_id": "a.txt#0"}}}]}`),
+			})
+			results, err := db.Search(context.Background(), "docs", map[string]Vector{"Embedding": {1, 0, 0}}, 1, tt.metric, nil)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			r := results[0]
+			if r.ID != 4 || r.DocID != "a.txt#0" || r.Fields["Text"] != "hello" {
+				t.Errorf("result = %+v, want ID 4, DocID a.txt#0 and Text hello", r)
+			}
+			if math.Abs(r.Score-tt.wantScore) > 1e-9 || math.Abs(r.Distance-tt.wantDistance) > 1e-9 {
+				t.Errorf("score, distance = %v, %v, want %v, %v", r.Score, r.Distance, tt.wantScore, tt.wantDistance)
+			}
+		})
+	}
+}
+
+func TestQdrantSearchBatch(t *testing.T) {
+	ctx := context.Background()
+	queries := []Vector{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	db, _ := newTestQdrant(t, map[string][]restResponse{
+		"GET /collections/docs": reply(qdrantInfo),
+		"POST /collections/docs/points/search/batch": reply(`{"result": [
+			[{"id": 1, "score": 0, "payload": {"Text": "a"}}, {"id": 2, "score": 1, "payload": {"Text": "b"}}],
+			[],
+			[{"id": 3, "score": 3, "payload": {"Text": "c"}}]]}`),
+	})
+	batch, err := db.SearchBatch(ctx, "docs", "Embedding", queries, 2, "L2", nil)
+	if err != nil {
+		t.Fatalf("SearchBatch: %v", err)
+	}
+	want := [][]SearchResult{
+		{
+			{ID: 1, Score: 1, Distance: 0, Fields: map[string]interface{}{"Text": "a"}},
+			{ID: 2, Score: 0.5, Distance: 1, Fields: map[string]interface{}{"Text": "b"}},
+		},
+		{},
+		{{ID: 3, Score: 0.25, Distance: 3, Fields: map[string]interface{}{"Text": "c"}}},
+	}
+	if !reflect.DeepEqual(batch, want) {
+		t.Errorf("SearchBatch = %+v, want %+v", batch, want)
+	}
+
+	// No request is sent without queries
+	empty, server := newTestQdrant(t, nil)
+	if batch, err := empty.SearchBatch(ctx, "docs", "Embedding", nil, 2, "L2", nil); err != nil || len(batch) != 0 {
+		t.Errorf("SearchBatch without queries = %v, %v, want no result lists", batch, err)
+	}
+	if requests := server.received(); len(requests) != 0 {
+		t.Errorf("SearchBatch without queries sent %v", requests)
+	}
+}
+
+func TestQdrantErrors(t *testing.T) {
+	notFound := restResponse{Status: http.StatusNotFound, Body: `{"status": {"error": "Not found: Collection missing doesn't exist!"}}`}
+
+	tests := []struct {
+		name      string
+		responses map[string][]restResponse
+		call      func(ctx context.Context, db *QdrantDB) error
+		wantIs    error  // Sentinel the error must match, if any
+		wantText  string // Text the error must contain
+	}{
+		{
+			name:      "missing collection",
+			responses: map[string][]restResponse{"POST /collections/missing/points/count": {notFound}},
+			call: func(ctx context.Context, db *QdrantDB) error {
+				_, err := db.Count(ctx, "missing")
+				return err
+			},
+			wantIs:   ErrCollectionNotFound,
+			wantText: "Collection missing doesn't exist",
+		},
+		{
+			name:      "existing collection",
+			responses: map[string][]restResponse{"GET /collections/docs": reply(qdrantInfo)},
+			call: func(ctx context.Context, db *QdrantDB) error {
+				return db.CreateCollection(ctx, "docs", Schema{Fields: []Field{{Name: "Embedding", DataType: "float_vector", Dimension: 3}}})
+			},
+			wantIs: ErrCollectionExists,
+		},
+		{
+			name: "server error",
+			responses: map[string][]restResponse{
+				"POST /collections/docs/points/delete?wait=true": {{Status: http.StatusInternalServerError, Body: `{"status": {"error": "Service internal error: disk full"}}`}},
+			},
+			call: func(ctx context.Context, db *QdrantDB) error {
+				return db.Delete(ctx, "docs", []int64{1})
+			},
+			wantText: "qdrant returned HTTP 500: Service internal error: disk full",
+		},
+		{
+			name:      "metric mismatch",
+			responses: map[string][]restResponse{"GET /collections/docs": reply(qdrantInfo)},
+			call: func(ctx context.Context, db *QdrantDB) error {
+				_, err := db.Search(ctx, "docs", map[string]Vector{"Embedding": {1, 0, 0}}, 1, "COSINE", nil)
+				return err
+			},
+			wantText: "uses L2 and cannot be searched with COSINE",
+		},
+		{
+			name: "batch result count",
+			responses: map[string][]restResponse{
+				"GET /collections/docs":                      reply(qdrantInfo),
+				"POST /collections/docs/points/search/batch": reply(`{"result": [[]]}`),
+			},
+			call: func(ctx context.Context, db *QdrantDB) error {
+				_, err := db.SearchBatch(ctx, "docs", "Embedding", []Vector{{1, 0, 0}, {0, 1, 0}}, 1, "L2", nil)
+				return err
+			},
+			wantText: "qdrant returned 1 result lists for 2 queries",
+		},
+		{
+			name:      "batch metric mismatch",
+			responses: map[string][]restResponse{"GET /collections/docs": reply(qdrantInfo)},
+			call: func(ctx context.Context, db *QdrantDB) error {
+				_, err := db.SearchBatch(ctx, "docs", "Embedding", []Vector{{1, 0, 0}}, 1, "IP", nil)
+				return err
+			},
+			wantText: "uses L2 and cannot be searched with IP",
+		},
+		{
+			name:      "dimension mismatch",
+			responses: map[string][]restResponse{"GET /collections/docs": reply(qdrantInfo)},
+			call: func(ctx context.Context, db *QdrantDB) error {
+				return db.Insert(ctx, "docs", []Record{{Fields: map[string]interface{}{"ID": int64(1), "Embedding": Vector{1, 2}}}})
+			},
+			wantIs: ErrDimensionMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newTestQdrant(t, tt.responses)
+			err := tt.call(context.Background(), db)
+			if err == nil {
+				t.Fatal("call succeeded, want an error")
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("error %q does not match %v", err, tt.wantIs)
+			}
+			if !strings.Contains(err.Error(), tt.wantText) {
+				t.Errorf("error %q does not contain %q", err, tt.wantText)
+			}
+		})
+	}
+
+	t.Run("has collection", func(t *testing.T) {
+		db, _ := newTestQdrant(t, map[string][]restResponse{"GET /collections/missing": {notFound}})
+		exists, err := db.HasCollection(context.Background(), "missing")
+		if err != nil || exists {
+			t.Errorf("HasCollection = %v, %v, want false, nil", exists, err)
+		}
+	})
+}
+
+func TestQdrantFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *Filter
+		want    string
+		wantErr bool
+	}{
+		{name: "string equality", filter: Eq("source", "a.txt"), want: `{"must": [{"key": "Metadata.source", "match": {"value": "a.txt"}}]}`},
+		{name: "integer equality", filter: Eq("page", 3), want: `{"must": [{"key": "Metadata.page", "match": {"value": 3}}]}`},
+		{name: "float equality", filter: Eq("weight", 0.5), want: `{"must": [{"key": "Metadata.weight", "range": {"gte": 0.5, "lte": 0.5}}]}`},
+		{name: "bool equality", filter: Eq("draft", true), want: `{"must": [{"key": "Metadata.draft", "match": {"value": true}}]}`},
+		{name: "inequality", filter: Ne("lang", "fr"), want: `{"must": [{"must_not": [{"key": "Metadata.lang", "match": {"value": "fr"}}]}]}`},
+		{name: "range", filter: Lte("page", 10), want: `{"must": [{"key": "Metadata.page", "range": {"lte": 10}}]}`},
+		{name: "in", filter: In("lang", "en", "de"), want: `{"must": [{"should": [
+			{"key": "Metadata.lang", "match": {"value": "en"}},
+			{"key": "Metadata.lang", "match": {"value": "de"}}]}]}`},
+		{name: "empty in", filter: In("lang"), want: `{"must": [{"has_id": []}]}`},
+		{name: "not in", filter: NotIn("lang", "fr"), want: `{"must": [{"must_not": [{"key": "Metadata.lang", "match": {"value": "fr"}}]}]}`},
+		{name: "text contains", filter: Contains(TextField, "vector"), want: `{"must": [{"key": "Text", "match": {"text": "vector"}}]}`},
+		{
+			name:   "nested logic",
+			filter: And(Eq("lang", "en"), Or(Gt("page", 2), Not(Eq("draft", true)))),
+			want: `{"must": [
+				{"key": "Metadata.lang", "match": {"value": "en"}},
+				{"should": [
+					{"key": "Metadata.page", "range": {"gt": 2}},
+					{"must_not": [{"key": "Metadata.draft", "match": {"value": true}}]}]}]}`,
+		},
+		{name: "non-numeric range", filter: Gt("page", "two"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := qdrantFilter(tt.filter)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("qdrantFilter = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("qdrantFilter: %v", err)
+			}
+			if encoded := mustJSON(t, got); !jsonEqual(encoded, tt.want) {
+				t.Errorf("qdrantFilter =\n%s\nwant\n%s", encoded, tt.want)
+			}
+		})
+	}
+}
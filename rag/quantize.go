@@ -0,0 +1,267 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	// IndexTypeSQ8 is the Index.Type of a scalar quantized index, which keeps
+	// one int8 code per dimension: about 4x smaller than float32 vectors
+	IndexTypeSQ8 = "SQ8"
+	// IndexTypeBinary is the Index.Type of a binary quantized index, which
+	// keeps one sign bit per dimension: about 32x smaller than float32 vectors
+	IndexTypeBinary = "BINARY"
+
+	// defaultSQ8Oversample is the default ratio of SQ8 candidates to results
+	defaultSQ8Oversample = 3.0
+	// defaultBinaryOversample is the default ratio of binary candidates to
+	// results, higher than SQ8 as Hamming distances are much coarser
+	defaultBinaryOversample = 10.0
+)
+
+// isQuantizedIndex reports whether indexType names a quantized index.
+func isQuantizedIndex(indexType string) bool {
+	return indexType == IndexTypeSQ8 || indexType == IndexTypeBinary
+}
+
+// quantizedIndex holds compressed codes of the vectors of one field, one row
+// per record. A search scans the codes for the topK * oversample closest
+// candidates, which the owning store then rescores against the original
+// vectors. The codes serve every metric, as the rescoring uses the query's.
+//
+// SQ8 codes scale each vector by its largest absolute value into [-127, 127];
+// the scale and the original norm are kept per row so that inner products,
+// cosine and L2 distances can be estimated from an integer dot product.
+// BINARY codes keep the sign of each dimension and are compared by Hamming
+// distance.
+type quantizedIndex struct {
+	binary     bool      // BINARY rather than SQ8 codes
+	dim        int       // Vector dimension, 0 until the first vector is set
+	words      int       // uint64 words per binary code
+	oversample float64   // Default ratio of candidates to results
+	codes      []int8    // SQ8 codes, dim per row
+	bits       []uint64  // Binary codes, words per row
+	scales     []float32 // SQ8 scale of each row
+	norms      []float32 // SQ8 Euclidean norm of each original vector
+	present    []bool    // Whether each row holds a vector
+}
+
+// quantizedQuery is a query vector encoded like the rows of an index.
+type quantizedQuery struct {
+	codes []int8
+	bits  []uint64
+	scale float32
+	norm  float32
+}
+
+// newQuantizedIndex creates an empty index of the given type for vectors of
+// dimension dim (0 if not yet known). The "oversample" parameter sets the
+// default ratio of rescored candidates to results.
+func newQuantizedIndex(index Index, dim int) *quantizedIndex {
+	q := &quantizedIndex{binary: index.Type == IndexTypeBinary}
+	q.oversample = defaultSQ8Oversample
+	if q.binary {
+		q.oversample = defaultBinaryOversample
+	}
+	if v, ok := toFloat(index.Parameters["oversample"]); ok && v >= 1 {
+		q.oversample = v
+	}
+	q.setDim(dim)
+	return q
+}
+
+// setDim fixes the dimension of the codes. It must be called before any row
+// holds a vector.
+func (q *quantizedIndex) setDim(dim int) {
+	q.dim = dim
+	q.words = (dim + 63) / 64
+	rows := q.rows()
+	if q.binary {
+		q.bits = make([]uint64, rows*q.words)
+	} else {
+		q.codes = make([]int8, rows*dim)
+	}
+}
+
+// candidates returns the number of candidates to rescore for topK results,
+// honouring an "oversample" search parameter.
+func (q *quantizedIndex) candidates(topK int, searchParams map[string]interface{}) int {
+	oversample := q.oversample
+	if v, ok := toFloat(searchParams["oversample"]); ok && v >= 1 {
+		oversample = v
+	}
+	return int(math.Ceil(float64(topK) * oversample))
+}
+
+// rows returns the number of rows of the index.
+func (q *quantizedIndex) rows() int {
+	return len(q.present)
+}
+
+// grow appends absent rows until the index has n rows.
+func (q *quantizedIndex) grow(n int) {
+	for q.rows() < n {
+		if q.binary {
+			q.bits = append(q.bits, make([]uint64, q.words)...)
+		} else {
+			q.codes = append(q.codes, make([]int8, q.dim)...)
+			q.scales = append(q.scales, 0)
+			q.norms = append(q.norms, 0)
+		}
+		q.present = append(q.present, false)
+	}
+}
+
+// set encodes vector into row i. Vectors of another dimension leave the row
+// absent.
+func (q *quantizedIndex) set(i int, vector []float32) {
+	if q.dim == 0 && len(vector) > 0 {
+		q.setDim(len(vector))
+	}
+	if len(vector) != q.dim {
+		q.clear(i)
+		return
+	}
+	if q.binary {
+		encodeBinary(q.bits[i*q.words:(i+1)*q.words], vector)
+	} else {
+		q.scales[i], q.norms[i] = encodeSQ8(q.codes[i*q.dim:(i+1)*q.dim], vector)
+	}
+	q.present[i] = true
+}
+
+// clear marks row i as absent.
+func (q *quantizedIndex) clear(i int) {
+	if q.binary {
+		clear(q.bits[i*q.words : (i+1)*q.words])
+	} else {
+		clear(q.codes[i*q.dim : (i+1)*q.dim])
+		q.scales[i], q.norms[i] = 0, 0
+	}
+	q.present[i] = false
+}
+
+// move copies row src over row dst.
+func (q *quantizedIndex) move(dst, src int) {
+	if q.binary {
+		copy(q.bits[dst*q.words:(dst+1)*q.words], q.bits[src*q.words:(src+1)*q.words])
+	} else {
+		copy(q.codes[dst*q.dim:(dst+1)*q.dim], q.codes[src*q.dim:(src+1)*q.dim])
+		q.scales[dst], q.norms[dst] = q.scales[src], q.norms[src]
+	}
+	q.present[dst] = q.present[src]
+}
+
+// truncate keeps the first n rows.
+func (q *quantizedIndex) truncate(n int) {
+	if q.binary {
+		q.bits = q.bits[:n*q.words]
+	} else {
+		q.codes = q.codes[:n*q.dim]
+		q.scales = q.scales[:n]
+		q.norms = q.norms[:n]
+	}
+	q.present = q.present[:n]
+}
+
+// size returns the number of bytes held by the codes.
+func (q *quantizedIndex) size() int64 {
+	return int64(len(q.codes)) + int64(len(q.bits))*8 + int64(len(q.scales)+len(q.norms))*4 + int64(len(q.present))
+}
+
+// prepare encodes a query vector, or returns false when its dimension does
+// not match the index.
+func (q *quantizedIndex) prepare(vector []float32) (quantizedQuery, bool) {
+	if len(vector) != q.dim {
+		return quantizedQuery{}, false
+	}
+	var query quantizedQuery
+	if q.binary {
+		query.bits = make([]uint64, q.words)
+		encodeBinary(query.bits, vector)
+	} else {
+		query.codes = make([]int8, q.dim)
+		query.scale, query.norm = encodeSQ8(query.codes, vector)
+	}
+	return query, true
+}
+
+// estimate returns the approximate distance under metric between the query
+// and row i, and false when the row is absent. Binary indexes return the
+// Hamming distance whatever the metric.
+func (q *quantizedIndex) estimate(query quantizedQuery, i int, metric string) (float64, bool) {
+	if !q.present[i] {
+		return 0, false
+	}
+	if q.binary {
+		var distance int
+		row := q.bits[i*q.words : (i+1)*q.words]
+		for w, word := range query.bits {
+			distance += bits.OnesCount64(word ^ row[w])
+		}
+		return float64(distance), true
+	}
+
+	dot := float64(dotInt8(query.codes, q.codes[i*q.dim:(i+1)*q.dim])) * float64(query.scale) * float64(q.scales[i])
+	norm := float64(q.norms[i])
+	switch metric {
+	case MetricIP:
+		return -dot, true
+	case MetricCosine:
+		if norm == 0 || query.norm == 0 {
+			return 1, true
+		}
+		return 1 - dot/(float64(query.norm)*norm), true
+	default:
+		queryNorm := float64(query.norm)
+		return math.Sqrt(math.Max(0, queryNorm*queryNorm+norm*norm-2*dot)), true
+	}
+}
+
+// encodeSQ8 writes the int8 codes of vector into codes and returns the scale
+// that maps codes back to values and the Euclidean norm of vector.
+func encodeSQ8(codes []int8, vector []float32) (scale, norm float32) {
+	var maxAbs, sum float64
+	for _, v := range vector {
+		maxAbs = math.Max(maxAbs, math.Abs(float64(v)))
+		sum += float64(v) * float64(v)
+	}
+	if maxAbs == 0 {
+		clear(codes)
+		return 0, 0
+	}
+	scale = float32(maxAbs / 127)
+	for j, v := range vector {
+		codes[j] = int8(math.Round(float64(v) / float64(scale)))
+	}
+	return scale, float32(math.Sqrt(sum))
+}
+
+// encodeBinary writes the sign bits of vector into words.
+func encodeBinary(words []uint64, vector []float32) {
+	clear(words)
+	for j, v := range vector {
+		if v > 0 {
+			words[j/64] |= 1 << (j % 64)
+		}
+	}
+}
+
+// dotInt8 returns the inner product of two int8 code vectors of equal length.
+func dotInt8(a, b []int8) int32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 int32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += int32(a[i]) * int32(b[i])
+		s1 += int32(a[i+1]) * int32(b[i+1])
+		s2 += int32(a[i+2]) * int32(b[i+2])
+		s3 += int32(a[i+3]) * int32(b[i+3])
+	}
+	for ; i < len(a); i++ {
+		s0 += int32(a[i]) * int32(b[i])
+	}
+	return s0 + s1 + s2 + s3
+}
@@ -0,0 +1,96 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Factory creates a VectorDB from its configuration. It should not connect;
+// callers call Connect separately.
+type Factory func(cfg *Config) (VectorDB, error)
+
+// Capabilities describes the optional features a vector database backend
+// supports, so that callers can check for them before relying on them.
+type Capabilities struct {
+	// Hybrid reports whether HybridSearch is supported.
+	Hybrid bool
+	// KeywordSearch reports whether HybridSearch runs a keyword search itself
+	// on the text passed under QueryTextParam.
+	KeywordSearch bool
+	// Filters reports whether metadata filters (FilterParam) are applied
+	// by Search and HybridSearch.
+	Filters bool
+	// Delete reports whether Delete and DeleteByFilter are supported.
+	Delete bool
+	// Persistence reports whether the backend can keep data across process
+	// restarts. Some backends only do so when given an address.
+	Persistence bool
+}
+
+// backend is a registered vector database implementation.
+type backend struct {
+	factory      Factory
+	capabilities Capabilities
+}
+
+// backendRegistry is the thread-safe registry of vector database backends,
+// keyed by lowercase type name. Built-in backends register themselves in
+// their init functions.
+var backendRegistry = struct {
+	mu       sync.RWMutex
+	backends map[string]backend
+}{backends: make(map[string]backend)}
+
+// Register makes a vector database backend available to NewVectorDB under
+// dbType. Type names are case-insensitive. Registering an existing type
+// replaces it, which allows a built-in backend to be overridden.
+// It panics if factory is nil.
+func Register(dbType string, factory Factory, capabilities Capabilities) {
+	if factory == nil {
+		panic("rag: Register factory is nil for " + dbType)
+	}
+	backendRegistry.mu.Lock()
+	defer backendRegistry.mu.Unlock()
+	backendRegistry.backends[strings.ToLower(dbType)] = backend{factory: factory, capabilities: capabilities}
+}
+
+// RegisteredTypes returns the sorted names of all registered backends.
+func RegisteredTypes() []string {
+	backendRegistry.mu.RLock()
+	defer backendRegistry.mu.RUnlock()
+
+	types := make([]string, 0, len(backendRegistry.backends))
+	for dbType := range backendRegistry.backends {
+		types = append(types, dbType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// BackendCapabilities returns the capabilities declared by the backend
+// registered under dbType, and whether such a backend exists.
+func BackendCapabilities(dbType string) (Capabilities, bool) {
+	b, ok := lookupBackend(dbType)
+	return b.capabilities, ok
+}
+
+// lookupBackend returns the backend registered under dbType.
+func lookupBackend(dbType string) (backend, bool) {
+	backendRegistry.mu.RLock()
+	defer backendRegistry.mu.RUnlock()
+	b, ok := backendRegistry.backends[strings.ToLower(dbType)]
+	return b, ok
+}
+
+// NewVectorDB creates a new VectorDB instance with the backend registered
+// under cfg.Type.
+func NewVectorDB(cfg *Config) (VectorDB, error) {
+	b, ok := lookupBackend(cfg.Type)
+	if !ok {
+		return nil, fmt.Errorf("unsupported database type: %s (registered: %s)", cfg.Type, strings.Join(RegisteredTypes(), ", "))
+	}
+	return b.factory(cfg)
+}
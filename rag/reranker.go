@@ -95,6 +95,7 @@ func (r *RRFReranker) Rerank(
 	for id, score := range scores {
 		result := docMap[id]
 		result.Score = score
+		result.Distance = 0
 		results = append(results, result)
 	}
 
@@ -106,3 +107,59 @@ func (r *RRFReranker) Rerank(
 	// Return all reranked results
 	return results, nil
 }
+
+// fuse combines any number of equally weighted rankings with Reciprocal Rank
+// Fusion. It is used by backends that search several vector fields at once.
+// Ties are broken by ID so that the fused order is deterministic.
+func (r *RRFReranker) fuse(rankings ...[]SearchResult) []SearchResult {
+	scores := make(map[int64]float64)
+	docMap := make(map[int64]SearchResult)
+	for _, ranking := range rankings {
+		for rank, result := range ranking {
+			scores[result.ID] += 1.0 / (float64(rank+1) + r.k)
+			if _, exists := docMap[result.ID]; !exists {
+				docMap[result.ID] = result
+			}
+		}
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		result := docMap[id]
+		result.Score = score
+		result.Distance = 0
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	return results
+}
+
+// WeightedReranker combines dense and sparse results with a weighted sum of
+// their scores. Unlike RRF, it depends on the scale of each retriever's
+// scores, so it is mainly useful for backends that compute the combined
+// score natively, such as Elasticsearch and OpenSearch.
+type WeightedReranker struct {
+	denseWeight  float64 // Weight of the vector similarity scores
+	sparseWeight float64 // Weight of the keyword (BM25) scores
+}
+
+// NewWeightedReranker creates a reranker that weights dense and sparse
+// scores. The weights are normalized to sum to 1.0; if both are <= 0, they
+// default to 0.5 each.
+func NewWeightedReranker(denseWeight, sparseWeight float64) *WeightedReranker {
+	return &WeightedReranker{denseWeight: denseWeight, sparseWeight: sparseWeight}
+}
+
+// normalizedWeights returns the dense and sparse weights scaled to sum to 1.0.
+func (w *WeightedReranker) normalizedWeights() (float64, float64) {
+	total := w.denseWeight + w.sparseWeight
+	if w.denseWeight < 0 || w.sparseWeight < 0 || total <= 0 {
+		return 0.5, 0.5
+	}
+	return w.denseWeight / total, w.sparseWeight / total
+}
@@ -0,0 +1,150 @@
+package rag
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// restRequest is a request received by a restServer.
+type restRequest struct {
+	Method string
+	Path   string // Path and query, e.g. /collections/docs/points?wait=true
+	Body   string
+}
+
+// restResponse is a canned answer of a restServer.
+type restResponse struct {
+	Status int
+	Body   string
+}
+
+// restServer is an httptest server standing in for a REST vector database.
+// It records every request and answers from canned responses keyed by
+// "METHOD path?query"; a key's responses are used in turn, the last one
+// repeating. Requests without a response get 200 and an empty JSON object.
+type restServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	requests  []restRequest
+	responses map[string][]restResponse
+	header    http.Header // Headers of the last request
+}
+
+// newRESTServer starts a restServer that is closed when the test ends.
+func newRESTServer(t *testing.T, responses map[string][]restResponse) *restServer {
+	t.Helper()
+	s := &restServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *restServer) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	key := r.Method + " " + r.URL.RequestURI()
+
+	s.mu.Lock()
+	s.requests = append(s.requests, restRequest{Method: r.Method, Path: r.URL.RequestURI(), Body: string(body)})
+	s.header = r.Header.Clone()
+	response := restResponse{Status: http.StatusOK, Body: "{}"}
+	if queue := s.responses[key]; len(queue) > 0 {
+		response = queue[0]
+		if len(queue) > 1 {
+			s.responses[key] = queue[1:]
+		}
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.Status)
+	io.WriteString(w, response.Body)
+}
+
+// received returns the requests received so far.
+func (s *restServer) received() []restRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]restRequest(nil), s.requests...)
+}
+
+// reply returns a single 200 response with body.
+func reply(body string) []restResponse {
+	return []restResponse{{Status: http.StatusOK, Body: body}}
+}
+
+// checkRequests compares the requests a server received with want. Bodies
+// are compared as JSON, or as NDJSON streams, so formatting and key order do
+// not matter; an empty body must be empty.
+func checkRequests(t *testing.T, got, want []restRequest) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d requests, want %d:\n got %v\nwant %v", len(got), len(want), got, want)
+	}
+	for i := range want {
+		if got[i].Method != want[i].Method || got[i].Path != want[i].Path {
+			t.Errorf("request %d = %s %s, want %s %s", i, got[i].Method, got[i].Path, want[i].Method, want[i].Path)
+			continue
+		}
+		if !jsonEqual(got[i].Body, want[i].Body) {
+			t.Errorf("request %d (%s %s) body =\n%s\nwant\n%s", i, want[i].Method, want[i].Path, got[i].Body, want[i].Body)
+		}
+	}
+}
+
+// jsonEqual reports whether two JSON documents, or streams of documents such
+// as NDJSON bodies, hold the same values. Empty documents are only equal to
+// each other.
+func jsonEqual(a, b string) bool {
+	if a == "" || b == "" {
+		return a == b
+	}
+	va, errA := jsonValues(a)
+	vb, errB := jsonValues(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// jsonValues decodes every JSON value of a stream.
+func jsonValues(s string) ([]interface{}, error) {
+	var values []interface{}
+	decoder := json.NewDecoder(strings.NewReader(s))
+	for {
+		var v interface{}
+		err := decoder.Decode(&v)
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+}
+
+// mustJSON encodes v, failing the test on error.
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return string(encoded)
+}
+
+// mustContentID returns the ContentID of a record, panicking if it has none.
+func mustContentID(record Record) int64 {
+	id, err := ContentID(record)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
@@ -2,13 +2,22 @@
 package rag
 
 import (
+	"bufio"
 	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
 	"math"
 	"sort"
-	"strings"
 	"sync"
 )
 
+// bm25FormatVersion is the version of the format written by BM25Index.Save.
+// Version 1 stored metadata as gob values, which failed on nested values;
+// version 2 stores it as JSON. Load reads both.
+const bm25FormatVersion = 2
+
 // BM25Parameters holds the parameters for BM25 scoring algorithm.
 // BM25 (Best Match 25) is a probabilistic ranking function that estimates
 // the relevance of documents to a given search query based on term frequency,
@@ -32,35 +41,52 @@ func DefaultBM25Parameters() BM25Parameters {
 
 // BM25Index implements a sparse retrieval index using the BM25 ranking algorithm.
 // It provides thread-safe document indexing and retrieval with the following features:
-// - Efficient term-based document scoring
-// - Document length normalization
+// - Inverted index with one posting list per term
+// - Document length normalization from a running total length
 // - Configurable text preprocessing
 // - Metadata storage and retrieval
+// - Persistence through Save and Load
 // - Thread-safe operations
+//
+// Add and Remove cost O(terms in the document) and Search costs
+// O(postings of the query terms), independently of the collection size.
 type BM25Index struct {
-	mu            sync.RWMutex                        // Protects concurrent access to index
-	docs          map[int64]string                    // Stores original document content
-	metadata      map[int64]map[string]interface{}    // Stores document metadata
-	termFreq      map[int64]map[string]int           // Term frequency per document
-	docFreq       map[string]int                      // Document frequency per term
-	docLength     map[int64]int                       // Length of each document
-	avgDocLength  float64                             // Average document length
-	totalDocs     int                                 // Total number of documents
-	params        BM25Parameters                      // BM25 scoring parameters
-	preprocessor  func(string) []string               // Text preprocessing function
+	mu           sync.RWMutex                     // Protects concurrent access to index
+	docs         map[int64]string                 // Stores original document content
+	metadata     map[int64]map[string]interface{} // Stores document metadata
+	postings     map[string]map[int64]int         // Posting list per term: document ID to term frequency
+	docTerms     map[int64][]string               // Distinct terms of each document, to update postings on removal
+	docLength    map[int64]int                    // Length of each document
+	totalLength  int                              // Sum of all document lengths
+	params       BM25Parameters                   // BM25 scoring parameters
+	preprocessor func(string) []string            // Text preprocessing function
+}
+
+// bm25Snapshot is the serialised form of a BM25Index. Posting lists are
+// stored as-is so that loading does not re-tokenise the corpus. Metadata is
+// stored as JSON, as gob cannot encode the nested maps and lists of
+// interface{} values that metadata read back from a database holds.
+type bm25Snapshot struct {
+	Version      int
+	Params       BM25Parameters
+	Docs         map[int64]string
+	Metadata     map[int64]map[string]interface{} // Version 1 only
+	MetadataJSON map[int64][]byte
+	DocLength    map[int64]int
+	Postings     map[string]map[int64]int
 }
 
 // NewBM25Index creates a new BM25 index with default parameters.
 // The index is initialized with:
 // - Default BM25 parameters (K1=1.5, B=0.75)
-// - Basic preprocessor (lowercase, whitespace tokenization)
+// - Basic preprocessor (Unicode word tokenization, lowercase)
 // - Empty document store and statistics
 func NewBM25Index() *BM25Index {
 	return &BM25Index{
 		docs:         make(map[int64]string),
 		metadata:     make(map[int64]map[string]interface{}),
-		termFreq:     make(map[int64]map[string]int),
-		docFreq:      make(map[string]int),
+		postings:     make(map[string]map[int64]int),
+		docTerms:     make(map[int64][]string),
 		docLength:    make(map[int64]int),
 		params:       DefaultBM25Parameters(),
 		preprocessor: defaultPreprocessor,
@@ -68,19 +94,19 @@ func NewBM25Index() *BM25Index {
 }
 
 // defaultPreprocessor implements basic text preprocessing by:
-// 1. Converting text to lowercase
-// 2. Splitting on whitespace
+// 1. Segmenting text into Unicode words, dropping punctuation
+// 2. Converting words to lowercase
 // Users can replace this with custom preprocessing via SetPreprocessor
+// or a language-specific Analyzer via SetAnalyzer
 func defaultPreprocessor(text string) []string {
-	// Convert to lowercase and split into words
-	words := strings.Fields(strings.ToLower(text))
-	return words
+	return LowercaseFilter(UnicodeTokenizer(text))
 }
 
 // Add indexes a new document with the given ID, content, and metadata.
 // This operation is thread-safe and automatically updates all relevant
-// index statistics including term frequencies, document lengths, and
-// collection-wide averages.
+// index statistics including posting lists, document lengths, and the
+// running total length. Adding an ID that is already indexed replaces
+// the previous document.
 //
 // Parameters:
 //   - ctx: Context for potential future extensions
@@ -90,88 +116,131 @@ func defaultPreprocessor(text string) []string {
 //
 // Returns error if the operation fails (currently always nil).
 func (idx *BM25Index) Add(ctx context.Context, id int64, content string, metadata map[string]interface{}) error {
+	// Tokenise outside the lock; the preprocessor may be expensive.
+	idx.mu.RLock()
+	preprocessor := idx.preprocessor
+	idx.mu.RUnlock()
+	terms := preprocessor(content)
+
+	termFreq := make(map[string]int)
+	for _, term := range terms {
+		termFreq[term]++
+	}
+
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
+	// Replace any previous version of the document
+	if _, exists := idx.docs[id]; exists {
+		idx.remove(id)
+	}
+
 	// Store document and metadata
 	idx.docs[id] = content
 	idx.metadata[id] = metadata
 
-	// Process terms
-	terms := idx.preprocessor(content)
-	termFreq := make(map[string]int)
-	for _, term := range terms {
-		termFreq[term]++
-	}
-
-	// Update index statistics
-	idx.termFreq[id] = termFreq
-	idx.docLength[id] = len(terms)
-	for term := range termFreq {
-		idx.docFreq[term]++
+	// Update posting lists
+	distinct := make([]string, 0, len(termFreq))
+	for term, tf := range termFreq {
+		posting, ok := idx.postings[term]
+		if !ok {
+			posting = make(map[int64]int)
+			idx.postings[term] = posting
+		}
+		posting[id] = tf
+		distinct = append(distinct, term)
 	}
+	idx.docTerms[id] = distinct
 
 	// Update collection statistics
-	idx.totalDocs++
-	var totalLength int
-	for _, length := range idx.docLength {
-		totalLength += length
-	}
-	idx.avgDocLength = float64(totalLength) / float64(idx.totalDocs)
+	idx.docLength[id] = len(terms)
+	idx.totalLength += len(terms)
 
 	return nil
 }
 
 // Remove deletes a document from the index and updates all relevant statistics.
 // This operation is thread-safe and maintains index consistency by:
-// - Updating document frequencies
+// - Removing the document from the posting list of each of its terms
 // - Removing document data
-// - Recalculating collection statistics
+// - Updating the running total length
 //
 // Parameters:
 //   - ctx: Context for potential future extensions
 //   - id: ID of document to remove
 //
 // Returns error if the operation fails (currently always nil).
+// Removing an ID that is not indexed is a no-op.
 func (idx *BM25Index) Remove(ctx context.Context, id int64) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	// Update document frequencies
-	if termFreq, exists := idx.termFreq[id]; exists {
-		for term := range termFreq {
-			idx.docFreq[term]--
-			if idx.docFreq[term] == 0 {
-				delete(idx.docFreq, term)
-			}
+	if _, exists := idx.docs[id]; exists {
+		idx.remove(id)
+	}
+	return nil
+}
+
+// RemoveByFilter removes every document whose metadata matches filter, with
+// the semantics of Filter.Matches, and returns how many were removed. It
+// mirrors VectorDB.DeleteByFilter so that a keyword index can follow the
+// deletions of the collection it indexes. A nil filter is rejected.
+func (idx *BM25Index) RemoveByFilter(ctx context.Context, filter *Filter) (int, error) {
+	if filter == nil {
+		return 0, fmt.Errorf("delete filter must not be empty")
+	}
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	removed := 0
+	for id, content := range idx.docs {
+		record := Record{Fields: map[string]interface{}{
+			"Text":     content,
+			"Metadata": idx.metadata[id],
+		}}
+		if filter.Matches(record) {
+			idx.remove(id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// remove deletes an indexed document. The caller must hold the write lock.
+func (idx *BM25Index) remove(id int64) {
+	for _, term := range idx.docTerms[id] {
+		posting := idx.postings[term]
+		delete(posting, id)
+		if len(posting) == 0 {
+			delete(idx.postings, term)
 		}
 	}
 
-	// Remove document data
+	idx.totalLength -= idx.docLength[id]
+
 	delete(idx.docs, id)
 	delete(idx.metadata, id)
-	delete(idx.termFreq, id)
+	delete(idx.docTerms, id)
 	delete(idx.docLength, id)
+}
 
-	// Update collection statistics
-	idx.totalDocs--
-	if idx.totalDocs > 0 {
-		var totalLength int
-		for _, length := range idx.docLength {
-			totalLength += length
-		}
-		idx.avgDocLength = float64(totalLength) / float64(idx.totalDocs)
-	} else {
-		idx.avgDocLength = 0
-	}
-
-	return nil
+// Len returns the number of documents in the index.
+func (idx *BM25Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
 }
 
 // Search performs BM25-based retrieval on the index.
 // The BM25 score for a document D and query Q is calculated as:
 // score(D,Q) = Σ IDF(qi) * (f(qi,D) * (k1 + 1)) / (f(qi,D) + k1 * (1 - b + b * |D|/avgdl))
 //
+// Only the posting lists of the query terms are visited.
+//
 // Parameters:
 //   - ctx: Context for potential future extensions
 //   - query: Search query text
@@ -184,42 +253,55 @@ func (idx *BM25Index) Search(ctx context.Context, query string, topK int) ([]Sea
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
+	totalDocs := len(idx.docs)
+	if totalDocs == 0 {
+		return []SearchResult{}, nil
+	}
+	avgDocLength := float64(idx.totalLength) / float64(totalDocs)
+
 	// Process query terms
 	queryTerms := idx.preprocessor(query)
 	scores := make(map[int64]float64)
 
 	// Calculate BM25 scores
 	for _, term := range queryTerms {
-		if df, exists := idx.docFreq[term]; exists {
-			idf := math.Log(1 + (float64(idx.totalDocs)-float64(df)+0.5)/(float64(df)+0.5))
-
-			for docID, docTerms := range idx.termFreq {
-				if tf, exists := docTerms[term]; exists {
-					docLen := float64(idx.docLength[docID])
-					numerator := float64(tf) * (idx.params.K1 + 1)
-					denominator := float64(tf) + idx.params.K1*(1-idx.params.B+idx.params.B*docLen/idx.avgDocLength)
-					scores[docID] += idf * numerator / denominator
-				}
-			}
+		posting, exists := idx.postings[term]
+		if !exists {
+			continue
+		}
+		df := float64(len(posting))
+		idf := math.Log(1 + (float64(totalDocs)-df+0.5)/(df+0.5))
+
+		for docID, tf := range posting {
+			docLen := float64(idx.docLength[docID])
+			numerator := float64(tf) * (idx.params.K1 + 1)
+			denominator := float64(tf) + idx.params.K1*(1-idx.params.B+idx.params.B*docLen/avgDocLength)
+			scores[docID] += idf * numerator / denominator
 		}
 	}
 
 	// Convert scores to results
 	results := make([]SearchResult, 0, len(scores))
 	for docID, score := range scores {
+		metadata := idx.metadata[docID]
+		stableID, _ := metadata[DocIDKey].(string)
 		results = append(results, SearchResult{
-			ID:     docID,
-			Score:  score,
+			ID:    docID,
+			DocID: stableID,
+			Score: score,
 			Fields: map[string]interface{}{
 				"Text":     idx.docs[docID],
-				"Metadata": idx.metadata[docID],
+				"Metadata": metadata,
 			},
 		})
 	}
 
-	// Sort by score
+	// Sort by score, breaking ties by ID for a stable order
 	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
 	})
 
 	// Return top K results
@@ -230,6 +312,115 @@ func (idx *BM25Index) Search(ctx context.Context, query string, topK int) ([]Sea
 	return results, nil
 }
 
+// Save writes the index, including its documents, metadata, posting lists
+// and BM25 parameters, to w. The preprocessor is not saved; set the same
+// preprocessor on the index before calling Load. Metadata is saved as JSON,
+// so numbers are loaded back as float64 and other values as the types
+// encoding/json decodes them to.
+//
+// Example:
+//
+//	f, _ := os.Create("data/bm25.gob")
+//	defer f.Close()
+//	err := idx.Save(f)
+func (idx *BM25Index) Save(w io.Writer) error {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	metadata := make(map[int64][]byte, len(idx.metadata))
+	for id, m := range idx.metadata {
+		encoded, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("failed to encode metadata of document %d: %w", id, err)
+		}
+		metadata[id] = encoded
+	}
+
+	writer := bufio.NewWriter(w)
+	snapshot := bm25Snapshot{
+		Version:      bm25FormatVersion,
+		Params:       idx.params,
+		Docs:         idx.docs,
+		MetadataJSON: metadata,
+		DocLength:    idx.docLength,
+		Postings:     idx.postings,
+	}
+	if err := gob.NewEncoder(writer).Encode(snapshot); err != nil {
+		return fmt.Errorf("failed to encode BM25 index: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write BM25 index: %w", err)
+	}
+	return nil
+}
+
+// Load replaces the contents of the index with an index previously written
+// by Save. Documents are not re-tokenised, so the index must use the same
+// preprocessor as the one that built it.
+//
+// Example:
+//
+//	idx := rag.NewBM25Index()
+//	f, _ := os.Open("data/bm25.gob")
+//	defer f.Close()
+//	err := idx.Load(f)
+func (idx *BM25Index) Load(r io.Reader) error {
+	var snapshot bm25Snapshot
+	if err := gob.NewDecoder(bufio.NewReader(r)).Decode(&snapshot); err != nil {
+		return fmt.Errorf("failed to decode BM25 index: %w", err)
+	}
+	if snapshot.Version < 1 || snapshot.Version > bm25FormatVersion {
+		return fmt.Errorf("unsupported BM25 index format version %d", snapshot.Version)
+	}
+
+	docs := snapshot.Docs
+	if docs == nil {
+		docs = make(map[int64]string)
+	}
+	metadata := snapshot.Metadata
+	if metadata == nil {
+		metadata = make(map[int64]map[string]interface{}, len(snapshot.MetadataJSON))
+	}
+	for id, encoded := range snapshot.MetadataJSON {
+		var m map[string]interface{}
+		if err := json.Unmarshal(encoded, &m); err != nil {
+			return fmt.Errorf("failed to decode metadata of document %d: %w", id, err)
+		}
+		metadata[id] = m
+	}
+	docLength := snapshot.DocLength
+	if docLength == nil {
+		docLength = make(map[int64]int)
+	}
+	postings := snapshot.Postings
+	if postings == nil {
+		postings = make(map[string]map[int64]int)
+	}
+
+	// Rebuild the derived per-document term lists and total length.
+	docTerms := make(map[int64][]string, len(docs))
+	for term, posting := range postings {
+		for id := range posting {
+			docTerms[id] = append(docTerms[id], term)
+		}
+	}
+	var totalLength int
+	for _, length := range docLength {
+		totalLength += length
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.docs = docs
+	idx.metadata = metadata
+	idx.docLength = docLength
+	idx.postings = postings
+	idx.docTerms = docTerms
+	idx.totalLength = totalLength
+	idx.params = snapshot.Params
+	return nil
+}
+
 // SetParameters updates the BM25 scoring parameters.
 // This operation is thread-safe and affects all subsequent searches.
 // Typical values:
@@ -248,8 +439,22 @@ func (idx *BM25Index) SetParameters(params BM25Parameters) {
 // - Stemming/lemmatization
 // - N-gram generation
 // - Special character handling
+//
+// See Analyzer for a composable implementation of these steps.
 func (idx *BM25Index) SetPreprocessor(preprocessor func(string) []string) {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 	idx.preprocessor = preprocessor
 }
+
+// SetAnalyzer sets an Analyzer as the text preprocessing function.
+// Documents already indexed are not re-analyzed, so set the analyzer
+// before adding documents.
+//
+// Example:
+//
+//	idx := rag.NewBM25Index()
+//	idx.SetAnalyzer(rag.NewFrenchAnalyzer())
+func (idx *BM25Index) SetAnalyzer(analyzer *Analyzer) {
+	idx.SetPreprocessor(analyzer.Analyze)
+}
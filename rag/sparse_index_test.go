@@ -0,0 +1,108 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBM25SaveLoad(t *testing.T) {
+	ctx := context.Background()
+	idx := NewBM25Index()
+	idx.SetParameters(BM25Parameters{K1: 1.2, B: 0.5})
+	docs := []struct {
+		id       int64
+		text     string
+		metadata map[string]interface{}
+	}{
+		{1, "The quick brown fox", map[string]interface{}{
+			"source": "a.txt",
+			"chunk":  0,
+			"tags":   []interface{}{"animal", "fast"},
+			"author": map[string]interface{}{"name": "Ada", "ids": []interface{}{1, 2}},
+		}},
+		{2, "A lazy brown dog sleeps", map[string]interface{}{"source": "b.txt", "chunk": 1, "draft": true}},
+		{3, "Foxes and dogs", nil},
+	}
+	for _, doc := range docs {
+		if err := idx.Add(ctx, doc.id, doc.text, doc.metadata); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := idx.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded := NewBM25Index()
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	for _, query := range []string{"brown fox", "dog", "foxes"} {
+		want, _ := idx.Search(ctx, query, 10)
+		got, _ := loaded.Search(ctx, query, 10)
+		if len(got) != len(want) {
+			t.Fatalf("Search(%q) after Load returned %d results, want %d", query, len(got), len(want))
+		}
+		for i := range want {
+			if got[i].ID != want[i].ID || got[i].Score != want[i].Score || got[i].Fields["Text"] != want[i].Fields["Text"] {
+				t.Errorf("Search(%q) result %d after Load = %d %v, want %d %v", query, i, got[i].ID, got[i].Score, want[i].ID, want[i].Score)
+			}
+		}
+	}
+
+	// Metadata comes back as JSON decodes it
+	results, _ := loaded.Search(ctx, "quick", 1)
+	wantMetadata := map[string]interface{}{
+		"source": "a.txt",
+		"chunk":  0.0,
+		"tags":   []interface{}{"animal", "fast"},
+		"author": map[string]interface{}{"name": "Ada", "ids": []interface{}{1.0, 2.0}},
+	}
+	if len(results) != 1 || !reflect.DeepEqual(results[0].Fields["Metadata"], wantMetadata) {
+		t.Errorf("metadata after Load = %v, want %v", results, wantMetadata)
+	}
+	if n, err := loaded.RemoveByFilter(ctx, Eq("chunk", 1)); err != nil || n != 1 {
+		t.Errorf("RemoveByFilter after Load removed %d, %v, want 1, nil", n, err)
+	}
+	if results, _ := loaded.Search(ctx, "brown dogs", 10); len(results) != 2 || results[0].ID == 2 || results[1].ID == 2 {
+		t.Errorf("Search after removal = %v, want documents 1 and 3", results)
+	}
+}
+
+func TestBM25LoadVersions(t *testing.T) {
+	encode := func(snapshot bm25Snapshot) *bytes.Buffer {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(snapshot); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		return &buf
+	}
+
+	// Version 1 stored flat metadata as gob values
+	v1 := encode(bm25Snapshot{
+		Version:   1,
+		Params:    DefaultBM25Parameters(),
+		Docs:      map[int64]string{7: "hello world"},
+		Metadata:  map[int64]map[string]interface{}{7: {"source": "old.txt"}},
+		DocLength: map[int64]int{7: 2},
+		Postings:  map[string]map[int64]int{"hello": {7: 1}, "world": {7: 1}},
+	})
+	idx := NewBM25Index()
+	if err := idx.Load(v1); err != nil {
+		t.Fatalf("Load of version 1: %v", err)
+	}
+	results, _ := idx.Search(context.Background(), "hello", 1)
+	if len(results) != 1 || results[0].ID != 7 || !reflect.DeepEqual(results[0].Fields["Metadata"], map[string]interface{}{"source": "old.txt"}) {
+		t.Errorf("Search after loading version 1 = %v", results)
+	}
+
+	err := NewBM25Index().Load(encode(bm25Snapshot{Version: bm25FormatVersion + 1}))
+	if err == nil || !strings.Contains(err.Error(), "unsupported BM25 index format version") {
+		t.Errorf("Load of a future version: error = %v", err)
+	}
+}
@@ -0,0 +1,1659 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver, registered as "sqlite"
+)
+
+const (
+	// sqliteCatalogTable stores the schema and indexes of every collection
+	sqliteCatalogTable = "raggo_collections"
+	// sqliteBusyTimeout is how long, in milliseconds, a connection waits for
+	// the write lock held by another connection or process
+	sqliteBusyTimeout = 5000
+)
+
+// SQLiteDB implements the VectorDB interface on an embedded SQLite file,
+// using a pure-Go driver so that no cgo or external server is needed. Each
+// collection is a table:
+//   - float_vector fields are BLOBs of little-endian float32 values
+//   - json fields (Metadata) are JSON text, filtered with SQLite's JSON functions
+//   - the int64 primary key is the table's INTEGER PRIMARY KEY, derived from
+//     the document ID (see DocIDKey) or assigned by SQLite when a record has
+//     no ID
+//
+// The schema and indexes of each collection are kept in a catalog table, so
+// the file is self-describing. Search scans the vectors by default; an HNSW
+// graph or quantized codes built with CreateIndex are kept in memory, rebuilt
+// on Connect and updated by the writes made through this instance. The
+// database runs in WAL
+// mode, so other processes can read it while it is being written.
+type SQLiteDB struct {
+	db          *sql.DB                      // Connection pool
+	path        string                       // Database file
+	syncWrites  bool                         // Whether commits are fsynced (synchronous=FULL)
+	columnNames []string                     // Columns to retrieve in search results
+	collections map[string]*sqliteCollection // Catalog entries, loaded on Connect
+	mu          sync.RWMutex                 // Protects collections and their indexes
+}
+
+// sqliteCollection is the catalog entry of a collection.
+type sqliteCollection struct {
+	schema     Schema
+	primaryKey string
+	indexes    map[string]Index            // HNSW and quantized index definitions per vector field
+	graphs     map[string]*sqliteGraph     // In-memory HNSW graphs per vector field
+	quantized  map[string]*sqliteQuantized // In-memory quantized codes per vector field
+}
+
+// sqliteGraph is an HNSW graph over one vector column, with the node of each
+// ID. The graph reads its vectors from a float32 copy of the column, one row
+// per node, with the ID of each row.
+type sqliteGraph struct {
+	graph   *hnswGraph
+	nodes   map[int64]int32
+	vectors *vectorColumn
+	ids     []int64
+}
+
+// add indexes the vector of row id. Rows are appended and never reused, so
+// tombstoned nodes keep their vectors until the graph is rebuilt.
+func (g *sqliteGraph) add(id int64, vector Vector) {
+	if len(vector) != g.vectors.Dim {
+		return
+	}
+	row := g.vectors.rows()
+	g.vectors.grow(row + 1)
+	g.vectors.set(row, vector)
+	g.ids = append(g.ids, id)
+	g.nodes[id] = g.graph.add(g.vectors, row)
+}
+
+// sqliteQuantized holds the quantized codes of one vector column, with the
+// row of each ID. Only the codes are kept in memory; candidates are rescored
+// against the float32 vectors read back from the file.
+type sqliteQuantized struct {
+	index *quantizedIndex
+	rows  map[int64]int
+	ids   []int64
+}
+
+// sqliteCandidate is a row and its distance to the query.
+type sqliteCandidate struct {
+	id       int64
+	distance float64
+}
+
+// field returns the named field of the collection's schema.
+func (c *sqliteCollection) field(name string) (Field, bool) {
+	for _, f := range c.schema.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+func init() {
+	Register("sqlite", func(cfg *Config) (VectorDB, error) { return newSQLiteDB(cfg) }, Capabilities{Hybrid: true, Filters: true, Delete: true, Persistence: true})
+}
+
+// newSQLiteDB creates a new SQLiteDB instance with the given configuration.
+// Note: This doesn't open the database - call Connect() separately.
+//
+// cfg.Address is the path of the database file, created if missing. Supported
+// parameters:
+// - "sync_writes" (bool): fsync every commit rather than at checkpoints (default false)
+func newSQLiteDB(cfg *Config) (*SQLiteDB, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("sqlite backend requires an address naming the database file")
+	}
+	s := &SQLiteDB{
+		path:        cfg.Address,
+		collections: make(map[string]*sqliteCollection),
+	}
+	if syncWrites, ok := cfg.Parameters["sync_writes"].(bool); ok {
+		s.syncWrites = syncWrites
+	}
+	return s, nil
+}
+
+// Connect opens the database file, creates the catalog if needed and loads
+// the collections, building the HNSW graph of every indexed vector field.
+func (s *SQLiteDB) Connect(ctx context.Context) error {
+	GlobalLogger.Debug("Opening SQLite database", "path", s.path)
+
+	synchronous := "NORMAL"
+	if s.syncWrites {
+		synchronous = "FULL"
+	}
+	dsn := s.path
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	// Pragmas in the DSN apply to every connection of the pool
+	dsn += fmt.Sprintf("%s_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)&_pragma=synchronous(%s)",
+		separator, sqliteBusyTimeout, synchronous)
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open SQLite database %s: %w", s.path, err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to open SQLite database %s: %w", s.path, err)
+	}
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		name TEXT PRIMARY KEY,
+		schema TEXT NOT NULL,
+		indexes TEXT NOT NULL DEFAULT '{}'
+	)`, sqliteCatalogTable))
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("failed to create SQLite catalog: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db = db
+	s.collections = make(map[string]*sqliteCollection)
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT name FROM %s", sqliteCatalogTable))
+	if err != nil {
+		return fmt.Errorf("failed to read SQLite catalog: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read SQLite catalog: %w", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read SQLite catalog: %w", err)
+	}
+
+	for _, name := range names {
+		if _, err := s.loadCollection(ctx, name); err != nil {
+			return err
+		}
+	}
+	GlobalLogger.Debug("Opened SQLite database", "path", s.path, "collections", len(names))
+	return nil
+}
+
+// Close closes the database.
+func (s *SQLiteDB) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+// HasCollection checks if a collection with the given name exists.
+func (s *SQLiteDB) HasCollection(ctx context.Context, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collection, err := s.collection(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	return collection != nil, nil
+}
+
+// DropCollection drops the table of a collection and its catalog entry.
+// Warning: This operation is irreversible.
+func (s *SQLiteDB) DropCollection(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DROP TABLE IF EXISTS "+sqliteIdent(name)); err != nil {
+		return fmt.Errorf("failed to drop collection %s: %w", name, err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE name = ?", sqliteCatalogTable), name); err != nil {
+		return fmt.Errorf("failed to drop collection %s: %w", name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to drop collection %s: %w", name, err)
+	}
+
+	delete(s.collections, name)
+	return nil
+}
+
+// CreateCollection creates a table with one column per schema field and
+// records the schema in the catalog. The schema must have an int64 primary key.
+func (s *SQLiteDB) CreateCollection(ctx context.Context, name string, schema Schema) error {
+	collection := &sqliteCollection{schema: schema}
+	definitions := make([]string, 0, len(schema.Fields))
+	for _, field := range schema.Fields {
+		definition, err := sqliteColumnDefinition(field)
+		if err != nil {
+			return err
+		}
+		if field.PrimaryKey {
+			collection.primaryKey = field.Name
+		}
+		definitions = append(definitions, definition)
+	}
+	if collection.primaryKey == "" {
+		return fmt.Errorf("schema for collection %s has no primary key", name)
+	}
+
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("failed to encode schema: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, err := s.collection(ctx, name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("%w: %s", ErrCollectionExists, name)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf("CREATE TABLE %s (%s)", sqliteIdent(name), strings.Join(definitions, ", "))
+	GlobalLogger.Debug("Creating collection", "name", name, "query", query)
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create collection %s: %w", name, err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (name, schema) VALUES (?, ?)", sqliteCatalogTable), name, string(encoded)); err != nil {
+		return fmt.Errorf("failed to create collection %s: %w", name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create collection %s: %w", name, err)
+	}
+
+	s.collections[name] = collection
+	return nil
+}
+
+// Insert adds records to a collection in a single transaction.
+func (s *SQLiteDB) Insert(ctx context.Context, collectionName string, data []Record) error {
+	return s.write(ctx, collectionName, data, false)
+}
+
+// Upsert inserts records, replacing the rows that share the same primary key,
+// in a single transaction.
+func (s *SQLiteDB) Upsert(ctx context.Context, collectionName string, data []Record) error {
+	return s.write(ctx, collectionName, data, true)
+}
+
+// write inserts or upserts records in one transaction and then adds them to
+// the collection's HNSW graphs and quantized codes.
+func (s *SQLiteDB) write(ctx context.Context, collectionName string, data []Record, upsert bool) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collection, err := s.requireCollection(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+	if err := ValidateRecords(collection.schema, data); err != nil {
+		return err
+	}
+	data = assignDocIDs(data, collection.primaryKey)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, len(data))
+	for i, record := range data {
+		query, args, err := sqliteWriteQuery(collectionName, collection, record, upsert)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			GlobalLogger.Error("Failed to write record", "collection", collectionName, "index", i, "error", err)
+			return fmt.Errorf("failed to write record %d: %w", i, err)
+		}
+		if id, ok := sqliteInt64(record.Fields[collection.primaryKey]); ok {
+			ids[i] = id
+		} else if ids[i], err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to read ID of record %d: %w", i, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit records: %w", err)
+	}
+
+	for field, g := range collection.graphs {
+		for i, record := range data {
+			// An upsert may replace an indexed row
+			if node, ok := g.nodes[ids[i]]; ok {
+				g.graph.remove(node)
+				delete(g.nodes, ids[i])
+			}
+			if vector, ok := sqliteVectorValue(record.Fields[field]); ok {
+				g.add(ids[i], vector)
+			}
+		}
+	}
+	for field, q := range collection.quantized {
+		for i, record := range data {
+			if vector, ok := sqliteVectorValue(record.Fields[field]); ok {
+				q.put(ids[i], toFloat32Slice(vector))
+			} else {
+				q.remove(ids[i])
+			}
+		}
+	}
+	return s.compactGraphs(ctx, collectionName, collection)
+}
+
+// Delete removes the rows with the given primary keys.
+func (s *SQLiteDB) Delete(ctx context.Context, collectionName string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collection, err := s.requireCollection(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+
+	placeholders, args := sqliteIDList(ids)
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s IN (%s)", sqliteIdent(collectionName), sqliteIdent(collection.primaryKey), placeholders)
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete records: %w", err)
+	}
+	return s.removeFromIndexes(ctx, collectionName, collection, ids)
+}
+
+// DeleteByFilter removes every row matching filter.
+func (s *SQLiteDB) DeleteByFilter(ctx context.Context, collectionName string, filter *Filter) error {
+	if filter == nil {
+		return fmt.Errorf("delete filter must not be empty")
+	}
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collection, err := s.requireCollection(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+
+	var args []interface{}
+	where, err := sqliteFilterExpr(filter, &args)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s RETURNING %s", sqliteIdent(collectionName), where, sqliteIdent(collection.primaryKey))
+	ids, err := s.queryIDs(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete records by filter: %w", err)
+	}
+	return s.removeFromIndexes(ctx, collectionName, collection, ids)
+}
+
+// Get retrieves the rows with the given primary keys, with every field.
+// IDs that are not present in the collection are skipped.
+func (s *SQLiteDB) Get(ctx context.Context, collectionName string, ids []int64) ([]Record, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	collection, ok := s.collections[collectionName]
+	if !ok {
+		return nil, collectionNotFound(collectionName)
+	}
+
+	rows, err := s.fetch(ctx, collectionName, collection, collection.schema.Fields, ids)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(rows))
+	for _, id := range ids {
+		if fields, ok := rows[id]; ok {
+			records = append(records, Record{Fields: fields})
+		}
+	}
+	return records, nil
+}
+
+// Scan calls fn with every row of a collection, ordered by ID. Each batch
+// is read under a read lock starting after the last ID of the previous
+// batch; the lock is released while fn runs, so fn may write to the database.
+func (s *SQLiteDB) Scan(ctx context.Context, collectionName string, batchSize int, fn func([]Record) error) error {
+	batchSize = scanBatchSize(batchSize)
+	after := int64(math.MinInt64)
+	for {
+		records, last, err := s.scanBatch(ctx, collectionName, after, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := fn(records); err != nil {
+			return err
+		}
+		if len(records) < batchSize {
+			return nil
+		}
+		after = last
+	}
+}
+
+// scanBatch reads up to limit rows whose ID is greater than after, ordered
+// by ID, and returns them with the last ID read.
+func (s *SQLiteDB) scanBatch(ctx context.Context, collectionName string, after int64, limit int) ([]Record, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	collection, ok := s.collections[collectionName]
+	if !ok {
+		return nil, 0, collectionNotFound(collectionName)
+	}
+
+	ids, err := s.queryIDs(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s > ? ORDER BY %s LIMIT ?",
+		sqliteIdent(collection.primaryKey), sqliteIdent(collectionName), sqliteIdent(collection.primaryKey), sqliteIdent(collection.primaryKey)),
+		after, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to scan collection %s: %w", collectionName, err)
+	}
+	if len(ids) == 0 {
+		return nil, 0, nil
+	}
+	rows, err := s.fetch(ctx, collectionName, collection, collection.schema.Fields, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	records := make([]Record, 0, len(ids))
+	for _, id := range ids {
+		if fields, ok := rows[id]; ok {
+			records = append(records, Record{Fields: fields})
+		}
+	}
+	return records, ids[len(ids)-1], nil
+}
+
+// Flush checkpoints the write-ahead log into the database file, so that the
+// file alone holds every committed write.
+func (s *SQLiteDB) Flush(ctx context.Context, collectionName string) error {
+	if _, err := s.db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint SQLite database: %w", err)
+	}
+	return nil
+}
+
+// CreateIndex creates an index on a field.
+//   - On a vector field, an "HNSW" index builds an in-memory graph for the
+//     index metric (parameters "M" and "efConstruction"); searches with that
+//     metric use it. The definition is stored in the catalog and the graph is
+//     rebuilt on Connect.
+//   - On a vector field, an IndexTypeSQ8 or IndexTypeBinary index keeps int8
+//     or sign-bit codes in memory, about 4x or 32x smaller than the vectors.
+//     Searches with any metric scan the codes for topK * "oversample"
+//     candidates (parameter or search parameter, default 3 for SQ8 and 10 for
+//     BINARY) and rescore them with the vectors stored in the file.
+//   - On any other field, a "BTREE" index creates a SQLite index on the
+//     column, or on the Metadata key of that name, which speeds up filters.
+func (s *SQLiteDB) CreateIndex(ctx context.Context, collectionName, field string, index Index) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collection, err := s.requireCollection(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+
+	schemaField, isColumn := collection.field(field)
+	if !isColumn || schemaField.DataType != "float_vector" {
+		if !strings.EqualFold(index.Type, "BTREE") {
+			return fmt.Errorf("unsupported index type: %s", index.Type)
+		}
+		target := sqliteIdent(field)
+		if !isColumn {
+			target = sqliteMetadataExpr(field)
+		}
+		name := sqliteIdent(strings.ToLower(collectionName + "_" + field + "_idx"))
+		query := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", name, sqliteIdent(collectionName), target)
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to create index: %w", err)
+		}
+		return nil
+	}
+
+	if index.Type != "HNSW" && !isQuantizedIndex(index.Type) {
+		return fmt.Errorf("unsupported index type: %s", index.Type)
+	}
+	if _, err := ResolveMetric(index.Metric); err != nil {
+		return err
+	}
+
+	indexes := make(map[string]Index, len(collection.indexes)+1)
+	for f, idx := range collection.indexes {
+		indexes[f] = idx
+	}
+	indexes[field] = index
+	encoded, err := json.Marshal(indexes)
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET indexes = ? WHERE name = ?", sqliteCatalogTable), string(encoded), collectionName); err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+
+	collection.indexes = indexes
+	return s.buildIndex(ctx, collectionName, collection, field)
+}
+
+// LoadCollection checks that the collection exists. Indexed collections are
+// loaded into memory by Connect.
+func (s *SQLiteDB) LoadCollection(ctx context.Context, name string) error {
+	exists, err := s.HasCollection(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return collectionNotFound(name)
+	}
+	return nil
+}
+
+// ListCollections returns the names of the collections in the catalog, sorted.
+func (s *SQLiteDB) ListCollections(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT name FROM %s ORDER BY name", sqliteCatalogTable))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SQLite catalog: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to read SQLite catalog: %w", err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read SQLite catalog: %w", err)
+	}
+	return names, nil
+}
+
+// DescribeCollection returns the schema of a collection, as stored in the
+// catalog, with its HNSW indexes and the BTREE indexes on its columns and
+// Metadata keys.
+func (s *SQLiteDB) DescribeCollection(ctx context.Context, name string) (*CollectionInfo, error) {
+	s.mu.Lock()
+	collection, err := s.requireCollection(ctx, name)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	info := &CollectionInfo{Name: name, Schema: collection.schema, Indexes: make(map[string]Index)}
+	for field, index := range collection.indexes {
+		info.Indexes[field] = index
+	}
+	s.mu.Unlock()
+
+	// Only the first key of each index created with CREATE INDEX is read;
+	// CreateIndex creates single-key indexes
+	rows, err := s.db.QueryContext(ctx, `SELECT ii.name, m.sql
+		FROM pragma_index_list(?) AS il
+		JOIN pragma_index_xinfo(il.name) AS ii
+		JOIN sqlite_master AS m ON m.type = 'index' AND m.name = il.name
+		WHERE il.origin = 'c' AND ii.key = 1 AND ii.seqno = 0`, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes of collection %s: %w", name, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var column sql.NullString
+		var definition string
+		if err := rows.Scan(&column, &definition); err != nil {
+			return nil, fmt.Errorf("failed to list indexes of collection %s: %w", name, err)
+		}
+		field := column.String
+		if !column.Valid {
+			var ok bool
+			if field, ok = sqliteMetadataKey(definition); !ok {
+				continue
+			}
+		}
+		info.Indexes[field] = Index{Type: "BTREE"}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list indexes of collection %s: %w", name, err)
+	}
+	return info, nil
+}
+
+// Count returns the number of rows in a collection.
+func (s *SQLiteDB) Count(ctx context.Context, collectionName string) (int64, error) {
+	s.mu.Lock()
+	_, err := s.requireCollection(ctx, collectionName)
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err := s.db.QueryRowContext(ctx, "SELECT count(*) FROM "+sqliteIdent(collectionName)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count collection %s: %w", collectionName, err)
+	}
+	return count, nil
+}
+
+// Stats reports the row count of a collection and the pages used by its
+// table and indexes in the database file. HNSW graphs and quantized codes are
+// built in memory on Connect and kept up to date by writes, so they are
+// always ready.
+func (s *SQLiteDB) Stats(ctx context.Context, collectionName string) (*CollectionStats, error) {
+	count, err := s.Count(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	collection, ok := s.collections[collectionName]
+	if !ok {
+		s.mu.RUnlock()
+		return nil, collectionNotFound(collectionName)
+	}
+	stats := &CollectionStats{
+		Count:       count,
+		Dimension:   vectorDimension(collection.schema),
+		StorageSize: -1,
+		IndexStatus: IndexStatusNone,
+	}
+	if len(collection.graphs) > 0 || len(collection.quantized) > 0 {
+		stats.IndexStatus = IndexStatusReady
+	}
+	s.mu.RUnlock()
+
+	// dbstat is an optional SQLite extension; without it the size is unknown
+	var size sql.NullInt64
+	err = s.db.QueryRowContext(ctx, `SELECT SUM(pgsize) FROM dbstat
+		WHERE name = ? OR name IN (SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ?)`,
+		collectionName, collectionName).Scan(&size)
+	if err == nil && size.Valid {
+		stats.StorageSize = size.Int64
+	} else if err != nil {
+		GlobalLogger.Debug("SQLite storage size unavailable", "collection", collectionName, "error", err)
+	}
+	return stats, nil
+}
+
+// Search performs vector similarity search on a single vector field.
+// The process:
+//  1. Uses the field's HNSW graph when one exists for the requested metric,
+//     exploring "ef" candidates (searchParams, default 64); rows rejected by
+//     the optional FilterParam filter are skipped
+//  2. Uses the field's SQ8 or BINARY codes when they exist, rescoring topK *
+//     "oversample" candidates (searchParams) with the stored vectors
+//  3. Otherwise scans the vectors of the rows matching the filter, which
+//     SQLite evaluates as a WHERE clause
+//  4. Reads the configured columns of the top K rows
+func (s *SQLiteDB) Search(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}) ([]SearchResult, error) {
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("sqlite search requires exactly one vector, got %d", len(vectors))
+	}
+	metric, err := ResolveMetric(metricType)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	collection, ok := s.collections[collectionName]
+	if !ok {
+		return nil, collectionNotFound(collectionName)
+	}
+	if err := validateQueryVectors(collection.schema, vectors); err != nil {
+		return nil, err
+	}
+
+	for field, vector := range vectors {
+		return s.searchField(ctx, collectionName, collection, field, vector, topK, metric, searchParams)
+	}
+	return nil, nil
+}
+
+// SearchBatch runs the searches of the query vectors on field concurrently,
+// on up to GOMAXPROCS goroutines and connections, under a single read lock.
+// Each search is performed as by Search.
+func (s *SQLiteDB) SearchBatch(ctx context.Context, collectionName, field string, queries []Vector, topK int, metricType string, searchParams map[string]interface{}) ([][]SearchResult, error) {
+	metric, err := ResolveMetric(metricType)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	collection, ok := s.collections[collectionName]
+	if !ok {
+		return nil, collectionNotFound(collectionName)
+	}
+	return searchEach(ctx, queries, runtime.GOMAXPROCS(0), func(ctx context.Context, query Vector) ([]SearchResult, error) {
+		if err := validateQueryVectors(collection.schema, map[string]Vector{field: query}); err != nil {
+			return nil, err
+		}
+		return s.searchField(ctx, collectionName, collection, field, query, topK, metric, searchParams)
+	})
+}
+
+// HybridSearch searches each vector field separately and fuses the rankings
+// with Reciprocal Rank Fusion. The reranker may be nil (RRF with k = 60) or
+// a *RRFReranker. Result scores are the fused scores; their Distance is zero.
+func (s *SQLiteDB) HybridSearch(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}, reranker interface{}) ([]SearchResult, error) {
+	rrf := NewRRFReranker(0)
+	if reranker != nil {
+		var ok bool
+		if rrf, ok = reranker.(*RRFReranker); !ok {
+			return nil, fmt.Errorf("invalid reranker type %T for sqlite", reranker)
+		}
+	}
+	metric, err := ResolveMetric(metricType)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	collection, ok := s.collections[collectionName]
+	if !ok {
+		return nil, collectionNotFound(collectionName)
+	}
+	if err := validateQueryVectors(collection.schema, vectors); err != nil {
+		return nil, err
+	}
+
+	// Search fields in a stable order so that ties fuse deterministically
+	fields := make([]string, 0, len(vectors))
+	for field := range vectors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	rankings := make([][]SearchResult, 0, len(fields))
+	for _, field := range fields {
+		ranking, err := s.searchField(ctx, collectionName, collection, field, vectors[field], topK, metric, searchParams)
+		if err != nil {
+			return nil, err
+		}
+		rankings = append(rankings, ranking)
+	}
+
+	results := rrf.fuse(rankings...)
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+// SetColumnNames sets the fields to retrieve in search results. When empty,
+// every field except vectors is returned.
+func (s *SQLiteDB) SetColumnNames(names []string) {
+	s.columnNames = names
+}
+
+// searchField finds the topK rows closest to vector in one vector field.
+// The caller must hold the read lock.
+func (s *SQLiteDB) searchField(ctx context.Context, collectionName string, collection *sqliteCollection, field string, vector Vector, topK int, metric string, searchParams map[string]interface{}) ([]SearchResult, error) {
+	schemaField, ok := collection.field(field)
+	if !ok || schemaField.DataType != "float_vector" {
+		return nil, fmt.Errorf("collection %s has no vector field %s", collectionName, field)
+	}
+	if len(vector) != schemaField.Dimension {
+		return nil, fmt.Errorf("query vector has dimension %d, field %s has %d", len(vector), field, schemaField.Dimension)
+	}
+
+	filter, err := filterFromParams(searchParams)
+	if err != nil {
+		return nil, err
+	}
+	var where string
+	var args []interface{}
+	if filter != nil {
+		expr, err := sqliteFilterExpr(filter, &args)
+		if err != nil {
+			return nil, err
+		}
+		where = " WHERE " + expr
+	}
+
+	var candidates []sqliteCandidate
+	if g, ok := collection.graphs[field]; ok && g.graph.metric == metric {
+		var accept func(int) bool
+		if filter != nil {
+			allowed, err := s.filterIDs(ctx, collectionName, collection, where, args)
+			if err != nil {
+				return nil, err
+			}
+			accept = func(row int) bool {
+				return allowed[g.ids[row]]
+			}
+		}
+		for _, c := range g.graph.search(vector, topK, intParam(searchParams, "ef", defaultHNSWEf), accept) {
+			candidates = append(candidates, sqliteCandidate{id: g.ids[c.index], distance: c.dist})
+		}
+	} else if q, ok := collection.quantized[field]; ok {
+		var allowed map[int64]bool
+		if filter != nil {
+			if allowed, err = s.filterIDs(ctx, collectionName, collection, where, args); err != nil {
+				return nil, err
+			}
+		}
+		ids, err := q.search(ctx, toFloat32Slice(vector), metric, q.index.candidates(topK, searchParams), allowed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search collection %s: %w", collectionName, err)
+		}
+		if len(ids) > 0 {
+			// Rescore the candidates against their stored vectors
+			placeholders, idArgs := sqliteIDList(ids)
+			query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s IN (%s)", sqliteIdent(collection.primaryKey), sqliteIdent(field),
+				sqliteIdent(collectionName), sqliteIdent(collection.primaryKey), placeholders)
+			if candidates, err = s.scan(ctx, query, idArgs, vector, distanceFunc(metric), topK); err != nil {
+				return nil, fmt.Errorf("failed to search collection %s: %w", collectionName, err)
+			}
+		}
+	} else {
+		query := fmt.Sprintf("SELECT %s, %s FROM %s%s", sqliteIdent(collection.primaryKey), sqliteIdent(field), sqliteIdent(collectionName), where)
+		if candidates, err = s.scan(ctx, query, args, vector, distanceFunc(metric), topK); err != nil {
+			return nil, fmt.Errorf("failed to search collection %s: %w", collectionName, err)
+		}
+	}
+
+	ids := make([]int64, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.id
+	}
+	rows, err := s.fetch(ctx, collectionName, collection, s.resultFields(collection), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(candidates))
+	for _, c := range candidates {
+		fields, ok := rows[c.id]
+		if !ok {
+			// Deleted by another process since the graph was built
+			continue
+		}
+		if !s.selectsColumn(collection.primaryKey) {
+			delete(fields, collection.primaryKey)
+		}
+		results = append(results, SearchResult{
+			ID:       c.id,
+			DocID:    docIDFromFields(fields),
+			Score:    ScoreFromDistance(metric, c.distance),
+			Distance: c.distance,
+			Fields:   fields,
+		})
+	}
+	return results, nil
+}
+
+// scan computes the distance of every selected (id, vector) row to the query
+// and returns the topK closest, sorted by ascending distance.
+func (s *SQLiteDB) scan(ctx context.Context, query string, args []interface{}, vector Vector, distance func(a, b Vector) float64, topK int) ([]sqliteCandidate, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []sqliteCandidate
+	var blob []byte
+	stored := make(Vector, len(vector))
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id, &blob); err != nil {
+			return nil, err
+		}
+		if len(blob) != 4*len(vector) {
+			continue
+		}
+		sqliteDecodeVectorInto(stored, blob)
+		candidates = append(candidates, sqliteCandidate{id: id, distance: distance(vector, stored)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].id < candidates[j].id
+	})
+	if len(candidates) > topK {
+		candidates = candidates[:topK]
+	}
+	return candidates, nil
+}
+
+// fetch reads the given fields of the rows with the given IDs, keyed by ID.
+// The primary key is always included.
+func (s *SQLiteDB) fetch(ctx context.Context, collectionName string, collection *sqliteCollection, fields []Field, ids []int64) (map[int64]map[string]interface{}, error) {
+	result := make(map[int64]map[string]interface{}, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	columns := []string{sqliteIdent(collection.primaryKey)}
+	for _, f := range fields {
+		columns = append(columns, sqliteIdent(f.Name))
+	}
+	placeholders, args := sqliteIDList(ids)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IN (%s)",
+		strings.Join(columns, ", "), sqliteIdent(collectionName), sqliteIdent(collection.primaryKey), placeholders)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		values := make([]interface{}, len(fields)+1)
+		values[0] = &id
+		for i := range fields {
+			values[i+1] = new(interface{})
+		}
+		if err := rows.Scan(values...); err != nil {
+			return nil, fmt.Errorf("failed to read record: %w", err)
+		}
+
+		record := make(map[string]interface{}, len(fields)+1)
+		for i, f := range fields {
+			record[f.Name] = sqliteDecodeValue(f, *values[i+1].(*interface{}))
+		}
+		record[collection.primaryKey] = id
+		result[id] = record
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read records: %w", err)
+	}
+	return result, nil
+}
+
+// resultFields returns the fields read for search results: the configured
+// columns, or every non-vector field.
+func (s *SQLiteDB) resultFields(collection *sqliteCollection) []Field {
+	var fields []Field
+	for _, f := range collection.schema.Fields {
+		if f.Name == collection.primaryKey {
+			continue
+		}
+		if len(s.columnNames) == 0 && f.DataType != "float_vector" || s.selectsColumn(f.Name) {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// selectsColumn reports whether name was requested with SetColumnNames.
+func (s *SQLiteDB) selectsColumn(name string) bool {
+	for _, c := range s.columnNames {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// filterIDs returns the set of IDs of the rows matching a WHERE clause built
+// by sqliteFilterExpr.
+func (s *SQLiteDB) filterIDs(ctx context.Context, collectionName string, collection *sqliteCollection, where string, args []interface{}) (map[int64]bool, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s%s", sqliteIdent(collection.primaryKey), sqliteIdent(collectionName), where)
+	ids, err := s.queryIDs(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter collection %s: %w", collectionName, err)
+	}
+	allowed := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		allowed[id] = true
+	}
+	return allowed, nil
+}
+
+// queryIDs runs a query returning one integer column.
+func (s *SQLiteDB) queryIDs(ctx context.Context, query string, args ...interface{}) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// collection returns the catalog entry of a collection, reading it from the
+// catalog when it was created by another process, or nil if it does not
+// exist. The caller must hold the write lock.
+func (s *SQLiteDB) collection(ctx context.Context, name string) (*sqliteCollection, error) {
+	if collection, ok := s.collections[name]; ok {
+		return collection, nil
+	}
+	return s.loadCollection(ctx, name)
+}
+
+// requireCollection is like collection but fails when it does not exist.
+func (s *SQLiteDB) requireCollection(ctx context.Context, name string) (*sqliteCollection, error) {
+	collection, err := s.collection(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if collection == nil {
+		return nil, collectionNotFound(name)
+	}
+	return collection, nil
+}
+
+// loadCollection reads a catalog entry and builds its indexes. It
+// returns nil when the collection does not exist. The caller must hold the
+// write lock.
+func (s *SQLiteDB) loadCollection(ctx context.Context, name string) (*sqliteCollection, error) {
+	var encodedSchema, encodedIndexes string
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT schema, indexes FROM %s WHERE name = ?", sqliteCatalogTable), name).
+		Scan(&encodedSchema, &encodedIndexes)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection %s: %w", name, err)
+	}
+
+	collection := &sqliteCollection{}
+	if err := json.Unmarshal([]byte(encodedSchema), &collection.schema); err != nil {
+		return nil, fmt.Errorf("failed to decode schema of collection %s: %w", name, err)
+	}
+	if err := json.Unmarshal([]byte(encodedIndexes), &collection.indexes); err != nil {
+		return nil, fmt.Errorf("failed to decode indexes of collection %s: %w", name, err)
+	}
+	for _, f := range collection.schema.Fields {
+		if f.PrimaryKey {
+			collection.primaryKey = f.Name
+		}
+	}
+
+	for field := range collection.indexes {
+		if err := s.buildIndex(ctx, name, collection, field); err != nil {
+			return nil, err
+		}
+	}
+	s.collections[name] = collection
+	return collection, nil
+}
+
+// buildIndex (re)builds the HNSW graph or quantized codes of a vector field
+// from its rows, replacing any index of the other kind.
+// The caller must hold the write lock.
+func (s *SQLiteDB) buildIndex(ctx context.Context, collectionName string, collection *sqliteCollection, field string) error {
+	if index := collection.indexes[field]; isQuantizedIndex(index.Type) {
+		delete(collection.graphs, field)
+		return s.buildQuantized(ctx, collectionName, collection, field, index)
+	}
+	delete(collection.quantized, field)
+	return s.buildGraph(ctx, collectionName, collection, field)
+}
+
+// buildQuantized (re)builds the quantized codes of a vector field from its
+// rows. The caller must hold the write lock.
+func (s *SQLiteDB) buildQuantized(ctx context.Context, collectionName string, collection *sqliteCollection, field string, index Index) error {
+	schemaField, _ := collection.field(field)
+	q := &sqliteQuantized{
+		index: newQuantizedIndex(index, schemaField.Dimension),
+		rows:  make(map[int64]int),
+	}
+
+	query := fmt.Sprintf("SELECT %s, %s FROM %s", sqliteIdent(collection.primaryKey), sqliteIdent(field), sqliteIdent(collectionName))
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to build index on %s: %w", field, err)
+	}
+	defer rows.Close()
+
+	vector := make([]float32, schemaField.Dimension)
+	for rows.Next() {
+		var id int64
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			return fmt.Errorf("failed to build index on %s: %w", field, err)
+		}
+		if len(blob) != 4*schemaField.Dimension {
+			continue
+		}
+		for i := range vector {
+			vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:]))
+		}
+		q.put(id, vector)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to build index on %s: %w", field, err)
+	}
+
+	if collection.quantized == nil {
+		collection.quantized = make(map[string]*sqliteQuantized)
+	}
+	collection.quantized[field] = q
+	return nil
+}
+
+// put encodes the vector of row id, adding the row if needed.
+func (q *sqliteQuantized) put(id int64, vector []float32) {
+	i, ok := q.rows[id]
+	if !ok {
+		i = len(q.ids)
+		q.ids = append(q.ids, id)
+		q.rows[id] = i
+		q.index.grow(i + 1)
+	}
+	q.index.set(i, vector)
+}
+
+// remove drops row id, moving the last row into its place.
+func (q *sqliteQuantized) remove(id int64) {
+	i, ok := q.rows[id]
+	if !ok {
+		return
+	}
+	last := len(q.ids) - 1
+	q.index.move(i, last)
+	q.ids[i] = q.ids[last]
+	q.rows[q.ids[i]] = i
+	q.ids = q.ids[:last]
+	q.index.truncate(last)
+	delete(q.rows, id)
+}
+
+// search returns the IDs of the n rows whose codes are closest to vector,
+// skipping rows outside allowed when it is not nil.
+func (q *sqliteQuantized) search(ctx context.Context, vector []float32, metric string, n int, allowed map[int64]bool) ([]int64, error) {
+	encoded, ok := q.index.prepare(vector)
+	if !ok {
+		return nil, nil
+	}
+	candidates, err := scanTopK(ctx, len(q.ids), n, func(i int) (float64, bool) {
+		if allowed != nil && !allowed[q.ids[i]] {
+			return 0, false
+		}
+		return q.index.estimate(encoded, i, metric)
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(candidates))
+	for i, c := range candidates {
+		ids[i] = q.ids[c.index]
+	}
+	return ids, nil
+}
+
+// buildGraph (re)builds the HNSW graph of a vector field from its rows.
+// The caller must hold the write lock.
+func (s *SQLiteDB) buildGraph(ctx context.Context, collectionName string, collection *sqliteCollection, field string) error {
+	schemaField, _ := collection.field(field)
+	g := &sqliteGraph{
+		graph:   newHNSWGraph(field, collection.indexes[field], false),
+		nodes:   make(map[int64]int32),
+		vectors: newVectorColumn(schemaField.Dimension, 0),
+	}
+
+	query := fmt.Sprintf("SELECT %s, %s FROM %s", sqliteIdent(collection.primaryKey), sqliteIdent(field), sqliteIdent(collectionName))
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to build index on %s: %w", field, err)
+	}
+	defer rows.Close()
+
+	vector := make(Vector, schemaField.Dimension)
+	for rows.Next() {
+		var id int64
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			return fmt.Errorf("failed to build index on %s: %w", field, err)
+		}
+		if len(blob) != 4*schemaField.Dimension {
+			continue
+		}
+		sqliteDecodeVectorInto(vector, blob)
+		g.add(id, vector)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to build index on %s: %w", field, err)
+	}
+
+	if collection.graphs == nil {
+		collection.graphs = make(map[string]*sqliteGraph)
+	}
+	collection.graphs[field] = g
+	return nil
+}
+
+// removeFromIndexes tombstones deleted rows in the collection's graphs and
+// drops their quantized codes. The caller must hold the write lock.
+func (s *SQLiteDB) removeFromIndexes(ctx context.Context, collectionName string, collection *sqliteCollection, ids []int64) error {
+	for _, g := range collection.graphs {
+		for _, id := range ids {
+			if node, ok := g.nodes[id]; ok {
+				g.graph.remove(node)
+				delete(g.nodes, id)
+			}
+		}
+	}
+	for _, q := range collection.quantized {
+		for _, id := range ids {
+			q.remove(id)
+		}
+	}
+	return s.compactGraphs(ctx, collectionName, collection)
+}
+
+// compactGraphs rebuilds graphs in which deleted rows have piled up.
+// The caller must hold the write lock.
+func (s *SQLiteDB) compactGraphs(ctx context.Context, collectionName string, collection *sqliteCollection) error {
+	for field, g := range collection.graphs {
+		if g.graph.needsRebuild() {
+			if err := s.buildGraph(ctx, collectionName, collection, field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sqliteColumnDefinition returns the column definition of a schema field.
+func sqliteColumnDefinition(field Field) (string, error) {
+	column := sqliteIdent(field.Name)
+	if field.PrimaryKey {
+		if field.DataType != "int64" {
+			return "", fmt.Errorf("sqlite primary key %s must be int64, got %s", field.Name, field.DataType)
+		}
+		// INTEGER PRIMARY KEY aliases the rowid, which SQLite assigns when
+		// a record has no ID
+		return column + " INTEGER PRIMARY KEY", nil
+	}
+
+	switch field.DataType {
+	case "int64", "int32", "int16", "int8", "int":
+		return column + " INTEGER", nil
+	case "float", "double":
+		return column + " REAL", nil
+	case "bool":
+		return column + " BOOLEAN", nil
+	case "varchar", "string":
+		if field.MaxLength > 0 {
+			return fmt.Sprintf("%s TEXT CHECK (length(%s) <= %d)", column, column, field.MaxLength), nil
+		}
+		return column + " TEXT", nil
+	case "json":
+		// TEXT affinity keeps JSON documents as written; a JSON type name
+		// would get NUMERIC affinity
+		return column + " TEXT", nil
+	case "float_vector":
+		if field.Dimension <= 0 {
+			return "", fmt.Errorf("vector field %s requires a positive dimension", field.Name)
+		}
+		return column + " BLOB", nil
+	default:
+		return "", fmt.Errorf("%w %q for field %s", ErrUnsupportedDataType, field.DataType, field.Name)
+	}
+}
+
+// sqliteWriteQuery builds the INSERT statement of one record. Upserts update
+// every written column when the primary key already exists.
+func sqliteWriteQuery(collectionName string, collection *sqliteCollection, record Record, upsert bool) (string, []interface{}, error) {
+	names := make([]string, 0, len(record.Fields))
+	for name := range record.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	primary, _ := collection.field(collection.primaryKey)
+	_, hasKey := record.Fields[collection.primaryKey]
+	if !hasKey && !primary.AutoID {
+		return "", nil, fmt.Errorf("record has no %s and the collection does not generate IDs", collection.primaryKey)
+	}
+
+	columns := make([]string, len(names))
+	placeholders := make([]string, len(names))
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		field, ok := collection.field(name)
+		if !ok {
+			return "", nil, fmt.Errorf("collection %s has no field %s", collectionName, name)
+		}
+		arg, err := sqliteEncodeValue(field, record.Fields[name])
+		if err != nil {
+			return "", nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		columns[i] = sqliteIdent(name)
+		placeholders[i] = "?"
+		args[i] = arg
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		sqliteIdent(collectionName), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+	if upsert && hasKey {
+		var updates []string
+		for _, name := range names {
+			if name != collection.primaryKey {
+				updates = append(updates, fmt.Sprintf("%s = excluded.%s", sqliteIdent(name), sqliteIdent(name)))
+			}
+		}
+		if len(updates) == 0 {
+			query += fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", sqliteIdent(collection.primaryKey))
+		} else {
+			query += fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", sqliteIdent(collection.primaryKey), strings.Join(updates, ", "))
+		}
+	}
+	return query, args, nil
+}
+
+// sqliteEncodeValue converts a field value into a query argument: vectors
+// become float32 BLOBs and JSON fields JSON text.
+func sqliteEncodeValue(field Field, value interface{}) (interface{}, error) {
+	switch field.DataType {
+	case "float_vector":
+		vector, ok := sqliteVectorValue(value)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected a vector, got %T", ErrFieldType, value)
+		}
+		if len(vector) != field.Dimension {
+			return nil, fmt.Errorf("%w: expected %d, got %d", ErrDimensionMismatch, field.Dimension, len(vector))
+		}
+		blob := make([]byte, 4*len(vector))
+		for i, f := range vector {
+			binary.LittleEndian.PutUint32(blob[4*i:], math.Float32bits(float32(f)))
+		}
+		return blob, nil
+	case "json":
+		if value == nil {
+			return nil, nil
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode json: %w", err)
+		}
+		return string(encoded), nil
+	default:
+		return value, nil
+	}
+}
+
+// sqliteDecodeValue converts a scanned column value back into a Go value.
+func sqliteDecodeValue(field Field, value interface{}) interface{} {
+	switch field.DataType {
+	case "float_vector":
+		if blob, ok := value.([]byte); ok && len(blob)%4 == 0 {
+			vector := make(Vector, len(blob)/4)
+			sqliteDecodeVectorInto(vector, blob)
+			return vector
+		}
+	case "json":
+		var raw []byte
+		switch v := value.(type) {
+		case string:
+			raw = []byte(v)
+		case []byte:
+			raw = v
+		default:
+			return value
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(raw, &decoded); err == nil {
+			return decoded
+		}
+	case "bool":
+		if n, ok := value.(int64); ok {
+			return n != 0
+		}
+	}
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value
+}
+
+// sqliteDecodeVectorInto decodes a float32 BLOB into vector, which must have
+// room for len(blob)/4 values.
+func sqliteDecodeVectorInto(vector Vector, blob []byte) {
+	for i := range vector {
+		vector[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:])))
+	}
+}
+
+// sqliteVectorValue reads a vector field value of any supported slice type.
+func sqliteVectorValue(value interface{}) (Vector, bool) {
+	switch v := value.(type) {
+	case Vector:
+		return v, true
+	case []float64:
+		return Vector(v), true
+	case []float32:
+		vector := make(Vector, len(v))
+		for i, f := range v {
+			vector[i] = float64(f)
+		}
+		return vector, true
+	default:
+		return nil, false
+	}
+}
+
+// sqliteInt64 reads a primary key value.
+func sqliteInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// sqliteIDList returns the placeholders and arguments of an IN list of IDs.
+func sqliteIDList(ids []int64) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return strings.Join(placeholders, ", "), args
+}
+
+// sqliteIdent quotes an identifier, preserving its case.
+func sqliteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqliteMetadataExpr returns the expression reading a Metadata key. The JSON
+// path is a literal, rather than a parameter, so that filters can use the
+// expression indexes created by CreateIndex.
+func sqliteMetadataExpr(key string) string {
+	path := `$."` + strings.ReplaceAll(key, `"`, `\"`) + `"`
+	return fmt.Sprintf("json_extract(%s, '%s')", sqliteIdent("Metadata"), strings.ReplaceAll(path, "'", "''"))
+}
+
+// sqliteMetadataKey returns the Metadata key read by the expression index
+// whose CREATE INDEX statement is definition, the reverse of sqliteMetadataExpr.
+func sqliteMetadataKey(definition string) (string, bool) {
+	start := strings.Index(definition, `'$."`)
+	end := strings.LastIndex(definition, `"'`)
+	if start < 0 || end < start+4 {
+		return "", false
+	}
+	key := strings.ReplaceAll(definition[start+4:end], "''", "'")
+	return strings.ReplaceAll(key, `\"`, `"`), true
+}
+
+// sqliteFilterExpr translates a Filter into a SQL boolean expression,
+// appending its parameters to args. Metadata keys are read with json_extract
+// and TextField maps to the Text column. Comparisons only match values of the
+// same type as the filter value, and negations treat NULL (a missing key) as
+// false, like Filter.Matches.
+func sqliteFilterExpr(f *Filter, args *[]interface{}) (string, error) {
+	switch f.Op {
+	case OpAnd, OpOr:
+		joiner := " AND "
+		if f.Op == OpOr {
+			joiner = " OR "
+		}
+		parts := make([]string, len(f.Filters))
+		for i, child := range f.Filters {
+			expr, err := sqliteFilterExpr(child, args)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = "(" + expr + ")"
+		}
+		return strings.Join(parts, joiner), nil
+	case OpNot:
+		inner, err := sqliteFilterExpr(f.Filters[0], args)
+		if err != nil {
+			return "", err
+		}
+		return "NOT COALESCE((" + inner + "), 0)", nil
+	}
+
+	target := sqliteIdent(TextField)
+	if f.Field != TextField {
+		target = sqliteMetadataExpr(f.Field)
+	}
+
+	switch f.Op {
+	case OpEq, OpLt, OpLte, OpGt, OpGte:
+		value, err := sqliteFilterValue(f.Value)
+		if err != nil {
+			return "", err
+		}
+		*args = append(*args, value)
+		op := string(f.Op)
+		if f.Op == OpEq {
+			op = "="
+		}
+		return fmt.Sprintf("%s %s ?", sqliteTypeGuard(target, value), op), nil
+	case OpNe:
+		value, err := sqliteFilterValue(f.Value)
+		if err != nil {
+			return "", err
+		}
+		*args = append(*args, value)
+		return fmt.Sprintf("%s IS NOT ?", target), nil
+	case OpIn, OpNotIn:
+		values := filterValues(f.Value)
+		if len(values) == 0 {
+			if f.Op == OpNotIn {
+				return "1", nil
+			}
+			return "0", nil
+		}
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			value, err := sqliteFilterValue(v)
+			if err != nil {
+				return "", err
+			}
+			*args = append(*args, value)
+			placeholders[i] = "?"
+		}
+		expr := fmt.Sprintf("%s IN (%s)", target, strings.Join(placeholders, ", "))
+		if f.Op == OpNotIn {
+			expr = "NOT COALESCE(" + expr + ", 0)"
+		}
+		return expr, nil
+	case OpContains:
+		*args = append(*args, f.Value)
+		return fmt.Sprintf("instr(%s, ?) > 0", target), nil
+	default:
+		return "", fmt.Errorf("unsupported filter operation for SQLite: %q", f.Op)
+	}
+}
+
+// sqliteTypeGuard wraps target so that it is NULL unless its type matches
+// value. SQLite orders all numbers before all text, so without it a numeric
+// range would also match text values.
+func sqliteTypeGuard(target string, value interface{}) string {
+	if _, ok := value.(string); ok {
+		return fmt.Sprintf("(CASE WHEN typeof(%s) = 'text' THEN %s END)", target, target)
+	}
+	return fmt.Sprintf("(CASE WHEN typeof(%s) IN ('integer', 'real') THEN %s END)", target, target)
+}
+
+// sqliteFilterValue converts a filter value into a query argument. Booleans
+// are stored by SQLite's JSON functions as 1 and 0.
+func sqliteFilterValue(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case bool:
+		if val {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	default:
+		if n, ok := toFloat(v); ok {
+			return n, nil
+		}
+		return nil, fmt.Errorf("unsupported filter value type for SQLite: %T", v)
+	}
+}
@@ -0,0 +1,173 @@
+package rag
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSQLiteFilterExpr(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   *Filter
+		want     string
+		wantArgs []interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "string equality",
+			filter:   Eq("source", "a.txt"),
+			want:     `(CASE WHEN typeof(json_extract("Metadata", '$."source"')) = 'text' THEN json_extract("Metadata", '$."source"') END) = ?`,
+			wantArgs: []interface{}{"a.txt"},
+		},
+		{
+			name:     "numeric range",
+			filter:   Gt("page", 2),
+			want:     `(CASE WHEN typeof(json_extract("Metadata", '$."page"')) IN ('integer', 'real') THEN json_extract("Metadata", '$."page"') END) > ?`,
+			wantArgs: []interface{}{2.0},
+		},
+		{
+			name:     "bool inequality",
+			filter:   Ne("draft", true),
+			want:     `json_extract("Metadata", '$."draft"') IS NOT ?`,
+			wantArgs: []interface{}{int64(1)},
+		},
+		{
+			name:     "quoted key",
+			filter:   Eq(`it's "x"`, "y"),
+			want:     `(CASE WHEN typeof(json_extract("Metadata", '$."it''s \"x\""')) = 'text' THEN json_extract("Metadata", '$."it''s \"x\""') END) = ?`,
+			wantArgs: []interface{}{"y"},
+		},
+		{
+			name:     "not in",
+			filter:   NotIn("lang", "fr", "de"),
+			want:     `NOT COALESCE(json_extract("Metadata", '$."lang"') IN (?, ?), 0)`,
+			wantArgs: []interface{}{"fr", "de"},
+		},
+		{name: "empty in", filter: In("lang"), want: "0"},
+		{name: "empty not in", filter: NotIn("lang"), want: "1"},
+		{
+			name:     "text contains",
+			filter:   Contains(TextField, "vector"),
+			want:     `instr("Text", ?) > 0`,
+			wantArgs: []interface{}{"vector"},
+		},
+		{
+			name:     "nested logic",
+			filter:   Or(Eq(TextField, "a"), Not(Contains("title", "b"))),
+			want:     `((CASE WHEN typeof("Text") = 'text' THEN "Text" END) = ?) OR (NOT COALESCE((instr(json_extract("Metadata", '$."title"'), ?) > 0), 0))`,
+			wantArgs: []interface{}{"a", "b"},
+		},
+		{name: "unsupported value", filter: Eq("tags", []string{"a"}), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []interface{}
+			got, err := sqliteFilterExpr(tt.filter, &args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("sqliteFilterExpr = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sqliteFilterExpr: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("sqliteFilterExpr =\n%s\nwant\n%s", got, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+// TestSQLiteFilterMatches checks that filtered searches return exactly the
+// records Filter.Matches accepts, including records whose keys are missing
+// or hold values of another type.
+func TestSQLiteFilterMatches(t *testing.T) {
+	ctx := context.Background()
+	db, err := newSQLiteDB(&Config{Address: filepath.Join(t.TempDir(), "filter.db")})
+	if err != nil {
+		t.Fatalf("newSQLiteDB: %v", err)
+	}
+	if err := db.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer db.Close()
+
+	schema := Schema{Fields: []Field{
+		{Name: "ID", DataType: "int64", PrimaryKey: true},
+		{Name: "Embedding", DataType: "float_vector", Dimension: 2},
+		{Name: "Text", DataType: "varchar"},
+		{Name: "Metadata", DataType: "json"},
+	}}
+	if err := db.CreateCollection(ctx, "docs", schema); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	metadata := []map[string]interface{}{
+		{"lang": "en", "page": 1, "draft": true, "title": "go vectors"},
+		{"lang": "de", "page": 2.5, "draft": false},
+		{"lang": "fr", "page": "three", "title": "rust"},
+		{"page": 10, "title": "go and rust"},
+		{"lang": "en", "draft": false},
+	}
+	texts := []string{"alpha", "beta vector", "gamma", "vector delta", "alpha"}
+	records := make([]Record, len(metadata))
+	for i := range records {
+		records[i] = Record{Fields: map[string]interface{}{
+			"ID":        int64(i + 1),
+			"Embedding": Vector{float64(i), 1},
+			"Text":      texts[i],
+			"Metadata":  metadata[i],
+		}}
+	}
+	if err := db.Insert(ctx, "docs", records); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	filters := []*Filter{
+		Eq("lang", "en"),
+		Ne("lang", "en"),
+		Gt("page", 1),
+		Lte("page", 2.5),
+		Eq("draft", true),
+		Ne("draft", true),
+		In("lang", "de", "fr"),
+		NotIn("lang", "de", "fr"),
+		Contains("title", "rust"),
+		Eq(TextField, "alpha"),
+		Contains(TextField, "vector"),
+		And(Eq("lang", "en"), Not(Eq("draft", true))),
+		Or(Gte("page", 10), Eq("lang", "fr")),
+		Not(Or(Contains("title", "go"), Eq("lang", "de"))),
+	}
+	for _, filter := range filters {
+		t.Run(filter.String(), func(t *testing.T) {
+			results, err := db.Search(ctx, "docs", map[string]Vector{"Embedding": {0, 1}}, len(records), "L2",
+				map[string]interface{}{FilterParam: filter})
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			got := make([]int64, 0, len(results))
+			for _, r := range results {
+				got = append(got, r.ID)
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+
+			want := []int64{}
+			for _, record := range records {
+				if filter.Matches(record) {
+					want = append(want, record.Fields["ID"].(int64))
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("filtered IDs = %v, want %v", got, want)
+			}
+		})
+	}
+}
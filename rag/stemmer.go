@@ -0,0 +1,684 @@
+// Package rag provides retrieval-augmented generation capabilities.
+package rag
+
+import (
+	"strings"
+	"unicode"
+)
+
+// PorterStem reduces an English word to its stem using the Porter stemming
+// algorithm (M.F. Porter, 1980), following the reference implementation.
+// The word must be lowercase; words of two letters or less and words
+// containing characters outside a-z are returned unchanged.
+//
+// Example:
+//
+//	rag.PorterStem("connections") // "connect"
+//	rag.PorterStem("relational")  // "relat"
+func PorterStem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	s := &porterStemmer{b: []byte(word)}
+	s.step1ab()
+	if len(s.b) > 1 {
+		s.step1c()
+		s.step2()
+		s.step3()
+		s.step4()
+		s.step5()
+	}
+	return string(s.b)
+}
+
+// porterStemmer holds the word being stemmed. j marks the end of the stem
+// preceding the suffix matched by the last call to ends.
+type porterStemmer struct {
+	b []byte
+	j int
+}
+
+// k returns the index of the last letter of the word.
+func (s *porterStemmer) k() int { return len(s.b) - 1 }
+
+// cons reports whether b[i] is a consonant.
+func (s *porterStemmer) cons(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.cons(i-1)
+	}
+	return true
+}
+
+// m measures the number of consonant sequences in b[0..j]. With C a
+// consonant sequence and V a vowel sequence, the stem has the form
+// [C](VC){m}[V].
+func (s *porterStemmer) m() int {
+	n, i := 0, 0
+	for {
+		if i > s.j {
+			return n
+		}
+		if !s.cons(i) {
+			break
+		}
+		i++
+	}
+	i++
+	for {
+		for {
+			if i > s.j {
+				return n
+			}
+			if s.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+		n++
+		for {
+			if i > s.j {
+				return n
+			}
+			if !s.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+	}
+}
+
+// vowelInStem reports whether b[0..j] contains a vowel.
+func (s *porterStemmer) vowelInStem() bool {
+	for i := 0; i <= s.j; i++ {
+		if !s.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doubleC reports whether b[i-1..i] is a double consonant.
+func (s *porterStemmer) doubleC(i int) bool {
+	return i >= 1 && s.b[i] == s.b[i-1] && s.cons(i)
+}
+
+// cvc reports whether b[i-2..i] is consonant-vowel-consonant and the last
+// consonant is not w, x or y, as in "hop" but not "snow".
+func (s *porterStemmer) cvc(i int) bool {
+	if i < 2 || !s.cons(i) || s.cons(i-1) || !s.cons(i-2) {
+		return false
+	}
+	switch s.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// ends reports whether the word ends with suffix, setting j accordingly.
+func (s *porterStemmer) ends(suffix string) bool {
+	if len(suffix) > len(s.b) || string(s.b[len(s.b)-len(suffix):]) != suffix {
+		return false
+	}
+	s.j = len(s.b) - len(suffix) - 1
+	return true
+}
+
+// setTo replaces b[j+1..] with replacement.
+func (s *porterStemmer) setTo(replacement string) {
+	s.b = append(s.b[:s.j+1], replacement...)
+}
+
+// r replaces the suffix matched by ends when the stem has m() > 0.
+func (s *porterStemmer) r(replacement string) {
+	if s.m() > 0 {
+		s.setTo(replacement)
+	}
+}
+
+// step1ab removes plurals and -ed or -ing.
+func (s *porterStemmer) step1ab() {
+	if s.b[s.k()] == 's' {
+		switch {
+		case s.ends("sses"):
+			s.b = s.b[:len(s.b)-2]
+		case s.ends("ies"):
+			s.setTo("i")
+		case s.b[s.k()-1] != 's':
+			s.b = s.b[:len(s.b)-1]
+		}
+	}
+	if s.ends("eed") {
+		if s.m() > 0 {
+			s.b = s.b[:len(s.b)-1]
+		}
+	} else if (s.ends("ed") || s.ends("ing")) && s.vowelInStem() {
+		s.b = s.b[:s.j+1]
+		switch {
+		case s.ends("at"):
+			s.setTo("ate")
+		case s.ends("bl"):
+			s.setTo("ble")
+		case s.ends("iz"):
+			s.setTo("ize")
+		case s.doubleC(s.k()):
+			switch s.b[s.k()] {
+			case 'l', 's', 'z':
+			default:
+				s.b = s.b[:len(s.b)-1]
+			}
+		default:
+			s.j = s.k()
+			if s.m() == 1 && s.cvc(s.k()) {
+				s.setTo("e")
+			}
+		}
+	}
+}
+
+// step1c turns a terminal y into i when there is another vowel in the stem.
+func (s *porterStemmer) step1c() {
+	if s.ends("y") && s.vowelInStem() {
+		s.b[s.k()] = 'i'
+	}
+}
+
+// porterStep2 maps double suffixes to single ones, keyed by the penultimate
+// letter of the suffix.
+var porterStep2 = map[byte][][2]string{
+	'a': {{"ational", "ate"}, {"tional", "tion"}},
+	'c': {{"enci", "ence"}, {"anci", "ance"}},
+	'e': {{"izer", "ize"}},
+	'l': {{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"}},
+	'o': {{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}},
+	's': {{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"}},
+	't': {{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}},
+	'g': {{"logi", "log"}},
+}
+
+// porterStep3 handles -ic-, -full, -ness etc., keyed by the last letter.
+var porterStep3 = map[byte][][2]string{
+	'e': {{"icate", "ic"}, {"ative", ""}, {"alize", "al"}},
+	'i': {{"iciti", "ic"}},
+	'l': {{"ical", "ic"}, {"ful", ""}},
+	's': {{"ness", ""}},
+}
+
+// porterStep4 lists the suffixes removed when m() > 1, keyed by the
+// penultimate letter.
+var porterStep4 = map[byte][]string{
+	'a': {"al"},
+	'c': {"ance", "ence"},
+	'e': {"er"},
+	'i': {"ic"},
+	'l': {"able", "ible"},
+	'n': {"ant", "ement", "ment", "ent"},
+	'o': {"ion", "ou"},
+	's': {"ism"},
+	't': {"ate", "iti"},
+	'u': {"ous"},
+	'v': {"ive"},
+	'z': {"ize"},
+}
+
+func (s *porterStemmer) step2() {
+	for _, rule := range porterStep2[s.b[s.k()-1]] {
+		if s.ends(rule[0]) {
+			s.r(rule[1])
+			return
+		}
+	}
+}
+
+func (s *porterStemmer) step3() {
+	for _, rule := range porterStep3[s.b[s.k()]] {
+		if s.ends(rule[0]) {
+			s.r(rule[1])
+			return
+		}
+	}
+}
+
+// step4 removes -ant, -ence etc. in context <c>vcvc<v>.
+func (s *porterStemmer) step4() {
+	for _, suffix := range porterStep4[s.b[s.k()-1]] {
+		if !s.ends(suffix) {
+			continue
+		}
+		if suffix == "ion" && (s.j < 0 || (s.b[s.j] != 's' && s.b[s.j] != 't')) {
+			continue
+		}
+		if s.m() > 1 {
+			s.b = s.b[:s.j+1]
+		}
+		return
+	}
+}
+
+// step5 removes a final -e if m() > 1 and changes -ll to -l if m() > 1.
+func (s *porterStemmer) step5() {
+	s.j = s.k()
+	if s.b[s.k()] == 'e' {
+		a := s.m()
+		if a > 1 || (a == 1 && !s.cvc(s.k()-1)) {
+			s.b = s.b[:len(s.b)-1]
+		}
+	}
+	s.j = s.k()
+	if s.b[s.k()] == 'l' && s.doubleC(s.k()) && s.m() > 1 {
+		s.b = s.b[:len(s.b)-1]
+	}
+}
+
+// frenchVowels are the vowels of the Snowball French stemmer. The uppercase
+// forms I, U and Y, marked during the prelude, are treated as consonants.
+const frenchVowels = "aeiouyâàëéêèïîôûù"
+
+// FrenchStem reduces a French word to its stem using the Snowball French
+// stemming algorithm. The word must be lowercase.
+//
+// Example:
+//
+//	rag.FrenchStem("continuellement") // "continuel"
+//	rag.FrenchStem("indexation")      // "index"
+func FrenchStem(word string) string {
+	if word == "" {
+		return word
+	}
+	s := &frenchStemmer{w: []rune(word)}
+	s.prelude()
+	s.markRegions()
+
+	if s.standardSuffix() || s.iVerbSuffix() || s.verbSuffix() {
+		// Step 3
+		switch s.w[len(s.w)-1] {
+		case 'Y':
+			s.w[len(s.w)-1] = 'i'
+		case 'ç':
+			s.w[len(s.w)-1] = 'c'
+		}
+	} else {
+		s.residualSuffix()
+	}
+	s.unDouble()
+	s.unAccent()
+
+	return strings.NewReplacer("I", "i", "U", "u", "Y", "y").Replace(string(s.w))
+}
+
+// frenchStemmer holds the word being stemmed and the start of its RV, R1
+// and R2 regions.
+type frenchStemmer struct {
+	w          []rune
+	rv, r1, r2 int
+}
+
+func isFrenchVowel(r rune) bool {
+	return strings.ContainsRune(frenchVowels, r)
+}
+
+// vowel reports whether w[i] is a vowel, treating out-of-range as consonant.
+func (s *frenchStemmer) vowel(i int) bool {
+	return i >= 0 && i < len(s.w) && isFrenchVowel(s.w[i])
+}
+
+// prelude marks u and i between vowels, y next to a vowel and u after q as
+// consonants by uppercasing them, scanning left to right so that a marked
+// letter no longer counts as a vowel for the letters after it.
+func (s *frenchStemmer) prelude() {
+	n := len(s.w)
+	for p := 0; p < n; p++ {
+		switch {
+		case s.vowel(p) && p+2 < n && (s.w[p+1] == 'u' || s.w[p+1] == 'i') && s.vowel(p+2):
+			s.w[p+1] = unicode.ToUpper(s.w[p+1])
+		case s.vowel(p) && p+1 < n && s.w[p+1] == 'y':
+			s.w[p+1] = 'Y'
+		case s.w[p] == 'y' && s.vowel(p+1):
+			s.w[p] = 'Y'
+		case s.w[p] == 'q' && p+1 < n && s.w[p+1] == 'u':
+			s.w[p+1] = 'U'
+		}
+	}
+}
+
+// markRegions computes RV, R1 and R2 as defined by the Snowball algorithm.
+func (s *frenchStemmer) markRegions() {
+	n := len(s.w)
+	s.rv, s.r1, s.r2 = n, n, n
+
+	word := string(s.w)
+	switch {
+	case n >= 2 && s.vowel(0) && s.vowel(1):
+		s.rv = min(3, n)
+	case strings.HasPrefix(word, "par") || strings.HasPrefix(word, "col") || strings.HasPrefix(word, "tap"):
+		s.rv = 3
+	default:
+		for i := 1; i < n; i++ {
+			if s.vowel(i) {
+				s.rv = i + 1
+				break
+			}
+		}
+	}
+
+	s.r1 = s.regionAfter(0)
+	s.r2 = s.regionAfter(s.r1)
+}
+
+// regionAfter returns the position after the first non-vowel following a
+// vowel, searching from start.
+func (s *frenchStemmer) regionAfter(start int) int {
+	for i := start + 1; i < len(s.w); i++ {
+		if !s.vowel(i) && s.vowel(i-1) {
+			return i + 1
+		}
+	}
+	return len(s.w)
+}
+
+// longestSuffix returns the longest of suffixes that ends the word and
+// starts at or after limit, and its start position.
+func (s *frenchStemmer) longestSuffix(limit int, suffixes ...string) (string, int) {
+	word := string(s.w)
+	best, bestStart := "", -1
+	for _, suffix := range suffixes {
+		if !strings.HasSuffix(word, suffix) {
+			continue
+		}
+		start := len(s.w) - len([]rune(suffix))
+		if start < limit {
+			continue
+		}
+		if bestStart < 0 || start < bestStart {
+			best, bestStart = suffix, start
+		}
+	}
+	return best, bestStart
+}
+
+// endsWith reports whether w[:end] ends with suffix, returning its start.
+func (s *frenchStemmer) endsWith(end int, suffix string) (int, bool) {
+	rs := []rune(suffix)
+	start := end - len(rs)
+	if start < 0 || string(s.w[start:end]) != suffix {
+		return 0, false
+	}
+	return start, true
+}
+
+func (s *frenchStemmer) cut(start int) {
+	s.w = s.w[:start]
+}
+
+func (s *frenchStemmer) replace(start int, replacement string) {
+	s.w = append(s.w[:start], []rune(replacement)...)
+}
+
+// standardSuffix implements step 1 and reports whether it altered the word
+// in a way that ends the suffix stripping. The -amment, -emment and -ment
+// rules always report false so that verb suffixes are tried afterwards.
+func (s *frenchStemmer) standardSuffix() bool {
+	suffix, start := s.longestSuffix(0,
+		"ance", "iqUe", "isme", "able", "iste", "eux", "ances", "iqUes", "ismes", "ables", "istes",
+		"atrice", "ateur", "ation", "atrices", "ateurs", "ations",
+		"logie", "logies", "usion", "ution", "usions", "utions", "ence", "ences",
+		"ement", "ements", "ité", "ités", "if", "ive", "ifs", "ives",
+		"eaux", "aux", "euse", "euses", "issement", "issements",
+		"amment", "emment", "ment", "ments",
+	)
+	if start < 0 {
+		return false
+	}
+
+	switch suffix {
+	case "ance", "iqUe", "isme", "able", "iste", "eux", "ances", "iqUes", "ismes", "ables", "istes":
+		if start < s.r2 {
+			return false
+		}
+		s.cut(start)
+	case "atrice", "ateur", "ation", "atrices", "ateurs", "ations":
+		if start < s.r2 {
+			return false
+		}
+		s.cut(start)
+		if icStart, ok := s.endsWith(start, "ic"); ok {
+			if icStart >= s.r2 {
+				s.cut(icStart)
+			} else {
+				s.replace(icStart, "iqU")
+			}
+		}
+	case "logie", "logies":
+		if start < s.r2 {
+			return false
+		}
+		s.replace(start, "log")
+	case "usion", "ution", "usions", "utions":
+		if start < s.r2 {
+			return false
+		}
+		s.replace(start, "u")
+	case "ence", "ences":
+		if start < s.r2 {
+			return false
+		}
+		s.replace(start, "ent")
+	case "ement", "ements":
+		if start < s.rv {
+			return false
+		}
+		s.cut(start)
+		if p, ok := s.endsWith(start, "iv"); ok {
+			if p >= s.r2 {
+				s.cut(p)
+				if at, ok := s.endsWith(p, "at"); ok && at >= s.r2 {
+					s.cut(at)
+				}
+			}
+		} else if p, ok := s.endsWith(start, "eus"); ok {
+			if p >= s.r2 {
+				s.cut(p)
+			} else if p >= s.r1 {
+				s.replace(p, "eux")
+			}
+		} else if p, ok := s.endsWith(start, "abl"); ok {
+			if p >= s.r2 {
+				s.cut(p)
+			}
+		} else if p, ok := s.endsWith(start, "iqU"); ok {
+			if p >= s.r2 {
+				s.cut(p)
+			}
+		} else if p, ok := s.endsWith(start, "ièr"); ok {
+			if p >= s.rv {
+				s.replace(p, "i")
+			}
+		} else if p, ok := s.endsWith(start, "Ièr"); ok {
+			if p >= s.rv {
+				s.replace(p, "i")
+			}
+		}
+	case "ité", "ités":
+		if start < s.r2 {
+			return false
+		}
+		s.cut(start)
+		if p, ok := s.endsWith(start, "abil"); ok {
+			if p >= s.r2 {
+				s.cut(p)
+			} else {
+				s.replace(p, "abl")
+			}
+		} else if p, ok := s.endsWith(start, "ic"); ok {
+			if p >= s.r2 {
+				s.cut(p)
+			} else {
+				s.replace(p, "iqU")
+			}
+		} else if p, ok := s.endsWith(start, "iv"); ok {
+			if p >= s.r2 {
+				s.cut(p)
+			}
+		}
+	case "if", "ive", "ifs", "ives":
+		if start < s.r2 {
+			return false
+		}
+		s.cut(start)
+		if at, ok := s.endsWith(start, "at"); ok && at >= s.r2 {
+			s.cut(at)
+			if ic, ok := s.endsWith(at, "ic"); ok {
+				if ic >= s.r2 {
+					s.cut(ic)
+				} else {
+					s.replace(ic, "iqU")
+				}
+			}
+		}
+	case "eaux":
+		s.replace(start, "eau")
+	case "aux":
+		if start < s.r1 {
+			return false
+		}
+		s.replace(start, "al")
+	case "euse", "euses":
+		if start >= s.r2 {
+			s.cut(start)
+		} else if start >= s.r1 {
+			s.replace(start, "eux")
+		} else {
+			return false
+		}
+	case "issement", "issements":
+		if start < s.r1 || s.vowel(start-1) {
+			return false
+		}
+		s.cut(start)
+	case "amment":
+		if start >= s.rv {
+			s.replace(start, "ant")
+		}
+		return false
+	case "emment":
+		if start >= s.rv {
+			s.replace(start, "ent")
+		}
+		return false
+	case "ment", "ments":
+		if s.vowel(start-1) && start-1 >= s.rv {
+			s.cut(start)
+		}
+		return false
+	}
+	return true
+}
+
+// iVerbSuffix implements step 2a: verb suffixes beginning with i, removed
+// when preceded by a non-vowel inside RV.
+func (s *frenchStemmer) iVerbSuffix() bool {
+	_, start := s.longestSuffix(s.rv,
+		"îmes", "ît", "îtes", "i", "ie", "ies", "ir", "ira", "irai", "iraIent",
+		"irais", "irait", "iras", "irent", "irez", "iriez", "irions", "irons",
+		"iront", "is", "issaIent", "issais", "issait", "issant", "issante",
+		"issantes", "issants", "isse", "issent", "isses", "issez", "issiez",
+		"issions", "issons", "it",
+	)
+	if start < 0 || start-1 < s.rv || s.vowel(start-1) {
+		return false
+	}
+	s.cut(start)
+	return true
+}
+
+// verbSuffix implements step 2b: other verb suffixes inside RV.
+func (s *frenchStemmer) verbSuffix() bool {
+	suffix, start := s.longestSuffix(s.rv,
+		"ions",
+		"é", "ée", "ées", "és", "èrent", "er", "era", "erai", "eraIent", "erais",
+		"erait", "eras", "erez", "eriez", "erions", "erons", "eront", "ez", "iez",
+		"âmes", "ât", "âtes", "a", "ai", "aIent", "ais", "ait", "ant", "ante",
+		"antes", "ants", "as", "asse", "assent", "asses", "assiez", "assions",
+	)
+	if start < 0 {
+		return false
+	}
+
+	switch suffix {
+	case "ions":
+		if start < s.r2 {
+			return false
+		}
+		s.cut(start)
+	case "âmes", "ât", "âtes", "a", "ai", "aIent", "ais", "ait", "ant", "ante",
+		"antes", "ants", "as", "asse", "assent", "asses", "assiez", "assions":
+		s.cut(start)
+		if e, ok := s.endsWith(start, "e"); ok && e >= s.rv {
+			s.cut(e)
+		}
+	default:
+		s.cut(start)
+	}
+	return true
+}
+
+// residualSuffix implements step 4, applied when steps 1 and 2 left the
+// word unchanged.
+func (s *frenchStemmer) residualSuffix() {
+	if n := len(s.w); n > 1 && s.w[n-1] == 's' && !strings.ContainsRune("aiouès", s.w[n-2]) {
+		s.cut(n - 1)
+	}
+
+	suffix, start := s.longestSuffix(s.rv, "ion", "ier", "ière", "Ier", "Ière", "e", "ë")
+	if start < 0 {
+		return
+	}
+	switch suffix {
+	case "ion":
+		if start >= s.r2 && start-1 >= s.rv && (s.w[start-1] == 's' || s.w[start-1] == 't') {
+			s.cut(start)
+		}
+	case "ier", "ière", "Ier", "Ière":
+		s.replace(start, "i")
+	case "e":
+		s.cut(start)
+	case "ë":
+		if gu, ok := s.endsWith(start, "gu"); ok && gu >= s.rv {
+			s.cut(start)
+		}
+	}
+}
+
+// unDouble implements step 5: enn, onn, ett, ell and eill lose their last letter.
+func (s *frenchStemmer) unDouble() {
+	word := string(s.w)
+	for _, suffix := range []string{"enn", "onn", "ett", "ell", "eill"} {
+		if strings.HasSuffix(word, suffix) {
+			s.cut(len(s.w) - 1)
+			return
+		}
+	}
+}
+
+// unAccent implements step 6: é or è followed by at least one non-vowel at
+// the end of the word becomes e.
+func (s *frenchStemmer) unAccent() {
+	i := len(s.w) - 1
+	for i >= 0 && !s.vowel(i) {
+		i--
+	}
+	if i < len(s.w)-1 && i >= 0 && (s.w[i] == 'é' || s.w[i] == 'è') {
+		s.w[i] = 'e'
+	}
+}
@@ -0,0 +1,69 @@
+package rag
+
+import "testing"
+
+func TestPorterStem(t *testing.T) {
+	// Examples from the paper describing the algorithm, step by step
+	tests := map[string]string{
+		// Short words are left alone
+		"a": "a", "is": "is", "sky": "sky",
+		// Step 1a
+		"caresses": "caress", "ponies": "poni", "ties": "ti", "caress": "caress", "cats": "cat",
+		// Step 1b
+		"feed": "feed", "agreed": "agre", "plastered": "plaster", "bled": "bled",
+		"motoring": "motor", "sing": "sing", "conflated": "conflat", "troubled": "troubl",
+		"sized": "size", "hopping": "hop", "tanned": "tan", "falling": "fall",
+		"hissing": "hiss", "fizzed": "fizz", "failing": "fail", "filing": "file",
+		// Step 1c
+		"happy": "happi",
+		// Step 2
+		"relational": "relat", "conditional": "condit", "rational": "ration",
+		"valenci": "valenc", "digitizer": "digit", "conformabli": "conform",
+		"radicalli": "radic", "differentli": "differ", "vileli": "vile",
+		"analogousli": "analog", "vietnamization": "vietnam", "predication": "predic",
+		"operator": "oper", "feudalism": "feudal", "decisiveness": "decis",
+		"hopefulness": "hope", "callousness": "callous", "formaliti": "formal",
+		"sensitiviti": "sensit", "sensibiliti": "sensibl",
+		// Step 3
+		"triplicate": "triplic", "formative": "form", "formalize": "formal",
+		"electriciti": "electr", "electrical": "electr", "hopeful": "hope", "goodness": "good",
+		// Step 4
+		"revival": "reviv", "allowance": "allow", "inference": "infer", "airliner": "airlin",
+		"gyroscopic": "gyroscop", "adjustable": "adjust", "defensible": "defens",
+		"irritant": "irrit", "replacement": "replac", "adjustment": "adjust",
+		"dependent": "depend", "adoption": "adopt", "homologou": "homolog",
+		"communism": "commun", "activate": "activ", "angulariti": "angular",
+		"homologous": "homolog", "effective": "effect", "bowdlerize": "bowdler",
+		// Step 5
+		"probate": "probat", "rate": "rate", "cease": "ceas", "controll": "control", "roll": "roll",
+		// Several steps
+		"generalizations": "gener", "oscillators": "oscil",
+	}
+	for word, want := range tests {
+		if got := PorterStem(word); got != want {
+			t.Errorf("PorterStem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestFrenchStem(t *testing.T) {
+	tests := map[string]string{
+		// Standard suffixes
+		"continuellement": "continuel", "continuité": "continu", "continuation": "continu",
+		"majesté": "majest", "majestueuse": "majestu", "majestueusement": "majestu",
+		"nationalité": "national", "rapidement": "rapid", "documents": "docu", "chevaux": "cheval",
+		// Verb suffixes
+		"abandonna": "abandon", "abandonnée": "abandon", "continuer": "continu",
+		"couronner": "couron", "finissons": "fin", "jouer": "jou",
+		// Residual suffixes, undoubling and unaccenting
+		"abandonne": "abandon", "couronnes": "couron", "chevaliers": "chevali",
+		"données": "don", "recherche": "recherch", "abbaye": "abbay",
+		// Words without a suffix to remove
+		"quand": "quand", "yeux": "yeux",
+	}
+	for word, want := range tests {
+		if got := FrenchStem(word); got != want {
+			t.Errorf("FrenchStem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
@@ -5,9 +5,29 @@ package rag
 import (
 	"context"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 )
 
+// DefaultScanBatchSize is the batch size Scan uses when none is given.
+const DefaultScanBatchSize = 1000
+
+// Supported metric types for Search, HybridSearch and Index.Metric.
+const (
+	// MetricL2 ranks by Euclidean distance
+	MetricL2 = "L2"
+	// MetricIP ranks by inner product
+	MetricIP = "IP"
+	// MetricCosine ranks by cosine similarity
+	MetricCosine = "COSINE"
+)
+
+// QueryTextParam is the searchParams key under which HybridSearch receives
+// the text of the query. Backends with native keyword search (Elasticsearch,
+// OpenSearch) combine it with the vector search; others ignore it.
+const QueryTextParam = "query_text"
+
 // VectorDB defines the standard interface that all vector database implementations must implement.
 // It provides operations for managing collections, inserting data, and performing vector similarity searches.
 type VectorDB interface {
@@ -29,6 +49,23 @@ type VectorDB interface {
 	// Insert adds new records to the specified collection.
 	Insert(ctx context.Context, collectionName string, data []Record) error
 	
+	// Upsert inserts records, replacing any existing records that share the same ID.
+	Upsert(ctx context.Context, collectionName string, data []Record) error
+	
+	// Delete removes the records with the given IDs from the specified collection.
+	Delete(ctx context.Context, collectionName string, ids []int64) error
+	
+	// DeleteByFilter removes every record matching the metadata filter.
+	DeleteByFilter(ctx context.Context, collectionName string, filter *Filter) error
+	
+	// Get retrieves the records with the given IDs from the specified collection.
+	Get(ctx context.Context, collectionName string, ids []int64) ([]Record, error)
+	
+	// Scan calls fn with every record of the collection, vectors included, in
+	// batches of at most batchSize records (DefaultScanBatchSize if batchSize <= 0).
+	// Scanning stops at the first error returned by fn, which Scan returns.
+	Scan(ctx context.Context, collectionName string, batchSize int, fn func([]Record) error) error
+	
 	// Flush ensures all pending writes are committed to storage.
 	Flush(ctx context.Context, collectionName string) error
 	
@@ -38,9 +75,27 @@ type VectorDB interface {
 	// LoadCollection loads a collection into memory for faster access.
 	LoadCollection(ctx context.Context, name string) error
 	
+	// ListCollections returns the names of all collections, sorted.
+	ListCollections(ctx context.Context) ([]string, error)
+	
+	// DescribeCollection returns the schema of a collection and the indexes built on it.
+	DescribeCollection(ctx context.Context, name string) (*CollectionInfo, error)
+	
+	// Count returns the number of records in a collection.
+	Count(ctx context.Context, collectionName string) (int64, error)
+	
+	// Stats reports the size and index status of a collection.
+	Stats(ctx context.Context, collectionName string) (*CollectionStats, error)
+	
 	// Search performs a vector similarity search in the specified collection.
+	// An optional metadata filter can be passed in searchParams under FilterParam.
 	Search(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}) ([]SearchResult, error)
 	
+	// SearchBatch searches the vector field with each query vector and returns
+	// one result list per query, in order. Backends that accept several query
+	// vectors in one request use it; the others run the searches concurrently.
+	SearchBatch(ctx context.Context, collectionName, field string, queries []Vector, topK int, metricType string, searchParams map[string]interface{}) ([][]SearchResult, error)
+	
 	// HybridSearch combines vector similarity search with additional filtering or reranking.
 	HybridSearch(ctx context.Context, collectionName string, vectors map[string]Vector, topK int, metricType string, searchParams map[string]interface{}, reranker interface{}) ([]SearchResult, error)
 	
@@ -101,12 +156,62 @@ type Index struct {
 	Parameters map[string]interface{}
 }
 
+// Index statuses reported in CollectionStats.IndexStatus.
+const (
+	// IndexStatusNone means the vector field has no index and is searched exhaustively
+	IndexStatusNone = "none"
+	// IndexStatusBuilding means the index is being built or is not yet up to date
+	IndexStatusBuilding = "building"
+	// IndexStatusReady means the index covers every record
+	IndexStatusReady = "ready"
+	// IndexStatusFailed means the backend failed to build the index
+	IndexStatusFailed = "failed"
+)
+
+// CollectionInfo describes a collection: its schema and the indexes built on it.
+type CollectionInfo struct {
+	// Name is the name of the collection
+	Name string
+	// Schema is the structure of the collection's records. Backends that do
+	// not store a schema report the fields raggo writes.
+	Schema Schema
+	// Indexes maps each indexed field to its index definition
+	Indexes map[string]Index
+}
+
+// CollectionStats reports the size and state of a collection.
+type CollectionStats struct {
+	// Count is the number of records in the collection
+	Count int64
+	// Dimension is the dimension of the collection's vector field, or 0 if unknown
+	Dimension int
+	// StorageSize is the approximate size of the collection in bytes, or -1
+	// when the backend cannot report it
+	StorageSize int64
+	// IndexStatus is the state of the vector index, one of the IndexStatus constants
+	IndexStatus string
+}
+
 // SearchResult represents a single result from a vector similarity search.
+//
+// Every backend reports Score on the same "higher is more similar" scale, so
+// a single MinScore threshold means the same thing everywhere:
+// - L2: 1 / (1 + distance), in (0, 1]
+// - IP: the inner product
+// - COSINE: the cosine similarity, in [-1, 1]
 type SearchResult struct {
 	// ID is the identifier for the result
-	ID     int64
-	// Score is the similarity score for the result
-	Score  float64
+	ID int64
+	// DocID is the caller-supplied document ID stored under DocIDKey in the
+	// result's Metadata, or "" if it has none or Metadata was not retrieved
+	DocID string
+	// Score is the similarity score for the result; higher is more similar
+	Score float64
+	// Distance is the raw distance for the result; lower is more similar.
+	// It is the Euclidean distance for L2, the negated inner product for IP
+	// and 1 - cosine similarity for COSINE. Results fused by a reranker
+	// carry the reranker's Score and a zero Distance.
+	Distance float64
 	// Fields contains additional information about the result
 	Fields map[string]interface{}
 }
@@ -152,16 +257,106 @@ func (c *Config) SetTimeout(timeout time.Duration) *Config {
 	return c
 }
 
-// NewVectorDB creates a new VectorDB instance based on the provided configuration.
-func NewVectorDB(cfg *Config) (VectorDB, error) {
-	switch cfg.Type {
-	case "milvus":
-		return newMilvusDB(cfg)
-	case "memory":
-		return newMemoryDB(cfg)
-	case "chromem":
-		return newChromemDB(cfg)
+// vectorDimension returns the dimension of the first vector field of a schema,
+// or 0 if it has none.
+func vectorDimension(schema Schema) int {
+	for _, field := range schema.Fields {
+		if field.DataType == "float_vector" {
+			return field.Dimension
+		}
+	}
+	return 0
+}
+
+// normalizeVectors returns the records with the values of the schema's
+// vector fields converted to Vector, copying only the records that change.
+// Callers may hold vectors as []float64 or []float32.
+func normalizeVectors(schema Schema, records []Record) []Record {
+	normalized, copied := records, false
+	for i, record := range records {
+		var fields map[string]interface{}
+		for _, field := range schema.Fields {
+			if field.DataType != "float_vector" {
+				continue
+			}
+			var vector Vector
+			switch v := record.Fields[field.Name].(type) {
+			case []float64:
+				vector = Vector(v)
+			case []float32:
+				vector = make(Vector, len(v))
+				for j, f := range v {
+					vector[j] = float64(f)
+				}
+			default:
+				continue
+			}
+			if fields == nil {
+				fields = make(map[string]interface{}, len(record.Fields))
+				for k, v := range record.Fields {
+					fields[k] = v
+				}
+			}
+			fields[field.Name] = vector
+		}
+		if fields == nil {
+			continue
+		}
+		if !copied {
+			normalized = append([]Record(nil), records...)
+			copied = true
+		}
+		normalized[i] = Record{Fields: fields}
+	}
+	return normalized
+}
+
+// documentSchema returns the schema of the records raggo writes, for
+// backends that do not store one.
+func documentSchema(name string, dimension int) Schema {
+	return Schema{
+		Name: name,
+		Fields: []Field{
+			{Name: "ID", DataType: "int64", PrimaryKey: true, AutoID: true},
+			{Name: "Embedding", DataType: "float_vector", Dimension: dimension},
+			{Name: "Text", DataType: "varchar", MaxLength: 65535},
+			{Name: "Metadata", DataType: "json"},
+		},
+	}
+}
+
+// scanBatchSize returns the batch size to scan with.
+func scanBatchSize(batchSize int) int {
+	if batchSize <= 0 {
+		return DefaultScanBatchSize
+	}
+	return batchSize
+}
+
+// ResolveMetric returns the canonical name of metricType (L2, IP or COSINE).
+// Matching is case-insensitive and an empty metric defaults to L2; any other
+// value is rejected rather than silently treated as L2.
+func ResolveMetric(metricType string) (string, error) {
+	switch metric := strings.ToUpper(strings.TrimSpace(metricType)); metric {
+	case "":
+		return MetricL2, nil
+	case MetricL2, MetricIP, MetricCosine:
+		return metric, nil
+	default:
+		return "", fmt.Errorf("unsupported metric type: %s", metricType)
+	}
+}
+
+// ScoreFromDistance converts a distance, as documented on SearchResult.Distance,
+// into the "higher is more similar" score of the given metric.
+func ScoreFromDistance(metricType string, distance float64) float64 {
+	metric, _ := ResolveMetric(metricType)
+	switch metric {
+	case MetricIP:
+		return -distance
+	case MetricCosine:
+		return 1 - distance
 	default:
-		return nil, fmt.Errorf("unsupported database type: %s", cfg.Type)
+		return 1 / (1 + math.Max(distance, 0))
 	}
 }
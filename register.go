@@ -7,12 +7,15 @@ package raggo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strconv"
-	"sync"
 	"time"
 
+	"github.com/teilomillet/raggo/config"
 	"github.com/teilomillet/raggo/rag"
 )
 
@@ -35,9 +38,15 @@ type RegisterConfig struct {
 	Timeout        time.Duration // Operation timeout duration
 
 	// Embedding settings configure the embedding generation
-	EmbeddingProvider string // Embedding service provider (e.g., "openai")
-	EmbeddingModel    string // Specific model to use for embeddings
-	EmbeddingKey      string // Authentication key for embedding service
+	EmbeddingProvider string            // Embedding service provider (e.g., "openai")
+	EmbeddingModel    string            // Specific model to use for embeddings
+	EmbeddingKey      string            // Authentication key for embedding service
+	MaxRetries        int               // Retries of a failed embedding request (0 disables them)
+	EmbeddingHeaders  map[string]string // Extra HTTP headers sent with every embedding request
+
+	// SparseIndex receives every inserted chunk for hybrid keyword search.
+	// When nil, the shared index returned by SparseIndex(CollectionName) is used.
+	SparseIndex *BM25Index
 
 	// Callbacks for monitoring and error handling
 	OnProgress func(processed, total int) // Called to report progress
@@ -54,7 +63,7 @@ type RegisterConfig struct {
 //   - 100 items per batch
 //   - 4 concurrent operations
 //   - 5-minute timeout
-//   - OpenAI's text-embedding-3-small model
+//   - OpenAI's text-embedding-3-small model, retrying failed requests 3 times
 func defaultConfig() *RegisterConfig {
 	return &RegisterConfig{
 		VectorDBType:      "milvus",
@@ -70,6 +79,7 @@ func defaultConfig() *RegisterConfig {
 		EmbeddingProvider: "openai",
 		EmbeddingModel:    "text-embedding-3-small",
 		EmbeddingKey:      os.Getenv("OPENAI_API_KEY"),
+		MaxRetries:        3,
 		OnProgress:        func(processed, total int) { Debug("Progress", "processed", processed, "total", total) },
 		OnError:           func(err error) { Error("Error during registration", "error", err) },
 	}
@@ -86,6 +96,7 @@ type RegisterOption func(*RegisterConfig)
 //  2. Text chunking and preprocessing
 //  3. Embedding generation
 //  4. Vector database storage
+//  5. Keyword indexing in the collection's BM25 index for hybrid search
 //
 // The process is highly configurable through RegisterOptions and supports
 // progress monitoring and error handling through callbacks.
@@ -133,6 +144,8 @@ func Register(ctx context.Context, source string, opts ...RegisterOption) error
 		SetEmbedderProvider(cfg.EmbeddingProvider),
 		SetEmbedderModel(cfg.EmbeddingModel),
 		SetEmbedderAPIKey(cfg.EmbeddingKey),
+		SetEmbedderMaxRetries(cfg.MaxRetries),
+		SetEmbedderHeaders(cfg.EmbeddingHeaders),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create embedder: %w", err)
@@ -188,7 +201,7 @@ func Register(ctx context.Context, source string, opts ...RegisterOption) error
 					{Name: "ID", DataType: "int64", PrimaryKey: true, AutoID: true},
 					{Name: "Embedding", DataType: "float_vector", Dimension: dimension},
 					{Name: "Text", DataType: "varchar", MaxLength: 65535},
-					{Name: "Metadata", DataType: "varchar", MaxLength: 65535},
+					{Name: "Metadata", DataType: "json"},
 				},
 			}
 
@@ -201,7 +214,7 @@ func Register(ctx context.Context, source string, opts ...RegisterOption) error
 			Debug("Creating index")
 			index := Index{
 				Type:   "HNSW",
-				Metric: "L2",
+				Metric: defaultMetric(cfg.VectorDBType),
 				Parameters: map[string]interface{}{
 					"M":              16,
 					"efConstruction": 256,
@@ -221,46 +234,33 @@ func Register(ctx context.Context, source string, opts ...RegisterOption) error
 
 	// Process source
 	Debug("Processing source", "source", source)
-	var paths []string
-	var loadErr error
-	if info, err := os.Stat(source); err == nil {
-		if info.IsDir() {
-			Debug("Loading directory")
-			paths, loadErr = loader.LoadDir(ctx, source)
-		} else {
-			Debug("Loading file")
-			var path string
-			path, loadErr = loader.LoadFile(ctx, source)
-			if loadErr == nil {
-				paths = []string{path}
-			}
-		}
-		if loadErr != nil {
-			return fmt.Errorf("failed to load source: %w", loadErr)
-		}
-	} else if isURL(source) {
-		Debug("Loading URL")
-		path, loadErr := loader.LoadURL(ctx, source)
-		if loadErr != nil {
-			return fmt.Errorf("failed to load URL: %w", loadErr)
-		}
-		paths = []string{path}
-	} else {
-		return fmt.Errorf("invalid source: %s", source)
+	sources, err := loadSources(ctx, loader, source)
+	if err != nil {
+		return err
 	}
 
 	// Create embedding service
 	Debug("Creating embedding service")
-	embeddingService := NewEmbeddingService(embedder)
+	embeddingService := NewEmbeddingService(embedder,
+		SetEmbeddingConcurrency(cfg.MaxConcurrency),
+		SetEmbeddingBatchSize(cfg.BatchSize),
+		SetEmbeddingFailureMode(CollectErrors),
+	)
+
+	sparseIndex := cfg.SparseIndex
+	if sparseIndex == nil {
+		sparseIndex = SparseIndex(cfg.CollectionName)
+	}
 
 	// Process files
-	Debug("Processing files", "count", len(paths))
-	for i, path := range paths {
-		Debug("Processing file", "path", path, "index", i+1, "total", len(paths))
+	Debug("Processing files", "count", len(sources))
+	for i, src := range sources {
+		path := src.source
+		Debug("Processing file", "path", path, "index", i+1, "total", len(sources))
 
-		// Parse content
+		// Parse the local copy of the source
 		parser := NewParser()
-		doc, err := parser.Parse(path)
+		doc, err := parser.Parse(src.path)
 		if err != nil {
 			cfg.OnError(fmt.Errorf("failed to parse %s: %w", path, err))
 			continue
@@ -273,8 +273,15 @@ func Register(ctx context.Context, source string, opts ...RegisterOption) error
 
 		// Create embeddings
 		Debug("Creating embeddings")
+		// Chunks that failed to embed are reported below and skipped
 		embeddedChunks, err := embeddingService.EmbedChunks(ctx, chunks)
-		if err != nil {
+		failed := make(map[int]error)
+		var chunkErrs ChunkErrors
+		if errors.As(err, &chunkErrs) {
+			for _, chunkErr := range chunkErrs {
+				failed[chunkErr.Index] = chunkErr.Err
+			}
+		} else if err != nil {
 			cfg.OnError(fmt.Errorf("failed to embed chunks from %s: %w", path, err))
 			continue
 		}
@@ -282,42 +289,60 @@ func Register(ctx context.Context, source string, opts ...RegisterOption) error
 
 		// Convert to records
 		Debug("Converting to records")
-		records := make([]Record, len(embeddedChunks))
+		records := make([]Record, 0, len(embeddedChunks))
+		var skipped []int
 		for j, chunk := range embeddedChunks {
 			embedding, ok := chunk.Embeddings["default"]
 			if !ok || len(embedding) == 0 {
-				cfg.OnError(fmt.Errorf("missing or empty embedding for chunk %d in %s", j, path))
+				if cause, ok := failed[j]; ok {
+					cfg.OnError(fmt.Errorf("failed to embed chunk %d in %s: %w", j, path, cause))
+				} else {
+					cfg.OnError(fmt.Errorf("missing or empty embedding for chunk %d in %s", j, path))
+				}
+				skipped = append(skipped, j)
 				continue
 			}
 
-			// Convert []float64 to []float32 for ChromemDB
-			embedding32 := make([]float32, len(embedding))
-			for i, v := range embedding {
-				embedding32[i] = float32(v)
-			}
-
-			records[j] = Record{
+			records = append(records, Record{
 				Fields: map[string]interface{}{
-					"Embedding": embedding32,
+					"Embedding": Vector(embedding),
 					"Text":      chunk.Text,
 					"Metadata": map[string]interface{}{
+						DocIDKey:     ChunkDocID(path, j),
 						"source":     path,
 						"chunk":      j,
 						"total":      len(chunks),
 						"token_size": chunk.Metadata["token_size"],
 					},
 				},
-			}
+			})
 		}
 
-		// Insert into vector store
-		Debug("Inserting records", "count", len(records))
-		if err := vectorDB.Insert(ctx, cfg.CollectionName, records); err != nil {
-			cfg.OnError(fmt.Errorf("failed to insert records from %s: %w", path, err))
+		// Store the records, then remove the chunks of any earlier
+		// registration of the source, keeping the earlier version of the
+		// chunks that failed to embed. Nothing is removed when no chunk
+		// could be embedded.
+		Debug("Storing records", "count", len(records))
+		if err := vectorDB.ReplaceSource(ctx, cfg.CollectionName, path, records, skipped...); err != nil {
+			cfg.OnError(fmt.Errorf("failed to store records from %s: %w", path, err))
 			continue
 		}
 
-		cfg.OnProgress(i+1, len(paths))
+		// Keep the keyword index in sync with the vector store
+		Debug("Indexing records for keyword search", "count", len(records))
+		for _, record := range records {
+			if record.Fields == nil {
+				continue
+			}
+			text, _ := record.Fields["Text"].(string)
+			metadata, _ := record.Fields["Metadata"].(map[string]interface{})
+			if err := sparseIndex.Add(ctx, chunkKey(0, record.Fields), text, metadata); err != nil {
+				cfg.OnError(fmt.Errorf("failed to index records from %s for keyword search: %w", path, err))
+				break
+			}
+		}
+
+		cfg.OnProgress(i+1, len(sources))
 	}
 
 	Debug("Registration complete")
@@ -327,10 +352,9 @@ func Register(ctx context.Context, source string, opts ...RegisterOption) error
 // WithVectorDB configures the vector database settings for registration.
 // It specifies the database type and its configuration parameters.
 //
-// Supported database types include:
-//   - "milvus": Milvus vector database
-//   - "pinecone": Pinecone vector database
-//   - "qdrant": Qdrant vector database
+// Supported database types are the built-in backends ("milvus", "memory",
+// "chromem", "qdrant", "pgvector", "sqlite", "elasticsearch", "opensearch")
+// and any type added with RegisterVectorDB; see ListRegisteredDBs.
 //
 // Example:
 //
@@ -412,11 +436,73 @@ func WithEmbedding(provider, model, key string) RegisterOption {
 	}
 }
 
+// WithMaxRetries sets how many times a failed embedding request is retried
+// with backoff (3 by default, 0 disables retries). A rate limit answered
+// halfway through a file is then waited out instead of failing its chunks.
+//
+// Example:
+//
+//	Register(ctx, "docs/",
+//	    WithMaxRetries(5),
+//	)
+func WithMaxRetries(n int) RegisterOption {
+	return func(cfg *RegisterConfig) {
+		cfg.MaxRetries = n
+	}
+}
+
+// WithEmbeddingHeaders sets extra HTTP headers sent with every embedding
+// request, for gateways that need them, e.g. with the "openai-compatible"
+// provider.
+//
+// Example:
+//
+//	Register(ctx, "docs/",
+//	    WithEmbeddingHeaders(map[string]string{"X-Team": "search"}),
+//	)
+func WithEmbeddingHeaders(headers map[string]string) RegisterOption {
+	return func(cfg *RegisterConfig) {
+		cfg.EmbeddingHeaders = headers
+	}
+}
+
+// WithConfig applies the embedding settings of a config.Config, such as one
+// returned by config.LoadConfig: its Model, the key APIKeys holds for the
+// embedding provider, MaxRetries and ExtraHeaders. Empty and zero values
+// keep the current setting, and the provider must be chosen before
+// WithConfig for its key to be found.
+//
+// Example:
+//
+//	conf, err := config.LoadConfig()
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	Register(ctx, "docs/",
+//	    WithConfig(conf),
+//	)
+func WithConfig(conf *config.Config) RegisterOption {
+	return func(cfg *RegisterConfig) {
+		if conf.Model != "" {
+			cfg.EmbeddingModel = conf.Model
+		}
+		if key := conf.APIKeys[cfg.EmbeddingProvider]; key != "" {
+			cfg.EmbeddingKey = key
+		}
+		if conf.MaxRetries > 0 {
+			cfg.MaxRetries = conf.MaxRetries
+		}
+		if len(conf.ExtraHeaders) > 0 {
+			cfg.EmbeddingHeaders = conf.ExtraHeaders
+		}
+	}
+}
+
 // WithConcurrency sets the maximum number of concurrent operations
 // during document processing. This affects:
 //   - Document loading
 //   - Chunk processing
-//   - Embedding generation
+//   - Embedding generation, as the number of batches embedded at once
 //   - Vector storage
 //
 // Example:
@@ -430,50 +516,111 @@ func WithConcurrency(max int) RegisterOption {
 	}
 }
 
+// WithSparseIndex sets the BM25 index that receives every registered chunk
+// for hybrid keyword search. By default the shared index returned by
+// SparseIndex for the collection is used, so this is only needed to keep a
+// separate index, e.g. one that is persisted alongside the vector store.
+//
+// Example:
+//
+//	idx := raggo.NewBM25Index()
+//	Register(ctx, "docs/",
+//	    WithSparseIndex(idx),
+//	)
+func WithSparseIndex(idx *BM25Index) RegisterOption {
+	return func(cfg *RegisterConfig) {
+		cfg.SparseIndex = idx
+	}
+}
+
 // isURL determines if a string represents a valid URL.
 // It checks for common URL schemes (http, https, ftp).
 func isURL(s string) bool {
 	return len(s) > 8 && (s[:7] == "http://" || s[:8] == "https://")
 }
 
-// dbRegistry maintains a thread-safe registry of vector database implementations.
-// It provides a central location for registering and retrieving database
-// implementations, ensuring thread-safe access in concurrent environments.
-//
-// The registry supports:
-//   - Dynamic registration of new implementations
-//   - Thread-safe access to implementations
-//   - Runtime discovery of available databases
-type dbRegistry struct {
-	mu        sync.RWMutex
-	factories map[string]func(cfg *Config) (rag.VectorDB, error)
+// loadedSource pairs a document's original location with the local copy the
+// loader made of it. Doc IDs and "source" metadata derive from the original
+// location, so they do not depend on the loader's temporary directory.
+type loadedSource struct {
+	source string // Original file path or URL
+	path   string // Local copy to parse
 }
 
-// registry is the global instance of dbRegistry that maintains all registered
-// vector database implementations. It is initialized when the package is loaded
-// and should be accessed through the package-level registration functions.
-var registry = &dbRegistry{
-	factories: make(map[string]func(cfg *Config) (rag.VectorDB, error)),
+// loadSources loads a file, every file under a directory, or a URL. Files of
+// a directory that fail to load are logged and skipped, like Loader.LoadDir.
+func loadSources(ctx context.Context, loader Loader, source string) ([]loadedSource, error) {
+	if isURL(source) {
+		Debug("Loading URL")
+		path, err := loader.LoadURL(ctx, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load URL: %w", err)
+		}
+		return []loadedSource{{source: source, path: path}}, nil
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source: %s", source)
+	}
+	if !info.IsDir() {
+		Debug("Loading file")
+		path, err := loader.LoadFile(ctx, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load source: %w", err)
+		}
+		return []loadedSource{{source: source, path: path}}, nil
+	}
+
+	Debug("Loading directory")
+	var sources []loadedSource
+	err = filepath.WalkDir(source, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		path, err := loader.LoadFile(ctx, file)
+		if err != nil {
+			Warn("Failed to load file", "path", file, "error", err)
+			return nil
+		}
+		sources = append(sources, loadedSource{source: file, path: path})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source: %w", err)
+	}
+	return sources, nil
 }
 
-// RegisterVectorDB registers a new vector database implementation.
-// It allows third-party implementations to be integrated into the
-// Raggo ecosystem at runtime.
+// RegisterVectorDB registers a new vector database implementation under
+// dbType, making it available to NewVectorDB and therefore to Register,
+// Retriever, RAG and SimpleRAG through their DBType settings. Registering a
+// built-in type replaces it. The optional capabilities declare which
+// optional features the implementation supports; without them none are
+// assumed.
 //
 // Example:
 //
 //	RegisterVectorDB("custom_db", func(cfg *Config) (rag.VectorDB, error) {
 //	    return NewCustomDB(cfg)
-//	})
-func RegisterVectorDB(dbType string, factory func(cfg *Config) (rag.VectorDB, error)) {
-	registry.mu.Lock()
-	defer registry.mu.Unlock()
-	registry.factories[dbType] = factory
+//	}, Capabilities{Filters: true, Delete: true})
+func RegisterVectorDB(dbType string, factory func(cfg *Config) (rag.VectorDB, error), capabilities ...Capabilities) {
+	var caps Capabilities
+	if len(capabilities) > 0 {
+		caps = capabilities[0]
+	}
+	rag.Register(dbType, func(cfg *rag.Config) (rag.VectorDB, error) {
+		return factory(configFromRag(cfg))
+	}, caps)
 }
 
-// GetVectorDB retrieves a vector database implementation from the registry.
-// It returns an error if the requested implementation is not found or
-// if creation fails.
+// GetVectorDB creates a vector database implementation from the registry,
+// which holds the built-in backends as well as the ones added with
+// RegisterVectorDB. It returns an error if the requested implementation is
+// not found or if creation fails.
 //
 // Example:
 //
@@ -481,20 +628,17 @@ func RegisterVectorDB(dbType string, factory func(cfg *Config) (rag.VectorDB, er
 //	    Address: "localhost:19530",
 //	})
 func GetVectorDB(dbType string, cfg *Config) (rag.VectorDB, error) {
-	registry.mu.RLock()
-	factory, ok := registry.factories[dbType]
-	registry.mu.RUnlock()
-
-	if !ok {
+	if _, ok := rag.BackendCapabilities(dbType); !ok {
 		return nil, fmt.Errorf("vector database type not registered: %s", dbType)
 	}
-
-	return factory(cfg)
+	ragCfg := cfg.ragConfig()
+	ragCfg.Type = dbType
+	return rag.NewVectorDB(ragCfg)
 }
 
-// ListRegisteredDBs returns a list of all registered vector database types.
-// This is useful for discovering available implementations and validating
-// configuration options.
+// ListRegisteredDBs returns the sorted list of all registered vector
+// database types, built-in ones included. This is useful for discovering
+// available implementations and validating configuration options.
 //
 // Example:
 //
@@ -503,12 +647,18 @@ func GetVectorDB(dbType string, cfg *Config) (rag.VectorDB, error) {
 //	    fmt.Printf("Supported database: %s\n", db)
 //	}
 func ListRegisteredDBs() []string {
-	registry.mu.RLock()
-	defer registry.mu.RUnlock()
+	return rag.RegisteredTypes()
+}
 
-	types := make([]string, 0, len(registry.factories))
-	for dbType := range registry.factories {
-		types = append(types, dbType)
-	}
-	return types
+// VectorDBCapabilities returns the capabilities declared by the vector
+// database type, and whether the type is registered. Use it to check that a
+// backend supports e.g. hybrid search or deletes before relying on them.
+//
+// Example:
+//
+//	if caps, ok := VectorDBCapabilities("chromem"); ok && !caps.KeywordSearch {
+//	    // keep a BM25Index for keyword matches
+//	}
+func VectorDBCapabilities(dbType string) (Capabilities, bool) {
+	return rag.BackendCapabilities(dbType)
 }
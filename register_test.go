@@ -0,0 +1,84 @@
+package raggo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/teilomillet/raggo/rag/providers"
+)
+
+// flakyEmbedder embeds a text as its length, and fails every request while
+// failing is set.
+type flakyEmbedder struct {
+	failing *atomic.Bool
+}
+
+func (e flakyEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	embeddings, err := e.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+func (e flakyEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	if e.failing.Load() {
+		return nil, errors.New("embedding service unavailable")
+	}
+	embeddings := make([][]float64, len(texts))
+	for i, text := range texts {
+		embeddings[i] = []float64{float64(len(text)), 1}
+	}
+	return embeddings, nil
+}
+
+func (e flakyEmbedder) GetDimension() (int, error) { return 2, nil }
+
+func TestRegisterKeepsSourceOnFailure(t *testing.T) {
+	var failing atomic.Bool
+	providers.RegisterEmbedder("test-flaky", func(map[string]interface{}) (providers.Embedder, error) {
+		return flakyEmbedder{failing: &failing}, nil
+	})
+
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "db")
+	doc := filepath.Join(dir, "doc.txt")
+	register := func(content string) []string {
+		t.Helper()
+		if err := os.WriteFile(doc, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		err := Register(context.Background(), doc,
+			WithVectorDB("memory", map[string]string{"address": dataDir}),
+			WithCollection("docs", true),
+			WithEmbedding("test-flaky", "", ""),
+			func(cfg *RegisterConfig) { cfg.TempDir = t.TempDir() },
+		)
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		db := newTestVectorDB(t, dataDir)
+		defer db.Close()
+		return storedTexts(t, db, "docs")
+	}
+
+	first := register("The first version of the document.")
+	if len(first) != 1 {
+		t.Fatalf("stored %q after the first registration, want one chunk", first)
+	}
+
+	failing.Store(true)
+	if got := register("The second version of the document."); !reflect.DeepEqual(got, first) {
+		t.Errorf("stored %q after a failed registration, want the earlier %q", got, first)
+	}
+
+	failing.Store(false)
+	if got := register("The third version of the document."); len(got) != 1 || got[0] == first[0] {
+		t.Errorf("stored %q after a successful registration, want the third version only", got)
+	}
+}
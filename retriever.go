@@ -14,8 +14,12 @@ package raggo
 import (
 	"context"
 	"fmt"
+	"math"
 	"os"
+	"sync"
 	"time"
+
+	"github.com/teilomillet/raggo/rag"
 )
 
 // Retriever handles semantic search operations with a reusable configuration.
@@ -27,6 +31,10 @@ type Retriever struct {
 	vectorDB *VectorDB        // Connection to vector database
 	embedder Embedder         // Embedding service client
 	ready    bool             // Initialization status
+
+	sparseMu     sync.Mutex // Guards sparseLoaded
+	sparseLoaded bool       // Whether the keyword index was checked, and rebuilt if empty and enabled
+	warnSparse   sync.Once  // Warns once that hybrid search found no keyword index
 }
 
 // RetrieverConfig holds settings for the retrieval process. It provides
@@ -36,10 +44,17 @@ type RetrieverConfig struct {
 	// Core settings define the basic search behavior
 	Collection string   // Name of the vector collection to search
 	TopK       int      // Maximum number of results to return
-	MinScore   float64  // Minimum similarity score threshold
+	MinScore   float64  // Minimum similarity score threshold (see rag.SearchResult for the scale)
 	UseHybrid  bool     // Enable hybrid search (vector + keyword)
 	Columns    []string // Columns to retrieve from the database
 
+	// Hybrid settings control how dense and keyword results are fused
+	SparseIndex  *BM25Index // Keyword index; defaults to SparseIndex(Collection)
+	RebuildIndex bool       // Rebuild an empty keyword index from the whole collection on first search
+	DenseWeight  float64    // Weight of the vector ranking in fusion
+	SparseWeight float64    // Weight of the keyword ranking in fusion
+	RRFConstant  float64    // Reciprocal Rank Fusion constant k
+
 	// Vector DB settings configure the database connection
 	DBType    string // Type of vector database (e.g., "milvus")
 	DBAddress string // Database connection address
@@ -51,9 +66,10 @@ type RetrieverConfig struct {
 	APIKey   string // Authentication key
 
 	// Advanced settings provide additional control
-	MetricType   string                 // Distance metric (e.g., "L2", "IP")
+	MetricType   string                 // Distance metric ("L2", "IP" or "COSINE")
 	Timeout      time.Duration          // Operation timeout
 	SearchParams map[string]interface{} // Additional search parameters
+	Filter       *Filter                // Metadata filter applied to every search
 	OnResult     func(SearchResult)     // Callback for each result
 	OnError      func(error)            // Error handling callback
 }
@@ -62,8 +78,10 @@ type RetrieverConfig struct {
 // and relevance information. It provides a structured way to access both
 // the content and context of each search result.
 type RetrieverResult struct {
+	DocID      string                 `json:"doc_id"`      // Stable document ID, see DocIDKey
 	Content    string                 `json:"content"`     // Retrieved text content
-	Score      float64                `json:"score"`       // Similarity score
+	Score      float64                `json:"score"`       // Similarity score, higher is more similar
+	Distance   float64                `json:"distance"`    // Raw distance, lower is more similar
 	Metadata   map[string]interface{} `json:"metadata"`    // Associated metadata
 	Source     string                 `json:"source"`      // Source identifier
 	ChunkIndex int                    `json:"chunk_index"` // Position in source
@@ -86,6 +104,9 @@ func NewRetriever(opts ...RetrieverOption) (*Retriever, error) {
 	for _, opt := range opts {
 		opt(cfg)
 	}
+	if cfg.MetricType == "" {
+		cfg.MetricType = defaultMetric(cfg.DBType)
+	}
 
 	r := &Retriever{config: cfg}
 	if err := r.initialize(); err != nil {
@@ -126,22 +147,23 @@ func (r *Retriever) Retrieve(ctx context.Context, query string) ([]RetrieverResu
 		return nil, fmt.Errorf("failed to create query embedding: %w", err)
 	}
 
-	r.vectorDB.SetColumnNames(r.config.Columns)
+	columns := r.config.Columns
+	if r.config.UseHybrid {
+		// Dense results are matched with keyword results by their metadata
+		columns = withColumn(columns, "Metadata")
+	}
+	r.vectorDB.SetColumnNames(columns)
 	vectors := map[string]Vector{"Embedding": queryEmbedding}
+	searchParams := r.searchParams()
 
 	var searchResults []SearchResult
 	var searchErr error
+	minScore := r.config.MinScore
 
 	if r.config.UseHybrid {
-		searchResults, searchErr = r.vectorDB.HybridSearch(
-			ctx,
-			r.config.Collection,
-			vectors,
-			r.config.TopK,
-			r.config.MetricType,
-			r.config.SearchParams,
-			nil,
-		)
+		searchResults, searchErr = r.hybridSearch(ctx, query, vectors, searchParams)
+		// Dense scores are thresholded before fusion; fused scores are ranks.
+		minScore = math.Inf(-1)
 	} else {
 		searchResults, searchErr = r.vectorDB.Search(
 			ctx,
@@ -149,7 +171,7 @@ func (r *Retriever) Retrieve(ctx context.Context, query string) ([]RetrieverResu
 			vectors,
 			r.config.TopK,
 			r.config.MetricType,
-			r.config.SearchParams,
+			searchParams,
 		)
 	}
 
@@ -159,7 +181,7 @@ func (r *Retriever) Retrieve(ctx context.Context, query string) ([]RetrieverResu
 
 	results := make([]RetrieverResult, 0, len(searchResults))
 	for _, result := range searchResults {
-		if result.Score < r.config.MinScore {
+		if result.Score < minScore {
 			continue
 		}
 
@@ -167,8 +189,10 @@ func (r *Retriever) Retrieve(ctx context.Context, query string) ([]RetrieverResu
 		metadata, _ := result.Fields["Metadata"].(map[string]interface{})
 
 		match := RetrieverResult{
+			DocID:    result.DocID,
 			Content:  content,
 			Score:    result.Score,
+			Distance: result.Distance,
 			Metadata: metadata,
 		}
 
@@ -187,6 +211,195 @@ func (r *Retriever) Retrieve(ctx context.Context, query string) ([]RetrieverResu
 	return results, nil
 }
 
+// hybridCandidateFactor is how many candidates per requested result each
+// ranking contributes to fusion.
+const hybridCandidateFactor = 4
+
+// hybridSearch runs the dense vector search and a BM25 keyword search over
+// the same chunks and fuses both rankings with Reciprocal Rank Fusion,
+// weighted by DenseWeight and SparseWeight. MinScore applies to the dense
+// results before fusion; fused results carry the RRF score. When the keyword
+// index is empty, and still empty after being rebuilt from the collection if
+// RebuildIndex is set, a warning is logged and the dense results are
+// returned unchanged.
+func (r *Retriever) hybridSearch(ctx context.Context, query string, vectors map[string]Vector, searchParams map[string]interface{}) ([]SearchResult, error) {
+	candidates := r.config.TopK * hybridCandidateFactor
+
+	dense, err := r.vectorDB.Search(ctx, r.config.Collection, vectors, candidates, r.config.MetricType, searchParams)
+	if err != nil {
+		return nil, err
+	}
+	kept := dense[:0]
+	for _, result := range dense {
+		if result.Score >= r.config.MinScore {
+			kept = append(kept, result)
+		}
+	}
+	dense = kept
+
+	index := r.sparseIndex(ctx)
+	if index.Len() == 0 {
+		r.warnSparse.Do(func() {
+			Warn("Hybrid search requested but the keyword index is empty, returning vector results only; register the documents in this process or enable WithSparseIndexRebuild", "collection", r.config.Collection)
+		})
+		if len(dense) > r.config.TopK {
+			dense = dense[:r.config.TopK]
+		}
+		return dense, nil
+	}
+
+	// Fetch every keyword match when a filter may discard some of them.
+	sparseLimit := candidates
+	if r.config.Filter != nil {
+		sparseLimit = index.Len()
+	}
+	sparse, err := index.Search(ctx, query, sparseLimit)
+	if err != nil {
+		return nil, fmt.Errorf("keyword search failed: %w", err)
+	}
+	if r.config.Filter != nil {
+		matched := sparse[:0]
+		for _, result := range sparse {
+			if r.config.Filter.Matches(Record{Fields: result.Fields}) {
+				matched = append(matched, result)
+			}
+		}
+		sparse = matched
+		if len(sparse) > candidates {
+			sparse = sparse[:candidates]
+		}
+	}
+
+	// Key dense results like the sparse index so shared chunks are fused,
+	// remembering the database IDs to restore them afterwards.
+	denseIDs := make(map[int64]int64, len(dense))
+	for i := range dense {
+		key := chunkKey(dense[i].ID, dense[i].Fields)
+		denseIDs[key] = dense[i].ID
+		dense[i].ID = key
+	}
+
+	fused, err := rag.NewRRFReranker(r.config.RRFConstant).Rerank(ctx, query, dense, sparse, r.config.DenseWeight, r.config.SparseWeight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fuse results: %w", err)
+	}
+	fused, err = r.resolveKeywordMatches(ctx, fused, denseIDs, vectors, searchParams)
+	if err != nil {
+		return nil, err
+	}
+	if len(fused) > r.config.TopK {
+		fused = fused[:r.config.TopK]
+	}
+	return fused, nil
+}
+
+// resolveKeywordMatches gives fused results their database IDs back. Results
+// of the dense search get the ID they were found with. Results found only by
+// keyword search are looked up by document ID, with a vector search
+// restricted to those documents, and take the ID and fields stored in the
+// collection; those without a document ID, or whose document is no longer
+// stored, are dropped.
+func (r *Retriever) resolveKeywordMatches(ctx context.Context, fused []SearchResult, denseIDs map[int64]int64, vectors map[string]Vector, searchParams map[string]interface{}) ([]SearchResult, error) {
+	var docIDs []interface{}
+	for _, result := range fused {
+		if _, ok := denseIDs[result.ID]; !ok && result.DocID != "" {
+			docIDs = append(docIDs, result.DocID)
+		}
+	}
+
+	stored := make(map[string]SearchResult, len(docIDs))
+	if len(docIDs) > 0 {
+		filter := rag.In(DocIDKey, docIDs...)
+		if r.config.Filter != nil {
+			filter = rag.And(r.config.Filter, filter)
+		}
+		params := make(map[string]interface{}, len(searchParams)+1)
+		for k, v := range searchParams {
+			params[k] = v
+		}
+		params[rag.FilterParam] = filter
+
+		found, err := r.vectorDB.Search(ctx, r.config.Collection, vectors, len(docIDs), r.config.MetricType, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up keyword matches: %w", err)
+		}
+		for _, result := range found {
+			stored[result.DocID] = result
+		}
+	}
+
+	resolved := fused[:0]
+	for _, result := range fused {
+		if id, ok := denseIDs[result.ID]; ok {
+			result.ID = id
+		} else if match, ok := stored[result.DocID]; ok && result.DocID != "" {
+			result.ID = match.ID
+			result.Fields = match.Fields
+		} else {
+			Debug("Dropping keyword match missing from the collection", "collection", r.config.Collection, "doc_id", result.DocID)
+			continue
+		}
+		resolved = append(resolved, result)
+	}
+	return resolved, nil
+}
+
+// sparseIndex returns the keyword index searched by hybrid retrieval: the
+// configured one or the collection's shared index. When RebuildIndex is set,
+// an empty index is rebuilt the first time from the chunks stored in the
+// collection, so that hybrid search works in a process that did not register
+// them. A failed rebuild is logged and attempted again on the next search.
+func (r *Retriever) sparseIndex(ctx context.Context) *BM25Index {
+	index := r.config.SparseIndex
+	if index == nil {
+		index = SparseIndex(r.config.Collection)
+	}
+
+	r.sparseMu.Lock()
+	defer r.sparseMu.Unlock()
+	if r.sparseLoaded {
+		return index
+	}
+	if index.Len() == 0 && r.config.RebuildIndex {
+		Debug("Rebuilding keyword index from collection", "collection", r.config.Collection)
+		if err := rebuildSparseIndex(ctx, r.vectorDB, r.config.Collection, index); err != nil {
+			Warn("Failed to rebuild the keyword index from the collection", "collection", r.config.Collection, "error", err)
+			return index
+		}
+	}
+	r.sparseLoaded = true
+	return index
+}
+
+// withColumn returns columns with name appended if it is missing. An empty
+// list, which selects every column, is returned as is, and the configured
+// slice is copied so it is never mutated.
+func withColumn(columns []string, name string) []string {
+	if len(columns) == 0 {
+		return columns
+	}
+	for _, column := range columns {
+		if column == name {
+			return columns
+		}
+	}
+	return append(append(make([]string, 0, len(columns)+1), columns...), name)
+}
+
+// searchParams returns the configured search parameters with the metadata
+// filter added. The configured map is copied so it is never mutated.
+func (r *Retriever) searchParams() map[string]interface{} {
+	if r.config.Filter == nil {
+		return r.config.SearchParams
+	}
+	params := make(map[string]interface{}, len(r.config.SearchParams)+1)
+	for k, v := range r.config.SearchParams {
+		params[k] = v
+	}
+	params[rag.FilterParam] = r.config.Filter
+	return params
+}
+
 // GetVectorDB returns the underlying vector database instance.
 // This provides access to lower-level database operations when needed.
 func (r *Retriever) GetVectorDB() *VectorDB {
@@ -222,7 +435,9 @@ func WithTopK(k int) RetrieverOption {
 }
 
 // WithMinScore sets the minimum similarity score threshold.
-// Results with scores below this threshold will be filtered out.
+// Results with scores below this threshold will be filtered out. Scores are
+// "higher is more similar" on every backend: 1/(1+distance) for L2, the inner
+// product for IP and the cosine similarity for COSINE.
 //
 // Example:
 //
@@ -271,7 +486,9 @@ func WithRetrieveEmbedding(provider, model, key string) RetrieverOption {
 }
 
 // WithHybrid enables or disables hybrid search.
-// Hybrid search combines vector similarity with keyword matching.
+// Hybrid search combines vector similarity with BM25 keyword matching over the
+// chunks added by Register, fusing both rankings with Reciprocal Rank Fusion.
+// This helps keyword-heavy queries such as error codes and product SKUs.
 //
 // Example:
 //
@@ -284,6 +501,57 @@ func WithHybrid(enabled bool) RetrieverOption {
 	}
 }
 
+// WithHybridWeights sets the relative weights of the vector and keyword
+// rankings in hybrid search. Weights are normalised to sum to 1.
+//
+// Example:
+//
+//	retriever, err := NewRetriever(
+//	    WithHybrid(true),
+//	    WithHybridWeights(0.7, 0.3), // Favour semantic matches
+//	)
+func WithHybridWeights(dense, sparse float64) RetrieverOption {
+	return func(c *RetrieverConfig) {
+		c.DenseWeight = dense
+		c.SparseWeight = sparse
+	}
+}
+
+// WithRetrieveSparseIndex sets the BM25 index searched in hybrid mode.
+// By default the shared index returned by SparseIndex for the collection is
+// used, which Register keeps in sync.
+//
+// Example:
+//
+//	retriever, err := NewRetriever(
+//	    WithHybrid(true),
+//	    WithRetrieveSparseIndex(idx),
+//	)
+func WithRetrieveSparseIndex(idx *BM25Index) RetrieverOption {
+	return func(c *RetrieverConfig) {
+		c.SparseIndex = idx
+	}
+}
+
+// WithSparseIndexRebuild enables rebuilding an empty keyword index from the
+// collection the first time a hybrid Retriever searches, for processes that
+// query a collection without having registered its documents. The rebuild
+// reads every chunk of the collection into memory, so it is disabled by
+// default; prefer saving the index with BM25Index.Save where it is built
+// and loading it with BM25Index.Load for large collections.
+//
+// Example:
+//
+//	retriever, err := NewRetriever(
+//	    WithHybrid(true),
+//	    WithSparseIndexRebuild(true),
+//	)
+func WithSparseIndexRebuild(enabled bool) RetrieverOption {
+	return func(c *RetrieverConfig) {
+		c.RebuildIndex = enabled
+	}
+}
+
 // WithColumns specifies which columns to retrieve from the database.
 // This can optimize performance by only fetching needed fields.
 //
@@ -298,6 +566,22 @@ func WithColumns(columns ...string) RetrieverOption {
 	}
 }
 
+// WithRetrieveFilter restricts every search to records whose metadata
+// matches the filter. This allows serving several tenants from a single
+// collection.
+//
+// Example:
+//
+//	filter, _ := ParseFilter(`customer == "acme" AND lang IN ["en", "fr"]`)
+//	retriever, err := NewRetriever(
+//	    WithRetrieveFilter(filter),
+//	)
+func WithRetrieveFilter(filter *Filter) RetrieverOption {
+	return func(c *RetrieverConfig) {
+		c.Filter = filter
+	}
+}
+
 // WithRetrieveDimension sets the embedding vector dimension.
 // This must match the dimension of your chosen embedding model.
 //
@@ -341,24 +625,27 @@ func WithRetrieveCallbacks(onResult func(SearchResult), onError func(error)) Ret
 // Default settings include:
 //   - Top 10 results
 //   - Minimum score of 0.7
-//   - L2 distance metric
+//   - Hybrid search with equal dense and keyword weights
+//   - L2 distance metric (COSINE for chromem)
 //   - 30-second timeout
 //   - Standard column set (Text, Metadata)
 func defaultRetrieverConfig() *RetrieverConfig {
 	return &RetrieverConfig{
-		Collection: "documents",
-		TopK:       5,
-		MinScore:   0.7,
-		UseHybrid:  true,
-		Columns:    []string{"Text", "Metadata"},
-		DBType:     "milvus",
-		DBAddress:  "localhost:19530",
-		Dimension:  128,
-		Provider:   "openai",
-		Model:      "text-embedding-3-small",
-		APIKey:     os.Getenv("OPENAI_API_KEY"),
-		MetricType: "L2",
-		Timeout:    30 * time.Second,
+		Collection:   "documents",
+		TopK:         5,
+		MinScore:     0.7,
+		UseHybrid:    true,
+		Columns:      []string{"Text", "Metadata"},
+		DBType:       "milvus",
+		DBAddress:    "localhost:19530",
+		Dimension:    128,
+		Provider:     "openai",
+		Model:        "text-embedding-3-small",
+		APIKey:       os.Getenv("OPENAI_API_KEY"),
+		Timeout:      30 * time.Second,
+		DenseWeight:  0.5,
+		SparseWeight: 0.5,
+		RRFConstant:  60,
 		SearchParams: map[string]interface{}{
 			"type": "HNSW",
 			"ef":   64,
@@ -0,0 +1,126 @@
+package raggo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/teilomillet/raggo/rag/providers"
+)
+
+func TestHybridRetrieve(t *testing.T) {
+	providers.RegisterEmbedder("test-length", func(map[string]interface{}) (providers.Embedder, error) {
+		return flakyEmbedder{failing: new(atomic.Bool)}, nil
+	})
+	ctx := context.Background()
+
+	// Short documents are the nearest neighbours of the query "zebra" by
+	// embedding; the long one is only found by keyword.
+	dir := t.TempDir()
+	docs := filepath.Join(dir, "docs")
+	dataDir := filepath.Join(dir, "db")
+	if err := os.Mkdir(docs, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	texts := map[string]string{
+		"a.txt": "Seven.",
+		"b.txt": "Three.",
+		"c.txt": "Forty.",
+		"d.txt": "Eleven.",
+		"e.txt": "Twelve.",
+		"f.txt": "The zebra crossed the long road at noon.",
+	}
+	for name, text := range texts {
+		if err := os.WriteFile(filepath.Join(docs, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	registered := NewBM25Index()
+	err := Register(ctx, docs,
+		WithVectorDB("memory", map[string]string{"address": dataDir}),
+		WithCollection("docs", true),
+		WithEmbedding("test-length", "", ""),
+		WithSparseIndex(registered),
+		func(cfg *RegisterConfig) { cfg.TempDir = t.TempDir() },
+	)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// The database ID and stored text of each document
+	db := newTestVectorDB(t, dataDir)
+	ids := make(map[string]int64)
+	stored := make(map[string]string)
+	err = db.Scan(ctx, "docs", 0, func(records []Record) error {
+		for _, record := range records {
+			metadata, _ := record.Fields["Metadata"].(map[string]interface{})
+			source, _ := metadata["source"].(string)
+			ids[filepath.Base(source)] = recordID(record)
+			stored[filepath.Base(source)], _ = record.Fields["Text"].(string)
+		}
+		return nil
+	})
+	db.Close()
+	if err != nil || len(ids) != len(texts) {
+		t.Fatalf("Scan found %v, %v, want the %d documents", ids, err, len(texts))
+	}
+
+	// An index entry of a document deleted from the collection
+	stale := NewBM25Index()
+	if err := stale.Add(ctx, 1, "The zebra", map[string]interface{}{DocIDKey: "gone", "source": "gone.txt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		index       *BM25Index
+		rebuild     bool
+		wantKeyword bool // Whether the keyword match is the result
+	}{
+		{name: "registered index", index: registered, wantKeyword: true},
+		{name: "empty index", index: NewBM25Index()},
+		{name: "stale index", index: stale},
+		{name: "rebuilt index", index: NewBM25Index(), rebuild: true, wantKeyword: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var found []SearchResult
+			retriever, err := NewRetriever(
+				WithRetrieveDB("memory", dataDir),
+				WithRetrieveCollection("docs"),
+				WithRetrieveEmbedding("test-length", "", ""),
+				WithTopK(1), // Four dense candidates, which leave out the keyword match
+				WithMinScore(0),
+				WithHybrid(true),
+				WithHybridWeights(0.2, 0.8),
+				WithRetrieveSparseIndex(tt.index),
+				WithSparseIndexRebuild(tt.rebuild),
+				WithRetrieveCallbacks(func(result SearchResult) { found = append(found, result) }, nil),
+			)
+			if err != nil {
+				t.Fatalf("NewRetriever: %v", err)
+			}
+			defer retriever.Close()
+
+			results, err := retriever.Retrieve(ctx, "zebra")
+			if err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if len(results) != 1 || len(found) != 1 {
+				t.Fatalf("Retrieve returned %d results, want 1", len(results))
+			}
+			name := filepath.Base(results[0].Source)
+			if found[0].ID != ids[name] {
+				t.Errorf("result %s has ID %d, want its database ID %d", name, found[0].ID, ids[name])
+			}
+			if results[0].Content != stored[name] {
+				t.Errorf("result %s has content %q, want %q", name, results[0].Content, stored[name])
+			}
+			if (name == "f.txt") != tt.wantKeyword {
+				t.Errorf("result is %s, want the keyword match f.txt: %v", name, tt.wantKeyword)
+			}
+		})
+	}
+}
@@ -264,7 +264,7 @@ func (s *SimpleRAG) AddDocuments(ctx context.Context, source string) error {
 	// Create and load index only once after all documents are processed
 	err = s.vectorDB.CreateIndex(ctx, s.collection, "Embedding", Index{
 		Type:   "HNSW",
-		Metric: "L2",
+		Metric: defaultMetric(s.vectorDB.Type()),
 		Parameters: map[string]interface{}{
 			"M":              16,
 			"efConstruction": 256,
@@ -317,15 +317,14 @@ func (s *SimpleRAG) Search(ctx context.Context, query string) (string, error) {
 
 	// Set the retriever's TopK based on the config or dynamically
 	if s.retriever.config.TopK <= 0 {
-		// Do a test search with topK=1 to get number of documents
-		testResults, err := s.vectorDB.Search(ctx, s.collection, map[string]Vector{"test": make(Vector, s.vectorDB.Dimension())}, 1, "L2", nil)
+		count, err := s.vectorDB.Count(ctx, s.collection)
 		if err != nil {
 			return "", fmt.Errorf("failed to get collection size: %w", err)
 		}
 		// Set TopK to min(20, numDocs) if not specified
 		s.retriever.config.TopK = 20
-		if len(testResults) < 20 {
-			s.retriever.config.TopK = len(testResults)
+		if count < 20 {
+			s.retriever.config.TopK = int(count)
 		}
 	}
 
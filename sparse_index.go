@@ -0,0 +1,186 @@
+// Package raggo provides keyword (sparse) indexing for hybrid retrieval.
+// This file exposes the BM25 index used alongside vector search and keeps a
+// shared index per collection so that Register and Retriever see the same chunks.
+package raggo
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/teilomillet/raggo/rag"
+)
+
+// BM25Index is the sparse keyword index used by hybrid retrieval.
+// See rag.BM25Index for details.
+type BM25Index = rag.BM25Index
+
+// NewBM25Index creates an empty BM25 index with default parameters.
+func NewBM25Index() *BM25Index {
+	return rag.NewBM25Index()
+}
+
+// Analyzer is a composable text analysis chain for BM25 indexes.
+// See rag.Analyzer for details.
+type Analyzer = rag.Analyzer
+
+// NewEnglishAnalyzer returns an analyzer with English stop words and the
+// Porter stemmer.
+func NewEnglishAnalyzer() *Analyzer {
+	return rag.NewEnglishAnalyzer()
+}
+
+// NewFrenchAnalyzer returns an analyzer with French elisions, stop words and
+// the Snowball French stemmer.
+func NewFrenchAnalyzer() *Analyzer {
+	return rag.NewFrenchAnalyzer()
+}
+
+// sparseIndexes holds the process-wide BM25 index of each collection.
+var sparseIndexes = struct {
+	mu      sync.Mutex
+	indexes map[string]*BM25Index
+}{
+	indexes: make(map[string]*BM25Index),
+}
+
+// SparseIndex returns the shared BM25 index of a collection, creating it on
+// first use. Register adds every ingested chunk to it, the VectorDB delete
+// methods remove deleted chunks from it, and hybrid Retrievers search it,
+// unless they are configured with their own index through WithSparseIndex
+// or WithRetrieveSparseIndex. The index lives in memory only: save it with
+// BM25Index.Save to reuse it in another process, or let a Retriever
+// configured with WithSparseIndexRebuild rebuild an empty index from the
+// chunks stored in the collection the first time it searches.
+//
+// Example:
+//
+//	idx := raggo.SparseIndex("documents")
+//	fmt.Printf("%d chunks indexed for keyword search\n", idx.Len())
+func SparseIndex(collection string) *BM25Index {
+	sparseIndexes.mu.Lock()
+	defer sparseIndexes.mu.Unlock()
+
+	idx, ok := sparseIndexes.indexes[collection]
+	if !ok {
+		idx = NewBM25Index()
+		sparseIndexes.indexes[collection] = idx
+	}
+	return idx
+}
+
+// sharedSparseIndex returns the shared BM25 index of a collection, or nil
+// when none has been created yet.
+func sharedSparseIndex(collection string) *BM25Index {
+	sparseIndexes.mu.Lock()
+	defer sparseIndexes.mu.Unlock()
+	return sparseIndexes.indexes[collection]
+}
+
+// dropSparseIndex forgets the shared BM25 index of a dropped collection.
+func dropSparseIndex(collection string) {
+	sparseIndexes.mu.Lock()
+	defer sparseIndexes.mu.Unlock()
+	delete(sparseIndexes.indexes, collection)
+}
+
+// rebuildSparseIndex adds every chunk stored in a collection to idx, so that
+// a process which only queries an existing collection gets keyword results
+// without having registered the documents itself.
+func rebuildSparseIndex(ctx context.Context, vdb *VectorDB, collection string, idx *BM25Index) error {
+	return vdb.Scan(ctx, collection, 0, func(records []Record) error {
+		for _, record := range records {
+			text, _ := record.Fields["Text"].(string)
+			if text == "" {
+				continue
+			}
+			metadata, _ := record.Fields["Metadata"].(map[string]interface{})
+			if err := idx.Add(ctx, chunkKey(recordID(record), record.Fields), text, metadata); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// removeFromSparseIndex removes the chunks matching filter from the shared
+// BM25 index of a collection, if there is one, after they have been deleted
+// from the collection itself.
+func removeFromSparseIndex(ctx context.Context, collection string, filter *Filter) error {
+	idx := sharedSparseIndex(collection)
+	if idx == nil {
+		return nil
+	}
+	if _, err := idx.RemoveByFilter(ctx, filter); err != nil {
+		return fmt.Errorf("failed to remove deleted chunks from the keyword index: %w", err)
+	}
+	return nil
+}
+
+// chunkKey returns the sparse index ID of a chunk. It is derived from the
+// chunk's source and position so that the dense and sparse results of the
+// same chunk can be fused, whatever IDs the vector database assigns. Chunks
+// without source metadata are keyed by their text, and chunks without text
+// either by their database ID, so that distinct chunks never share a key.
+func chunkKey(id int64, fields map[string]interface{}) int64 {
+	h := fnv.New64a()
+	var source string
+	var chunk int
+	var hasSource, hasChunk bool
+	switch metadata := fields["Metadata"].(type) {
+	case map[string]interface{}:
+		source, hasSource = metadata["source"].(string)
+		chunk, hasChunk = metadataInt(metadata["chunk"])
+	case map[string]string:
+		// chromem stores metadata values as strings
+		source, hasSource = metadata["source"]
+		chunk, hasChunk = metadataInt(metadata["chunk"])
+	}
+	text, hasText := fields["Text"].(string)
+	switch {
+	case hasSource && hasChunk:
+		h.Write([]byte(source))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.Itoa(chunk)))
+	case hasText:
+		h.Write([]byte(text))
+	default:
+		return id
+	}
+	return int64(h.Sum64() & math.MaxInt64)
+}
+
+// recordID returns the database ID of a record read back with Get or Scan.
+func recordID(record Record) int64 {
+	switch id := record.Fields["ID"].(type) {
+	case int64:
+		return id
+	case int:
+		return int64(id)
+	case float64:
+		return int64(id)
+	default:
+		return 0
+	}
+}
+
+// metadataInt reads an integer metadata value, which may have been decoded
+// as a float64 or a string by backends that store metadata as JSON or text.
+func metadataInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,117 @@
+package raggo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// chunkRecords returns records for the given chunk positions of a source,
+// each chunk's text being the given version followed by its position.
+func chunkRecords(source, version string, chunks ...int) []Record {
+	records := make([]Record, len(chunks))
+	for i, chunk := range chunks {
+		records[i] = Record{Fields: map[string]interface{}{
+			"Embedding": Vector{float64(chunk + 1), 1},
+			"Text":      version + " " + string(rune('0'+chunk)),
+			"Metadata": map[string]interface{}{
+				DocIDKey: ChunkDocID(source, chunk),
+				"source": source,
+				"chunk":  chunk,
+			},
+		}}
+	}
+	return records
+}
+
+// storedTexts returns the texts of every record stored in a collection.
+func storedTexts(t *testing.T, db *VectorDB, collection string) []string {
+	t.Helper()
+	var texts []string
+	err := db.Scan(context.Background(), collection, 100, func(records []Record) error {
+		for _, record := range records {
+			text, _ := record.Fields["Text"].(string)
+			texts = append(texts, text)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	return texts
+}
+
+func newTestVectorDB(t *testing.T, address string) *VectorDB {
+	t.Helper()
+	db, err := NewVectorDB(WithType("memory"), WithAddress(address))
+	if err != nil {
+		t.Fatalf("NewVectorDB: %v", err)
+	}
+	if err := db.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestReplaceSource(t *testing.T) {
+	ctx := context.Background()
+	db := newTestVectorDB(t, "")
+	schema := Schema{Fields: []Field{
+		{Name: "ID", DataType: "int64", PrimaryKey: true, AutoID: true},
+		{Name: "Embedding", DataType: "float_vector", Dimension: 2},
+		{Name: "Text", DataType: "varchar", MaxLength: 100},
+		{Name: "Metadata", DataType: "json"},
+	}}
+	if err := db.CreateCollection(ctx, "docs", schema); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	if err := db.Insert(ctx, "docs", chunkRecords("other.txt", "other", 0)); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	steps := []struct {
+		name    string
+		records []Record
+		keep    []int
+		wantErr bool
+		want    []string // Stored texts, sorted by insertion
+	}{
+		{
+			name:    "first ingestion",
+			records: chunkRecords("a.txt", "v1", 0, 1, 2),
+			want:    []string{"other 0", "v1 0", "v1 1", "v1 2"},
+		},
+		{
+			name:    "failed chunk keeps its earlier version",
+			records: chunkRecords("a.txt", "v2", 0, 2),
+			keep:    []int{1},
+			want:    []string{"other 0", "v2 0", "v1 1", "v2 2"},
+		},
+		{
+			name:    "nothing embedded removes nothing",
+			records: nil,
+			want:    []string{"other 0", "v2 0", "v1 1", "v2 2"},
+		},
+		{
+			name:    "failed upsert removes nothing",
+			records: []Record{{Fields: map[string]interface{}{"Embedding": Vector{1, 2, 3}, "Text": "bad"}}},
+			wantErr: true,
+			want:    []string{"other 0", "v2 0", "v1 1", "v2 2"},
+		},
+		{
+			name:    "shorter document prunes trailing chunks",
+			records: chunkRecords("a.txt", "v3", 0),
+			want:    []string{"other 0", "v3 0"},
+		},
+	}
+	for _, step := range steps {
+		err := db.ReplaceSource(ctx, "docs", "a.txt", step.records, step.keep...)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: ReplaceSource error = %v, want error %v", step.name, err, step.wantErr)
+		}
+		if got := storedTexts(t, db, "docs"); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: stored %q, want %q", step.name, got, step.want)
+		}
+	}
+}